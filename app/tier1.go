@@ -52,6 +52,9 @@ type Tier1Config struct {
 	PipelineOptions []pipeline.PipelineOptioner
 
 	Tracing bool
+
+	WASMProfiling        bool   // honors the requests of the clients to profile modules, see service.WithWASMProfiling
+	WASMProfilesStoreURL string // if not empty, the profiles are written to this store, apart from the state store
 }
 
 type Tier1App struct {
@@ -158,6 +161,17 @@ func (a *Tier1App) Run() error {
 		opts = append(opts, service.WithModuleExecutionTracing())
 	}
 
+	if a.config.WASMProfiling {
+		var profilesStore dstore.Store
+		if a.config.WASMProfilesStoreURL != "" {
+			profilesStore, err = dstore.NewStore(a.config.WASMProfilesStoreURL, "", "", true)
+			if err != nil {
+				return fmt.Errorf("failed setting up wasm profiles store from url %q: %w", a.config.WASMProfilesStoreURL, err)
+			}
+		}
+		opts = append(opts, service.WithWASMProfiling(profilesStore))
+	}
+
 	svc := service.NewTier1(
 		a.logger,
 		mergedBlocksStore,
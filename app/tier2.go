@@ -31,6 +31,9 @@ type Tier2Config struct {
 	PipelineOptions []pipeline.PipelineOptioner
 
 	Tracing bool
+
+	WASMProfiling        bool   // honors the requests of the clients to profile modules, see service.WithWASMProfiling
+	WASMProfilesStoreURL string // if not empty, the profiles are written to this store, apart from the state store
 }
 
 type Tier2App struct {
@@ -81,6 +84,17 @@ func (a *Tier2App) Run() error {
 		opts = append(opts, service.WithModuleExecutionTracing())
 	}
 
+	if a.config.WASMProfiling {
+		var profilesStore dstore.Store
+		if a.config.WASMProfilesStoreURL != "" {
+			profilesStore, err = dstore.NewStore(a.config.WASMProfilesStoreURL, "", "", true)
+			if err != nil {
+				return fmt.Errorf("failed setting up wasm profiles store from url %q: %w", a.config.WASMProfilesStoreURL, err)
+			}
+		}
+		opts = append(opts, service.WithWASMProfiling(profilesStore))
+	}
+
 	svc := service.NewTier2(
 		a.logger,
 		mergedBlocksStore,
@@ -0,0 +1,30 @@
+package client
+
+import (
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// ProfileModulesHeader lists, comma-separated, the modules of which the
+// server profiles the WASM execution.
+const ProfileModulesHeader = "X-Sf-Substreams-Profile-Modules"
+
+// ProfileTrailer is the key of the trailer carrying the gzipped pprof profile
+// of `module` at the end of a Blocks stream. Binary trailers are base64 encoded
+// on the wire, and decoded by gRPC clients.
+func ProfileTrailer(module string) string {
+	return "X-Substreams-Profile-" + module + "-Bin"
+}
+
+// ProfilesFromTrailer returns the profiles of `modules` found in `trailer`,
+// by module name.
+func ProfilesFromTrailer(trailer metadata.MD, modules []string) map[string][]byte {
+	out := make(map[string][]byte)
+	for _, module := range modules {
+		if values := trailer.Get(strings.ToLower(ProfileTrailer(module))); len(values) != 0 {
+			out[module] = []byte(values[0])
+		}
+	}
+	return out
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/cli"
+	"github.com/streamingfast/dstore"
+
+	"github.com/streamingfast/substreams/block"
+	"github.com/streamingfast/substreams/manifest"
+	"github.com/streamingfast/substreams/orchestrator/plan"
+	"github.com/streamingfast/substreams/orchestrator/stage"
+	"github.com/streamingfast/substreams/pipeline"
+	"github.com/streamingfast/substreams/pipeline/outputmodules"
+	"github.com/streamingfast/substreams/reqctx"
+	"github.com/streamingfast/substreams/storage/execout"
+	"github.com/streamingfast/substreams/storage/store"
+)
+
+func init() {
+	planCmd.Flags().StringP("start-block", "s", "", "Start block of the request. If empty, will be replaced by initialBlock of the module")
+	planCmd.Flags().StringP("stop-block", "t", "0", "Stop block of the request, exclusively. A '+' prefix can indicate 'relative to start-block'")
+	planCmd.Flags().String("state-store", "", "Base URL of the cache of the Substreams servers (their state store)")
+	planCmd.Flags().String("cache-tag", "default", "Cache tag of the request, under the --state-store")
+	planCmd.Flags().Uint64("state-bundle-size", 1000, "Interval in blocks of the segments of the jobs, must match the state bundle size of the servers")
+	planCmd.Flags().Uint64("final-block", 0, "Recent final block of the chain, capping the linear handoff block. If 0, the whole range is assumed final")
+	planCmd.Flags().Bool("production-mode", false, "Plan a request in Production Mode")
+	planCmd.Flags().StringArrayP("params", "p", nil, "Set a params for parameterizable modules. Can be specified multiple times. Ex: -p module1=valA -p module2=valX&valY")
+	planCmd.Flags().StringP("output", "o", "text", "Output format, 'text' or 'json'")
+	rootCmd.AddCommand(planCmd)
+}
+
+var planCmd = &cobra.Command{
+	Use:   "plan <manifest> <module_name>",
+	Short: "Show the work a request would schedule, given what is already in the cache",
+	Long: cli.Dedent(`
+		Lays out the request plan of a request of <module_name> over the -s/-t range, like the
+		Substreams servers do, without running it: the stages of its parallel processing and their
+		segments, the ranges of each stage whose stores or outputs are already found in the cache of
+		--state-store, the jobs that would be scheduled for the others, the linear handoff block
+		and an estimate of the blocks to execute.
+
+		The linear handoff block is capped to --final-block, the recent final block of the chain,
+		when given. The partial stores of interrupted requests are not reused by a new request, so
+		they are not counted as cached.
+	`),
+	Example: string(cli.ExamplePrefixed("substreams plan", `
+		uniswap-v3.spkg map_pools_created -s 12369621 -t +1000000 --state-store gs://[bucket-url-path] --production-mode
+		uniswap-v3.spkg graph_out -s 12369621 -t 17000000 --state-store gs://[bucket-url-path] --final-block 18000000 -o json
+	`)),
+	RunE:         runPlan,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+}
+
+type planReport struct {
+	OutputModule       string `json:"output_module"`
+	OutputModuleHash   string `json:"output_module_hash"`
+	ProductionMode     bool   `json:"production_mode"`
+	StartBlock         uint64 `json:"start_block"`
+	StopBlock          uint64 `json:"stop_block"`
+	LinearHandoffBlock uint64 `json:"linear_handoff_block"`
+	SegmentInterval    uint64 `json:"segment_interval"`
+
+	BuildStores    *block.Range `json:"build_stores"`
+	WriteOutputs   *block.Range `json:"write_outputs"`
+	ReadOutputs    *block.Range `json:"read_outputs"`
+	LinearPipeline *block.Range `json:"linear_pipeline"`
+
+	Stages []*planStageReport `json:"stages"`
+
+	// Jobs and ParallelBlocks are the totals of the stages, LinearBlocks
+	// is 0 when the linear pipeline has no stop block.
+	Jobs            int    `json:"jobs"`
+	ParallelBlocks  uint64 `json:"parallel_blocks"`
+	LinearBlocks    uint64 `json:"linear_blocks"`
+	LinearUnbounded bool   `json:"linear_unbounded"`
+}
+
+type planStageReport struct {
+	Index   int      `json:"index"`
+	Kind    string   `json:"kind"`
+	Modules []string `json:"modules"`
+
+	// Cached are the ranges whose stores or outputs are in the cache, Jobs
+	// the ones that would be scheduled.
+	Cached    block.Ranges `json:"cached"`
+	Jobs      block.Ranges `json:"jobs"`
+	JobBlocks uint64       `json:"job_blocks"`
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	ctx := reqctx.WithLogger(cmd.Context(), zlog)
+	manifestPath, outputModule := args[0], args[1]
+
+	outputFormat := mustGetString(cmd, "output")
+	if outputFormat != "text" && outputFormat != "json" {
+		return fmt.Errorf("invalid output format %q, expected 'text' or 'json'", outputFormat)
+	}
+	if mustGetString(cmd, "state-store") == "" {
+		return fmt.Errorf("the --state-store flag is required")
+	}
+	productionMode := mustGetBool(cmd, "production-mode")
+	segmentInterval := mustGetUint64(cmd, "state-bundle-size")
+
+	manifestReader, err := manifest.NewReader(manifestPath)
+	if err != nil {
+		return fmt.Errorf("manifest reader: %w", err)
+	}
+	pkg, err := manifestReader.Read()
+	if err != nil {
+		return fmt.Errorf("read manifest %q: %w", manifestPath, err)
+	}
+	if err := manifest.ApplyParams(mustGetStringArray(cmd, "params"), pkg); err != nil {
+		return err
+	}
+
+	graph, err := manifest.NewModuleGraph(pkg.Modules.Modules)
+	if err != nil {
+		return fmt.Errorf("creating module graph: %w", err)
+	}
+	signedStartBlock, readFromModule, err := readStartBlockFlag(cmd, "start-block")
+	if err != nil {
+		return fmt.Errorf("start block: %w", err)
+	}
+	if readFromModule {
+		sb, err := graph.ModuleInitialBlock(outputModule)
+		if err != nil {
+			return fmt.Errorf("getting module start block: %w", err)
+		}
+		signedStartBlock = int64(sb)
+	}
+	if signedStartBlock < 0 {
+		return fmt.Errorf("start block: a start block relative to the chain head is not supported")
+	}
+	startBlock := uint64(signedStartBlock)
+	stopBlock, err := readStopBlockFlag(cmd, signedStartBlock, "stop-block", false)
+	if err != nil {
+		return fmt.Errorf("stop block: %w", err)
+	}
+
+	outputGraph, err := outputmodules.NewOutputModuleGraph(outputModule, productionMode, pkg.Modules)
+	if err != nil {
+		return fmt.Errorf("creating output module graph: %w", err)
+	}
+	if err := outputGraph.ValidateRequestStartBlock(startBlock); err != nil {
+		return err
+	}
+
+	finalBlock := mustGetUint64(cmd, "final-block")
+	linearHandoff, err := pipeline.ComputeLiveHandoffBlockNum(productionMode, startBlock, stopBlock, func() (uint64, error) {
+		if finalBlock == 0 {
+			return 0, fmt.Errorf("no --final-block given")
+		}
+		return finalBlock, nil
+	})
+	if err != nil {
+		return fmt.Errorf("computing linear handoff block: %w", err)
+	}
+
+	scheduleStores := outputGraph.StagedUsedModules()[0].LastLayer().IsStoreLayer()
+	reqPlan, err := plan.BuildTier1RequestPlan(productionMode, segmentInterval, outputGraph.LowestInitBlock(), startBlock, linearHandoff, stopBlock, scheduleStores)
+	if err != nil {
+		return fmt.Errorf("building request plan: %w", err)
+	}
+
+	report := &planReport{
+		OutputModule:       outputModule,
+		OutputModuleHash:   outputGraph.ModuleHashes().Get(outputModule),
+		ProductionMode:     productionMode,
+		StartBlock:         startBlock,
+		StopBlock:          stopBlock,
+		LinearHandoffBlock: linearHandoff,
+		SegmentInterval:    segmentInterval,
+		BuildStores:        reqPlan.BuildStores,
+		WriteOutputs:       reqPlan.WriteExecOut,
+		ReadOutputs:        reqPlan.ReadExecOut,
+		LinearPipeline:     reqPlan.LinearPipeline,
+	}
+	if linear := reqPlan.LinearPipeline; linear != nil {
+		if linear.ExclusiveEndBlock == 0 {
+			report.LinearUnbounded = true
+		} else {
+			report.LinearBlocks = linear.Len()
+		}
+	}
+
+	if reqPlan.RequiresParallelProcessing() {
+		baseStore, err := dstore.NewStore(mustGetString(cmd, "state-store"), "zst", "zstd", false)
+		if err != nil {
+			return fmt.Errorf("creating state store: %w", err)
+		}
+		cacheStore, err := baseStore.SubStore(mustGetString(cmd, "cache-tag"))
+		if err != nil {
+			return fmt.Errorf("creating cache store: %w", err)
+		}
+
+		execoutConfigs, err := execout.NewConfigs(cacheStore, outputGraph.UsedModules(), outputGraph.ModuleHashes(), segmentInterval, zlog)
+		if err != nil {
+			return fmt.Errorf("new config map: %w", err)
+		}
+		storeConfigs, err := store.NewConfigMap(cacheStore, outputGraph.Stores(), outputGraph.ModuleHashes(), segmentInterval, "")
+		if err != nil {
+			return fmt.Errorf("configuring stores: %w", err)
+		}
+
+		stages := stage.NewStages(ctx, outputGraph, reqPlan, storeConfigs, "")
+		if err := stages.FetchStoresState(ctx, reqPlan.BackprocessSegmenter(), storeConfigs, execoutConfigs, ""); err != nil {
+			return fmt.Errorf("fetch stores storage state: %w", err)
+		}
+
+		for _, layout := range stages.Layout() {
+			stageReport := newPlanStageReport(layout)
+			report.Stages = append(report.Stages, stageReport)
+			report.Jobs += len(stageReport.Jobs)
+			report.ParallelBlocks += stageReport.JobBlocks
+		}
+	}
+
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	printPlanReport(report)
+	return nil
+}
+
+// newPlanStageReport reports the units of `layout`: the completed ones are
+// cached, the others are jobs. The partial stores found are jobs too, as a
+// new request does not reuse the partial stores of interrupted requests.
+func newPlanStageReport(layout *stage.StageLayout) *planStageReport {
+	stageReport := &planStageReport{
+		Index:   layout.Index,
+		Kind:    layout.Kind.String(),
+		Modules: layout.Modules,
+		Cached:  block.Ranges{},
+		Jobs:    block.Ranges{},
+	}
+	for _, unit := range layout.Units {
+		switch unit.State {
+		case stage.UnitPending, stage.UnitPartialPresent:
+			stageReport.Jobs = append(stageReport.Jobs, unit.Range)
+			stageReport.JobBlocks += unit.Range.Len()
+		case stage.UnitCompleted:
+			stageReport.Cached = append(stageReport.Cached, unit.Range)
+		}
+	}
+	stageReport.Cached = append(block.Ranges{}, stageReport.Cached.Merged()...)
+	return stageReport
+}
+
+func printPlanReport(report *planReport) {
+	mode := "development mode"
+	if report.ProductionMode {
+		mode = "production mode"
+	}
+	stop := "unbounded"
+	if report.StopBlock != 0 {
+		stop = fmt.Sprintf("%d", report.StopBlock)
+	}
+	fmt.Printf("Module %q (%s), %s, from block %d to %s\n", report.OutputModule, report.OutputModuleHash, mode, report.StartBlock, stop)
+	fmt.Printf("Linear handoff block: %d\n", report.LinearHandoffBlock)
+	fmt.Printf("Stores built: %s, outputs written: %s, outputs read: %s, linear pipeline: %s\n", report.BuildStores, report.WriteOutputs, report.ReadOutputs, report.LinearPipeline)
+
+	for _, stage := range report.Stages {
+		fmt.Printf("Stage %d (%s: %s): %d jobs, %d blocks\n", stage.Index, stage.Kind, strings.Join(stage.Modules, ", "), len(stage.Jobs), stage.JobBlocks)
+		if len(stage.Cached) > 0 {
+			fmt.Printf("  cached: %s\n", stage.Cached)
+		}
+		if len(stage.Jobs) > 0 {
+			// The jobs are listed one by one in the JSON output.
+			fmt.Printf("  jobs: %s\n", stage.Jobs.Merged())
+		}
+	}
+
+	if report.LinearUnbounded {
+		fmt.Printf("Estimate: %d blocks in %d parallel jobs, then linear processing from block %d, unbounded\n", report.ParallelBlocks, report.Jobs, report.LinearHandoffBlock)
+		return
+	}
+	fmt.Printf("Estimate: %d blocks in %d parallel jobs, then %d blocks processed linearly\n", report.ParallelBlocks, report.Jobs, report.LinearBlocks)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamingfast/substreams/block"
+	"github.com/streamingfast/substreams/orchestrator/stage"
+)
+
+func Test_newPlanStageReport(t *testing.T) {
+	layout := &stage.StageLayout{
+		Index:   1,
+		Kind:    stage.KindStore,
+		Modules: []string{"store_a"},
+		Units: []stage.UnitLayout{
+			{Range: block.NewRange(0, 10), State: stage.UnitCompleted},
+			{Range: block.NewRange(10, 20), State: stage.UnitCompleted},
+			{Range: block.NewRange(20, 30), State: stage.UnitPartialPresent},
+			{Range: block.NewRange(30, 40), State: stage.UnitPending},
+			{Range: block.NewRange(40, 50), State: stage.UnitNoOp},
+		},
+	}
+
+	report := newPlanStageReport(layout)
+	assert.Equal(t, 1, report.Index)
+	assert.Equal(t, "store", report.Kind)
+	assert.Equal(t, []string{"store_a"}, report.Modules)
+	assert.Equal(t, block.Ranges{block.NewRange(0, 20)}, report.Cached)
+	// The partial stores are not reused by a new request.
+	assert.Equal(t, block.Ranges{block.NewRange(20, 30), block.NewRange(30, 40)}, report.Jobs)
+	assert.Equal(t, uint64(20), report.JobBlocks)
+}
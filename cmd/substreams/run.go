@@ -14,6 +14,8 @@ import (
 	"go.uber.org/zap"
 	"google.golang.org/grpc/metadata"
 	"io"
+	"os"
+	"strings"
 )
 
 func init() {
@@ -33,6 +35,7 @@ func init() {
 	runCmd.Flags().StringArrayP("params", "p", nil, "Set a params for parameterizable modules. Can be specified multiple times. Ex: -p module1=valA -p module2=valX&valY")
 	runCmd.Flags().String("test-file", "", "runs a test file")
 	runCmd.Flags().Bool("test-verbose", false, "print out all the results")
+	runCmd.Flags().StringSlice("profile-module", nil, "List of modules of which to profile the WASM execution, the pprof profiles are written to '<module>.pprof' at the end of the stream. Only covers blocks processed linearly by the server, see the server's cache for the other ones")
 	rootCmd.AddCommand(runCmd)
 }
 
@@ -189,6 +192,11 @@ func runRun(cmd *cobra.Command, args []string) error {
 		streamCtx = metadata.AppendToOutgoingContext(streamCtx, headerArray...)
 	}
 
+	profileModules := mustGetStringSlice(cmd, "profile-module")
+	if len(profileModules) != 0 {
+		streamCtx = metadata.AppendToOutgoingContext(streamCtx, client.ProfileModulesHeader, strings.Join(profileModules, ","))
+	}
+
 	ui.SetRequest(req)
 	ui.Connecting()
 	cli, err := ssClient.Blocks(streamCtx, req, callOpts...)
@@ -205,6 +213,9 @@ func runRun(cmd *cobra.Command, args []string) error {
 			}
 		}
 		if err != nil {
+			if len(profileModules) != 0 {
+				writeModuleProfiles(cli.Trailer(), profileModules)
+			}
 			if err == io.EOF {
 				ui.Cancel()
 				fmt.Println("all done")
@@ -225,3 +236,14 @@ func runRun(cmd *cobra.Command, args []string) error {
 		}
 	}
 }
+
+func writeModuleProfiles(trailer metadata.MD, modules []string) {
+	for module, profile := range client.ProfilesFromTrailer(trailer, modules) {
+		filename := module + ".pprof"
+		if err := os.WriteFile(filename, profile, 0644); err != nil {
+			fmt.Printf("Cannot write profile of module %q: %s\n", module, err)
+			continue
+		}
+		fmt.Printf("Wrote profile of module %q to %s, inspect it with 'go tool pprof %s'\n", module, filename, filename)
+	}
+}
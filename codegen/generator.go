@@ -89,6 +89,7 @@ var UpdatePoliciesMap = map[string]string{
 	manifest.UpdatePolicyMin:            "Min",
 	manifest.UpdatePolicyMax:            "Max",
 	manifest.UpdatePolicyAppend:         "Append",
+	manifest.UpdatePolicySetSum:         "SetSum",
 }
 
 type Generator struct {
@@ -348,6 +349,11 @@ func (e *Engine) ReadableStoreType(store *manifest.Module, input *manifest.Input
 	t := store.ValueType
 	p := store.UpdatePolicy
 
+	// The values of `set_sum` stores are read with their `set:` or `sum:` prefix
+	if p == manifest.UpdatePolicySetSum {
+		t = "string"
+	}
+
 	//TODO(colin): split out deltas code into a separate function
 	if input.Mode == "deltas" {
 		if strings.HasPrefix(t, "proto") {
@@ -413,6 +419,9 @@ func (e *Engine) WritableStoreDeclaration(store *manifest.Module) string {
 func (e *Engine) ReadableStoreDeclaration(name string, store *manifest.Module, input *manifest.Input) string {
 	t := store.ValueType
 	p := store.UpdatePolicy
+	if p == manifest.UpdatePolicySetSum {
+		t = "string"
+	}
 	isProto := strings.HasPrefix(t, "proto")
 	if isProto {
 		t = mustTransformProtoType(t, e.Manifest)
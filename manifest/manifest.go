@@ -82,6 +82,9 @@ type Module struct {
 
 	UpdatePolicy string `yaml:"updatePolicy"`
 	ValueType    string `yaml:"valueType"`
+	TTLBlocks    uint64 `yaml:"ttlBlocks"`
+	MaxSizeBytes uint64 `yaml:"maxSizeBytes"`
+	MaxKeys      uint64 `yaml:"maxKeys"`
 	Binary       string `yaml:"binary"`
 
 	Inputs []*Input     `yaml:"inputs"`
@@ -210,6 +213,11 @@ func validateStoreBuilder(module *Module) error {
 		"set_if_not_exists:float64",
 		"append:bytes",
 		"append:string",
+		"set_sum:bigint",
+		"set_sum:int64",
+		"set_sum:bigdecimal",
+		"set_sum:bigfloat",
+		"set_sum:float64",
 	}
 	found := false
 	var lastCombination string
@@ -346,6 +354,7 @@ const (
 	UpdatePolicyMax            = "max"
 	UpdatePolicyMin            = "min"
 	UpdatePolicyAppend         = "append"
+	UpdatePolicySetSum         = "set_sum"
 )
 
 func (m *Module) setKindToProto(pbModule *pbsubstreams.Module) {
@@ -371,6 +380,8 @@ func (m *Module) setKindToProto(pbModule *pbsubstreams.Module) {
 			updatePolicy = pbsubstreams.Module_KindStore_UPDATE_POLICY_MIN
 		case UpdatePolicyAppend:
 			updatePolicy = pbsubstreams.Module_KindStore_UPDATE_POLICY_APPEND
+		case UpdatePolicySetSum:
+			updatePolicy = pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM
 		default:
 			panic(fmt.Sprintf("invalid update policy %s", m.UpdatePolicy))
 		}
@@ -378,6 +389,9 @@ func (m *Module) setKindToProto(pbModule *pbsubstreams.Module) {
 			KindStore: &pbsubstreams.Module_KindStore{
 				UpdatePolicy: updatePolicy,
 				ValueType:    m.ValueType,
+				TtlBlocks:    m.TTLBlocks,
+				MaxSizeBytes: m.MaxSizeBytes,
+				MaxKeys:      m.MaxKeys,
 			},
 		}
 	}
@@ -607,6 +607,12 @@ func LoadManifestFile(inputPath string) (*Manifest, error) {
 			if s.Output.Type == "" {
 				return nil, fmt.Errorf("stream %q: missing 'output.type' for kind 'map'", s.Name)
 			}
+			if s.TTLBlocks != 0 {
+				return nil, fmt.Errorf("stream %q: 'ttlBlocks' is only valid for kind 'store'", s.Name)
+			}
+			if s.MaxSizeBytes != 0 || s.MaxKeys != 0 {
+				return nil, fmt.Errorf("stream %q: 'maxSizeBytes' and 'maxKeys' are only valid for kind 'store'", s.Name)
+			}
 		case ModuleKindStore:
 			if err := validateStoreBuilder(s); err != nil {
 				return nil, fmt.Errorf("stream %q: %w", s.Name, err)
@@ -766,7 +772,7 @@ func (r *Reader) convertToPkg(m *Manifest) (pkg *pbsubstreams.Package, err error
 		}
 
 		switch binaryDef.Type {
-		case "wasm/rust-v1":
+		case "wasm/rust-v1", "wasm/wasi-v1":
 			// OPTIM(abourget): also check if it's not already in
 			// `Binaries`, by comparing its, length + hash or value.
 			codeIndex, found := moduleCodeIndexes[binaryDef.File]
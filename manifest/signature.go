@@ -66,6 +66,13 @@ func (m *ModuleHashes) HashModule(modules *pbsubstreams.Modules, module *pbsubst
 		buf.WriteString("map")
 	case *pbsubstreams.Module_KindStore_:
 		buf.WriteString("store")
+		// Only hashed when set, to keep the hashes of the stores without TTL
+		if ttlBlocks := module.GetKindStore().TtlBlocks; ttlBlocks != 0 {
+			ttlBlocksBytes := make([]byte, 8)
+			binary.LittleEndian.PutUint64(ttlBlocksBytes, ttlBlocks)
+			buf.WriteString("ttl_blocks")
+			buf.Write(ttlBlocksBytes)
+		}
 	default:
 		return nil, fmt.Errorf("invalid module file %T", module.Kind)
 	}
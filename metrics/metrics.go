@@ -16,6 +16,10 @@ var SquashesLaunched = MetricSet.NewCounter("substreams_total_squashes_launched"
 var SquashersStarted = MetricSet.NewCounter("substreams_total_squash_processes_launched", "Counter for Total squash processes launched, used for rate")
 var SquashersEnded = MetricSet.NewCounter("substreams_total_squash_processes_closed", "Counter for Total squash processes closed, used for active processes")
 
+var WasmCompilationCacheHits = MetricSet.NewCounter("substreams_wasm_compilation_cache_hits", "Counter for WASM modules loaded from the compilation cache")
+var WasmCompilationCacheMisses = MetricSet.NewCounter("substreams_wasm_compilation_cache_misses", "Counter for WASM modules compiled because they were not in the compilation cache")
+var WasmDeterminismDivergences = MetricSet.NewCounter("substreams_wasm_determinism_divergences", "Counter for WASM module executions found non-deterministic by the determinism check")
+
 var AppReadiness = MetricSet.NewAppReadiness("firehose")
 
 var registerOnce sync.Once
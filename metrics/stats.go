@@ -50,6 +50,9 @@ func cloneStats(in *pbssinternal.ModuleStats) *pbssinternal.ModuleStats {
 		StoreWriteCount:        in.StoreWriteCount,
 		StoreDeleteprefixCount: in.StoreDeleteprefixCount,
 		StoreSizeBytes:         in.StoreSizeBytes,
+
+		StoreDeleterangeCount:         in.StoreDeleterangeCount,
+		StoreDeleterangepointersCount: in.StoreDeleterangepointersCount,
 	}
 }
 
@@ -79,6 +82,8 @@ func mergeModuleStats(left, right *pbssinternal.ModuleStats) {
 	left.ExternalCallMetrics = mergeCallMetricsSlices(left.ExternalCallMetrics, right.ExternalCallMetrics)
 	left.StoreWriteCount += right.StoreWriteCount
 	left.StoreDeleteprefixCount += right.StoreDeleteprefixCount
+	left.StoreDeleterangeCount += right.StoreDeleterangeCount
+	left.StoreDeleterangepointersCount += right.StoreDeleterangepointersCount
 	if right.StoreSizeBytes > left.StoreSizeBytes {
 		left.StoreSizeBytes = right.StoreSizeBytes
 	}
@@ -95,6 +100,8 @@ func mergeMixedModuleStats(left *pbsubstreamsrpc.ModuleStats, right *pbssinterna
 	left.ExternalCallMetrics = mergeMixedCallMetrics(left.ExternalCallMetrics, right.ExternalCallMetrics)
 	left.TotalStoreWriteCount += right.StoreWriteCount
 	left.TotalStoreDeleteprefixCount += right.StoreDeleteprefixCount
+	left.TotalStoreDeleterangeCount += right.StoreDeleterangeCount
+	left.TotalStoreDeleterangepointersCount += right.StoreDeleterangepointersCount
 	if right.StoreSizeBytes > left.StoreSizeBytes {
 		left.StoreSizeBytes = right.StoreSizeBytes
 	}
@@ -154,19 +161,6 @@ type extendedCallMetric struct {
 // updateDurations should be called while locked
 func (s *extendedStats) updateDurations() {
 	s.ModuleStats.ProcessingTimeMs = uint64(s.processingTime.Milliseconds())
-	s.ModuleStats.ExternalCallMetrics = make([]*pbssinternal.ExternalCallMetric, len(s.externalCallMetrics))
-	i := 0
-	for k, v := range s.externalCallMetrics {
-		s.ModuleStats.ExternalCallMetrics[i] = &pbssinternal.ExternalCallMetric{
-			Name:   k,
-			Count:  v.count,
-			TimeMs: uint64(v.time.Milliseconds()),
-		}
-		sort.Slice(s.ModuleStats.ExternalCallMetrics, func(i, j int) bool {
-			return s.ModuleStats.ExternalCallMetrics[i].Name < s.ModuleStats.ExternalCallMetrics[j].Name
-		})
-		i++
-	}
 	s.ModuleStats.StoreOperationTimeMs = uint64(s.storeOperationTime.Milliseconds())
 }
 
@@ -304,6 +298,35 @@ func (s *Stats) RecordModuleWasmStoreDeletePrefix(moduleName string, sizeBytes u
 	mod.storeOperationTime += elapsed
 }
 
+// RecordModuleWasmStoreDeleteRange can be called multiple times per module per block `elapsed` is the time spent in executing that operation.
+func (s *Stats) RecordModuleWasmStoreDeleteRange(moduleName string, sizeBytes uint64, elapsed time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	mod := s.moduleStats(moduleName)
+	mod.StoreSizeBytes = sizeBytes
+	mod.StoreDeleterangeCount++
+	mod.storeOperationTime += elapsed
+}
+
+// RecordModuleWasmStoreDeleteRangePointers can be called multiple times per module per block `elapsed` is the time spent in executing that operation.
+func (s *Stats) RecordModuleWasmStoreDeleteRangePointers(moduleName string, sizeBytes uint64, elapsed time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	mod := s.moduleStats(moduleName)
+	mod.StoreSizeBytes = sizeBytes
+	mod.StoreDeleterangepointersCount++
+	mod.storeOperationTime += elapsed
+}
+
+// RecordModuleStoreEviction is called when expired keys were evicted from the store, `elapsed` is the time spent evicting them.
+func (s *Stats) RecordModuleStoreEviction(moduleName string, sizeBytes uint64, elapsed time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	mod := s.moduleStats(moduleName)
+	mod.StoreSizeBytes = sizeBytes
+	mod.storeOperationTime += elapsed
+}
+
 func (s *Stats) RecordBlock(ref bstream.BlockRef) {
 	s.blockRate.Add(1)
 }
@@ -373,6 +396,9 @@ func (s *Stats) LocalModulesStats() []*pbssinternal.ModuleStats {
 			StoreWriteCount:        v.StoreWriteCount,
 			StoreDeleteprefixCount: v.StoreDeleteprefixCount,
 			StoreSizeBytes:         v.StoreSizeBytes,
+
+			StoreDeleterangeCount:         v.StoreDeleterangeCount,
+			StoreDeleterangepointersCount: v.StoreDeleterangepointersCount,
 		}
 
 		i++
@@ -475,6 +501,9 @@ func mergeCallMetricsMap(completeJobsMetrics []*pbssinternal.ExternalCallMetric,
 			})
 		}
 	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
 
 	return out
 }
@@ -532,6 +561,9 @@ func (s *Stats) AggregatedModulesStats() []*pbsubstreamsrpc.ModuleStats {
 			TotalProcessedBlockCount:    v.processedBlocksInCompleteJobs,
 			TotalStoreMergingTimeMs:     uint64(v.mergingTime.Milliseconds()),
 			StoreCurrentlyMerging:       v.merging,
+
+			TotalStoreDeleterangeCount:         v.StoreDeleterangeCount,
+			TotalStoreDeleterangepointersCount: v.StoreDeleterangepointersCount,
 		}
 
 		mergeMixedModuleStats(out[i], s.runningJobs.ModuleStats(k))
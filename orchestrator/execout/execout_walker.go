@@ -3,6 +3,7 @@ package execout
 import (
 	"context"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -67,7 +68,8 @@ func (r *Walker) CmdDownloadCurrentSegment(waitBefore time.Duration) loop.Cmd {
 	return func() loop.Msg {
 		time.Sleep(waitBefore)
 
-		err := file.Load(r.ctx)
+		// The blocks before the start of the walk are neither fetched nor decoded.
+		err := file.LoadRange(r.ctx, r.StartBlock, math.MaxUint64)
 		if err == dstore.ErrNotFound {
 
 			return MsgFileNotPresent{NextWait: computeNewWait(waitBefore)}
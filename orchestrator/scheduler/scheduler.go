@@ -26,6 +26,12 @@ type Scheduler struct {
 	WorkerPool    *work.WorkerPool
 	ExecOutWalker *execout.Walker
 
+	// WaitForMapOutputs makes the scheduler, when there is no
+	// ExecOutWalker, wait for the outputs of the mapper stage to be written
+	// before shutting down, instead of considering the output stream
+	// completed. Only the warming of the cache sets it, see orchestrator.Warm.
+	WaitForMapOutputs bool
+
 	logger *zap.Logger
 
 	// Final state:
@@ -49,6 +55,8 @@ func (s *Scheduler) Init() loop.Cmd {
 
 	if s.ExecOutWalker != nil {
 		cmds = append(cmds, execout.CmdDownloadSegment(0))
+	} else if s.WaitForMapOutputs {
+		s.outputStreamCompleted = s.Stages.AllMapsCompleted()
 	} else {
 		// This hides the fact that there _was no_ Walker. Could cause
 		// confusing error messages in `cmdShutdownWhenComplete()`.
@@ -88,6 +96,9 @@ func (s *Scheduler) Update(msg loop.Msg) loop.Cmd {
 		)
 		if s.ExecOutWalker != nil {
 			cmds = append(cmds, execout.CmdDownloadSegment(0))
+		} else if s.WaitForMapOutputs && !s.outputStreamCompleted && s.Stages.AllMapsCompleted() {
+			s.outputStreamCompleted = true
+			cmds = append(cmds, s.cmdShutdownWhenComplete())
 		}
 
 	case work.MsgScheduleNextJob:
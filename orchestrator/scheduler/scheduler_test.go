@@ -1,12 +1,19 @@
 package scheduler
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 
 	"github.com/streamingfast/substreams/orchestrator/execout"
 	"github.com/streamingfast/substreams/orchestrator/loop"
+	"github.com/streamingfast/substreams/orchestrator/plan"
+	"github.com/streamingfast/substreams/orchestrator/stage"
+	"github.com/streamingfast/substreams/pipeline/outputmodules"
+	"github.com/streamingfast/substreams/reqctx"
 )
 
 func TestSched2_JobFinished(t *testing.T) {
@@ -35,3 +42,26 @@ func TestSched2_JobFinished(t *testing.T) {
 	//  * NextSegment()
 
 }
+
+func TestScheduler_Init_WithoutExecOutWalker(t *testing.T) {
+	ctx := reqctx.WithLogger(context.Background(), zap.NewNop())
+	newScheduler := func(waitForMapOutputs bool) *Scheduler {
+		reqPlan, err := plan.BuildTier1RequestPlan(true, 10, 5, 25, 50, 50, true)
+		require.NoError(t, err)
+		s := New(ctx, nil)
+		s.Stages = stage.NewStages(ctx, outputmodules.TestGraphStagedModules(5, 5, 5, 5, 5), reqPlan, nil, "trace")
+		s.WaitForMapOutputs = waitForMapOutputs
+		require.False(t, s.Stages.AllMapsCompleted())
+		return s
+	}
+
+	// On tier1, there is no output to stream without a walker, the
+	// mapper outputs are not waited for.
+	s := newScheduler(false)
+	s.Init()
+	assert.True(t, s.outputStreamCompleted)
+
+	s = newScheduler(true)
+	s.Init()
+	assert.False(t, s.outputStreamCompleted)
+}
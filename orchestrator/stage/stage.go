@@ -1,6 +1,8 @@
 package stage
 
 import (
+	"fmt"
+
 	"github.com/abourget/llerrgroup"
 
 	"github.com/streamingfast/substreams/block"
@@ -55,3 +57,14 @@ const (
 	KindMap = Kind(iota)
 	KindStore
 )
+
+func (k Kind) String() string {
+	switch k {
+	case KindMap:
+		return "map"
+	case KindStore:
+		return "store"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
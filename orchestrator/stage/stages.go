@@ -151,6 +151,26 @@ func (s *Stages) AllStoresCompleted() bool {
 	return true
 }
 
+// AllMapsCompleted tells if the outputs of the mapper stage, when the last
+// stage is one, are written for all of its segments.
+func (s *Stages) AllMapsCompleted() bool {
+	if s.mapSegmenter == nil || len(s.stages) == 0 {
+		return true
+	}
+	lastStage := len(s.stages) - 1
+	if s.stages[lastStage].kind != KindMap {
+		return true
+	}
+
+	for segmentIdx := s.mapSegmenter.FirstIndex(); segmentIdx <= s.mapSegmenter.LastIndex(); segmentIdx++ {
+		state := s.getState(Unit{Segment: segmentIdx, Stage: lastStage})
+		if state != UnitPartialPresent && state != UnitCompleted && state != UnitNoOp {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Stages) UpdateStats() {
 	out := make([]*pbsubstreamsrpc.Stage, len(s.stages))
 
@@ -454,6 +474,48 @@ func (s *Stages) StatesString() string {
 	return out.String()
 }
 
+// StageLayout describes a stage and the state of its units, the segments
+// of its range.
+type StageLayout struct {
+	Index   int
+	Kind    Kind
+	Modules []string
+	Units   []UnitLayout
+}
+
+type UnitLayout struct {
+	Unit  Unit
+	Range *block.Range
+	State UnitState
+}
+
+// Layout returns the stages with the current state of their units, skipping
+// the empty ones, without changing them. Right after FetchStoresState, the
+// units still Pending are the jobs the scheduler would run.
+func (s *Stages) Layout() []*StageLayout {
+	out := make([]*StageLayout, 0, len(s.stages))
+	for stageIdx, stage := range s.stages {
+		layout := &StageLayout{
+			Index:   stageIdx,
+			Kind:    stage.kind,
+			Modules: s.StageModules(stageIdx),
+		}
+		for segmentIdx := stage.segmenter.FirstIndex(); segmentIdx <= stage.segmenter.LastIndex(); segmentIdx++ {
+			r := stage.segmenter.Range(segmentIdx)
+			if r == nil || r.Len() == 0 {
+				continue
+			}
+			unit := Unit{Segment: segmentIdx, Stage: stageIdx}
+			layout.Units = append(layout.Units, UnitLayout{Unit: unit, Range: r, State: s.getState(unit)})
+		}
+		if len(layout.Units) == 0 {
+			continue
+		}
+		out = append(out, layout)
+	}
+	return out
+}
+
 func (s *Stages) StageModules(stage int) (out []string) {
 	for _, modState := range s.stages[stage].moduleStates {
 		out = append(out, modState.name)
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/streamingfast/substreams/block"
 	"github.com/streamingfast/substreams/orchestrator/plan"
@@ -206,6 +207,78 @@ M:NCS.`)
 
 }
 
+func TestStages_AllMapsCompleted(t *testing.T) {
+	reqPlan, err := plan.BuildTier1RequestPlan(true, 10, 5, 25, 50, 50, true)
+	assert.NoError(t, err)
+	stages := NewStages(
+		context.Background(),
+		outputmodules.TestGraphStagedModules(5, 5, 5, 5, 5),
+		reqPlan,
+		nil,
+		"trace",
+	)
+	assert.False(t, stages.AllMapsCompleted())
+
+	stages.allocSegments(4)
+	for segment := 2; segment <= 4; segment++ {
+		stages.setState(id(segment, 2), UnitPartialPresent)
+	}
+	assert.True(t, stages.AllMapsCompleted(), "segments before the map output are not needed")
+
+	stages.setState(id(3, 2), UnitScheduled)
+	assert.False(t, stages.AllMapsCompleted())
+	stages.setState(id(3, 2), UnitCompleted)
+	assert.True(t, stages.AllMapsCompleted())
+
+	reqPlan, err = plan.BuildTier1RequestPlan(false, 10, 5, 25, 50, 50, true)
+	assert.NoError(t, err)
+	stages = NewStages(context.Background(), outputmodules.TestGraphStagedModules(5, 5, 5, 5, 5), reqPlan, nil, "trace")
+	assert.True(t, stages.AllMapsCompleted(), "no map stage")
+}
+
+func TestStages_Layout(t *testing.T) {
+	reqPlan, err := plan.BuildTier1RequestPlan(true, 10, 5, 25, 50, 50, true)
+	assert.NoError(t, err)
+	stages := NewStages(context.Background(), outputmodules.TestGraphStagedModules(5, 5, 5, 5, 5), reqPlan, nil, "trace")
+	stages.allocSegments(2)
+	stages.setState(id(0, 0), UnitCompleted)
+	stages.setState(id(1, 0), UnitPartialPresent)
+	stages.setState(id(2, 2), UnitCompleted)
+
+	layout := stages.Layout()
+	assert.Len(t, layout, 3)
+	assert.Equal(t, KindStore, layout[0].Kind)
+	assert.Equal(t, KindMap, layout[2].Kind)
+
+	var states []string
+	for _, unit := range layout[0].Units {
+		states = append(states, unit.Range.String()+" "+unit.State.String())
+	}
+	assert.Equal(t, []string{"[5, 10) Completed", "[10, 20) PartialPresent", "[20, 30) Pending", "[30, 40) Pending"}, states)
+
+	states = nil
+	for _, unit := range layout[2].Units {
+		states = append(states, unit.Range.String()+" "+unit.State.String())
+	}
+	assert.Equal(t, []string{"[5, 10) NoOp", "[10, 20) NoOp", "[20, 30) Completed", "[30, 40) Pending", "[40, 50) Pending"}, states)
+}
+
+// The stages without any unit, like a store stage whose modules start after
+// the range of the stores, are skipped.
+func TestStages_Layout_SkipsEmptyStages(t *testing.T) {
+	reqPlan, err := plan.BuildTier1RequestPlan(true, 10, 5, 25, 50, 50, true)
+	assert.NoError(t, err)
+	stages := NewStages(context.Background(), outputmodules.TestGraphStagedModules(5, 5, 5, 45, 5), reqPlan, nil, "trace")
+	stages.allocSegments(2)
+
+	layout := stages.Layout()
+	require.Len(t, layout, 2)
+	assert.Equal(t, 0, layout[0].Index)
+	assert.Equal(t, KindStore, layout[0].Kind)
+	assert.Equal(t, 2, layout[1].Index)
+	assert.Equal(t, KindMap, layout[1].Kind)
+}
+
 func id(segment, stage int) Unit {
 	return Unit{Stage: stage, Segment: segment}
 }
@@ -257,3 +330,9 @@ func TestStages_allocSegments(t *testing.T) {
 		})
 	}
 }
+
+func TestKind_String(t *testing.T) {
+	assert.Equal(t, "map", KindMap.String())
+	assert.Equal(t, "store", KindStore.String())
+	assert.Equal(t, "Kind(7)", Kind(7).String())
+}
@@ -0,0 +1,65 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/streamingfast/substreams"
+	"github.com/streamingfast/substreams/orchestrator/plan"
+	"github.com/streamingfast/substreams/orchestrator/response"
+	"github.com/streamingfast/substreams/orchestrator/scheduler"
+	"github.com/streamingfast/substreams/orchestrator/stage"
+	"github.com/streamingfast/substreams/orchestrator/work"
+	"github.com/streamingfast/substreams/pipeline/outputmodules"
+	"github.com/streamingfast/substreams/service/config"
+	"github.com/streamingfast/substreams/storage/execout"
+	"github.com/streamingfast/substreams/storage/store"
+)
+
+// Warm fills the cache with the stores and the mapper outputs laid out by
+// `reqPlan`, scheduling the same tier2 jobs as the ParallelProcessor, but
+// without streaming any output. It returns once they are all written.
+//
+// The stores and outputs already in the cache are not processed again, and
+// neither are the partial stores written under the same `traceID`, so an
+// interrupted run can be resumed with the same `traceID`.
+func Warm(
+	ctx context.Context,
+	reqPlan *plan.RequestPlan,
+	runtimeConfig config.RuntimeConfig,
+	maxParallelJobs int,
+	outputGraph *outputmodules.Graph,
+	execoutStorage *execout.Configs,
+	respFunc func(resp substreams.ResponseFromAnyTier) error,
+	storeConfigs store.ConfigMap,
+	traceID string,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sched := scheduler.New(ctx, response.New(respFunc))
+	sched.WaitForMapOutputs = true
+
+	stages := stage.NewStages(ctx, outputGraph, reqPlan, storeConfigs, traceID)
+	sched.Stages = stages
+
+	// Unlike on tier1, the mapper outputs are fetched even when there are
+	// no stores to build, the mapper being the only work to resume then.
+	err := stages.FetchStoresState(
+		ctx,
+		reqPlan.BackprocessSegmenter(),
+		storeConfigs,
+		execoutStorage,
+		traceID,
+	)
+	if err != nil {
+		return fmt.Errorf("fetch stores storage state: %w", err)
+	}
+
+	sched.WorkerPool = work.NewWorkerPool(ctx, maxParallelJobs, runtimeConfig.WorkerFactory)
+
+	if err := sched.Run(ctx, sched.Init()); err != nil {
+		return fmt.Errorf("scheduler run: %w", err)
+	}
+	return nil
+}
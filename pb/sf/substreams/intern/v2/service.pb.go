@@ -286,9 +286,11 @@ type ModuleStats struct {
 	StoreReadCount       uint64                `protobuf:"varint,4,opt,name=store_read_count,json=storeReadCount,proto3" json:"store_read_count,omitempty"`
 	ExternalCallMetrics  []*ExternalCallMetric `protobuf:"bytes,5,rep,name=external_call_metrics,json=externalCallMetrics,proto3" json:"external_call_metrics,omitempty"`
 	// store-specific (will be 0 on mappers)
-	StoreWriteCount        uint64 `protobuf:"varint,10,opt,name=store_write_count,json=storeWriteCount,proto3" json:"store_write_count,omitempty"`
-	StoreDeleteprefixCount uint64 `protobuf:"varint,11,opt,name=store_deleteprefix_count,json=storeDeleteprefixCount,proto3" json:"store_deleteprefix_count,omitempty"`
-	StoreSizeBytes         uint64 `protobuf:"varint,12,opt,name=store_size_bytes,json=storeSizeBytes,proto3" json:"store_size_bytes,omitempty"`
+	StoreWriteCount               uint64 `protobuf:"varint,10,opt,name=store_write_count,json=storeWriteCount,proto3" json:"store_write_count,omitempty"`
+	StoreDeleteprefixCount        uint64 `protobuf:"varint,11,opt,name=store_deleteprefix_count,json=storeDeleteprefixCount,proto3" json:"store_deleteprefix_count,omitempty"`
+	StoreSizeBytes                uint64 `protobuf:"varint,12,opt,name=store_size_bytes,json=storeSizeBytes,proto3" json:"store_size_bytes,omitempty"`
+	StoreDeleterangeCount         uint64 `protobuf:"varint,13,opt,name=store_deleterange_count,json=storeDeleterangeCount,proto3" json:"store_deleterange_count,omitempty"`
+	StoreDeleterangepointersCount uint64 `protobuf:"varint,14,opt,name=store_deleterangepointers_count,json=storeDeleterangepointersCount,proto3" json:"store_deleterangepointers_count,omitempty"`
 }
 
 func (x *ModuleStats) Reset() {
@@ -379,6 +381,20 @@ func (x *ModuleStats) GetStoreSizeBytes() uint64 {
 	return 0
 }
 
+func (x *ModuleStats) GetStoreDeleterangeCount() uint64 {
+	if x != nil {
+		return x.StoreDeleterangeCount
+	}
+	return 0
+}
+
+func (x *ModuleStats) GetStoreDeleterangepointersCount() uint64 {
+	if x != nil {
+		return x.StoreDeleterangepointersCount
+	}
+	return 0
+}
+
 type ExternalCallMetric struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -680,7 +696,7 @@ var file_sf_substreams_intern_v2_service_proto_rawDesc = []byte{
 	0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x69,
 	0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2e, 0x76, 0x32, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c,
 	0x65, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52, 0x0c, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x53,
-	0x74, 0x61, 0x74, 0x73, 0x22, 0xa3, 0x03, 0x0a, 0x0b, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x53,
+	0x74, 0x61, 0x74, 0x73, 0x22, 0xa3, 0x04, 0x0a, 0x0b, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x53,
 	0x74, 0x61, 0x74, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01,
 	0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x2c, 0x0a, 0x12, 0x70, 0x72, 0x6f, 0x63,
 	0x65, 0x73, 0x73, 0x69, 0x6e, 0x67, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x6d, 0x73, 0x18, 0x02,
@@ -706,7 +722,15 @@ var file_sf_substreams_intern_v2_service_proto_rawDesc = []byte{
 	0x65, 0x6c, 0x65, 0x74, 0x65, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x43, 0x6f, 0x75, 0x6e, 0x74,
 	0x12, 0x28, 0x0a, 0x10, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x5f, 0x62,
 	0x79, 0x74, 0x65, 0x73, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x73, 0x74, 0x6f, 0x72,
-	0x65, 0x53, 0x69, 0x7a, 0x65, 0x42, 0x79, 0x74, 0x65, 0x73, 0x22, 0x57, 0x0a, 0x12, 0x45, 0x78,
+	0x65, 0x53, 0x69, 0x7a, 0x65, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x36, 0x0a, 0x17, 0x73, 0x74,
+	0x6f, 0x72, 0x65, 0x5f, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x5f,
+	0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x04, 0x52, 0x15, 0x73, 0x74, 0x6f,
+	0x72, 0x65, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x43, 0x6f, 0x75,
+	0x6e, 0x74, 0x12, 0x46, 0x0a, 0x1f, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x5f, 0x64, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x73, 0x5f,
+	0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x04, 0x52, 0x1d, 0x73, 0x74, 0x6f,
+	0x72, 0x65, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x70, 0x6f, 0x69,
+	0x6e, 0x74, 0x65, 0x72, 0x73, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x22, 0x57, 0x0a, 0x12, 0x45, 0x78,
 	0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x43, 0x61, 0x6c, 0x6c, 0x4d, 0x65, 0x74, 0x72, 0x69, 0x63,
 	0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
 	0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x02, 0x20,
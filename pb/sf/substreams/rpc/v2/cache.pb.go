@@ -0,0 +1,479 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        (unknown)
+// source: sf/substreams/rpc/v2/cache.proto
+
+package pbsubstreamsrpc
+
+import (
+	v1 "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CachedRangesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The hash of the module, or the `output_module` and the `modules` to compute it from.
+	ModuleHash   string      `protobuf:"bytes,1,opt,name=module_hash,json=moduleHash,proto3" json:"module_hash,omitempty"`
+	OutputModule string      `protobuf:"bytes,2,opt,name=output_module,json=outputModule,proto3" json:"output_module,omitempty"`
+	Modules      *v1.Modules `protobuf:"bytes,3,opt,name=modules,proto3" json:"modules,omitempty"`
+}
+
+func (x *CachedRangesRequest) Reset() {
+	*x = CachedRangesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_rpc_v2_cache_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CachedRangesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CachedRangesRequest) ProtoMessage() {}
+
+func (x *CachedRangesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_rpc_v2_cache_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CachedRangesRequest.ProtoReflect.Descriptor instead.
+func (*CachedRangesRequest) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_rpc_v2_cache_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CachedRangesRequest) GetModuleHash() string {
+	if x != nil {
+		return x.ModuleHash
+	}
+	return ""
+}
+
+func (x *CachedRangesRequest) GetOutputModule() string {
+	if x != nil {
+		return x.OutputModule
+	}
+	return ""
+}
+
+func (x *CachedRangesRequest) GetModules() *v1.Modules {
+	if x != nil {
+		return x.Modules
+	}
+	return nil
+}
+
+type CachedRangesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ModuleHash string `protobuf:"bytes,1,opt,name=module_hash,json=moduleHash,proto3" json:"module_hash,omitempty"`
+	// Sorted and merged, the contiguous cached outputs being a single range. The
+	// `end_block` of the ranges is exclusive.
+	Ranges []*BlockRange `protobuf:"bytes,2,rep,name=ranges,proto3" json:"ranges,omitempty"`
+}
+
+func (x *CachedRangesResponse) Reset() {
+	*x = CachedRangesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_rpc_v2_cache_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CachedRangesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CachedRangesResponse) ProtoMessage() {}
+
+func (x *CachedRangesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_rpc_v2_cache_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CachedRangesResponse.ProtoReflect.Descriptor instead.
+func (*CachedRangesResponse) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_rpc_v2_cache_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CachedRangesResponse) GetModuleHash() string {
+	if x != nil {
+		return x.ModuleHash
+	}
+	return ""
+}
+
+func (x *CachedRangesResponse) GetRanges() []*BlockRange {
+	if x != nil {
+		return x.Ranges
+	}
+	return nil
+}
+
+type CachedOutputsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	StartBlockNum uint64 `protobuf:"varint,1,opt,name=start_block_num,json=startBlockNum,proto3" json:"start_block_num,omitempty"`
+	// When set, the stream resumes after the block of the cursor, which must be final.
+	StartCursor  string `protobuf:"bytes,2,opt,name=start_cursor,json=startCursor,proto3" json:"start_cursor,omitempty"`
+	StopBlockNum uint64 `protobuf:"varint,3,opt,name=stop_block_num,json=stopBlockNum,proto3" json:"stop_block_num,omitempty"`
+	// The outputs of a map module are sent in `output`, the deltas of a store module in
+	// `debug_store_outputs`.
+	OutputModule string      `protobuf:"bytes,4,opt,name=output_module,json=outputModule,proto3" json:"output_module,omitempty"`
+	Modules      *v1.Modules `protobuf:"bytes,5,opt,name=modules,proto3" json:"modules,omitempty"`
+}
+
+func (x *CachedOutputsRequest) Reset() {
+	*x = CachedOutputsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_rpc_v2_cache_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CachedOutputsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CachedOutputsRequest) ProtoMessage() {}
+
+func (x *CachedOutputsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_rpc_v2_cache_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CachedOutputsRequest.ProtoReflect.Descriptor instead.
+func (*CachedOutputsRequest) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_rpc_v2_cache_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CachedOutputsRequest) GetStartBlockNum() uint64 {
+	if x != nil {
+		return x.StartBlockNum
+	}
+	return 0
+}
+
+func (x *CachedOutputsRequest) GetStartCursor() string {
+	if x != nil {
+		return x.StartCursor
+	}
+	return ""
+}
+
+func (x *CachedOutputsRequest) GetStopBlockNum() uint64 {
+	if x != nil {
+		return x.StopBlockNum
+	}
+	return 0
+}
+
+func (x *CachedOutputsRequest) GetOutputModule() string {
+	if x != nil {
+		return x.OutputModule
+	}
+	return ""
+}
+
+func (x *CachedOutputsRequest) GetModules() *v1.Modules {
+	if x != nil {
+		return x.Modules
+	}
+	return nil
+}
+
+type MissingCachedRanges struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ModuleHash string `protobuf:"bytes,1,opt,name=module_hash,json=moduleHash,proto3" json:"module_hash,omitempty"`
+	// The ranges of the request whose outputs are not cached, `end_block` exclusive.
+	MissingRanges []*BlockRange `protobuf:"bytes,2,rep,name=missing_ranges,json=missingRanges,proto3" json:"missing_ranges,omitempty"`
+	CachedRanges  []*BlockRange `protobuf:"bytes,3,rep,name=cached_ranges,json=cachedRanges,proto3" json:"cached_ranges,omitempty"`
+}
+
+func (x *MissingCachedRanges) Reset() {
+	*x = MissingCachedRanges{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sf_substreams_rpc_v2_cache_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MissingCachedRanges) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MissingCachedRanges) ProtoMessage() {}
+
+func (x *MissingCachedRanges) ProtoReflect() protoreflect.Message {
+	mi := &file_sf_substreams_rpc_v2_cache_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MissingCachedRanges.ProtoReflect.Descriptor instead.
+func (*MissingCachedRanges) Descriptor() ([]byte, []int) {
+	return file_sf_substreams_rpc_v2_cache_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MissingCachedRanges) GetModuleHash() string {
+	if x != nil {
+		return x.ModuleHash
+	}
+	return ""
+}
+
+func (x *MissingCachedRanges) GetMissingRanges() []*BlockRange {
+	if x != nil {
+		return x.MissingRanges
+	}
+	return nil
+}
+
+func (x *MissingCachedRanges) GetCachedRanges() []*BlockRange {
+	if x != nil {
+		return x.CachedRanges
+	}
+	return nil
+}
+
+var File_sf_substreams_rpc_v2_cache_proto protoreflect.FileDescriptor
+
+var file_sf_substreams_rpc_v2_cache_proto_rawDesc = []byte{
+	0x0a, 0x20, 0x73, 0x66, 0x2f, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2f,
+	0x72, 0x70, 0x63, 0x2f, 0x76, 0x32, 0x2f, 0x63, 0x61, 0x63, 0x68, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x12, 0x14, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x73, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x76, 0x32, 0x1a, 0x1e, 0x73, 0x66, 0x2f, 0x73, 0x75, 0x62,
+	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2f, 0x76, 0x31, 0x2f, 0x6d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x22, 0x73, 0x66, 0x2f, 0x73, 0x75, 0x62,
+	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2f, 0x72, 0x70, 0x63, 0x2f, 0x76, 0x32, 0x2f, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x90, 0x01, 0x0a,
+	0x13, 0x43, 0x61, 0x63, 0x68, 0x65, 0x64, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f, 0x68,
+	0x61, 0x73, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x48, 0x61, 0x73, 0x68, 0x12, 0x23, 0x0a, 0x0d, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x5f,
+	0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x6f, 0x75,
+	0x74, 0x70, 0x75, 0x74, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x12, 0x33, 0x0a, 0x07, 0x6d, 0x6f,
+	0x64, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x73, 0x66,
+	0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d,
+	0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x52, 0x07, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x22,
+	0x71, 0x0a, 0x14, 0x43, 0x61, 0x63, 0x68, 0x65, 0x64, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6d, 0x6f,
+	0x64, 0x75, 0x6c, 0x65, 0x48, 0x61, 0x73, 0x68, 0x12, 0x38, 0x0a, 0x06, 0x72, 0x61, 0x6e, 0x67,
+	0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75,
+	0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x76, 0x32, 0x2e,
+	0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x06, 0x72, 0x61, 0x6e, 0x67,
+	0x65, 0x73, 0x22, 0xe1, 0x01, 0x0a, 0x14, 0x43, 0x61, 0x63, 0x68, 0x65, 0x64, 0x4f, 0x75, 0x74,
+	0x70, 0x75, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x26, 0x0a, 0x0f, 0x73,
+	0x74, 0x61, 0x72, 0x74, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x0d, 0x73, 0x74, 0x61, 0x72, 0x74, 0x42, 0x6c, 0x6f, 0x63, 0x6b,
+	0x4e, 0x75, 0x6d, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x74, 0x61, 0x72, 0x74, 0x5f, 0x63, 0x75, 0x72,
+	0x73, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x73, 0x74, 0x61, 0x72, 0x74,
+	0x43, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x12, 0x24, 0x0a, 0x0e, 0x73, 0x74, 0x6f, 0x70, 0x5f, 0x62,
+	0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0c,
+	0x73, 0x74, 0x6f, 0x70, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x4e, 0x75, 0x6d, 0x12, 0x23, 0x0a, 0x0d,
+	0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x5f, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0c, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x4d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x12, 0x33, 0x0a, 0x07, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x19, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x52, 0x07, 0x6d,
+	0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x22, 0xc6, 0x01, 0x0a, 0x13, 0x4d, 0x69, 0x73, 0x73, 0x69,
+	0x6e, 0x67, 0x43, 0x61, 0x63, 0x68, 0x65, 0x64, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x12, 0x1f,
+	0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x48, 0x61, 0x73, 0x68, 0x12,
+	0x47, 0x0a, 0x0e, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6e, 0x67, 0x5f, 0x72, 0x61, 0x6e, 0x67, 0x65,
+	0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62,
+	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x76, 0x32, 0x2e, 0x42,
+	0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x0d, 0x6d, 0x69, 0x73, 0x73, 0x69,
+	0x6e, 0x67, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x12, 0x45, 0x0a, 0x0d, 0x63, 0x61, 0x63, 0x68,
+	0x65, 0x64, 0x5f, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x20, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e,
+	0x72, 0x70, 0x63, 0x2e, 0x76, 0x32, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x61, 0x6e, 0x67,
+	0x65, 0x52, 0x0c, 0x63, 0x61, 0x63, 0x68, 0x65, 0x64, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x32,
+	0xcd, 0x01, 0x0a, 0x05, 0x43, 0x61, 0x63, 0x68, 0x65, 0x12, 0x65, 0x0a, 0x0c, 0x43, 0x61, 0x63,
+	0x68, 0x65, 0x64, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x12, 0x29, 0x2e, 0x73, 0x66, 0x2e, 0x73,
+	0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x76, 0x32,
+	0x2e, 0x43, 0x61, 0x63, 0x68, 0x65, 0x64, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x2a, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x73, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x76, 0x32, 0x2e, 0x43, 0x61, 0x63, 0x68,
+	0x65, 0x64, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x5d, 0x0a, 0x0d, 0x43, 0x61, 0x63, 0x68, 0x65, 0x64, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74,
+	0x73, 0x12, 0x2a, 0x2e, 0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x73, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x76, 0x32, 0x2e, 0x43, 0x61, 0x63, 0x68, 0x65, 0x64, 0x4f,
+	0x75, 0x74, 0x70, 0x75, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e,
+	0x73, 0x66, 0x2e, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2e, 0x72, 0x70,
+	0x63, 0x2e, 0x76, 0x32, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x42,
+	0x4d, 0x5a, 0x4b, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x69, 0x6e, 0x67, 0x66, 0x61, 0x73, 0x74, 0x2f, 0x73, 0x75, 0x62, 0x73,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2f, 0x70, 0x62, 0x2f, 0x73, 0x66, 0x2f, 0x73, 0x75, 0x62,
+	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x2f, 0x72, 0x70, 0x63, 0x2f, 0x76, 0x32, 0x3b, 0x70,
+	0x62, 0x73, 0x75, 0x62, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x72, 0x70, 0x63, 0x62, 0x06,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_sf_substreams_rpc_v2_cache_proto_rawDescOnce sync.Once
+	file_sf_substreams_rpc_v2_cache_proto_rawDescData = file_sf_substreams_rpc_v2_cache_proto_rawDesc
+)
+
+func file_sf_substreams_rpc_v2_cache_proto_rawDescGZIP() []byte {
+	file_sf_substreams_rpc_v2_cache_proto_rawDescOnce.Do(func() {
+		file_sf_substreams_rpc_v2_cache_proto_rawDescData = protoimpl.X.CompressGZIP(file_sf_substreams_rpc_v2_cache_proto_rawDescData)
+	})
+	return file_sf_substreams_rpc_v2_cache_proto_rawDescData
+}
+
+var file_sf_substreams_rpc_v2_cache_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_sf_substreams_rpc_v2_cache_proto_goTypes = []interface{}{
+	(*CachedRangesRequest)(nil),  // 0: sf.substreams.rpc.v2.CachedRangesRequest
+	(*CachedRangesResponse)(nil), // 1: sf.substreams.rpc.v2.CachedRangesResponse
+	(*CachedOutputsRequest)(nil), // 2: sf.substreams.rpc.v2.CachedOutputsRequest
+	(*MissingCachedRanges)(nil),  // 3: sf.substreams.rpc.v2.MissingCachedRanges
+	(*v1.Modules)(nil),           // 4: sf.substreams.v1.Modules
+	(*BlockRange)(nil),           // 5: sf.substreams.rpc.v2.BlockRange
+	(*Response)(nil),             // 6: sf.substreams.rpc.v2.Response
+}
+var file_sf_substreams_rpc_v2_cache_proto_depIdxs = []int32{
+	4, // 0: sf.substreams.rpc.v2.CachedRangesRequest.modules:type_name -> sf.substreams.v1.Modules
+	5, // 1: sf.substreams.rpc.v2.CachedRangesResponse.ranges:type_name -> sf.substreams.rpc.v2.BlockRange
+	4, // 2: sf.substreams.rpc.v2.CachedOutputsRequest.modules:type_name -> sf.substreams.v1.Modules
+	5, // 3: sf.substreams.rpc.v2.MissingCachedRanges.missing_ranges:type_name -> sf.substreams.rpc.v2.BlockRange
+	5, // 4: sf.substreams.rpc.v2.MissingCachedRanges.cached_ranges:type_name -> sf.substreams.rpc.v2.BlockRange
+	0, // 5: sf.substreams.rpc.v2.Cache.CachedRanges:input_type -> sf.substreams.rpc.v2.CachedRangesRequest
+	2, // 6: sf.substreams.rpc.v2.Cache.CachedOutputs:input_type -> sf.substreams.rpc.v2.CachedOutputsRequest
+	1, // 7: sf.substreams.rpc.v2.Cache.CachedRanges:output_type -> sf.substreams.rpc.v2.CachedRangesResponse
+	6, // 8: sf.substreams.rpc.v2.Cache.CachedOutputs:output_type -> sf.substreams.rpc.v2.Response
+	7, // [7:9] is the sub-list for method output_type
+	5, // [5:7] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_sf_substreams_rpc_v2_cache_proto_init() }
+func file_sf_substreams_rpc_v2_cache_proto_init() {
+	if File_sf_substreams_rpc_v2_cache_proto != nil {
+		return
+	}
+	file_sf_substreams_rpc_v2_service_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_sf_substreams_rpc_v2_cache_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CachedRangesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_rpc_v2_cache_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CachedRangesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_rpc_v2_cache_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CachedOutputsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sf_substreams_rpc_v2_cache_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MissingCachedRanges); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_sf_substreams_rpc_v2_cache_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_sf_substreams_rpc_v2_cache_proto_goTypes,
+		DependencyIndexes: file_sf_substreams_rpc_v2_cache_proto_depIdxs,
+		MessageInfos:      file_sf_substreams_rpc_v2_cache_proto_msgTypes,
+	}.Build()
+	File_sf_substreams_rpc_v2_cache_proto = out.File
+	file_sf_substreams_rpc_v2_cache_proto_rawDesc = nil
+	file_sf_substreams_rpc_v2_cache_proto_goTypes = nil
+	file_sf_substreams_rpc_v2_cache_proto_depIdxs = nil
+}
@@ -0,0 +1,136 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: sf/substreams/rpc/v2/cache.proto
+
+package pbsubstreamsrpcconnect
+
+import (
+	context "context"
+	errors "errors"
+	connect_go "github.com/bufbuild/connect-go"
+	v2 "github.com/streamingfast/substreams/pb/sf/substreams/rpc/v2"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect_go.IsAtLeastVersion0_1_0
+
+const (
+	// CacheName is the fully-qualified name of the Cache service.
+	CacheName = "sf.substreams.rpc.v2.Cache"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// CacheCachedRangesProcedure is the fully-qualified name of the Cache's CachedRanges RPC.
+	CacheCachedRangesProcedure = "/sf.substreams.rpc.v2.Cache/CachedRanges"
+	// CacheCachedOutputsProcedure is the fully-qualified name of the Cache's CachedOutputs RPC.
+	CacheCachedOutputsProcedure = "/sf.substreams.rpc.v2.Cache/CachedOutputs"
+)
+
+// CacheClient is a client for the sf.substreams.rpc.v2.Cache service.
+type CacheClient interface {
+	// CachedRanges lists the ranges of blocks for which the outputs of a module are cached.
+	CachedRanges(context.Context, *connect_go.Request[v2.CachedRangesRequest]) (*connect_go.Response[v2.CachedRangesResponse], error)
+	// CachedOutputs streams the cached outputs of a module over a range of blocks, like
+	// `Blocks` does with final blocks only. Its cursors can be used to resume the stream
+	// with `Blocks`, and the cursors of final blocks from `Blocks` to resume it.
+	//
+	// When the outputs of some blocks of the range are not cached, the request fails with
+	// FAILED_PRECONDITION, a `MissingCachedRanges` in the details of the error.
+	CachedOutputs(context.Context, *connect_go.Request[v2.CachedOutputsRequest]) (*connect_go.ServerStreamForClient[v2.Response], error)
+}
+
+// NewCacheClient constructs a client for the sf.substreams.rpc.v2.Cache service. By default, it
+// uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and sends
+// uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC() or
+// connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewCacheClient(httpClient connect_go.HTTPClient, baseURL string, opts ...connect_go.ClientOption) CacheClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &cacheClient{
+		cachedRanges: connect_go.NewClient[v2.CachedRangesRequest, v2.CachedRangesResponse](
+			httpClient,
+			baseURL+CacheCachedRangesProcedure,
+			opts...,
+		),
+		cachedOutputs: connect_go.NewClient[v2.CachedOutputsRequest, v2.Response](
+			httpClient,
+			baseURL+CacheCachedOutputsProcedure,
+			opts...,
+		),
+	}
+}
+
+// cacheClient implements CacheClient.
+type cacheClient struct {
+	cachedRanges  *connect_go.Client[v2.CachedRangesRequest, v2.CachedRangesResponse]
+	cachedOutputs *connect_go.Client[v2.CachedOutputsRequest, v2.Response]
+}
+
+// CachedRanges calls sf.substreams.rpc.v2.Cache.CachedRanges.
+func (c *cacheClient) CachedRanges(ctx context.Context, req *connect_go.Request[v2.CachedRangesRequest]) (*connect_go.Response[v2.CachedRangesResponse], error) {
+	return c.cachedRanges.CallUnary(ctx, req)
+}
+
+// CachedOutputs calls sf.substreams.rpc.v2.Cache.CachedOutputs.
+func (c *cacheClient) CachedOutputs(ctx context.Context, req *connect_go.Request[v2.CachedOutputsRequest]) (*connect_go.ServerStreamForClient[v2.Response], error) {
+	return c.cachedOutputs.CallServerStream(ctx, req)
+}
+
+// CacheHandler is an implementation of the sf.substreams.rpc.v2.Cache service.
+type CacheHandler interface {
+	// CachedRanges lists the ranges of blocks for which the outputs of a module are cached.
+	CachedRanges(context.Context, *connect_go.Request[v2.CachedRangesRequest]) (*connect_go.Response[v2.CachedRangesResponse], error)
+	// CachedOutputs streams the cached outputs of a module over a range of blocks, like
+	// `Blocks` does with final blocks only. Its cursors can be used to resume the stream
+	// with `Blocks`, and the cursors of final blocks from `Blocks` to resume it.
+	//
+	// When the outputs of some blocks of the range are not cached, the request fails with
+	// FAILED_PRECONDITION, a `MissingCachedRanges` in the details of the error.
+	CachedOutputs(context.Context, *connect_go.Request[v2.CachedOutputsRequest], *connect_go.ServerStream[v2.Response]) error
+}
+
+// NewCacheHandler builds an HTTP handler from the service implementation. It returns the path on
+// which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewCacheHandler(svc CacheHandler, opts ...connect_go.HandlerOption) (string, http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle(CacheCachedRangesProcedure, connect_go.NewUnaryHandler(
+		CacheCachedRangesProcedure,
+		svc.CachedRanges,
+		opts...,
+	))
+	mux.Handle(CacheCachedOutputsProcedure, connect_go.NewServerStreamHandler(
+		CacheCachedOutputsProcedure,
+		svc.CachedOutputs,
+		opts...,
+	))
+	return "/sf.substreams.rpc.v2.Cache/", mux
+}
+
+// UnimplementedCacheHandler returns CodeUnimplemented from all methods.
+type UnimplementedCacheHandler struct{}
+
+func (UnimplementedCacheHandler) CachedRanges(context.Context, *connect_go.Request[v2.CachedRangesRequest]) (*connect_go.Response[v2.CachedRangesResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("sf.substreams.rpc.v2.Cache.CachedRanges is not implemented"))
+}
+
+func (UnimplementedCacheHandler) CachedOutputs(context.Context, *connect_go.Request[v2.CachedOutputsRequest], *connect_go.ServerStream[v2.Response]) error {
+	return connect_go.NewError(connect_go.CodeUnimplemented, errors.New("sf.substreams.rpc.v2.Cache.CachedOutputs is not implemented"))
+}
@@ -83,6 +83,9 @@ func (e *BaseExecutor) wasmCall(outputGetter execout.ExecutionOutputGetter) (cal
 		call = wasm.NewCall(clock, e.moduleName, e.entrypoint, stats, e.wasmArguments)
 		inst, err = e.wasmModule.ExecuteNewCall(e.ctx, call, e.cachedInstance, e.wasmArguments)
 		//Timer += time.Since(t0)
+		if storeErr := call.StoreErr(); storeErr != nil {
+			return nil, fmt.Errorf("block %d: module %q: %w", clock.Number, e.moduleName, storeErr)
+		}
 		if panicErr := call.Err(); panicErr != nil {
 			errExecutor := &ErrorExecutor{
 				message:    panicErr.Error(),
@@ -90,6 +93,14 @@ func (e *BaseExecutor) wasmCall(outputGetter execout.ExecutionOutputGetter) (cal
 			}
 			return nil, fmt.Errorf("block %d: module %q: %w: %s", clock.Number, e.moduleName, ErrWasmDeterministicExec, errExecutor.Error())
 		}
+		var determinismErr *wasm.DeterminismError
+		if errors.As(err, &determinismErr) {
+			return nil, fmt.Errorf("block %d: module %q: %w", clock.Number, e.moduleName, determinismErr)
+		}
+		var wasmErr *wasm.Error
+		if errors.As(err, &wasmErr) {
+			return nil, fmt.Errorf("block %d: module %q: %w: %s", clock.Number, e.moduleName, ErrWasmDeterministicExec, wasmErr.Reason)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("block %d: module %q: general wasm execution failed: %v", clock.Number, e.moduleName, err)
 		}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"github.com/streamingfast/substreams/storage/execout"
 )
 
@@ -13,7 +14,7 @@ type ModuleExecutor interface {
 	String() string
 	Close(ctx context.Context) error
 	run(ctx context.Context, reader execout.ExecutionOutputGetter) (out []byte, moduleOutputData *pbssinternal.ModuleOutput, err error)
-	applyCachedOutput(value []byte) error
+	applyCachedOutput(clock *pbsubstreams.Clock, value []byte) error
 	toModuleOutput(data []byte) (*pbssinternal.ModuleOutput, error)
 	HasValidOutput() bool
 
@@ -7,6 +7,7 @@ import (
 	"google.golang.org/protobuf/types/known/anypb"
 
 	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"github.com/streamingfast/substreams/storage/execout"
 
 	"github.com/streamingfast/substreams/reqctx"
@@ -31,7 +32,7 @@ func (e *MapperModuleExecutor) String() string { return e.Name() }
 
 // todo: this is strange because it has to be done on both the store and the mapper
 // and in this case, we don't do anything
-func (e *MapperModuleExecutor) applyCachedOutput([]byte) error { return nil }
+func (e *MapperModuleExecutor) applyCachedOutput(*pbsubstreams.Clock, []byte) error { return nil }
 
 func (e *MapperModuleExecutor) run(ctx context.Context, reader execout.ExecutionOutputGetter) (out []byte, moduleOutputData *pbssinternal.ModuleOutput, err error) {
 	ctx, span := reqctx.WithModuleExecutionSpan(ctx, "exec_map")
@@ -36,7 +36,7 @@ func RunModule(ctx context.Context, executor ModuleExecutor, execOutput execout.
 	span.SetAttributes(attribute.Bool("substreams.module.cached", cached))
 
 	if cached {
-		if err = executor.applyCachedOutput(outputBytes); err != nil {
+		if err = executor.applyCachedOutput(execOutput.Clock(), outputBytes); err != nil {
 			return nil, nil, fmt.Errorf("apply cached output: %w", err)
 		}
 
@@ -64,7 +64,7 @@ func (t *MockModuleExecutor) run(ctx context.Context, reader execout.ExecutionOu
 	return nil, nil, fmt.Errorf("not implemented")
 }
 
-func (t *MockModuleExecutor) applyCachedOutput(value []byte) error {
+func (t *MockModuleExecutor) applyCachedOutput(_ *pbsubstreams.Clock, value []byte) error {
 	if t.ApplyFunc != nil {
 		return t.ApplyFunc(value)
 	}
@@ -152,6 +152,9 @@ func TestModuleExecutorRunner_Run_CachedOutput(t *testing.T) {
 		},
 	}
 	output := &MockExecOutput{
+		clockFunc: func() *pbsubstreams.Clock {
+			return &pbsubstreams.Clock{Number: 42}
+		},
 		cacheMap: map[string][]byte{
 			"test": []byte("cached"),
 		},
@@ -3,10 +3,12 @@ package exec
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 
 	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"github.com/streamingfast/substreams/reqctx"
 	"github.com/streamingfast/substreams/storage/execout"
 	"github.com/streamingfast/substreams/storage/store"
@@ -26,13 +28,22 @@ func NewStoreModuleExecutor(baseExecutor *BaseExecutor, outputStore store.DeltaA
 func (e *StoreModuleExecutor) Name() string   { return e.moduleName }
 func (e *StoreModuleExecutor) String() string { return e.Name() }
 
-func (e *StoreModuleExecutor) applyCachedOutput(value []byte) error {
+func (e *StoreModuleExecutor) applyCachedOutput(clock *pbsubstreams.Clock, value []byte) error {
 	deltas := &pbssinternal.StoreDeltas{}
 	err := proto.Unmarshal(value, deltas)
 	if err != nil {
 		return fmt.Errorf("unmarshalling output deltas: %w", err)
 	}
+	// The cached deltas hold the evictions of the block, evicting again only
+	// reaches its bundle boundary
+	expirable, isExpirable := e.outputStore.(store.Expirable)
+	if isExpirable {
+		expirable.SetBlock(clock.Number)
+	}
 	e.outputStore.SetDeltas(deltas.StoreDeltas)
+	if isExpirable {
+		expirable.EvictExpiredKeys()
+	}
 	return nil
 }
 
@@ -40,13 +51,37 @@ func (e *StoreModuleExecutor) run(ctx context.Context, reader execout.ExecutionO
 	ctx, span := reqctx.WithModuleExecutionSpan(ctx, "exec_store")
 	defer span.EndWithErr(&err)
 
+	expirable, isExpirable := e.outputStore.(store.Expirable)
+	if isExpirable {
+		expirable.SetBlock(reader.Clock().Number)
+	}
+
 	if _, err := e.wasmCall(reader); err != nil {
 		return nil, nil, fmt.Errorf("store wasm call: %w", err)
 	}
 
+	if isExpirable {
+		e.evictExpiredKeys(ctx, expirable)
+	}
+
+	if limited, ok := e.outputStore.(store.Limited); ok {
+		if err := limited.CheckQuota(); err != nil {
+			return nil, nil, fmt.Errorf("block %d: module %q: %w", reader.Clock().Number, e.moduleName, err)
+		}
+	}
+
 	return e.wrapDeltas()
 }
 
+func (e *StoreModuleExecutor) evictExpiredKeys(ctx context.Context, expirable store.Expirable) {
+	t0 := time.Now()
+	if evicted := expirable.EvictExpiredKeys(); evicted > 0 {
+		if outputStore, ok := e.outputStore.(store.Store); ok {
+			reqctx.ReqStats(ctx).RecordModuleStoreEviction(e.moduleName, outputStore.SizeBytes(), time.Since(t0))
+		}
+	}
+}
+
 func (e *StoreModuleExecutor) HasValidOutput() bool {
 	_, ok := e.outputStore.(*store.FullKV)
 	return ok
@@ -0,0 +1,76 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/manifest"
+	"github.com/streamingfast/substreams/metrics"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/reqctx"
+	"github.com/streamingfast/substreams/storage/store"
+	"github.com/streamingfast/substreams/wasm"
+)
+
+type MockInstance struct{}
+
+func (i *MockInstance) Cleanup(ctx context.Context) error { return nil }
+func (i *MockInstance) Close(ctx context.Context) error   { return nil }
+
+type MockWasmModule struct {
+	ExecuteFunc func(call *wasm.Call)
+}
+
+func (m *MockWasmModule) NewInstance(ctx context.Context) (wasm.Instance, error) {
+	return &MockInstance{}, nil
+}
+
+func (m *MockWasmModule) ExecuteNewCall(ctx context.Context, call *wasm.Call, cachedInstance wasm.Instance, arguments []wasm.Argument) (wasm.Instance, error) {
+	m.ExecuteFunc(call)
+	return &MockInstance{}, nil
+}
+
+func (m *MockWasmModule) Close(ctx context.Context) error { return nil }
+
+// A partial store exceeding its quota fails the block which exceeds it,
+// partway through its segment, rather than at the merge of the segment.
+func TestStoreModuleExecutor_Run_PartialStoreQuota(t *testing.T) {
+	ctx := reqctx.WithReqStats(context.Background(), metrics.NewReqStats(&metrics.Config{}, zap.NewNop()))
+
+	config, err := store.NewConfig("test", 0, "test.module.hash", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, manifest.OutputValueTypeString, dstore.NewMockStore(nil), "")
+	require.NoError(t, err)
+	config.SetQuota(0, 3)
+	partial := config.NewPartialKV(10, zap.NewNop())
+
+	module := &MockWasmModule{ExecuteFunc: func(call *wasm.Call) {
+		partial.Set(0, fmt.Sprintf("key:%d", call.Clock.Number), "value")
+	}}
+	arguments := []wasm.Argument{wasm.NewParamsInput("")}
+	executor := NewStoreModuleExecutor(NewBaseExecutor(ctx, "test", module, false, arguments, "map_test", nil), partial)
+
+	var failedAt uint64
+	for blockNum := uint64(10); blockNum < 20; blockNum++ {
+		output := &MockExecOutput{
+			clockFunc: func() *pbsubstreams.Clock { return &pbsubstreams.Clock{Number: blockNum} },
+			cacheMap:  map[string][]byte{},
+		}
+		_, _, err = executor.run(ctx, output)
+		partial.Reset()
+		if err != nil {
+			failedAt = blockNum
+			break
+		}
+	}
+
+	var quotaErr *store.QuotaExceededError
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, uint64(13), failedAt)
+	assert.Equal(t, uint64(4), quotaErr.KeyCount)
+	assert.EqualError(t, err, `block 13: module "test": store "test" exceeded its quota of 3 keys, at 4 keys (44 bytes)`)
+}
@@ -331,7 +331,7 @@ func toRPCStoreModuleOutputs(in *pbssinternal.ModuleOutput) (out *pbsubstreamsrp
 
 	return &pbsubstreamsrpc.StoreModuleOutput{
 		Name:             in.ModuleName,
-		DebugStoreDeltas: toRPCDeltas(deltas),
+		DebugStoreDeltas: ToRPCDeltas(deltas),
 		DebugInfo: &pbsubstreamsrpc.OutputDebugInfo{
 			Logs:          in.Logs,
 			LogsTruncated: in.DebugLogsTruncated,
@@ -340,7 +340,9 @@ func toRPCStoreModuleOutputs(in *pbssinternal.ModuleOutput) (out *pbsubstreamsrp
 	}
 }
 
-func toRPCDeltas(in *pbssinternal.StoreDeltas) (out []*pbsubstreamsrpc.StoreDelta) {
+// ToRPCDeltas converts the deltas of a store, as cached and exchanged between
+// tiers, to the ones sent to clients.
+func ToRPCDeltas(in *pbssinternal.StoreDeltas) (out []*pbsubstreamsrpc.StoreDelta) {
 	if len(in.StoreDeltas) == 0 {
 		return nil
 	}
@@ -455,7 +457,7 @@ func (p *Pipeline) buildModuleExecutors(ctx context.Context) ([][]exec.ModuleExe
 					continue
 				}
 				code := reqModules.Binaries[module.BinaryIndex]
-				m, err := p.wasmRuntime.NewModule(ctx, code.Content)
+				m, err := p.wasmRuntime.NewModuleWithBinaryType(ctx, code.Content, code.Type)
 				if err != nil {
 					return nil, fmt.Errorf("new wasm module: %w", err)
 				}
@@ -172,7 +172,7 @@ func Test_resolveStartBlockNum(t *testing.T) {
 	}
 }
 
-func Test_computeLiveHandoffBlockNum(t *testing.T) {
+func Test_ComputeLiveHandoffBlockNum(t *testing.T) {
 	tests := []struct {
 		liveHubAvailable bool
 		recentBlockNum   uint64
@@ -200,7 +200,7 @@ func Test_computeLiveHandoffBlockNum(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run("", func(t *testing.T) {
-			got, err := computeLiveHandoffBlockNum(
+			got, err := ComputeLiveHandoffBlockNum(
 				test.prodMode,
 				test.startBlockNum,
 				test.stopBlockNum,
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/pipeline/outputmodules"
+	"github.com/streamingfast/substreams/service/config"
+	"github.com/streamingfast/substreams/storage/cachegc"
+)
+
+// trackCacheAccess rewrites the access markers of the modules used by
+// `outputGraph` while a request reads their cached files, when the access
+// markers are enabled. The returned function stops it.
+func trackCacheAccess(ctx context.Context, runtimeConfig config.RuntimeConfig, cacheStore dstore.Store, outputGraph *outputmodules.Graph, traceID string, logger *zap.Logger) (stop func()) {
+	moduleHashes := make([]string, 0, len(outputGraph.UsedModules()))
+	for _, module := range outputGraph.UsedModules() {
+		moduleHashes = append(moduleHashes, outputGraph.ModuleHashes().Get(module.Name))
+	}
+	return trackModulesAccess(ctx, runtimeConfig, cacheStore, moduleHashes, traceID, logger)
+}
+
+// trackModulesAccess is trackCacheAccess for the modules of `moduleHashes`.
+func trackModulesAccess(ctx context.Context, runtimeConfig config.RuntimeConfig, cacheStore dstore.Store, moduleHashes []string, traceID string, logger *zap.Logger) (stop func()) {
+	interval := runtimeConfig.CacheAccessMarkerInterval
+	if interval <= 0 {
+		return func() {}
+	}
+	return cachegc.TrackAccess(ctx, cacheStore, moduleHashes, interval, traceID, logger)
+}
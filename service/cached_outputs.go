@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/streamingfast/bstream"
+	"github.com/streamingfast/dauth"
+	"github.com/streamingfast/dmetering"
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/logging"
+	tracing "github.com/streamingfast/sf-tracing"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/streamingfast/substreams/block"
+	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	pbsubstreamsrpc "github.com/streamingfast/substreams/pb/sf/substreams/rpc/v2"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline"
+	"github.com/streamingfast/substreams/pipeline/outputmodules"
+	"github.com/streamingfast/substreams/reqctx"
+	"github.com/streamingfast/substreams/storage/execout"
+	pboutput "github.com/streamingfast/substreams/storage/execout/pb"
+)
+
+var isValidModuleHash = regexp.MustCompile(`^[a-f0-9]{40}$`).MatchString
+
+// CachedRanges lists the ranges of blocks whose outputs are cached for a
+// module, identified by its hash or by the modules of a request.
+func (s *Tier1Service) CachedRanges(
+	ctx context.Context,
+	req *connect.Request[pbsubstreamsrpc.CachedRangesRequest],
+) (*connect.Response[pbsubstreamsrpc.CachedRangesResponse], error) {
+	logger := reqctx.Logger(ctx).Named("tier1")
+	request := req.Msg
+
+	name, moduleHash := request.ModuleHash, request.ModuleHash
+	var initialBlock uint64
+	if moduleHash == "" {
+		module, hash, err := cachedOutputModule(request.OutputModule, request.Modules)
+		if err != nil {
+			return nil, toConnectError(ctx, err)
+		}
+		name, moduleHash, initialBlock = module.Name, hash, module.InitialBlock
+	} else if !isValidModuleHash(moduleHash) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid module hash %q", moduleHash))
+	}
+	logger.Info("incoming Substreams CachedRanges request", zap.String("module", name), zap.String("module_hash", moduleHash))
+
+	cacheStore, err := s.requestCacheStore(ctx)
+	if err != nil {
+		return nil, toConnectError(ctx, err)
+	}
+	config, err := execout.NewConfig(name, initialBlock, pbsubstreams.ModuleKindMap, moduleHash, cacheStore, logger)
+	if err != nil {
+		return nil, toConnectError(ctx, fmt.Errorf("configuring outputs: %w", err))
+	}
+	cached, err := config.CachedRanges(ctx)
+	if err != nil {
+		return nil, toConnectError(ctx, err)
+	}
+
+	return connect.NewResponse(&pbsubstreamsrpc.CachedRangesResponse{
+		ModuleHash: moduleHash,
+		Ranges:     toRPCBlockRanges(cached),
+	}), nil
+}
+
+// CachedOutputs streams the cached outputs of a module without executing
+// it, failing with the ranges of blocks not cached when some are missing.
+func (s *Tier1Service) CachedOutputs(
+	ctx context.Context,
+	req *connect.Request[pbsubstreamsrpc.CachedOutputsRequest],
+	stream *connect.ServerStream[pbsubstreamsrpc.Response],
+) error {
+	var err error
+
+	logger := reqctx.Logger(ctx).Named("tier1")
+
+	ctx = logging.WithLogger(ctx, logger)
+	ctx = reqctx.WithTracer(ctx, s.tracer)
+	ctx = dmetering.WithBytesMeter(ctx)
+
+	ctx, span := reqctx.WithSpan(ctx, "substreams/tier1/cached_outputs")
+	defer span.EndWithErr(&err)
+
+	request := req.Msg
+	logger.Info("incoming Substreams CachedOutputs request",
+		zap.Uint64("start_block", request.StartBlockNum),
+		zap.Uint64("stop_block", request.StopBlockNum),
+		zap.String("cursor", request.StartCursor),
+		zap.String("output_module", request.OutputModule),
+	)
+
+	err = s.cachedOutputs(ctx, request, stream)
+	if connectErr := toConnectError(ctx, err); connectErr != nil {
+		logger.Info("CachedOutputs request completed with error", zap.Error(connectErr))
+		return connectErr
+	}
+
+	logger.Info("CachedOutputs request completed without error")
+	return nil
+}
+
+func (s *Tier1Service) cachedOutputs(ctx context.Context, request *pbsubstreamsrpc.CachedOutputsRequest, stream *connect.ServerStream[pbsubstreamsrpc.Response]) error {
+	logger := reqctx.Logger(ctx)
+
+	module, moduleHash, err := cachedOutputModule(request.OutputModule, request.Modules)
+	if err != nil {
+		return err
+	}
+	if request.StopBlockNum == 0 {
+		return status.Error(codes.InvalidArgument, "a stop block is required to stream cached outputs")
+	}
+
+	startBlock := request.StartBlockNum
+	if request.StartCursor != "" {
+		cursor, err := bstream.CursorFromOpaque(request.StartCursor)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid StartCursor %q: %s", request.StartCursor, err.Error())
+		}
+		if !cursor.IsOnFinalBlock() {
+			return status.Errorf(codes.InvalidArgument, "StartCursor %q is not on a final block, resume it with Blocks", request.StartCursor)
+		}
+		startBlock = cursor.Block.Num() + 1
+	} else if startBlock >= request.StopBlockNum {
+		return status.Errorf(codes.InvalidArgument, "start block %d is not before stop block %d", startBlock, request.StopBlockNum)
+	}
+	if startBlock < module.InitialBlock {
+		startBlock = module.InitialBlock
+	}
+
+	cacheStore, err := s.requestCacheStore(ctx)
+	if err != nil {
+		return err
+	}
+	stopTracking := trackModulesAccess(ctx, s.runtimeConfig, cacheStore, []string{moduleHash}, tracing.GetTraceID(ctx).String(), logger)
+	defer stopTracking()
+
+	config, err := execout.NewConfig(module.Name, module.InitialBlock, module.ModuleKind(), moduleHash, cacheStore, logger)
+	if err != nil {
+		return fmt.Errorf("configuring outputs: %w", err)
+	}
+	cached, err := config.CachedRanges(ctx)
+	if err != nil {
+		return err
+	}
+	if missing := execout.MissingRanges(cached, startBlock, request.StopBlockNum); len(missing) != 0 {
+		return missingCachedRangesError(module.Name, moduleHash, missing, cached)
+	}
+
+	auth := dauth.FromContext(ctx)
+	meter := dmetering.GetBytesMeter(ctx)
+	send := func(resp *pbsubstreamsrpc.Response) error {
+		if err := stream.Send(resp); err != nil {
+			logger.Info("unable to send cached output probably due to client disconnecting", zap.Error(err))
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		sendMetering(meter, auth.UserID(), auth.APIKeyID(), auth.RealIP(), "sf.substreams.rpc.v2/CachedOutputs", resp)
+		return nil
+	}
+
+	err = send(&pbsubstreamsrpc.Response{
+		Message: &pbsubstreamsrpc.Response_Session{
+			Session: &pbsubstreamsrpc.SessionInit{
+				TraceId:            tracing.GetTraceID(ctx).String(),
+				ResolvedStartBlock: startBlock,
+				LinearHandoffBlock: request.StopBlockNum,
+			},
+		},
+	})
+	if err != nil || startBlock >= request.StopBlockNum {
+		return err
+	}
+
+	readUntil, err := config.ReadOutputs(ctx, startBlock, request.StopBlockNum, func(item *pboutput.Item) error {
+		data, err := toCachedBlockScopedData(module, item)
+		if err != nil {
+			return fmt.Errorf("output of block %d: %w", item.BlockNum, err)
+		}
+		return send(&pbsubstreamsrpc.Response{Message: &pbsubstreamsrpc.Response_BlockScopedData{BlockScopedData: data}})
+	})
+	if err != nil {
+		return err
+	}
+	if readUntil < request.StopBlockNum {
+		// The outputs were deleted while streaming them.
+		return missingCachedRangesError(module.Name, moduleHash, block.Ranges{block.NewRange(readUntil, request.StopBlockNum)}, cached)
+	}
+	return nil
+}
+
+// requestCacheStore returns the store of the cache tag of the request.
+func (s *Tier1Service) requestCacheStore(ctx context.Context) (dstore.Store, error) {
+	cacheTag, err := s.requestCacheTag(ctx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	cacheStore, err := s.runtimeConfig.BaseObjectStore.SubStore(cacheTag)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "internal error setting store: %s", err)
+	}
+	return cacheStore, nil
+}
+
+// cachedOutputModule returns the output module of a request and its hash.
+func cachedOutputModule(outputModule string, modules *pbsubstreams.Modules) (*pbsubstreams.Module, string, error) {
+	if modules == nil {
+		return nil, "", status.Error(codes.InvalidArgument, "missing modules in request")
+	}
+	outputGraph, err := outputmodules.NewOutputModuleGraph(outputModule, true, modules)
+	if err != nil {
+		return nil, "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	return outputGraph.OutputModule(), outputGraph.ModuleHashes().Get(outputModule), nil
+}
+
+// missingCachedRangesError is a connect error, to carry the missing ranges
+// in its details to both connect and gRPC clients.
+func missingCachedRangesError(moduleName, moduleHash string, missing, cached block.Ranges) error {
+	out := connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("outputs of module %q (%s) are not cached for blocks %s", moduleName, moduleHash, missing))
+	detail, err := connect.NewErrorDetail(&pbsubstreamsrpc.MissingCachedRanges{
+		ModuleHash:    moduleHash,
+		MissingRanges: toRPCBlockRanges(missing),
+		CachedRanges:  toRPCBlockRanges(cached),
+	})
+	if err == nil {
+		out.AddDetail(detail)
+	}
+	return out
+}
+
+// toConnectError turns `err` into a connect error with the code `toGRPCError`
+// gives it, connect reporting any other error as unknown.
+func toConnectError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		return connectErr
+	}
+	st := status.Convert(toGRPCError(ctx, err))
+	return connect.NewError(connect.Code(st.Code()), errors.New(st.Message()))
+}
+
+func toRPCBlockRanges(in block.Ranges) []*pbsubstreamsrpc.BlockRange {
+	out := make([]*pbsubstreamsrpc.BlockRange, len(in))
+	for i, r := range in {
+		out[i] = &pbsubstreamsrpc.BlockRange{StartBlock: r.StartBlock, EndBlock: r.ExclusiveEndBlock}
+	}
+	return out
+}
+
+// toCachedBlockScopedData builds the data of a final block from its cached
+// output, with the same cursor as outputs served from the cache by `Blocks`.
+func toCachedBlockScopedData(module *pbsubstreams.Module, item *pboutput.Item) (*pbsubstreamsrpc.BlockScopedData, error) {
+	blockRef := bstream.NewBlockRef(item.BlockId, item.BlockNum)
+	cursor := bstream.Cursor{
+		Step:      bstream.StepNewIrreversible,
+		Block:     blockRef,
+		LIB:       blockRef,
+		HeadBlock: blockRef,
+	}
+	out := &pbsubstreamsrpc.BlockScopedData{
+		Cursor:           cursor.ToOpaque(),
+		Clock:            &pbsubstreams.Clock{Id: item.BlockId, Number: item.BlockNum, Timestamp: item.Timestamp},
+		FinalBlockHeight: item.BlockNum,
+	}
+
+	debugInfo := &pbsubstreamsrpc.OutputDebugInfo{Cached: true}
+	if module.GetKindStore() != nil {
+		deltas := &pbssinternal.StoreDeltas{}
+		if err := proto.Unmarshal(item.Payload, deltas); err != nil {
+			return nil, fmt.Errorf("unmarshalling store deltas: %w", err)
+		}
+		out.DebugStoreOutputs = []*pbsubstreamsrpc.StoreModuleOutput{{
+			Name:             module.Name,
+			DebugStoreDeltas: pipeline.ToRPCDeltas(deltas),
+			DebugInfo:        debugInfo,
+		}}
+		return out, nil
+	}
+
+	out.Output = &pbsubstreamsrpc.MapModuleOutput{
+		Name:      module.Name,
+		MapOutput: &anypb.Any{TypeUrl: "type.googleapis.com/" + strings.TrimPrefix(module.Output.Type, "proto:"), Value: item.Payload},
+		DebugInfo: debugInfo,
+	}
+	return out, nil
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/streamingfast/bstream"
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/streamingfast/substreams/block"
+	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	pbsubstreamsrpc "github.com/streamingfast/substreams/pb/sf/substreams/rpc/v2"
+	ssconnect "github.com/streamingfast/substreams/pb/sf/substreams/rpc/v2/pbsubstreamsrpcconnect"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/outputmodules"
+	"github.com/streamingfast/substreams/service/config"
+	"github.com/streamingfast/substreams/storage/cachegc"
+	"github.com/streamingfast/substreams/storage/execout"
+)
+
+func testCachedModules() *pbsubstreams.Modules {
+	return &pbsubstreams.Modules{
+		Binaries: []*pbsubstreams.Binary{{Type: "wasm/rust-v1", Content: []byte{}}},
+		Modules: []*pbsubstreams.Module{
+			{
+				Name:         "map_transfers",
+				Kind:         &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{OutputType: "proto:test.Transfers"}},
+				Inputs:       []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Source_{Source: &pbsubstreams.Module_Input_Source{Type: "sf.substreams.v1.test.Block"}}}},
+				Output:       &pbsubstreams.Module_Output{Type: "proto:test.Transfers"},
+				InitialBlock: 100,
+			},
+			{
+				Name:         "store_balances",
+				Kind:         &pbsubstreams.Module_KindStore_{KindStore: &pbsubstreams.Module_KindStore{UpdatePolicy: pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, ValueType: "string"}},
+				Inputs:       []*pbsubstreams.Module_Input{{Input: &pbsubstreams.Module_Input_Map_{Map: &pbsubstreams.Module_Input_Map{ModuleName: "map_transfers"}}}},
+				InitialBlock: 100,
+			},
+		},
+	}
+}
+
+// writeCachedOutputs caches outputs of `moduleName` for the blocks of
+// `ranges`, returning the hash of the module.
+func writeCachedOutputs(t *testing.T, cacheStore dstore.Store, moduleName string, ranges ...*block.Range) string {
+	graph, err := outputmodules.NewOutputModuleGraph(moduleName, true, testCachedModules())
+	require.NoError(t, err)
+	module, moduleHash := graph.OutputModule(), graph.ModuleHashes().Get(moduleName)
+
+	outputs, err := execout.NewConfig(module.Name, module.InitialBlock, module.ModuleKind(), moduleHash, cacheStore, zap.NewNop())
+	require.NoError(t, err)
+	for _, r := range ranges {
+		file := outputs.NewFile(r)
+		for i := r.StartBlock; i < r.ExclusiveEndBlock; i++ {
+			payload := []byte(fmt.Sprintf("transfers %d", i))
+			if module.GetKindStore() != nil {
+				payload, err = proto.Marshal(&pbssinternal.StoreDeltas{StoreDeltas: []*pbssinternal.StoreDelta{
+					{Operation: pbssinternal.StoreDelta_CREATE, Ordinal: 1, Key: fmt.Sprintf("balance:%d", i), NewValue: []byte("1")},
+				}})
+				require.NoError(t, err)
+			}
+			file.SetItem(&pbsubstreams.Clock{Number: i, Id: fmt.Sprintf("id%d", i)}, payload)
+		}
+		require.NoError(t, file.Save(context.Background()))
+	}
+	return moduleHash
+}
+
+func newTestCacheClient(t *testing.T, cacheAccessMarkerInterval time.Duration) (ssconnect.CacheClient, dstore.Store) {
+	baseStore, err := dstore.NewStore("file://"+t.TempDir(), "", "", false)
+	require.NoError(t, err)
+	cacheStore, err := baseStore.SubStore("tag")
+	require.NoError(t, err)
+
+	s := &Tier1Service{
+		runtimeConfig: config.RuntimeConfig{BaseObjectStore: baseStore, DefaultCacheTag: "tag", CacheAccessMarkerInterval: cacheAccessMarkerInterval},
+		logger:        zap.NewNop(),
+	}
+	mux := http.NewServeMux()
+	mux.Handle(ssconnect.NewCacheHandler(s))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return ssconnect.NewCacheClient(server.Client(), server.URL), cacheStore
+}
+
+func receiveCachedOutputs(t *testing.T, client ssconnect.CacheClient, request *pbsubstreamsrpc.CachedOutputsRequest) ([]*pbsubstreamsrpc.BlockScopedData, error) {
+	stream, err := client.CachedOutputs(context.Background(), connect.NewRequest(request))
+	require.NoError(t, err)
+	defer stream.Close()
+
+	var out []*pbsubstreamsrpc.BlockScopedData
+	for stream.Receive() {
+		if data := stream.Msg().GetBlockScopedData(); data != nil {
+			out = append(out, data)
+		}
+	}
+	return out, stream.Err()
+}
+
+func TestTier1Service_CachedRanges(t *testing.T) {
+	client, cacheStore := newTestCacheClient(t, 0)
+	moduleHash := writeCachedOutputs(t, cacheStore, "map_transfers", block.NewRange(100, 110), block.NewRange(110, 120), block.NewRange(130, 140))
+
+	expected := []*pbsubstreamsrpc.BlockRange{{StartBlock: 100, EndBlock: 120}, {StartBlock: 130, EndBlock: 140}}
+	for _, request := range []*pbsubstreamsrpc.CachedRangesRequest{
+		{ModuleHash: moduleHash},
+		{OutputModule: "map_transfers", Modules: testCachedModules()},
+	} {
+		resp, err := client.CachedRanges(context.Background(), connect.NewRequest(request))
+		require.NoError(t, err)
+		assert.Equal(t, moduleHash, resp.Msg.ModuleHash)
+		require.Len(t, resp.Msg.Ranges, len(expected))
+		for i := range expected {
+			assert.True(t, proto.Equal(expected[i], resp.Msg.Ranges[i]), resp.Msg.Ranges[i].String())
+		}
+	}
+
+	for _, moduleHash := range []string{"../other", "abc", moduleHash + "0"} {
+		_, err := client.CachedRanges(context.Background(), connect.NewRequest(&pbsubstreamsrpc.CachedRangesRequest{ModuleHash: moduleHash}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), moduleHash)
+	}
+}
+
+func TestTier1Service_CachedOutputs(t *testing.T) {
+	client, cacheStore := newTestCacheClient(t, 0)
+	writeCachedOutputs(t, cacheStore, "map_transfers", block.NewRange(100, 110), block.NewRange(110, 120), block.NewRange(130, 140))
+	writeCachedOutputs(t, cacheStore, "store_balances", block.NewRange(100, 110))
+
+	request := &pbsubstreamsrpc.CachedOutputsRequest{
+		StartBlockNum: 105,
+		StopBlockNum:  115,
+		OutputModule:  "map_transfers",
+		Modules:       testCachedModules(),
+	}
+	data, err := receiveCachedOutputs(t, client, request)
+	require.NoError(t, err)
+	require.Len(t, data, 10)
+	assert.Equal(t, uint64(105), data[0].Clock.Number)
+	assert.Equal(t, "type.googleapis.com/test.Transfers", data[0].Output.MapOutput.TypeUrl)
+	assert.Equal(t, []byte("transfers 105"), data[0].Output.MapOutput.Value)
+
+	// The cursors resume the stream after their block, like with `Blocks`.
+	cursor, err := bstream.CursorFromOpaque(data[4].Cursor)
+	require.NoError(t, err)
+	assert.True(t, cursor.IsOnFinalBlock())
+	assert.Equal(t, uint64(109), cursor.Block.Num())
+
+	request.StartCursor = data[4].Cursor
+	resumed, err := receiveCachedOutputs(t, client, request)
+	require.NoError(t, err)
+	require.Len(t, resumed, 5)
+	assert.Equal(t, uint64(110), resumed[0].Clock.Number)
+
+	request.StartCursor = ""
+	request.StopBlockNum = 135
+	_, err = receiveCachedOutputs(t, client, request)
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	assert.Contains(t, err.Error(), "not cached for blocks [120, 130)")
+	var connectErr *connect.Error
+	require.True(t, errors.As(err, &connectErr))
+	require.Len(t, connectErr.Details(), 1)
+	detail, err := connectErr.Details()[0].Value()
+	require.NoError(t, err)
+	missing := detail.(*pbsubstreamsrpc.MissingCachedRanges).MissingRanges
+	require.Len(t, missing, 1)
+	assert.Equal(t, uint64(120), missing[0].StartBlock)
+	assert.Equal(t, uint64(130), missing[0].EndBlock)
+
+	data, err = receiveCachedOutputs(t, client, &pbsubstreamsrpc.CachedOutputsRequest{
+		StartBlockNum: 0,
+		StopBlockNum:  105,
+		OutputModule:  "store_balances",
+		Modules:       testCachedModules(),
+	})
+	require.NoError(t, err)
+	require.Len(t, data, 5)
+	assert.Nil(t, data[0].Output)
+	require.Len(t, data[0].DebugStoreOutputs, 1)
+	assert.Equal(t, "balance:100", data[0].DebugStoreOutputs[0].DebugStoreDeltas[0].Key)
+}
+
+func TestTier1Service_CachedOutputs_AccessMarker(t *testing.T) {
+	client, cacheStore := newTestCacheClient(t, time.Hour)
+	moduleHash := writeCachedOutputs(t, cacheStore, "map_transfers", block.NewRange(100, 110))
+
+	_, err := receiveCachedOutputs(t, client, &pbsubstreamsrpc.CachedOutputsRequest{
+		StartBlockNum: 100,
+		StopBlockNum:  110,
+		OutputModule:  "map_transfers",
+		Modules:       testCachedModules(),
+	})
+	require.NoError(t, err)
+
+	exists, err := cacheStore.FileExists(context.Background(), moduleHash+"/"+cachegc.AccessMarkerFilename)
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
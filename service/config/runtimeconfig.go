@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/streamingfast/dstore"
 
 	"github.com/streamingfast/substreams/orchestrator/work"
@@ -11,9 +13,11 @@ import (
 type RuntimeConfig struct {
 	StateBundleSize uint64
 
-	MaxWasmFuel                uint64 // if not 0, enable fuel consumption monitoring to stop runaway wasm module processing forever
-	MaxJobsAhead               uint64 // limit execution of depencency jobs so they don't go too far ahead of the modules that depend on them (ex: module X is 2 million blocks ahead of module Y that depends on it, we don't want to schedule more module X jobs until Y caught up a little bit)
-	DefaultParallelSubrequests uint64 // how many sub-jobs to launch for a given user
+	MaxWasmFuel                uint64        // if not 0, enable fuel consumption monitoring to stop runaway wasm module processing forever
+	MaxWasmMemory              uint64        // if not 0, maximum size in bytes of the linear memory of a wasm module instance
+	MaxWasmExecutionTime       time.Duration // if not 0, maximum wall-clock time a wasm module can run on a single block
+	MaxJobsAhead               uint64        // limit execution of depencency jobs so they don't go too far ahead of the modules that depend on them (ex: module X is 2 million blocks ahead of module Y that depends on it, we don't want to schedule more module X jobs until Y caught up a little bit)
+	DefaultParallelSubrequests uint64        // how many sub-jobs to launch for a given user
 	// derives substores `states/`, for `store` modules snapshots (full and partial)
 	// and `outputs/` for execution output of both `map` and `store` module kinds
 	BaseObjectStore dstore.Store
@@ -21,6 +25,25 @@ type RuntimeConfig struct {
 	WorkerFactory   work.WorkerFactory
 
 	ModuleExecutionTracing bool
+
+	WasmCompilationCache    bool   // if true, compiled wasm modules are kept across requests
+	WasmCompilationCacheDir string // if not empty, compiled wasm modules are also persisted in this directory, to be reused across restarts
+
+	WasmProfiling     bool         // if true, the modules listed by the clients in the profiling header are profiled, see wasm.Profiler
+	WasmProfilesStore dstore.Store // if not nil, the profiles are written to this store, outside of the cache
+
+	WasmDeterminismCheck        bool   // if true, every wasm call is executed twice and the executions compared, failing on divergence
+	WasmDeterminismCheckRuntime string // runtime of the second execution, the same runtime (with a fresh instance) if empty
+
+	StoreIndexedSnapshots    bool   // if true, full store snapshots are saved indexed, and loaded lazily from local copies, see store.Config.SetIndexedSnapshots
+	StoreIndexedSnapshotsDir string // directory of the local copies of the indexed store snapshots, the default directory for temporary files if empty
+	StoreCheckpointInterval  uint64 // if greater than 1, full store snapshots only hold the keys changed since the previous one, with a complete snapshot every StoreCheckpointInterval snapshots
+	StoreMaxSizeBytes        uint64 // if not 0, maximum size in bytes (keys and values) of a store, the modules can only lower it, see store.Config.SetQuota
+	StoreMaxKeys             uint64 // if not 0, maximum number of keys of a store, the modules can only lower it
+
+	WasmExtensionCallsMode string // if not empty, the calls to wasm extensions are recorded (`record`) or replayed (`replay`) from the cache store, see wasm.WithExtensionCalls
+
+	CacheAccessMarkerInterval time.Duration // if not 0, the access markers of the modules of a request are rewritten at this interval while it runs, see cachegc.TrackAccess
 }
 
 func NewRuntimeConfig(
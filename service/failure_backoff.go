@@ -58,7 +58,8 @@ func (s *Tier1Service) errorFromRecordedFailure(id string, isProductionMode bool
 }
 
 // Error: rpc error: code = InvalidArgument desc = step new irr: handler step new: execute modules: applying executor results ... store wasm call: block 300: module "store_eth_stats": wasm execution failed ...
-var blockFailureRE = regexp.MustCompile(`store wasm call: block ([0-9]*): module "([^"]*)"`)
+// Error: ... maps wasm call: block 300: module "map_events": wasm execution failed deterministically: memory limit exceeded at block 300, limit is 104857600 bytes
+var blockFailureRE = regexp.MustCompile(`(?:store|maps) wasm call: block ([0-9]*): module "([^"]*)"`)
 
 func (s *Tier1Service) recordFailure(requestID string, err error) {
 	s.failedRequestsLock.Lock()
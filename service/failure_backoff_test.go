@@ -0,0 +1,44 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordFailure(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		expectAtBlock uint64
+	}{
+		{
+			name:          "store module",
+			err:           errors.New(`rpc error: code = InvalidArgument desc = step new irr: handler step new: execute modules: applying executor results "store_eth_stats": store wasm call: block 300: module "store_eth_stats": wasm execution failed deterministically: panic in the wasm`),
+			expectAtBlock: 300,
+		},
+		{
+			name:          "map module",
+			err:           errors.New(`rpc error: code = InvalidArgument desc = execute modules: running executor "map_events": maps wasm call: block 42: module "map_events": wasm execution failed deterministically: memory limit exceeded at block 42, limit is 1048576 bytes`),
+			expectAtBlock: 42,
+		},
+		{
+			name: "no block",
+			err:  errors.New("rpc error: code = Internal desc = something else"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &Tier1Service{failedRequests: make(map[string]*recordedFailure)}
+			s.recordFailure("req", test.err)
+			s.recordFailure("req", test.err)
+
+			failure := s.failedRequests["req"]
+			assert.Equal(t, test.expectAtBlock, failure.atBlock)
+			assert.Equal(t, 2, failure.count)
+			assert.Equal(t, test.err, failure.lastError)
+		})
+	}
+}
@@ -1,6 +1,95 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"time"
+
+	"github.com/streamingfast/dauth"
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/service/config"
+	"github.com/streamingfast/substreams/wasm"
+	"github.com/streamingfast/substreams/wasm/instrument"
 	//_ "github.com/streamingfast/substreams/wasm/wasmtime"
 	_ "github.com/streamingfast/substreams/wasm/wazero"
 )
+
+// newWASMCompilationCache returns nil when the compilation cache is disabled.
+// If the cache directory cannot be used, the cache is kept in memory only.
+func newWASMCompilationCache(runtimeConfig config.RuntimeConfig, logger *zap.Logger) *wasm.CompilationCache {
+	if !runtimeConfig.WasmCompilationCache {
+		return nil
+	}
+
+	cache, err := wasm.NewCompilationCache(runtimeConfig.WasmCompilationCacheDir)
+	if err != nil {
+		logger.Warn("cannot use wasm compilation cache directory, keeping compiled modules in memory only", zap.Error(err))
+		cache, _ = wasm.NewCompilationCache("")
+	}
+	logger.Info("wasm compilation cache enabled", zap.String("dir", cache.Dir()))
+	return cache
+}
+
+// wasmRegistryOptions returns the options of the wasm registry of a request.
+// The request can tighten the server's wasm limits with the
+// `X-Sf-Substreams-Max-Wasm-Memory` (in bytes) and
+// `X-Sf-Substreams-Max-Wasm-Execution-Time` (a duration like `500ms`)
+// headers. Values that are invalid or looser than the server's are ignored.
+// The memory limit is rounded down to a power of two, as it is part of the
+// key of the compiled modules in the compilation cache.
+// The profiler, when not nil, is the one of newWASMProfiler. The calls to
+// wasm extensions are recorded under `extensions/` of the cache store.
+func wasmRegistryOptions(ctx context.Context, runtimeConfig config.RuntimeConfig, compilationCache *wasm.CompilationCache, profiler *wasm.Profiler, cacheStore dstore.Store) ([]wasm.RegistryOption, error) {
+	maxMemory := runtimeConfig.MaxWasmMemory
+	timeout := runtimeConfig.MaxWasmExecutionTime
+	if auth := dauth.FromContext(ctx); auth != nil {
+		if value := auth.Get("X-Sf-Substreams-Max-Wasm-Memory"); value != "" {
+			if ll, err := strconv.ParseUint(value, 10, 64); err == nil && ll != 0 && (maxMemory == 0 || ll < maxMemory) {
+				maxMemory = quantizeWASMMemory(ll)
+			}
+		}
+		if value := auth.Get("X-Sf-Substreams-Max-Wasm-Execution-Time"); value != "" {
+			if d, err := time.ParseDuration(value); err == nil && d > 0 && (timeout == 0 || d < timeout) {
+				timeout = d
+			}
+		}
+	}
+
+	opts := []wasm.RegistryOption{
+		wasm.WithCompilationCache(compilationCache),
+		wasm.WithMaxMemory(maxMemory),
+		wasm.WithExecutionTimeout(timeout),
+	}
+	if profiler != nil {
+		opts = append(opts, wasm.WithProfiler(profiler))
+	}
+	if runtimeConfig.WasmDeterminismCheck {
+		opts = append(opts, wasm.WithDeterminismCheck(runtimeConfig.WasmDeterminismCheckRuntime))
+	}
+	if runtimeConfig.WasmExtensionCallsMode != "" {
+		mode, err := wasm.ParseExtensionCallsMode(runtimeConfig.WasmExtensionCallsMode)
+		if err != nil {
+			return nil, err
+		}
+		extensionCallsStore, err := cacheStore.SubStore("extensions")
+		if err != nil {
+			return nil, fmt.Errorf("setting extension calls store: %w", err)
+		}
+		opts = append(opts, wasm.WithExtensionCalls(mode, extensionCallsStore))
+	}
+	return opts, nil
+}
+
+// quantizeWASMMemory rounds `bytes` down to a power of two, at least one WASM
+// page, so requests can only produce a few distinct compiled variants of a
+// module.
+func quantizeWASMMemory(bytes uint64) uint64 {
+	if bytes <= instrument.PageSize {
+		return instrument.PageSize
+	}
+	return 1 << (bits.Len64(bytes) - 1)
+}
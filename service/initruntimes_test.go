@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamingfast/substreams/wasm"
+)
+
+func Test_quantizeWASMMemory(t *testing.T) {
+	assert.Equal(t, uint64(65536), quantizeWASMMemory(1))
+	assert.Equal(t, uint64(65536), quantizeWASMMemory(65536))
+	assert.Equal(t, uint64(65536), quantizeWASMMemory(131071))
+	assert.Equal(t, uint64(1<<30), quantizeWASMMemory(1<<30))
+	assert.Equal(t, uint64(1<<30), quantizeWASMMemory(1<<31-1))
+}
+
+func TestWithWASMDeterminismCheck_Runtime(t *testing.T) {
+	assert.NotPanics(t, func() { WithWASMDeterminismCheck("") })
+	assert.NotPanics(t, func() { WithWASMDeterminismCheck("wazero") })
+	assert.PanicsWithError(t, `wasm determinism check: could not find wasm runtime "wasmtime" (valid values are "wazero")`, func() { WithWASMDeterminismCheck("wasmtime") })
+}
+
+type testExtensioner map[string]map[string]wasm.WASMExtension
+
+func (e testExtensioner) WASMExtensions() map[string]map[string]wasm.WASMExtension { return e }
+
+func TestWithWASMExtension_ReservedNamespace(t *testing.T) {
+	assert.NotPanics(t, func() { WithWASMExtension(testExtensioner{"eth": {}}) })
+	assert.PanicsWithError(t, `wasm extension: cannot extend reserved "crypto" wasm namespace (reserved namespaces are "state, env, logger, crypto, wasi_snapshot_preview1")`, func() { WithWASMExtension(testExtensioner{"crypto": {}}) })
+}
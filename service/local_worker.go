@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/streamingfast/dmetering"
+	tracing "github.com/streamingfast/sf-tracing"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams"
+	"github.com/streamingfast/substreams/block"
+	"github.com/streamingfast/substreams/orchestrator/loop"
+	"github.com/streamingfast/substreams/orchestrator/response"
+	"github.com/streamingfast/substreams/orchestrator/stage"
+	"github.com/streamingfast/substreams/orchestrator/work"
+	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	"github.com/streamingfast/substreams/reqctx"
+)
+
+var lastLocalWorkerID uint64
+
+// LocalWorker runs the jobs on a Tier2Service of the same process, instead
+// of sending them to a tier2 server like the work.RemoteWorker.
+type LocalWorker struct {
+	svc    *Tier2Service
+	logger *zap.Logger
+	id     uint64
+}
+
+// NewLocalWorkerFactory returns a work.WorkerFactory creating LocalWorker
+// running the jobs on `svc`.
+func NewLocalWorkerFactory(svc *Tier2Service) work.WorkerFactory {
+	return func(logger *zap.Logger) work.Worker {
+		return &LocalWorker{
+			svc:    svc,
+			logger: logger,
+			id:     atomic.AddUint64(&lastLocalWorkerID, 1),
+		}
+	}
+}
+
+func (w *LocalWorker) ID() string {
+	return fmt.Sprintf("local-%d", w.id)
+}
+
+func (w *LocalWorker) Work(ctx context.Context, unit stage.Unit, workRange *block.Range, moduleNames []string, upstream *response.Stream) loop.Cmd {
+	request := work.NewRequest(reqctx.Details(ctx), unit.Stage, workRange)
+	logger := reqctx.Logger(ctx)
+
+	return func() loop.Msg {
+		stats := reqctx.ReqStats(ctx)
+		jobIdx := stats.RecordNewSubrequest(request.Stage, request.StartBlockNum, request.StopBlockNum)
+		defer stats.RecordEndSubrequest(jobIdx)
+
+		respFunc := func(resp substreams.ResponseFromAnyTier) error {
+			if update, ok := resp.(*pbssinternal.ProcessRangeResponse).Type.(*pbssinternal.ProcessRangeResponse_Update); ok {
+				stats.RecordJobUpdate(jobIdx, update.Update)
+			}
+			return nil
+		}
+
+		jobCtx := reqctx.WithLogger(ctx, w.logger.Named("tier2"))
+		jobCtx = dmetering.WithBytesMeter(jobCtx)
+		jobCtx = reqctx.WithTracer(jobCtx, w.svc.tracer)
+
+		w.logger.Info("launching local worker",
+			zap.Uint64("start_block_num", request.StartBlockNum),
+			zap.Uint64("stop_block_num", request.StopBlockNum),
+			zap.String("output_module", request.OutputModule),
+		)
+		if err := w.svc.processRange(jobCtx, request, respFunc, tracing.GetTraceID(ctx).String()); err != nil {
+			logger.Info("job failed", zap.Object("unit", unit), zap.Strings("module_name", moduleNames), zap.Error(err))
+			return work.MsgJobFailed{Unit: unit, Error: err}
+		}
+		if err := ctx.Err(); err != nil {
+			logger.Info("job not completed", zap.Object("unit", unit), zap.Error(err))
+			return work.MsgJobFailed{Unit: unit, Error: err}
+		}
+
+		logger.Info("job completed", zap.Object("unit", unit), zap.Strings("module_name", moduleNames))
+		return work.MsgJobSucceeded{Unit: unit, Worker: w}
+	}
+}
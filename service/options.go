@@ -1,6 +1,11 @@
 package service
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/streamingfast/dstore"
+
 	"github.com/streamingfast/substreams/pipeline"
 	"github.com/streamingfast/substreams/wasm"
 )
@@ -9,7 +14,15 @@ type anyTierService interface{}
 
 type Option func(anyTierService)
 
+// WithWASMExtension provides the functions of `ext` to the wasm modules. It
+// panics when `ext` extends a namespace reserved for the host functions of
+// the runtimes, see wasm.ValidateExtensionNamespace.
 func WithWASMExtension(ext wasm.WASMExtensioner) Option {
+	for namespace := range ext.WASMExtensions() {
+		if err := wasm.ValidateExtensionNamespace(namespace); err != nil {
+			panic(fmt.Errorf("wasm extension: %w", err))
+		}
+	}
 	return func(a anyTierService) {
 		switch s := a.(type) {
 		case *Tier1Service:
@@ -45,6 +58,106 @@ func WithMaxWasmFuelPerBlockModule(maxFuel uint64) Option {
 	}
 }
 
+// WithMaxWasmMemoryPerModule limits the linear memory of each wasm module
+// instance to `maxMemory` bytes, rounded down to a whole number of pages.
+func WithMaxWasmMemoryPerModule(maxMemory uint64) Option {
+	return func(a anyTierService) {
+		switch s := a.(type) {
+		case *Tier1Service:
+			s.runtimeConfig.MaxWasmMemory = maxMemory
+		case *Tier2Service:
+			s.runtimeConfig.MaxWasmMemory = maxMemory
+		}
+	}
+}
+
+// WithMaxWasmExecutionTimePerBlockModule stops a wasm module running for
+// longer than `timeout` on a single block.
+func WithMaxWasmExecutionTimePerBlockModule(timeout time.Duration) Option {
+	return func(a anyTierService) {
+		switch s := a.(type) {
+		case *Tier1Service:
+			s.runtimeConfig.MaxWasmExecutionTime = timeout
+		case *Tier2Service:
+			s.runtimeConfig.MaxWasmExecutionTime = timeout
+		}
+	}
+}
+
+// WithWASMDeterminismCheck executes every wasm call a second time, on the
+// `runtime` given (or on a fresh instance of the same runtime if empty), and
+// fails the request when the outputs or store deltas of both executions
+// differ. This is a diagnostic mode, it doubles the cost of executing modules.
+// It panics when `runtime` is not registered: only wazero is, the servers
+// are built without the wasmtime runtime, which links cgo.
+func WithWASMDeterminismCheck(runtime string) Option {
+	if runtime != "" {
+		if err := wasm.ValidateRuntime(runtime); err != nil {
+			panic(fmt.Errorf("wasm determinism check: %w", err))
+		}
+	}
+	return func(a anyTierService) {
+		switch s := a.(type) {
+		case *Tier1Service:
+			s.runtimeConfig.WasmDeterminismCheck = true
+			s.runtimeConfig.WasmDeterminismCheckRuntime = runtime
+		case *Tier2Service:
+			s.runtimeConfig.WasmDeterminismCheck = true
+			s.runtimeConfig.WasmDeterminismCheckRuntime = runtime
+		}
+	}
+}
+
+// WithWASMExtensionCalls records the responses of the wasm extensions in the
+// cache store, under `extensions/`, and serves them back on the following
+// calls with the same module, block and input, across requests and tier2
+// jobs. With wasm.ExtensionCallsReplay, the extensions are never called and
+// a call without a recorded response fails the request.
+func WithWASMExtensionCalls(mode wasm.ExtensionCallsMode) Option {
+	return func(a anyTierService) {
+		switch s := a.(type) {
+		case *Tier1Service:
+			s.runtimeConfig.WasmExtensionCallsMode = string(mode)
+		case *Tier2Service:
+			s.runtimeConfig.WasmExtensionCallsMode = string(mode)
+		}
+	}
+}
+
+// WithIndexedStoreSnapshots saves the full store snapshots in an indexed
+// format, loaded lazily: the snapshot is copied to `localDir` (the default
+// directory for temporary files if empty) and its keys are read from there as
+// the modules look them up, instead of all loaded in memory before the first
+// block. Stores with a `ttlBlocks` keep the regular format.
+func WithIndexedStoreSnapshots(localDir string) Option {
+	return func(a anyTierService) {
+		switch s := a.(type) {
+		case *Tier1Service:
+			s.runtimeConfig.StoreIndexedSnapshots = true
+			s.runtimeConfig.StoreIndexedSnapshotsDir = localDir
+		case *Tier2Service:
+			s.runtimeConfig.StoreIndexedSnapshots = true
+			s.runtimeConfig.StoreIndexedSnapshotsDir = localDir
+		}
+	}
+}
+
+// WithIncrementalStoreSnapshots makes the full store snapshots hold only the
+// keys changed since the previous snapshot, a complete snapshot (a
+// checkpoint) being saved every `checkpointInterval` snapshots. Loading a
+// snapshot then loads its checkpoint and applies the changes of the
+// snapshots since. Stores with a `ttlBlocks` always save complete snapshots.
+func WithIncrementalStoreSnapshots(checkpointInterval uint64) Option {
+	return func(a anyTierService) {
+		switch s := a.(type) {
+		case *Tier1Service:
+			s.runtimeConfig.StoreCheckpointInterval = checkpointInterval
+		case *Tier2Service:
+			s.runtimeConfig.StoreCheckpointInterval = checkpointInterval
+		}
+	}
+}
+
 func WithModuleExecutionTracing() Option {
 	return func(a anyTierService) {
 		switch s := a.(type) {
@@ -55,3 +168,73 @@ func WithModuleExecutionTracing() Option {
 		}
 	}
 }
+
+// WithWASMCompilationCache keeps the compiled wasm modules in memory, keyed
+// by the content hash of their binary, so requests using the same binary don't
+// compile it again. If `dir` is not empty, the compiled modules are also
+// persisted there and survive restarts.
+func WithWASMCompilationCache(dir string) Option {
+	return func(a anyTierService) {
+		switch s := a.(type) {
+		case *Tier1Service:
+			s.runtimeConfig.WasmCompilationCache = true
+			s.runtimeConfig.WasmCompilationCacheDir = dir
+		case *Tier2Service:
+			s.runtimeConfig.WasmCompilationCache = true
+			s.runtimeConfig.WasmCompilationCacheDir = dir
+		}
+	}
+}
+
+// WithWASMProfiling honors the requests of the clients to profile the
+// modules listed in their `X-Sf-Substreams-Profile-Modules` header, ignored
+// otherwise: profiling slows the execution of the modules down. The profiles
+// are written to `profilesStore`, when not nil, kept apart from the cache
+// store, and tier1 also attaches them to the trailers of the stream.
+func WithWASMProfiling(profilesStore dstore.Store) Option {
+	return func(a anyTierService) {
+		switch s := a.(type) {
+		case *Tier1Service:
+			s.runtimeConfig.WasmProfiling = true
+			s.runtimeConfig.WasmProfilesStore = profilesStore
+		case *Tier2Service:
+			s.runtimeConfig.WasmProfiling = true
+			s.runtimeConfig.WasmProfilesStore = profilesStore
+		}
+	}
+}
+
+// WithStoreQuota limits the size in bytes (keys and values) and the number of
+// keys of every store, zero leaving a limit unset. The modules can lower
+// these quotas in their manifest (`maxSizeBytes` and `maxKeys`), but never
+// raise them. A store exceeding its quota after a block fails the request
+// with a deterministic error, which is not retried.
+func WithStoreQuota(maxSizeBytes, maxKeys uint64) Option {
+	return func(a anyTierService) {
+		switch s := a.(type) {
+		case *Tier1Service:
+			s.runtimeConfig.StoreMaxSizeBytes = maxSizeBytes
+			s.runtimeConfig.StoreMaxKeys = maxKeys
+		case *Tier2Service:
+			s.runtimeConfig.StoreMaxSizeBytes = maxSizeBytes
+			s.runtimeConfig.StoreMaxKeys = maxKeys
+		}
+	}
+}
+
+// WithCacheAccessMarkers makes tier1, tier2 and the Cache service record the
+// accesses to the cached files of the modules of each request, rewriting
+// their access marker every `interval` while the request runs. The cache
+// garbage collection (`substreams tools cache gc`) deletes the modules by
+// last access, and protects the ones accessed within its in-flight grace
+// period, which must exceed `interval`.
+func WithCacheAccessMarkers(interval time.Duration) Option {
+	return func(a anyTierService) {
+		switch s := a.(type) {
+		case *Tier1Service:
+			s.runtimeConfig.CacheAccessMarkerInterval = interval
+		case *Tier2Service:
+			s.runtimeConfig.CacheAccessMarkerInterval = interval
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/streamingfast/dauth"
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/client"
+	"github.com/streamingfast/substreams/manifest"
+	"github.com/streamingfast/substreams/service/config"
+	"github.com/streamingfast/substreams/wasm"
+)
+
+// newWASMProfiler returns a profiler for the modules listed in the
+// `X-Sf-Substreams-Profile-Modules` header, nil when there are none or when
+// profiling is not enabled on the server, see WithWASMProfiling.
+func newWASMProfiler(ctx context.Context, runtimeConfig config.RuntimeConfig) *wasm.Profiler {
+	if !runtimeConfig.WasmProfiling {
+		return nil
+	}
+	auth := dauth.FromContext(ctx)
+	if auth == nil {
+		return nil
+	}
+	var modules []string
+	for _, module := range strings.Split(auth.Get(client.ProfileModulesHeader), ",") {
+		if module = strings.TrimSpace(module); module != "" {
+			modules = append(modules, module)
+		}
+	}
+	if len(modules) == 0 {
+		return nil
+	}
+	return wasm.NewProfiler(modules)
+}
+
+// writeWASMProfiles writes the profiles of `profiler` to the profiles store,
+// when there is one, per module: `<module_hash>/<start>-<stop>.<trace_id>.pprof`.
+// Failures are logged, profiles are best effort.
+func writeWASMProfiles(ctx context.Context, profiler *wasm.Profiler, profilesStore dstore.Store, hashes *manifest.ModuleHashes, startBlock, stopBlock uint64, traceID string, logger *zap.Logger) {
+	if profiler == nil || profilesStore == nil {
+		return
+	}
+	for _, module := range profiler.Modules() {
+		filename := fmt.Sprintf("%s/%010d-%010d.%s.pprof", hashes.Get(module), startBlock, stopBlock, traceID)
+		if err := profilesStore.WriteObject(ctx, filename, bytes.NewReader(profiler.Profile(module))); err != nil {
+			logger.Warn("cannot write wasm profile", zap.String("module", module), zap.String("filename", filename), zap.Error(err))
+			continue
+		}
+		logger.Info("wrote wasm profile", zap.String("module", module), zap.String("filename", filename))
+	}
+}
+
+// setWASMProfileTrailers attaches the profiles of `profiler` to the trailers
+// of the Blocks stream, see client.ProfileTrailer.
+func setWASMProfileTrailers(trailer http.Header, profiler *wasm.Profiler) {
+	if profiler == nil {
+		return
+	}
+	for _, module := range profiler.Modules() {
+		trailer.Set(client.ProfileTrailer(module), connect.EncodeBinaryHeader(profiler.Profile(module)))
+	}
+}
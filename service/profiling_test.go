@@ -0,0 +1,21 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/streamingfast/dauth"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/streamingfast/substreams/client"
+	"github.com/streamingfast/substreams/service/config"
+)
+
+func Test_newWASMProfiler(t *testing.T) {
+	ctx := dauth.WithTrustedHeaders(context.Background(), dauth.TrustedHeaders{strings.ToLower(client.ProfileModulesHeader): "map_a, map_b"})
+
+	assert.Nil(t, newWASMProfiler(ctx, config.RuntimeConfig{}), "profiling requests are ignored unless enabled on the server")
+	assert.NotNil(t, newWASMProfiler(ctx, config.RuntimeConfig{WasmProfiling: true}))
+	assert.Nil(t, newWASMProfiler(context.Background(), config.RuntimeConfig{WasmProfiling: true}))
+}
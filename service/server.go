@@ -55,9 +55,12 @@ func ListenTier1(
 	streamHandlerGetter := func(opts ...connect_go.HandlerOption) (string, http.Handler) {
 		return ssconnect.NewStreamHandler(svc, opts...)
 	}
+	cacheHandlerGetter := func(opts ...connect_go.HandlerOption) (string, http.Handler) {
+		return ssconnect.NewCacheHandler(svc, opts...)
+	}
 
 	options = append(options, dgrpcserver.WithPermissiveCORS())
-	srv := connectweb.New([]connectweb.HandlerGetter{streamHandlerGetter}, options...)
+	srv := connectweb.New([]connectweb.HandlerGetter{streamHandlerGetter, cacheHandlerGetter}, options...)
 	addr = strings.ReplaceAll(addr, "*", "")
 	srv.Launch(addr)
 	<-srv.Terminated()
@@ -23,8 +23,8 @@ func TestTraceIDParam() store.TraceIDParam {
 	return store.TraceIDParam(TestTraceID)
 }
 
-func TestNewService(runtimeConfig config.RuntimeConfig, linearHandoffBlockNum uint64, streamFactoryFunc StreamFactoryFunc) *Tier1Service {
-	return &Tier1Service{
+func TestNewService(runtimeConfig config.RuntimeConfig, linearHandoffBlockNum uint64, streamFactoryFunc StreamFactoryFunc, opts ...Option) *Tier1Service {
+	s := &Tier1Service{
 		blockType:         "sf.substreams.v1.test.Block",
 		streamFactoryFunc: streamFactoryFunc,
 		runtimeConfig:     runtimeConfig,
@@ -37,6 +37,10 @@ func TestNewService(runtimeConfig config.RuntimeConfig, linearHandoffBlockNum ui
 		tracer: nil,
 		logger: zlog,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Tier1Service) TestBlocks(ctx context.Context, isSubRequest bool, request *pbsubstreamsrpc.Request, respFunc substreams.ResponseFunc) error {
@@ -45,7 +49,7 @@ func (s *Tier1Service) TestBlocks(ctx context.Context, isSubRequest bool, reques
 		return stream.NewErrInvalidArg(err.Error())
 	}
 
-	return s.blocks(ctx, request, outputGraph, respFunc)
+	return s.blocks(ctx, request, outputGraph, nil, respFunc)
 }
 
 func TestNewServiceTier2(runtimeConfig config.RuntimeConfig, streamFactoryFunc StreamFactoryFunc) *Tier2Service {
@@ -52,6 +52,7 @@ import (
 type Tier1Service struct {
 	*shutter.Shutter
 	ssconnect.UnimplementedStreamHandler
+	ssconnect.UnimplementedCacheHandler
 
 	blockType          string
 	wasmExtensions     []wasm.WASMExtensioner
@@ -60,6 +61,7 @@ type Tier1Service struct {
 	failedRequests     map[string]*recordedFailure
 	streamFactoryFunc  StreamFactoryFunc
 	runtimeConfig      config.RuntimeConfig
+	compilationCache   *wasm.CompilationCache
 	tracer             ttrace.Tracer
 	logger             *zap.Logger
 
@@ -125,6 +127,8 @@ func NewTier1(
 		opt(s)
 	}
 
+	s.compilationCache = newWASMCompilationCache(s.runtimeConfig, logger)
+
 	return s
 }
 
@@ -233,7 +237,9 @@ func (s *Tier1Service) Blocks(
 		}
 	}()
 
-	err = s.blocks(runningContext, request, outputGraph, respFunc)
+	profiler := newWASMProfiler(ctx, s.runtimeConfig)
+	err = s.blocks(runningContext, request, outputGraph, profiler, respFunc)
+	setWASMProfileTrailers(stream.ResponseTrailer(), profiler)
 
 	if grpcError := toGRPCError(runningContext, err); grpcError != nil {
 		switch status.Code(grpcError) {
@@ -284,7 +290,21 @@ func (s *Tier1Service) writePackage(ctx context.Context, request *pbsubstreamsrp
 
 var IsValidCacheTag = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`).MatchString
 
-func (s *Tier1Service) blocks(ctx context.Context, request *pbsubstreamsrpc.Request, outputGraph *outputmodules.Graph, respFunc substreams.ResponseFunc) error {
+// requestCacheTag returns the cache tag of the request, the default one
+// unless the X-Sf-Substreams-Cache-Tag header overrides it.
+func (s *Tier1Service) requestCacheTag(ctx context.Context) (string, error) {
+	if auth := dauth.FromContext(ctx); auth != nil {
+		if cacheTag := auth.Get("X-Sf-Substreams-Cache-Tag"); cacheTag != "" {
+			if !IsValidCacheTag(cacheTag) {
+				return "", fmt.Errorf("invalid value for X-Sf-Substreams-Cache-Tag %s, should only contain letters, numbers, hyphens and undescores", cacheTag)
+			}
+			return cacheTag, nil
+		}
+	}
+	return s.runtimeConfig.DefaultCacheTag, nil
+}
+
+func (s *Tier1Service) blocks(ctx context.Context, request *pbsubstreamsrpc.Request, outputGraph *outputmodules.Graph, profiler *wasm.Profiler, respFunc substreams.ResponseFunc) error {
 	chainFirstStreamableBlock := bstream.GetProtocolFirstStreamableBlock
 	if request.StartBlockNum >= 0 && request.StartBlockNum < int64(chainFirstStreamableBlock) {
 		return stream.NewErrInvalidArg("invalid start block %d, must be >= %d (the first streamable block of the chain)", request.StartBlockNum, chainFirstStreamableBlock)
@@ -304,21 +324,15 @@ func (s *Tier1Service) blocks(ctx context.Context, request *pbsubstreamsrpc.Requ
 	}
 
 	requestDetails.MaxParallelJobs = s.runtimeConfig.DefaultParallelSubrequests
-	requestDetails.CacheTag = s.runtimeConfig.DefaultCacheTag
 	if auth := dauth.FromContext(ctx); auth != nil {
 		if parallelJobs := auth.Get("X-Sf-Substreams-Parallel-Jobs"); parallelJobs != "" {
 			if ll, err := strconv.ParseUint(parallelJobs, 10, 64); err == nil {
 				requestDetails.MaxParallelJobs = ll
 			}
 		}
-		if cacheTag := auth.Get("X-Sf-Substreams-Cache-Tag"); cacheTag != "" {
-			if IsValidCacheTag(cacheTag) {
-				requestDetails.CacheTag = cacheTag
-			} else {
-				return fmt.Errorf("invalid value for X-Sf-Substreams-Cache-Tag %s, should only contain letters, numbers, hyphens and undescores", cacheTag)
-			}
-		}
-
+	}
+	if requestDetails.CacheTag, err = s.requestCacheTag(ctx); err != nil {
+		return err
 	}
 
 	var requestStats *metrics.Stats
@@ -350,22 +364,36 @@ func (s *Tier1Service) blocks(ctx context.Context, request *pbsubstreamsrpc.Requ
 		return stream.NewErrInvalidArg(err.Error())
 	}
 
-	wasmRuntime := wasm.NewRegistry(s.wasmExtensions, s.runtimeConfig.MaxWasmFuel)
-
 	cacheStore, err := s.runtimeConfig.BaseObjectStore.SubStore(requestDetails.CacheTag)
 	if err != nil {
 		return fmt.Errorf("internal error setting store: %w", err)
 	}
+	stopTracking := trackCacheAccess(ctx, s.runtimeConfig, cacheStore, outputGraph, tracing.GetTraceID(ctx).String(), logger)
+	defer stopTracking()
+	defer writeWASMProfiles(ctx, profiler, s.runtimeConfig.WasmProfilesStore, outputGraph.ModuleHashes(), requestDetails.LinearHandoffBlockNum, requestDetails.StopBlockNum, tracing.GetTraceID(ctx).String(), logger)
+
+	wasmRegistryOpts, err := wasmRegistryOptions(ctx, s.runtimeConfig, s.compilationCache, profiler, cacheStore)
+	if err != nil {
+		return fmt.Errorf("configuring wasm registry: %w", err)
+	}
+	wasmRuntime := wasm.NewRegistry(s.wasmExtensions, s.runtimeConfig.MaxWasmFuel, wasmRegistryOpts...)
 
 	execOutputConfigs, err := execout.NewConfigs(cacheStore, outputGraph.UsedModules(), outputGraph.ModuleHashes(), s.runtimeConfig.StateBundleSize, logger)
 	if err != nil {
 		return fmt.Errorf("new config map: %w", err)
 	}
 
-	storeConfigs, err := store.NewConfigMap(cacheStore, outputGraph.Stores(), outputGraph.ModuleHashes(), tracing.GetTraceID(ctx).String())
+	storeConfigs, err := store.NewConfigMap(cacheStore, outputGraph.Stores(), outputGraph.ModuleHashes(), s.runtimeConfig.StateBundleSize, tracing.GetTraceID(ctx).String())
 	if err != nil {
 		return fmt.Errorf("configuring stores: %w", err)
 	}
+	if s.runtimeConfig.StoreIndexedSnapshots {
+		storeConfigs.SetIndexedSnapshots(s.runtimeConfig.StoreIndexedSnapshotsDir)
+	}
+	if s.runtimeConfig.StoreCheckpointInterval > 1 {
+		storeConfigs.SetIncrementalSnapshots(s.runtimeConfig.StoreCheckpointInterval)
+	}
+	storeConfigs.SetQuota(s.runtimeConfig.StoreMaxSizeBytes, s.runtimeConfig.StoreMaxKeys)
 
 	stores := pipeline.NewStores(ctx, storeConfigs, s.runtimeConfig.StateBundleSize, requestDetails.LinearHandoffBlockNum, request.StopBlockNum, false)
 
@@ -564,6 +592,11 @@ func toGRPCError(ctx context.Context, err error) error {
 		return status.Error(codes.InvalidArgument, errInvalidArg.Error())
 	}
 
+	var errQuota *store.QuotaExceededError
+	if errors.As(err, &errQuota) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
 	// Do we want to print the full cause as coming from Golang? Would we like to maybe trim off "operational"
 	// data?
 	return status.Error(codes.Internal, err.Error())
@@ -37,6 +37,7 @@ type Tier2Service struct {
 	pipelineOptions   []pipeline.PipelineOptioner
 	streamFactoryFunc StreamFactoryFunc
 	runtimeConfig     config.RuntimeConfig
+	compilationCache  *wasm.CompilationCache
 	tracer            ttrace.Tracer
 	logger            *zap.Logger
 }
@@ -82,6 +83,8 @@ func NewTier2(
 		opt(s)
 	}
 
+	s.compilationCache = newWASMCompilationCache(s.runtimeConfig, logger)
+
 	return s
 }
 
@@ -198,22 +201,38 @@ func (s *Tier2Service) processRange(ctx context.Context, request *pbssinternal.P
 		return stream.NewErrInvalidArg(err.Error())
 	}
 
-	wasmRuntime := wasm.NewRegistry(s.wasmExtensions, s.runtimeConfig.MaxWasmFuel)
+	profiler := newWASMProfiler(ctx, s.runtimeConfig)
 
 	cacheStore, err := s.runtimeConfig.BaseObjectStore.SubStore(requestDetails.CacheTag)
 	if err != nil {
 		return fmt.Errorf("internal error setting store: %w", err)
 	}
+	stopTracking := trackCacheAccess(ctx, s.runtimeConfig, cacheStore, outputGraph, traceID, logger)
+	defer stopTracking()
+	defer writeWASMProfiles(ctx, profiler, s.runtimeConfig.WasmProfilesStore, outputGraph.ModuleHashes(), requestDetails.ResolvedStartBlockNum, requestDetails.StopBlockNum, traceID, logger)
+
+	wasmRegistryOpts, err := wasmRegistryOptions(ctx, s.runtimeConfig, s.compilationCache, profiler, cacheStore)
+	if err != nil {
+		return fmt.Errorf("configuring wasm registry: %w", err)
+	}
+	wasmRuntime := wasm.NewRegistry(s.wasmExtensions, s.runtimeConfig.MaxWasmFuel, wasmRegistryOpts...)
 
 	execOutputConfigs, err := execout.NewConfigs(cacheStore, outputGraph.UsedModules(), outputGraph.ModuleHashes(), s.runtimeConfig.StateBundleSize, logger)
 	if err != nil {
 		return fmt.Errorf("new config map: %w", err)
 	}
 
-	storeConfigs, err := store.NewConfigMap(cacheStore, outputGraph.Stores(), outputGraph.ModuleHashes(), traceID)
+	storeConfigs, err := store.NewConfigMap(cacheStore, outputGraph.Stores(), outputGraph.ModuleHashes(), s.runtimeConfig.StateBundleSize, traceID)
 	if err != nil {
 		return fmt.Errorf("configuring stores: %w", err)
 	}
+	if s.runtimeConfig.StoreIndexedSnapshots {
+		storeConfigs.SetIndexedSnapshots(s.runtimeConfig.StoreIndexedSnapshotsDir)
+	}
+	if s.runtimeConfig.StoreCheckpointInterval > 1 {
+		storeConfigs.SetIncrementalSnapshots(s.runtimeConfig.StoreCheckpointInterval)
+	}
+	storeConfigs.SetQuota(s.runtimeConfig.StoreMaxSizeBytes, s.runtimeConfig.StoreMaxKeys)
 	stores := pipeline.NewStores(ctx, storeConfigs, s.runtimeConfig.StateBundleSize, requestDetails.ResolvedStartBlockNum, request.StopBlockNum, true)
 
 	outputModule := outputGraph.OutputModule()
@@ -0,0 +1,278 @@
+// Package cachecompare compares the cached store snapshots and outputs of a
+// module written in two caches, two cache tags or two object stores, to find
+// where they stop agreeing.
+package cachecompare
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/streamingfast/bstream"
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/storage/execout"
+	pboutput "github.com/streamingfast/substreams/storage/execout/pb"
+	"github.com/streamingfast/substreams/storage/store"
+)
+
+// Divergence is the first difference found between the caches A and B of a
+// module, in an output file or in a full store snapshot.
+type Divergence struct {
+	// File is the name of the output file or store snapshot, the same in
+	// both caches.
+	File string
+	// BlockNum is the block of the diverging output, or the last block of
+	// the diverging store snapshot.
+	BlockNum uint64
+	// Key is the first diverging key of a store snapshot, in lexical order.
+	Key string
+
+	Reason string
+	// A and B are the diverging output payloads or store values, nil
+	// when missing.
+	A, B []byte
+}
+
+func (d *Divergence) String() string {
+	if d.Key != "" {
+		return fmt.Sprintf("%s: key %q at block %d: %s", d.File, d.Key, d.BlockNum, d.Reason)
+	}
+	return fmt.Sprintf("%s: block %d: %s", d.File, d.BlockNum, d.Reason)
+}
+
+// ModuleReport is the result of the comparison of the caches of a module.
+type ModuleReport struct {
+	ModuleName string
+	ModuleHash string
+
+	// ComparedSnapshots and ComparedOutputs count the files present in both
+	// caches that were compared, before the divergence if any.
+	ComparedSnapshots int
+	ComparedOutputs   int
+	// OnlyInA and OnlyInB are the files present in a single cache, not
+	// compared. The partial store snapshots are ignored.
+	OnlyInA []string
+	OnlyInB []string
+
+	// Divergence is the first one found, nil when the caches agree. An
+	// output divergence is reported rather than a store snapshot one ending
+	// at or after its block, the state diverging from the outputs.
+	Divergence *Divergence
+}
+
+// CompareModule compares the full store snapshots, for a store module, and
+// the outputs of `module` found in both `cacheA` and `cacheB`, the stores of
+// a cache tag, in block order.
+func CompareModule(ctx context.Context, module *pbsubstreams.Module, moduleHash string, cacheA, cacheB dstore.Store, logger *zap.Logger) (*ModuleReport, error) {
+	report := &ModuleReport{ModuleName: module.Name, ModuleHash: moduleHash}
+
+	outputsA, err := execout.NewConfig(module.Name, module.InitialBlock, module.ModuleKind(), moduleHash, cacheA, logger)
+	if err != nil {
+		return nil, fmt.Errorf("module %q outputs config: %w", module.Name, err)
+	}
+	outputsB, err := execout.NewConfig(module.Name, module.InitialBlock, module.ModuleKind(), moduleHash, cacheB, logger)
+	if err != nil {
+		return nil, fmt.Errorf("module %q outputs config: %w", module.Name, err)
+	}
+	if err := compareOutputs(ctx, report, outputsA, outputsB); err != nil {
+		return nil, err
+	}
+
+	if kind := module.GetKindStore(); kind != nil {
+		storeA, err := store.NewConfig(module.Name, module.InitialBlock, moduleHash, kind.UpdatePolicy, kind.ValueType, cacheA, "")
+		if err != nil {
+			return nil, fmt.Errorf("store %q config: %w", module.Name, err)
+		}
+		storeB, err := store.NewConfig(module.Name, module.InitialBlock, moduleHash, kind.UpdatePolicy, kind.ValueType, cacheB, "")
+		if err != nil {
+			return nil, fmt.Errorf("store %q config: %w", module.Name, err)
+		}
+		if err := compareSnapshots(ctx, report, storeA, storeB, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(report.OnlyInA)
+	sort.Strings(report.OnlyInB)
+	return report, nil
+}
+
+func compareOutputs(ctx context.Context, report *ModuleReport, configA, configB *execout.Config) error {
+	filesA, err := configA.ListSnapshotFiles(ctx, bstream.NewOpenRange(configA.ModuleInitialBlock()))
+	if err != nil {
+		return fmt.Errorf("listing outputs of module %q in cache A: %w", report.ModuleName, err)
+	}
+	filesB, err := configB.ListSnapshotFiles(ctx, bstream.NewOpenRange(configA.ModuleInitialBlock()))
+	if err != nil {
+		return fmt.Errorf("listing outputs of module %q in cache B: %w", report.ModuleName, err)
+	}
+
+	inB := make(map[string]*execout.FileInfo, len(filesB))
+	for _, file := range filesB {
+		inB[file.Filename] = file
+	}
+	var common []*execout.FileInfo
+	for _, file := range filesA {
+		if inB[file.Filename] == nil {
+			report.OnlyInA = append(report.OnlyInA, "outputs/"+file.Filename)
+			continue
+		}
+		delete(inB, file.Filename)
+		common = append(common, file)
+	}
+	for filename := range inB {
+		report.OnlyInB = append(report.OnlyInB, "outputs/"+filename)
+	}
+
+	for _, file := range common {
+		fileA, fileB := configA.NewFile(file.BlockRange), configB.NewFile(file.BlockRange)
+		if err := fileA.Load(ctx); err != nil {
+			return fmt.Errorf("loading %s of module %q in cache A: %w", file.Filename, report.ModuleName, err)
+		}
+		if err := fileB.Load(ctx); err != nil {
+			return fmt.Errorf("loading %s of module %q in cache B: %w", file.Filename, report.ModuleName, err)
+		}
+
+		if divergence := compareItems(fileA.SortedItems(), fileB.SortedItems()); divergence != nil {
+			divergence.File = "outputs/" + file.Filename
+			report.Divergence = divergence
+			return nil
+		}
+		report.ComparedOutputs++
+	}
+	return nil
+}
+
+// compareItems returns the first divergence between the items `a` and `b`,
+// sorted by block number.
+func compareItems(a, b []*pboutput.Item) *Divergence {
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case j == len(b) || (i < len(a) && a[i].BlockNum < b[j].BlockNum):
+			return &Divergence{BlockNum: a[i].BlockNum, Reason: "output missing in B", A: a[i].Payload}
+		case i == len(a) || b[j].BlockNum < a[i].BlockNum:
+			return &Divergence{BlockNum: b[j].BlockNum, Reason: "output missing in A", B: b[j].Payload}
+		case a[i].BlockId != b[j].BlockId:
+			return &Divergence{BlockNum: a[i].BlockNum, Reason: fmt.Sprintf("block id %q in A, %q in B", a[i].BlockId, b[j].BlockId), A: a[i].Payload, B: b[j].Payload}
+		case !bytes.Equal(a[i].Payload, b[j].Payload):
+			return &Divergence{BlockNum: a[i].BlockNum, Reason: "outputs differ", A: a[i].Payload, B: b[j].Payload}
+		}
+		i++
+		j++
+	}
+	return nil
+}
+
+func compareSnapshots(ctx context.Context, report *ModuleReport, configA, configB *store.Config, logger *zap.Logger) error {
+	filesA, err := listFullKVs(ctx, configA)
+	if err != nil {
+		return fmt.Errorf("listing snapshots of store %q in cache A: %w", report.ModuleName, err)
+	}
+	filesB, err := listFullKVs(ctx, configB)
+	if err != nil {
+		return fmt.Errorf("listing snapshots of store %q in cache B: %w", report.ModuleName, err)
+	}
+
+	for filename := range filesA {
+		if filesB[filename] == nil {
+			report.OnlyInA = append(report.OnlyInA, "states/"+filename)
+			delete(filesA, filename)
+		}
+	}
+	for filename := range filesB {
+		if filesA[filename] == nil {
+			report.OnlyInB = append(report.OnlyInB, "states/"+filename)
+		}
+	}
+
+	common := make([]*store.FileInfo, 0, len(filesA))
+	for _, file := range filesA {
+		common = append(common, file)
+	}
+	sort.Slice(common, func(i, j int) bool {
+		return common[i].Range.ExclusiveEndBlock < common[j].Range.ExclusiveEndBlock
+	})
+
+	for _, file := range common {
+		lastBlock := file.Range.ExclusiveEndBlock - 1
+		if report.Divergence != nil && report.Divergence.BlockNum <= lastBlock {
+			return nil
+		}
+
+		kvA, kvB := configA.NewFullKV(logger), configB.NewFullKV(logger)
+		if err := kvA.Load(ctx, file); err != nil {
+			return fmt.Errorf("loading %s of store %q in cache A: %w", file.Filename, report.ModuleName, err)
+		}
+		if err := kvB.Load(ctx, filesB[file.Filename]); err != nil {
+			return fmt.Errorf("loading %s of store %q in cache B: %w", file.Filename, report.ModuleName, err)
+		}
+
+		divergence, err := compareKVs(kvA, kvB)
+		if err != nil {
+			return fmt.Errorf("comparing %s of store %q: %w", file.Filename, report.ModuleName, err)
+		}
+		if divergence != nil {
+			divergence.File = "states/" + file.Filename
+			divergence.BlockNum = lastBlock
+			report.Divergence = divergence
+			return nil
+		}
+		report.ComparedSnapshots++
+	}
+	return nil
+}
+
+func listFullKVs(ctx context.Context, config *store.Config) (map[string]*store.FileInfo, error) {
+	files, err := config.ListSnapshotFiles(ctx, math.MaxUint64)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]*store.FileInfo, len(files))
+	for _, file := range files {
+		if !file.Partial {
+			out[file.Filename] = file
+		}
+	}
+	return out, nil
+}
+
+// compareKVs returns the first divergence between the keys of `a` and `b`,
+// in lexical order.
+func compareKVs(a, b *store.FullKV) (*Divergence, error) {
+	valuesB := make(map[string][]byte, b.Length())
+	if err := b.Iter(func(key string, value []byte) error {
+		valuesB[key] = value
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var first *Divergence
+	diverge := func(d *Divergence) {
+		if first == nil || d.Key < first.Key {
+			first = d
+		}
+	}
+	if err := a.Iter(func(key string, value []byte) error {
+		valueB, found := valuesB[key]
+		switch {
+		case !found:
+			diverge(&Divergence{Key: key, Reason: "key missing in B", A: value})
+		case !bytes.Equal(value, valueB):
+			diverge(&Divergence{Key: key, Reason: "values differ", A: value, B: valueB})
+		}
+		delete(valuesB, key)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	for key, value := range valuesB {
+		diverge(&Divergence{Key: key, Reason: "key missing in A", B: value})
+	}
+	return first, nil
+}
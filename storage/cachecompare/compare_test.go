@@ -0,0 +1,129 @@
+package cachecompare
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/block"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/storage/execout"
+	pboutput "github.com/streamingfast/substreams/storage/execout/pb"
+	"github.com/streamingfast/substreams/storage/store"
+)
+
+var testHash = strings.Repeat("a", 40)
+
+var testModule = &pbsubstreams.Module{
+	Name:         "store_test",
+	InitialBlock: 1,
+	Kind: &pbsubstreams.Module_KindStore_{KindStore: &pbsubstreams.Module_KindStore{
+		UpdatePolicy: pbsubstreams.Module_KindStore_UPDATE_POLICY_SET,
+		ValueType:    "string",
+	}},
+}
+
+func newTestStore(t *testing.T) dstore.Store {
+	t.Helper()
+	s, err := dstore.NewStore("file://"+t.TempDir(), "zst", "", true)
+	require.NoError(t, err)
+	return s
+}
+
+// writeSnapshot writes the full snapshot of the test store ending at
+// `endBlock`, holding `kv`.
+func writeSnapshot(t *testing.T, cache dstore.Store, endBlock uint64, kv map[string]string) {
+	t.Helper()
+	config, err := store.NewConfig(testModule.Name, testModule.InitialBlock, testHash, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", cache, "")
+	require.NoError(t, err)
+	s := config.NewFullKV(zap.NewNop())
+	for key, value := range kv {
+		s.Set(0, key, value)
+	}
+	_, writer, err := s.Save(endBlock)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(context.Background()))
+}
+
+// writeOutputs writes the outputs of the test store over
+// [startBlock, endBlock), `payloads` by block number.
+func writeOutputs(t *testing.T, cache dstore.Store, startBlock, endBlock uint64, payloads map[uint64]string) {
+	t.Helper()
+	config, err := execout.NewConfig(testModule.Name, testModule.InitialBlock, pbsubstreams.ModuleKindStore, testHash, cache, zap.NewNop())
+	require.NoError(t, err)
+	file := config.NewFile(block.NewRange(startBlock, endBlock))
+	for blockNum, payload := range payloads {
+		file.SetItem(&pbsubstreams.Clock{Number: blockNum, Id: fmt.Sprintf("id%d", blockNum)}, []byte(payload))
+	}
+	require.NoError(t, file.Save(context.Background()))
+}
+
+func TestCompareModule(t *testing.T) {
+	ctx := context.Background()
+
+	a, b := newTestStore(t), newTestStore(t)
+	for _, cache := range []dstore.Store{a, b} {
+		writeSnapshot(t, cache, 10, map[string]string{"k1": "v1", "k2": "v2"})
+		writeOutputs(t, cache, 1, 10, map[uint64]string{2: "x", 5: "y"})
+	}
+	writeSnapshot(t, a, 20, map[string]string{"k1": "v1"})
+	writeOutputs(t, b, 10, 20, map[uint64]string{12: "z"})
+
+	report, err := CompareModule(ctx, testModule, testHash, a, b, zap.NewNop())
+	require.NoError(t, err)
+	assert.Nil(t, report.Divergence)
+	assert.Equal(t, 1, report.ComparedSnapshots)
+	assert.Equal(t, 1, report.ComparedOutputs)
+	assert.Equal(t, []string{"states/0000000020-0000000001.kv"}, report.OnlyInA)
+	assert.Equal(t, []string{"outputs/0000000010-0000000020.output"}, report.OnlyInB)
+
+	// A diverging store snapshot reports its first diverging key.
+	writeSnapshot(t, b, 20, map[string]string{"k0": "v0", "k1": "other"})
+	report, err = CompareModule(ctx, testModule, testHash, a, b, zap.NewNop())
+	require.NoError(t, err)
+	require.NotNil(t, report.Divergence)
+	assert.Equal(t, &Divergence{File: "states/0000000020-0000000001.kv", BlockNum: 19, Key: "k0", Reason: "key missing in A", B: []byte("v0")}, report.Divergence)
+	assert.Equal(t, 1, report.ComparedSnapshots)
+
+	// An earlier output divergence is reported instead.
+	writeOutputs(t, b, 1, 10, map[uint64]string{2: "x", 5: "other"})
+	report, err = CompareModule(ctx, testModule, testHash, a, b, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, &Divergence{File: "outputs/0000000001-0000000010.output", BlockNum: 5, Reason: "outputs differ", A: []byte("y"), B: []byte("other")}, report.Divergence)
+	assert.Equal(t, 0, report.ComparedOutputs)
+	assert.Equal(t, 0, report.ComparedSnapshots)
+}
+
+func TestCompareItems(t *testing.T) {
+	items := func(payloads map[uint64]string) (out []*pboutput.Item) {
+		for blockNum := uint64(0); blockNum < 10; blockNum++ {
+			if payload, found := payloads[blockNum]; found {
+				out = append(out, &pboutput.Item{BlockNum: blockNum, BlockId: fmt.Sprintf("id%d", blockNum), Payload: []byte(payload)})
+			}
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		a, b   map[uint64]string
+		expect *Divergence
+	}{
+		{"equal", map[uint64]string{1: "a", 2: "b"}, map[uint64]string{1: "a", 2: "b"}, nil},
+		{"missing in B", map[uint64]string{1: "a", 2: "b"}, map[uint64]string{1: "a"}, &Divergence{BlockNum: 2, Reason: "output missing in B", A: []byte("b")}},
+		{"missing in A", map[uint64]string{3: "c"}, map[uint64]string{1: "a", 3: "c"}, &Divergence{BlockNum: 1, Reason: "output missing in A", B: []byte("a")}},
+		{"differ", map[uint64]string{1: "a", 2: "b"}, map[uint64]string{1: "a", 2: "c"}, &Divergence{BlockNum: 2, Reason: "outputs differ", A: []byte("b"), B: []byte("c")}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expect, compareItems(items(test.a), items(test.b)))
+		})
+	}
+}
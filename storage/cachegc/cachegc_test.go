@@ -0,0 +1,206 @@
+package cachegc
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var (
+	hashA = strings.Repeat("a", 40)
+	hashB = strings.Repeat("b", 40)
+	hashC = strings.Repeat("c", 40)
+)
+
+func newTestStore(t *testing.T) dstore.Store {
+	t.Helper()
+	s, err := dstore.NewStore("file://"+t.TempDir(), "zst", "", true)
+	require.NoError(t, err)
+	return s
+}
+
+// writeFile writes `size` bytes to `name`, last modified `age` ago.
+func writeFile(t *testing.T, s dstore.Store, name string, size int, age time.Duration) {
+	t.Helper()
+	require.NoError(t, s.WriteObject(context.Background(), name, bytes.NewReader(make([]byte, size))))
+	modTime := time.Now().Add(-age)
+	require.NoError(t, os.Chtimes(s.ObjectPath(name), modTime, modTime))
+}
+
+func TestScan(t *testing.T) {
+	s := newTestStore(t)
+	day := 24 * time.Hour
+	writeFile(t, s, "tag1/"+hashA+"/states/0000000200-0000000001.kv", 100, 10*day)
+	writeFile(t, s, "tag1/"+hashA+"/states/0000000300-0000000200.trace.partial", 50, 5*day)
+	writeFile(t, s, "tag1/"+hashA+"/"+AccessMarkerFilename, 10, 3*day)
+	writeFile(t, s, "tag1/"+hashB+"/outputs/0000000001-0000000200.output", 30, 8*day)
+	writeFile(t, s, "tag1/extensions/mod/0000000001-abc/eth.call.abc", 5, day)
+	writeFile(t, s, hashC+"/states/0000000200-0000000001.kv", 20, 2*day)
+
+	modules, err := Scan(context.Background(), s, nil, 4, zap.NewNop())
+	require.NoError(t, err)
+	require.Len(t, modules, 3)
+
+	assert.Equal(t, "", modules[0].CacheTag)
+	assert.Equal(t, hashC, modules[0].ModuleHash)
+	assert.Equal(t, hashC, modules[0].Path())
+	assert.False(t, modules[0].HasMarker)
+
+	a := modules[1]
+	assert.Equal(t, "tag1/"+hashA, a.Path())
+	assert.Equal(t, uint64(160), a.SizeBytes)
+	assert.True(t, a.HasMarker)
+	assert.WithinDuration(t, time.Now().Add(-3*day), a.LastAccess, time.Minute)
+	var partials []string
+	for _, file := range a.Files {
+		if file.Partial {
+			partials = append(partials, file.Name)
+		}
+	}
+	assert.Equal(t, []string{"tag1/" + hashA + "/states/0000000300-0000000200.trace.partial"}, partials)
+
+	b := modules[2]
+	assert.Equal(t, "tag1/"+hashB, b.Path())
+	assert.False(t, b.HasMarker)
+	assert.WithinDuration(t, time.Now().Add(-8*day), b.LastAccess, time.Minute)
+
+	modules, err = Scan(context.Background(), s, []string{"tag1"}, 4, zap.NewNop())
+	require.NoError(t, err)
+	require.Len(t, modules, 2)
+	assert.Equal(t, hashA, modules[0].ModuleHash)
+	assert.Equal(t, hashB, modules[1].ModuleHash)
+}
+
+func TestPlan(t *testing.T) {
+	now := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	module := func(hash string, lastAccess time.Duration, files ...*File) *ModuleCache {
+		m := &ModuleCache{CacheTag: "tag", ModuleHash: hash, LastAccess: now.Add(-lastAccess), HasMarker: true, Files: files}
+		m.SizeBytes = sizeOf(files)
+		return m
+	}
+	file := func(name string, size uint64, age time.Duration) *File {
+		return &File{Name: name, SizeBytes: size, LastModified: now.Add(-age), Partial: strings.HasSuffix(name, ".partial")}
+	}
+
+	modules := []*ModuleCache{
+		module("in-flight", time.Minute, file("a.kv", 100, 40*day), file("a.partial", 10, 40*day)),
+		module("old", 40*day, file("b.kv", 100, 40*day)),
+		module("partials", 2*day, file("c.kv", 100, 2*day), file("c1.partial", 10, 10*day), file("c2.partial", 10, day)),
+		module("least-recent", 10*day, file("d.kv", 100, 10*day)),
+		module("recent", 5*day, file("e.kv", 100, 5*day)),
+	}
+
+	report := Plan(modules, &Policy{MaxAge: 30 * day, PartialMaxAge: 7 * day, MaxSizeBytes: 350, InFlightGrace: time.Hour}, now)
+
+	actions := map[string]Action{}
+	for _, d := range report.Decisions {
+		actions[d.ModuleHash] = d.Action
+	}
+	assert.Equal(t, map[string]Action{
+		"in-flight":    ActionProtect,
+		"old":          ActionDelete,
+		"partials":     ActionDeletePartials,
+		"least-recent": ActionDelete,
+		"recent":       ActionKeep,
+	}, actions)
+
+	assert.Equal(t, 5, report.ModuleCount)
+	assert.Equal(t, uint64(530), report.SizeBytes)
+	assert.Equal(t, 2, report.DeletedModuleCount)
+	assert.Equal(t, 3, report.DeletedFileCount)
+	assert.Equal(t, uint64(210), report.DeletedBytes)
+	assert.Equal(t, []*File{modules[2].Files[1]}, report.Decisions[2].deleted)
+
+	// The modules without an access marker are only collected when forced.
+	unmarked := module("unmarked", 40*day, file("f.kv", 100, 40*day), file("f.partial", 10, 40*day))
+	unmarked.HasMarker = false
+	policy := &Policy{MaxAge: 30 * day, PartialMaxAge: 7 * day, MaxSizeBytes: 1, InFlightGrace: time.Hour}
+	report = Plan([]*ModuleCache{unmarked}, policy, now)
+	assert.Equal(t, ActionProtect, report.Decisions[0].Action)
+	assert.Equal(t, 0, report.DeletedFileCount)
+
+	policy.CollectUnmarked = true
+	report = Plan([]*ModuleCache{unmarked}, policy, now)
+	assert.Equal(t, ActionDelete, report.Decisions[0].Action)
+	assert.Equal(t, 2, report.DeletedFileCount)
+}
+
+func TestDelete_SnapshotsNewestFirst(t *testing.T) {
+	path := "tag/" + hashA
+	var files []*File
+	for _, name := range []string{
+		"/states/0000000100-0000000001.kv",
+		"/outputs/0000000001-0000000100.output",
+		"/states/0000000300-0000000001.kv",
+		"/states/0000000200-0000000001.kv",
+		"/" + AccessMarkerFilename,
+	} {
+		files = append(files, &File{Name: path + name, SizeBytes: 10})
+	}
+	module := &ModuleCache{CacheTag: "tag", ModuleHash: hashA, Files: files, HasMarker: true}
+	report := Plan([]*ModuleCache{module}, &Policy{MaxAge: time.Hour}, time.Now())
+
+	var deleted []string
+	s := dstore.NewMockStore(nil)
+	s.ObjectAttributesFunc = func(ctx context.Context, base string) (*dstore.ObjectAttributes, error) {
+		return nil, dstore.ErrNotFound
+	}
+	s.DeleteObjectFunc = func(ctx context.Context, base string) error {
+		deleted = append(deleted, base)
+		return nil
+	}
+	require.NoError(t, Delete(context.Background(), s, report, 1, zap.NewNop()))
+	assert.Equal(t, []string{
+		path + "/states/0000000300-0000000001.kv",
+		path + "/states/0000000200-0000000001.kv",
+		path + "/states/0000000100-0000000001.kv",
+		path + "/outputs/0000000001-0000000100.output",
+		path + "/" + AccessMarkerFilename,
+	}, deleted)
+}
+
+func TestDelete(t *testing.T) {
+	s := newTestStore(t)
+	day := 24 * time.Hour
+	writeFile(t, s, "tag/"+hashA+"/states/0000000200-0000000001.kv", 100, 40*day)
+	writeFile(t, s, "tag/"+hashA+"/"+AccessMarkerFilename, 10, 40*day)
+	writeFile(t, s, "tag/"+hashB+"/states/0000000200-0000000001.kv", 100, 40*day)
+	writeFile(t, s, "tag/"+hashB+"/"+AccessMarkerFilename, 10, 40*day)
+	writeFile(t, s, "tag/"+hashC+"/states/0000000200-0000000001.kv", 100, 2*day)
+	writeFile(t, s, "tag/"+hashC+"/states/0000000300-0000000200.trace.partial", 10, 10*day)
+
+	modules, err := Scan(context.Background(), s, nil, 4, zap.NewNop())
+	require.NoError(t, err)
+	report := Plan(modules, &Policy{MaxAge: 30 * day, PartialMaxAge: 7 * day, InFlightGrace: time.Hour, CollectUnmarked: true}, time.Now())
+	assert.Equal(t, 5, report.DeletedFileCount)
+
+	// A request uses the second module after the scan.
+	cacheStore, err := s.SubStore("tag")
+	require.NoError(t, err)
+	require.NoError(t, WriteAccessMarker(context.Background(), cacheStore, hashB, "trace"))
+
+	require.NoError(t, Delete(context.Background(), s, report, 4, zap.NewNop()))
+	var remaining []string
+	require.NoError(t, s.Walk(context.Background(), "", func(filename string) error {
+		remaining = append(remaining, filename)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{
+		"tag/" + hashB + "/states/0000000200-0000000001.kv",
+		"tag/" + hashB + "/" + AccessMarkerFilename,
+		"tag/" + hashC + "/states/0000000200-0000000001.kv",
+	}, remaining)
+	assert.Equal(t, ActionProtect, report.Decisions[1].Action)
+	assert.Equal(t, 1, report.DeletedModuleCount)
+	assert.Equal(t, 3, report.DeletedFileCount)
+	assert.Equal(t, uint64(120), report.DeletedBytes)
+}
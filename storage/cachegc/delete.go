@@ -0,0 +1,129 @@
+package cachegc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/abourget/llerrgroup"
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+)
+
+// Delete deletes the files planned for deletion in `report` from
+// `baseStore`. The access marker of each module is read again first: a
+// module accessed since the scan, by a request started in between, is
+// protected instead. The full store snapshots are deleted first, from the
+// newest to the oldest. The marker of a deleted module is deleted last, after
+// checking it once more, so an interrupted collection is resumed by the
+// next one. The report is updated with the files actually deleted.
+func Delete(ctx context.Context, baseStore dstore.Store, report *Report, parallel int, logger *zap.Logger) error {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	for _, d := range report.Decisions {
+		if len(d.deleted) == 0 {
+			continue
+		}
+
+		accessed, err := accessedSince(ctx, baseStore, d)
+		if err != nil {
+			return err
+		}
+		if accessed {
+			logger.Info("skipping module accessed during the garbage collection", zap.String("cache_tag", d.CacheTag), zap.String("module_hash", d.ModuleHash))
+			d.Action, d.Reason, d.deleted = ActionProtect, "accessed during the garbage collection", nil
+			continue
+		}
+
+		markerName := d.module.Path() + "/" + AccessMarkerFilename
+		var marker *File
+		var snapshots, others []*File
+		for _, file := range d.deleted {
+			switch {
+			case file.Name == markerName:
+				marker = file
+			case isFullSnapshot(d.module, file):
+				snapshots = append(snapshots, file)
+			default:
+				others = append(others, file)
+			}
+		}
+
+		// Incremental snapshots are loaded through the chain of their bases,
+		// the newest are deleted first so an interrupted collection never
+		// leaves a snapshot whose base is gone.
+		sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name > snapshots[j].Name })
+		for _, file := range snapshots {
+			if err := deleteFile(ctx, baseStore, file.Name); err != nil {
+				return fmt.Errorf("deleting files of module %s: %w", d.module.Path(), err)
+			}
+		}
+
+		eg := llerrgroup.New(parallel)
+		for _, file := range others {
+			if eg.Stop() {
+				break
+			}
+			file := file
+			eg.Go(func() error {
+				return deleteFile(ctx, baseStore, file.Name)
+			})
+		}
+		if err := eg.Wait(); err != nil {
+			return fmt.Errorf("deleting files of module %s: %w", d.module.Path(), err)
+		}
+
+		if marker != nil {
+			accessed, err := accessedSince(ctx, baseStore, d)
+			if err != nil {
+				return err
+			}
+			if accessed {
+				d.deleted = d.deleted[:0:0]
+				for _, file := range d.module.Files {
+					if file != marker {
+						d.deleted = append(d.deleted, file)
+					}
+				}
+				d.Reason += ", access marker kept, written during the garbage collection"
+			} else if err := deleteFile(ctx, baseStore, marker.Name); err != nil {
+				return fmt.Errorf("deleting access marker of module %s: %w", d.module.Path(), err)
+			}
+		}
+		logger.Debug("deleted module files", zap.String("cache_tag", d.CacheTag), zap.String("module_hash", d.ModuleHash), zap.String("action", string(d.Action)), zap.Int("file_count", len(d.deleted)))
+	}
+
+	report.count()
+	return nil
+}
+
+// accessedSince returns whether the access marker of the module of `d` was
+// written after the scan.
+func accessedSince(ctx context.Context, baseStore dstore.Store, d *Decision) (bool, error) {
+	attrs, err := baseStore.ObjectAttributes(ctx, d.module.Path()+"/"+AccessMarkerFilename)
+	if errors.Is(err, dstore.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading access marker of module %s: %w", d.module.Path(), err)
+	}
+	return !d.HasMarker || attrs.LastModified.After(d.LastAccess), nil
+}
+
+func deleteFile(ctx context.Context, baseStore dstore.Store, filename string) error {
+	err := baseStore.DeleteObject(ctx, filename)
+	if err != nil && !errors.Is(err, dstore.ErrNotFound) {
+		return fmt.Errorf("deleting %s: %w", filename, err)
+	}
+	return nil
+}
+
+// isFullSnapshot tells if `file` is a full store snapshot of `module`,
+// possibly an incremental one.
+func isFullSnapshot(module *ModuleCache, file *File) bool {
+	return strings.HasPrefix(file.Name, module.Path()+"/states/") && strings.HasSuffix(file.Name, ".kv")
+}
@@ -0,0 +1,81 @@
+package cachegc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+)
+
+// AccessMarkerFilename is the file, in the directory of a module hash in a
+// cache tag, rewritten by tier1 while a request uses the module. Its last
+// modification time is the last access to the cached files of the module.
+const AccessMarkerFilename = "substreams.last-access"
+
+type accessMarker struct {
+	TraceID    string    `json:"trace_id"`
+	AccessedAt time.Time `json:"accessed_at"`
+}
+
+// WriteAccessMarker records an access to the cached files of `moduleHash` in
+// `cacheStore`, the store of a cache tag.
+func WriteAccessMarker(ctx context.Context, cacheStore dstore.Store, moduleHash, traceID string) error {
+	cnt, err := json.Marshal(&accessMarker{TraceID: traceID, AccessedAt: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("marshalling access marker: %w", err)
+	}
+	filename := moduleHash + "/" + AccessMarkerFilename
+	if err := cacheStore.WriteObject(ctx, filename, bytes.NewReader(cnt)); err != nil {
+		return fmt.Errorf("writing access marker %s: %w", filename, err)
+	}
+	return nil
+}
+
+// TrackAccess writes the access markers of `moduleHashes` right away, then
+// every `interval` until the returned function is called, so the garbage
+// collection sees the modules of a running request as recently accessed.
+// Failing to write a marker is only logged.
+func TrackAccess(ctx context.Context, cacheStore dstore.Store, moduleHashes []string, interval time.Duration, traceID string, logger *zap.Logger) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	writeAll := func() {
+		wg := sync.WaitGroup{}
+		for _, hash := range moduleHashes {
+			wg.Add(1)
+			go func(hash string) {
+				defer wg.Done()
+				if err := WriteAccessMarker(ctx, cacheStore, hash, traceID); err != nil && ctx.Err() == nil {
+					logger.Warn("cannot write cache access marker", zap.String("module_hash", hash), zap.Error(err))
+				}
+			}(hash)
+		}
+		wg.Wait()
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		writeAll()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				writeAll()
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,156 @@
+package cachegc
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Policy decides which cached files are deleted. A zero limit is disabled.
+type Policy struct {
+	MaxAge        time.Duration // the modules not accessed for longer are deleted
+	PartialMaxAge time.Duration // the partial store files older than this, left by crashed jobs, are deleted
+	MaxSizeBytes  uint64        // the least recently accessed modules are deleted until the cache fits in this size
+	InFlightGrace time.Duration // the modules accessed more recently are never deleted, it must exceed the refresh interval of the access markers
+
+	// CollectUnmarked allows deleting the modules without an access marker,
+	// by the last write of their files. They are protected otherwise, as
+	// they may be read by servers not writing the markers.
+	CollectUnmarked bool
+}
+
+type Action string
+
+const (
+	ActionKeep           Action = "keep"
+	ActionProtect        Action = "protect"
+	ActionDelete         Action = "delete"
+	ActionDeletePartials Action = "delete_partials"
+)
+
+// Decision is the action taken on the files of a module.
+type Decision struct {
+	CacheTag   string    `json:"cache_tag"`
+	ModuleHash string    `json:"module_hash"`
+	Action     Action    `json:"action"`
+	Reason     string    `json:"reason,omitempty"`
+	LastAccess time.Time `json:"last_access"`
+	HasMarker  bool      `json:"has_access_marker"`
+	SizeBytes  uint64    `json:"size_bytes"`
+	FileCount  int       `json:"file_count"`
+
+	DeletedBytes     uint64 `json:"deleted_bytes"`
+	DeletedFileCount int    `json:"deleted_file_count"`
+
+	module  *ModuleCache
+	deleted []*File
+}
+
+// Report holds the decisions of a garbage collection, sorted like the
+// modules it was planned from.
+type Report struct {
+	Policy    *Policy     `json:"-"`
+	Decisions []*Decision `json:"modules"`
+
+	ModuleCount        int    `json:"module_count"`
+	SizeBytes          uint64 `json:"size_bytes"`
+	DeletedModuleCount int    `json:"deleted_module_count"`
+	DeletedFileCount   int    `json:"deleted_file_count"`
+	DeletedBytes       uint64 `json:"deleted_bytes"`
+	DryRun             bool   `json:"dry_run"`
+}
+
+// Plan decides, at `now`, the files of `modules` to delete according to
+// `policy`. The modules accessed within the in-flight grace period, and the
+// ones without an access marker unless CollectUnmarked is set, are
+// protected, the others are deleted when not accessed for longer than the
+// maximum age, then their old partial files, and finally the least recently
+// accessed modules until the cache fits in the maximum size.
+func Plan(modules []*ModuleCache, policy *Policy, now time.Time) *Report {
+	report := &Report{Policy: policy}
+	for _, module := range modules {
+		d := &Decision{
+			CacheTag:   module.CacheTag,
+			ModuleHash: module.ModuleHash,
+			Action:     ActionKeep,
+			LastAccess: module.LastAccess,
+			HasMarker:  module.HasMarker,
+			SizeBytes:  module.SizeBytes,
+			FileCount:  len(module.Files),
+			module:     module,
+		}
+		report.Decisions = append(report.Decisions, d)
+		report.ModuleCount++
+		report.SizeBytes += module.SizeBytes
+
+		age := now.Sub(module.LastAccess)
+		switch {
+		case age < policy.InFlightGrace:
+			d.Action, d.Reason = ActionProtect, fmt.Sprintf("accessed %s ago", age.Round(time.Second))
+		case !module.HasMarker && !policy.CollectUnmarked:
+			d.Action, d.Reason = ActionProtect, "no access marker"
+		case policy.MaxAge != 0 && age > policy.MaxAge:
+			d.deleteAll(fmt.Sprintf("not accessed for %s", age.Round(time.Second)))
+			if !module.HasMarker {
+				d.Reason = fmt.Sprintf("no access marker, last modified %s ago", age.Round(time.Second))
+			}
+		case policy.PartialMaxAge != 0:
+			for _, file := range module.Files {
+				if file.Partial && now.Sub(file.LastModified) > policy.PartialMaxAge {
+					d.deleted = append(d.deleted, file)
+				}
+			}
+			if len(d.deleted) != 0 {
+				d.Action, d.Reason = ActionDeletePartials, fmt.Sprintf("partial files older than %s", policy.PartialMaxAge)
+			}
+		}
+	}
+
+	if policy.MaxSizeBytes != 0 {
+		var remaining uint64
+		var candidates []*Decision
+		for _, d := range report.Decisions {
+			remaining += d.SizeBytes - sizeOf(d.deleted)
+			if d.Action == ActionKeep || d.Action == ActionDeletePartials {
+				candidates = append(candidates, d)
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].LastAccess.Before(candidates[j].LastAccess)
+		})
+		for _, d := range candidates {
+			if remaining <= policy.MaxSizeBytes {
+				break
+			}
+			remaining -= d.SizeBytes - sizeOf(d.deleted)
+			d.deleteAll(fmt.Sprintf("cache over %d bytes, least recently accessed", policy.MaxSizeBytes))
+		}
+	}
+
+	report.count()
+	return report
+}
+
+func (r *Report) count() {
+	r.DeletedModuleCount, r.DeletedFileCount, r.DeletedBytes = 0, 0, 0
+	for _, d := range r.Decisions {
+		d.DeletedFileCount, d.DeletedBytes = len(d.deleted), sizeOf(d.deleted)
+		if d.Action == ActionDelete {
+			r.DeletedModuleCount++
+		}
+		r.DeletedFileCount += d.DeletedFileCount
+		r.DeletedBytes += d.DeletedBytes
+	}
+}
+
+func (d *Decision) deleteAll(reason string) {
+	d.Action, d.Reason = ActionDelete, reason
+	d.deleted = d.module.Files
+}
+
+func sizeOf(files []*File) (out uint64) {
+	for _, file := range files {
+		out += file.SizeBytes
+	}
+	return out
+}
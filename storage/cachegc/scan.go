@@ -0,0 +1,174 @@
+package cachegc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/abourget/llerrgroup"
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+)
+
+var isModuleHash = regexp.MustCompile(`^[0-9a-f]{40}$`).MatchString
+
+// ModuleCache is the set of files cached for a module hash in a cache tag:
+// its store snapshots, outputs, package and access marker.
+type ModuleCache struct {
+	CacheTag   string
+	ModuleHash string
+	Files      []*File
+	SizeBytes  uint64
+
+	// LastAccess is the last modification of the access marker, or of the
+	// most recent file when HasMarker is false.
+	LastAccess time.Time
+	HasMarker  bool
+}
+
+// File is a cached file, its Name relative to the base store.
+type File struct {
+	Name         string
+	SizeBytes    uint64
+	LastModified time.Time
+	Partial      bool
+}
+
+// Path returns the directory of the module in the base store.
+func (m *ModuleCache) Path() string {
+	if m.CacheTag == "" {
+		return m.ModuleHash
+	}
+	return m.CacheTag + "/" + m.ModuleHash
+}
+
+// Scan walks `baseStore`, the base of all the cache tags, and groups its
+// files per cache tag and module hash, sorted by cache tag and module hash.
+// Only the cache tags in `cacheTags` are walked if any, the empty tag being
+// the module hashes at the root of `baseStore`. The files outside of a module
+// hash directory, like the recorded wasm extension calls, are ignored.
+func Scan(ctx context.Context, baseStore dstore.Store, cacheTags []string, parallel int, logger *zap.Logger) ([]*ModuleCache, error) {
+	var prefixes []string
+	for _, tag := range cacheTags {
+		if tag == "" {
+			prefixes = []string{""}
+			break
+		}
+		prefixes = append(prefixes, tag+"/")
+	}
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+	wantTag := func(tag string) bool {
+		if len(cacheTags) == 0 {
+			return true
+		}
+		for _, t := range cacheTags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	}
+
+	modules := map[string]*ModuleCache{}
+	var files []*File
+	for _, prefix := range prefixes {
+		err := baseStore.Walk(ctx, prefix, func(filename string) error {
+			tag, hash, ok := splitModulePath(filename)
+			if !ok || !wantTag(tag) {
+				logger.Debug("skipping file outside of a module hash directory", zap.String("filename", filename))
+				return nil
+			}
+
+			module := &ModuleCache{CacheTag: tag, ModuleHash: hash}
+			if existing, found := modules[module.Path()]; found {
+				module = existing
+			} else {
+				modules[module.Path()] = module
+			}
+
+			file := &File{
+				Name:    filename,
+				Partial: strings.HasPrefix(filename, module.Path()+"/states/") && strings.HasSuffix(filename, ".partial"),
+			}
+			module.Files = append(module.Files, file)
+			files = append(files, file)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walking %q: %w", prefix, err)
+		}
+	}
+
+	if err := readAttributes(ctx, baseStore, files, parallel); err != nil {
+		return nil, err
+	}
+
+	out := make([]*ModuleCache, 0, len(modules))
+	for _, module := range modules {
+		markerName := module.Path() + "/" + AccessMarkerFilename
+		for _, file := range module.Files {
+			module.SizeBytes += file.SizeBytes
+			switch {
+			case file.Name == markerName:
+				module.LastAccess, module.HasMarker = file.LastModified, true
+			case !module.HasMarker && file.LastModified.After(module.LastAccess):
+				module.LastAccess = file.LastModified
+			}
+		}
+		out = append(out, module)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CacheTag == out[j].CacheTag {
+			return out[i].ModuleHash < out[j].ModuleHash
+		}
+		return out[i].CacheTag < out[j].CacheTag
+	})
+	return out, nil
+}
+
+// splitModulePath returns the cache tag and module hash of a file in the
+// directory of a module, either `<cache_tag>/<module_hash>/...` or
+// `<module_hash>/...` for the empty cache tag.
+func splitModulePath(filename string) (cacheTag, moduleHash string, ok bool) {
+	parts := strings.SplitN(filename, "/", 3)
+	switch {
+	case len(parts) >= 2 && isModuleHash(parts[0]):
+		return "", parts[0], true
+	case len(parts) == 3 && isModuleHash(parts[1]):
+		return parts[0], parts[1], true
+	}
+	return "", "", false
+}
+
+func readAttributes(ctx context.Context, baseStore dstore.Store, files []*File, parallel int) error {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	eg := llerrgroup.New(parallel)
+	for _, file := range files {
+		if eg.Stop() {
+			break
+		}
+		file := file
+		eg.Go(func() error {
+			attrs, err := baseStore.ObjectAttributes(ctx, file.Name)
+			if errors.Is(err, dstore.ErrNotFound) {
+				// Deleted since the walk, like a partial file being merged.
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("reading attributes of %s: %w", file.Name, err)
+			}
+			file.SizeBytes, file.LastModified = uint64(attrs.Size), attrs.LastModified
+			return nil
+		})
+	}
+	return eg.Wait()
+}
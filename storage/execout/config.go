@@ -26,14 +26,18 @@ type Config struct {
 }
 
 func NewConfig(name string, moduleInitialBlock uint64, modKind pbsubstreams.ModuleKind, moduleHash string, baseStore dstore.Store, logger *zap.Logger) (*Config, error) {
-	subStore, err := baseStore.SubStore(fmt.Sprintf("%s/outputs", moduleHash))
+	outputs, err := outputStore(baseStore)
+	if err != nil {
+		return nil, err
+	}
+	subStore, err := outputs.SubStore(fmt.Sprintf("%s/outputs", moduleHash))
 	if err != nil {
 		return nil, fmt.Errorf("creating sub store: %w", err)
 	}
 
 	return &Config{
 		name:               name,
-		objStore:           subStore,
+		objStore:           withRangeReads(subStore),
 		modKind:            modKind,
 		moduleInitialBlock: moduleInitialBlock,
 		moduleHash:         moduleHash,
@@ -80,3 +84,87 @@ func (c *Config) ListSnapshotFiles(ctx context.Context, inRange *bstream.Range)
 
 	return files, nil
 }
+
+// CachedRanges returns the ranges of blocks whose outputs are cached, sorted
+// and merged, contiguous or overlapping files making a single range.
+func (c *Config) CachedRanges(ctx context.Context) (block.Ranges, error) {
+	files, err := c.ListSnapshotFiles(ctx, bstream.NewOpenRange(c.moduleInitialBlock))
+	if err != nil {
+		return nil, fmt.Errorf("listing outputs of module %q: %w", c.name, err)
+	}
+
+	var out block.Ranges
+	for _, fileInfo := range files {
+		last := len(out) - 1
+		if last >= 0 && fileInfo.BlockRange.StartBlock <= out[last].ExclusiveEndBlock {
+			if fileInfo.BlockRange.ExclusiveEndBlock > out[last].ExclusiveEndBlock {
+				out[last].ExclusiveEndBlock = fileInfo.BlockRange.ExclusiveEndBlock
+			}
+			continue
+		}
+		out = append(out, block.NewRange(fileInfo.BlockRange.StartBlock, fileInfo.BlockRange.ExclusiveEndBlock))
+	}
+	return out, nil
+}
+
+// MissingRanges returns the parts of [startBlock, stopBlock) that the sorted
+// and merged `cached` ranges do not cover.
+func MissingRanges(cached block.Ranges, startBlock, stopBlock uint64) (out block.Ranges) {
+	next := startBlock
+	for _, r := range cached {
+		if next >= stopBlock {
+			break
+		}
+		if r.ExclusiveEndBlock <= next {
+			continue
+		}
+		if r.StartBlock > next {
+			out = append(out, block.NewRange(next, min(r.StartBlock, stopBlock)))
+		}
+		next = r.ExclusiveEndBlock
+	}
+	if next < stopBlock {
+		out = append(out, block.NewRange(next, stopBlock))
+	}
+	return out
+}
+
+// ReadOutputs calls `f`, in block order, with the outputs cached for the
+// blocks of [startBlock, stopBlock). The files are read from the one holding
+// `startBlock`, as long as they are contiguous: the returned block is the end
+// of the last file read, lower than `stopBlock` when the cached outputs stop
+// before it.
+func (c *Config) ReadOutputs(ctx context.Context, startBlock, stopBlock uint64, f func(item *pboutput.Item) error) (readUntil uint64, err error) {
+	// Files are named after their start block: the one holding a
+	// `startBlock` in the middle of its segment is only listed when walking
+	// from an earlier block.
+	files, err := c.ListSnapshotFiles(ctx, bstream.NewOpenRange(c.moduleInitialBlock))
+	if err != nil {
+		return 0, fmt.Errorf("listing outputs of module %q: %w", c.name, err)
+	}
+
+	readUntil = startBlock
+	for _, fileInfo := range files {
+		if readUntil >= stopBlock || fileInfo.BlockRange.StartBlock > readUntil {
+			break
+		}
+		if fileInfo.BlockRange.ExclusiveEndBlock <= readUntil {
+			continue
+		}
+
+		file := c.NewFile(fileInfo.BlockRange)
+		if err := file.LoadRange(ctx, readUntil, stopBlock); err != nil {
+			return readUntil, fmt.Errorf("loading outputs %s of module %q: %w", fileInfo.Filename, c.name, err)
+		}
+		for _, item := range file.SortedItems() {
+			if item.BlockNum < readUntil || item.BlockNum >= stopBlock {
+				continue
+			}
+			if err := f(item); err != nil {
+				return readUntil, err
+			}
+		}
+		readUntil = fileInfo.BlockRange.ExclusiveEndBlock
+	}
+	return readUntil, nil
+}
@@ -0,0 +1,76 @@
+package execout
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/block"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	pboutput "github.com/streamingfast/substreams/storage/execout/pb"
+)
+
+func newTestCachedConfig(t *testing.T, ranges ...*block.Range) *Config {
+	config := &Config{name: "A", objStore: newTestStore(), moduleInitialBlock: 100, logger: zap.NewNop()}
+	for _, r := range ranges {
+		file := config.NewFile(r)
+		for i := r.StartBlock; i < r.ExclusiveEndBlock; i++ {
+			file.SetItem(&pbsubstreams.Clock{Number: i, Id: fmt.Sprintf("id%d", i)}, []byte(fmt.Sprintf("output %d", i)))
+		}
+		require.NoError(t, file.Save(context.Background()))
+	}
+	return config
+}
+
+func TestConfig_CachedRanges(t *testing.T) {
+	config := newTestCachedConfig(t,
+		block.NewRange(100, 200),
+		block.NewRange(200, 300),
+		block.NewRange(300, 350), // partial file, later completed
+		block.NewRange(300, 400),
+		block.NewRange(500, 600),
+	)
+
+	ranges, err := config.CachedRanges(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "[100, 400),[500, 600)", ranges.String())
+
+	tests := []struct {
+		start, stop uint64
+		expected    string
+	}{
+		{100, 400, ""},
+		{150, 250, ""},
+		{50, 150, "[50, 100)"},
+		{350, 550, "[400, 500)"},
+		{350, 700, "[400, 500),[600, 700)"},
+		{450, 480, "[450, 480)"},
+		{700, 800, "[700, 800)"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, MissingRanges(ranges, test.start, test.stop).String(), "[%d, %d)", test.start, test.stop)
+	}
+}
+
+func TestConfig_ReadOutputs(t *testing.T) {
+	config := newTestCachedConfig(t, block.NewRange(100, 200), block.NewRange(200, 300), block.NewRange(400, 500))
+
+	var blocks []uint64
+	readUntil, err := config.ReadOutputs(context.Background(), 150, 250, func(item *pboutput.Item) error {
+		blocks = append(blocks, item.BlockNum)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(300), readUntil)
+	require.Len(t, blocks, 100)
+	assert.Equal(t, uint64(150), blocks[0])
+	assert.Equal(t, uint64(249), blocks[99])
+
+	readUntil, err = config.ReadOutputs(context.Background(), 250, 450, func(item *pboutput.Item) error { return nil })
+	require.NoError(t, err)
+	assert.Equal(t, uint64(300), readUntil)
+}
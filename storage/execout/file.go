@@ -3,6 +3,7 @@ package execout
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -15,6 +16,7 @@ import (
 
 	"go.uber.org/zap/zapcore"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/streamingfast/derr"
 	"github.com/streamingfast/dstore"
 	"go.uber.org/zap"
@@ -96,45 +98,150 @@ func (c *File) GetAtBlock(blockNumber uint64) ([]byte, bool) {
 	return nil, false
 }
 
+// Load reads the outputs of all the blocks of the file.
 func (c *File) Load(ctx context.Context) error {
+	return c.LoadRange(ctx, 0, math.MaxUint64)
+}
+
+// LoadRange reads the outputs of the blocks of [startBlock, stopBlock) only,
+// fetching and decoding only these blocks from an output file, see
+// RangeReader. Legacy output files are read whole.
+func (c *File) LoadRange(ctx context.Context, startBlock, stopBlock uint64) error {
 	filename := computeDBinFilename(c.Range.StartBlock, c.Range.ExclusiveEndBlock)
-	c.logger.Debug("loading execout file", zap.String("file_name", filename), zap.Object("block_range", c.Range))
+	c.logger.Debug("loading execout file", zap.String("file_name", filename), zap.Object("block_range", c.Range), zap.Uint64("start_block", startBlock), zap.Uint64("stop_block", stopBlock))
 
 	return derr.RetryContext(ctx, 5, func(ctx context.Context) error {
-		objectReader, err := c.store.OpenObject(ctx, filename)
+		kv, err := c.readItems(ctx, filename, startBlock, stopBlock)
 		if err == dstore.ErrNotFound {
 			return derr.NewFatalError(err)
 		}
-
+		if errors.Is(err, ErrChecksumMismatch) {
+			return derr.NewFatalError(fmt.Errorf("reading file %s: %w", filename, err))
+		}
 		if err != nil {
-			return fmt.Errorf("loading block reader %s: %w", filename, err)
+			return fmt.Errorf("reading file %s: %w", filename, err)
 		}
-		defer objectReader.Close()
 
-		bytes, err := io.ReadAll(objectReader)
+		c.Lock()
+		c.kv = kv
+		c.Unlock()
+
+		c.logger.Debug("outputs data loaded", zap.Int("output_count", len(kv)), zap.Stringer("block_range", c.Range))
+		return nil
+	})
+}
+
+func (c *File) readItems(ctx context.Context, filename string, startBlock, stopBlock uint64) (map[string]*pboutput.Item, error) {
+	rangeReader, canRange := c.store.(RangeReader)
+
+	var reader io.ReadCloser
+	defer func() {
+		if reader != nil {
+			reader.Close()
+		}
+	}()
+	var err error
+	if reader, err = c.store.OpenObject(ctx, filename); err != nil {
+		return nil, err
+	}
+
+	headerData := make([]byte, outputFileHeaderSize)
+	n, err := io.ReadFull(reader, headerData)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	if isCompressedWhole(headerData[:n]) {
+		decoder, err := zstd.NewReader(io.MultiReader(bytes.NewReader(headerData[:n]), reader))
 		if err != nil {
-			return fmt.Errorf("reading store file %s: %w", filename, err)
+			return nil, fmt.Errorf("decompressing file: %w", err)
 		}
+		reader = &decompressingReadCloser{Decoder: decoder, compressed: reader}
+		canRange = false
+
+		if n, err = io.ReadFull(reader, headerData); err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return nil, fmt.Errorf("reading header: %w", err)
+		}
+	}
 
+	if !IsOutputFile(headerData[:n]) {
+		rest, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("reading legacy file: %w", err)
+		}
 		outputData := &pboutput.Map{}
-		if err = outputData.UnmarshalFast(bytes); err != nil {
-			return fmt.Errorf("unmarshalling file %s: %w", filename, err)
+		if err := outputData.UnmarshalFast(append(headerData[:n], rest...)); err != nil {
+			return nil, fmt.Errorf("unmarshalling legacy file: %w", err)
 		}
+		return outputData.Kv, nil
+	}
 
-		c.kv = outputData.Kv
+	header, err := decodeOutputFileHeader(headerData)
+	if err != nil {
+		return nil, err
+	}
+	indexData := make([]byte, header.indexLength)
+	if _, err := io.ReadFull(reader, indexData); err != nil {
+		return nil, fmt.Errorf("reading index: %w", err)
+	}
+	entries, err := decodeOutputIndex(indexData, header)
+	if err != nil {
+		return nil, err
+	}
 
-		c.logger.Debug("outputs data loaded", zap.Int("output_count", len(c.kv)), zap.Stringer("block_range", c.Range))
-		return nil
-	})
+	kv := make(map[string]*pboutput.Item)
+	entries = entriesBetween(entries, startBlock, stopBlock)
+	if len(entries) == 0 {
+		return kv, nil
+	}
+
+	spanStart, spanEnd := entries[0].offset, entries[len(entries)-1].end()
+	if canRange {
+		reader.Close()
+		if reader, err = rangeReader.OpenObjectRange(ctx, filename, int64(header.blocksOffset()+spanStart), int64(spanEnd-spanStart)); err != nil {
+			return nil, err
+		}
+	} else if _, err := io.CopyN(io.Discard, reader, int64(spanStart)); err != nil {
+		return nil, fmt.Errorf("skipping to block %d: %w", entries[0].item.BlockNum, err)
+	}
+	span := make([]byte, spanEnd-spanStart)
+	if _, err := io.ReadFull(reader, span); err != nil {
+		return nil, fmt.Errorf("reading blocks: %w", err)
+	}
+
+	for _, entry := range entries {
+		item, err := entry.decodeItem(span[entry.offset-spanStart:entry.end()-spanStart], header.codec)
+		if err != nil {
+			return nil, err
+		}
+		kv[item.BlockId] = item
+	}
+	return kv, nil
 }
 
-func (c *File) Save(ctx context.Context) error {
+// decompressingReadCloser reads a file compressed whole by the store.
+type decompressingReadCloser struct {
+	*zstd.Decoder
+	compressed io.Closer
+}
 
+func (r *decompressingReadCloser) Close() error {
+	r.Decoder.Close()
+	return r.compressed.Close()
+}
+
+func (c *File) Save(ctx context.Context) error {
 	filename := c.Filename()
-	outputData := &pboutput.Map{Kv: c.kv}
-	cnt, err := outputData.MarshalFast()
+	c.RLock()
+	items := make([]*pboutput.Item, 0, len(c.kv))
+	for _, item := range c.kv {
+		items = append(items, item)
+	}
+	c.RUnlock()
+
+	cnt, err := encodeOutputFile(items, CodecZstd)
 	if err != nil {
-		return fmt.Errorf("unmarshalling file %s: %w", filename, err)
+		return fmt.Errorf("encoding file %s: %w", filename, err)
 	}
 
 	c.logger.Info("writing execution output file", zap.String("filename", filename))
@@ -0,0 +1,240 @@
+package execout
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/streamingfast/substreams/block"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	pboutput "github.com/streamingfast/substreams/storage/execout/pb"
+)
+
+// testStore is a MockStore counting the bytes read, serving range reads
+// when wrapped in a rangeStore.
+type testStore struct {
+	*dstore.MockStore
+	bytesRead int
+}
+
+type rangeStore struct {
+	*testStore
+}
+
+func newTestStore() *testStore {
+	s := &testStore{MockStore: dstore.NewMockStore(nil)}
+	s.OpenObjectFunc = func(ctx context.Context, name string) (io.ReadCloser, error) {
+		content, found := s.Files[name]
+		if !found {
+			return nil, dstore.ErrNotFound
+		}
+		return io.NopCloser(&countingReader{Reader: bytes.NewReader(content), count: &s.bytesRead}), nil
+	}
+	return s
+}
+
+func (s *rangeStore) OpenObjectRange(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	content, found := s.Files[name]
+	if !found {
+		return nil, dstore.ErrNotFound
+	}
+	return io.NopCloser(&countingReader{Reader: bytes.NewReader(content[offset : offset+length]), count: &s.bytesRead}), nil
+}
+
+type countingReader struct {
+	io.Reader
+	count *int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	*r.count += n
+	return n, err
+}
+
+func newTestFile(objStore dstore.Store) *File {
+	return (&Config{name: "A", objStore: objStore, logger: zap.NewNop()}).NewFile(block.NewRange(100, 200))
+}
+
+func writeTestFile(t *testing.T, objStore dstore.Store) *File {
+	file := newTestFile(objStore)
+	for i := uint64(100); i < 200; i++ {
+		clock := &pbsubstreams.Clock{Number: i, Id: fmt.Sprintf("id%d", i), Timestamp: timestamppb.New(time.Unix(int64(i), 0))}
+		file.SetItem(clock, bytes.Repeat([]byte(fmt.Sprintf("output %d|", i)), 50))
+	}
+	require.NoError(t, file.Save(context.Background()))
+	return file
+}
+
+func assertSameItems(t *testing.T, expected, actual []*pboutput.Item) {
+	t.Helper()
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		assert.True(t, proto.Equal(expected[i], actual[i]), "item of block %d", expected[i].BlockNum)
+	}
+}
+
+func TestFile_SaveLoad(t *testing.T) {
+	objStore := newTestStore()
+	written := writeTestFile(t, objStore)
+	assert.True(t, IsOutputFile(objStore.Files[written.Filename()]))
+
+	loaded := newTestFile(objStore)
+	require.NoError(t, loaded.Load(context.Background()))
+	assertSameItems(t, written.SortedItems(), loaded.SortedItems())
+
+	payload, found := loaded.GetAtBlock(150)
+	require.True(t, found)
+	assert.Equal(t, bytes.Repeat([]byte("output 150|"), 50), payload)
+}
+
+func TestFile_LoadRange(t *testing.T) {
+	objStore := newTestStore()
+	written := writeTestFile(t, objStore)
+	fileSize := len(objStore.Files[written.Filename()])
+
+	for _, withRanges := range []bool{false, true} {
+		t.Run(fmt.Sprintf("range reads %t", withRanges), func(t *testing.T) {
+			var s dstore.Store = objStore
+			if withRanges {
+				s = &rangeStore{objStore}
+			}
+
+			// The range reads only fetch the header, the index and the
+			// blocks needed.
+			objStore.bytesRead = 0
+			loaded := newTestFile(s)
+			require.NoError(t, loaded.LoadRange(context.Background(), 180, 185))
+			assertSameItems(t, written.SortedItems()[80:85], loaded.SortedItems())
+			if withRanges {
+				assert.Less(t, objStore.bytesRead, fileSize/2)
+			}
+
+			// Streaming reads stop after the last block needed.
+			objStore.bytesRead = 0
+			loaded = newTestFile(s)
+			require.NoError(t, loaded.LoadRange(context.Background(), 0, 110))
+			assertSameItems(t, written.SortedItems()[:10], loaded.SortedItems())
+			assert.Less(t, objStore.bytesRead, fileSize/2)
+
+			loaded = newTestFile(s)
+			require.NoError(t, loaded.LoadRange(context.Background(), 300, 400))
+			assert.Empty(t, loaded.SortedItems())
+		})
+	}
+}
+
+// The output files of a compressing state store are written uncompressed,
+// their payloads compressed on their own, and read by range from the local
+// stores.
+func TestFile_LoadRange_CompressedStore(t *testing.T) {
+	dir := t.TempDir()
+	stateStore, err := dstore.NewStore("file://"+dir, "zst", "zstd", false)
+	require.NoError(t, err)
+	config, err := NewConfig("A", 0, pbsubstreams.ModuleKindMap, "abc", stateStore, zap.NewNop())
+	require.NoError(t, err)
+	require.Implements(t, (*RangeReader)(nil), config.objStore)
+
+	written := writeTestFile(t, config.objStore)
+	content, err := os.ReadFile(filepath.Join(dir, "abc", "outputs", written.Filename()+".zst"))
+	require.NoError(t, err)
+	require.True(t, IsOutputFile(content))
+	header, err := decodeOutputFileHeader(content)
+	require.NoError(t, err)
+	assert.Equal(t, CodecZstd, header.codec)
+
+	loaded := config.NewFile(block.NewRange(100, 200))
+	require.NoError(t, loaded.LoadRange(context.Background(), 150, 160))
+	assertSameItems(t, written.SortedItems()[50:60], loaded.SortedItems())
+}
+
+// The files compressed whole by the store, before the output files were
+// range addressable, are still read.
+func TestFile_LoadCompressedWhole(t *testing.T) {
+	dir := t.TempDir()
+	stateStore, err := dstore.NewStore("file://"+dir, "zst", "zstd", false)
+	require.NoError(t, err)
+	compressedStore, err := stateStore.SubStore("abc/outputs")
+	require.NoError(t, err)
+	config, err := NewConfig("A", 0, pbsubstreams.ModuleKindMap, "abc", stateStore, zap.NewNop())
+	require.NoError(t, err)
+
+	written := newTestFile(newTestStore())
+	for i := uint64(100); i < 200; i++ {
+		written.SetItem(&pbsubstreams.Clock{Number: i, Id: fmt.Sprintf("id%d", i)}, []byte(fmt.Sprintf("output %d", i)))
+	}
+	content, err := encodeOutputFile(written.SortedItems(), CodecNone)
+	require.NoError(t, err)
+	require.NoError(t, compressedStore.WriteObject(context.Background(), written.Filename(), bytes.NewReader(content)))
+
+	loaded := config.NewFile(block.NewRange(100, 200))
+	require.NoError(t, loaded.LoadRange(context.Background(), 150, 160))
+	assertSameItems(t, written.SortedItems()[50:60], loaded.SortedItems())
+
+	legacy, err := (&pboutput.Map{Kv: written.kv}).MarshalFast()
+	require.NoError(t, err)
+	require.NoError(t, compressedStore.WriteObject(context.Background(), written.Filename(), bytes.NewReader(legacy)))
+
+	loaded = config.NewFile(block.NewRange(100, 200))
+	require.NoError(t, loaded.Load(context.Background()))
+	assertSameItems(t, written.SortedItems(), loaded.SortedItems())
+}
+
+// Files written with uncompressed payloads are still read.
+func TestFile_LoadUncompressedPayloads(t *testing.T) {
+	objStore := newTestStore()
+	written := writeTestFile(t, objStore)
+	content, err := encodeOutputFile(written.SortedItems(), CodecNone)
+	require.NoError(t, err)
+	objStore.Files[written.Filename()] = content
+
+	loaded := newTestFile(&rangeStore{objStore})
+	require.NoError(t, loaded.LoadRange(context.Background(), 190, 200))
+	assertSameItems(t, written.SortedItems()[90:], loaded.SortedItems())
+}
+
+func TestFile_LoadLegacy(t *testing.T) {
+	objStore := newTestStore()
+	written := newTestFile(objStore)
+	for i := uint64(100); i < 110; i++ {
+		written.SetItem(&pbsubstreams.Clock{Number: i, Id: fmt.Sprintf("id%d", i)}, []byte(fmt.Sprintf("output %d", i)))
+	}
+	legacy, err := (&pboutput.Map{Kv: written.kv}).MarshalFast()
+	require.NoError(t, err)
+	objStore.Files[written.Filename()] = legacy
+
+	loaded := newTestFile(objStore)
+	require.NoError(t, loaded.LoadRange(context.Background(), 105, 106))
+	assertSameItems(t, written.SortedItems(), loaded.SortedItems())
+
+	objStore.Files[written.Filename()] = nil
+	loaded = newTestFile(objStore)
+	require.NoError(t, loaded.Load(context.Background()))
+	assert.Empty(t, loaded.SortedItems())
+}
+
+func TestFile_LoadCorrupted(t *testing.T) {
+	objStore := newTestStore()
+	written := writeTestFile(t, objStore)
+	content := objStore.Files[written.Filename()]
+	content[len(content)-1] ^= 0xff
+
+	loaded := newTestFile(objStore)
+	require.NoError(t, loaded.LoadRange(context.Background(), 100, 150))
+
+	err := newTestFile(objStore).LoadRange(context.Background(), 150, 200)
+	assert.True(t, errors.Is(err, ErrChecksumMismatch), err)
+}
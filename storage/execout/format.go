@@ -0,0 +1,258 @@
+package execout
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+
+	pboutput "github.com/streamingfast/substreams/storage/execout/pb"
+)
+
+// An output file holds the outputs of a module for the blocks of a segment,
+// the output of each block encoded on its own behind an index of the blocks,
+// so a reader only fetches and decodes the blocks it needs:
+//
+//	magic (4 bytes) | version (1 byte) | codec (1 byte) | index length (4 bytes) | index checksum (4 bytes) | index | blocks
+//
+// The index lists the blocks in block order, each as `uvarint(len(item)) |
+// item | uvarint(offset) | uvarint(length) | checksum (4 bytes)`: item is
+// the marshalled pboutput.Item of the block without its payload, offset and
+// length locate the payload, encoded with the codec, from the start of the
+// blocks, and checksum is the CRC-32 (Castagnoli) of the encoded payload. The
+// index checksum is the CRC-32 (Castagnoli) of the index, integers are in
+// little endian.
+//
+// Legacy output files, written before this format, are a marshalled
+// pboutput.Array: it starts with the tag of its field, never with the 0xff
+// of the magic. They, and the output files written before the files were
+// range addressable, were compressed whole by the store: they start with the
+// magic of a zstd frame.
+const (
+	OutputFileFormatVersion = 1
+
+	outputFileMagic      = "\xffSEO"
+	outputFileHeaderSize = len(outputFileMagic) + 1 + 1 + 4 + 4
+)
+
+// Codec is the compression of the outputs of the blocks in an output file.
+// Files are written with CodecZstd, the stores writing them uncompressed.
+type Codec uint8
+
+const (
+	CodecNone Codec = 0
+	CodecZstd Codec = 1
+)
+
+func (c Codec) String() string {
+	switch c {
+	case CodecNone:
+		return "none"
+	case CodecZstd:
+		return "zstd"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(c))
+}
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// IsOutputFile tells if `data` starts like an output file, as opposed to a
+// legacy one.
+func IsOutputFile(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(outputFileMagic))
+}
+
+// zstdFrameMagic starts the files compressed whole by the store.
+const zstdFrameMagic = "\x28\xb5\x2f\xfd"
+
+func isCompressedWhole(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(zstdFrameMagic))
+}
+
+// encodeOutputFile writes `items` as an output file, their payloads encoded
+// with `codec`.
+func encodeOutputFile(items []*pboutput.Item, codec Codec) ([]byte, error) {
+	sorted := make([]*pboutput.Item, len(items))
+	copy(sorted, items)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].BlockNum < sorted[j].BlockNum })
+
+	var index, blocks []byte
+	for _, item := range sorted {
+		meta, err := (&pboutput.Item{BlockNum: item.BlockNum, BlockId: item.BlockId, Timestamp: item.Timestamp}).MarshalVT()
+		if err != nil {
+			return nil, fmt.Errorf("marshalling item of block %d: %w", item.BlockNum, err)
+		}
+		encoded, err := encodePayload(item.Payload, codec)
+		if err != nil {
+			return nil, err
+		}
+
+		index = binary.AppendUvarint(index, uint64(len(meta)))
+		index = append(index, meta...)
+		index = binary.AppendUvarint(index, uint64(len(blocks)))
+		index = binary.AppendUvarint(index, uint64(len(encoded)))
+		index = binary.LittleEndian.AppendUint32(index, crc32.Checksum(encoded, crc32c))
+		blocks = append(blocks, encoded...)
+	}
+
+	out := make([]byte, outputFileHeaderSize, outputFileHeaderSize+len(index)+len(blocks))
+	copy(out, outputFileMagic)
+	out[4] = OutputFileFormatVersion
+	out[5] = byte(codec)
+	binary.LittleEndian.PutUint32(out[6:10], uint32(len(index)))
+	binary.LittleEndian.PutUint32(out[10:14], crc32.Checksum(index, crc32c))
+	out = append(out, index...)
+	return append(out, blocks...), nil
+}
+
+type outputFileHeader struct {
+	codec         Codec
+	indexLength   uint32
+	indexChecksum uint32
+}
+
+// blocksOffset is the offset of the blocks in the file.
+func (h *outputFileHeader) blocksOffset() uint64 {
+	return uint64(outputFileHeaderSize) + uint64(h.indexLength)
+}
+
+func decodeOutputFileHeader(data []byte) (*outputFileHeader, error) {
+	if len(data) < outputFileHeaderSize {
+		return nil, fmt.Errorf("truncated output file header: %d bytes", len(data))
+	}
+	if version := data[4]; version != OutputFileFormatVersion {
+		return nil, fmt.Errorf("unsupported output file format version %d", version)
+	}
+	header := &outputFileHeader{
+		codec:         Codec(data[5]),
+		indexLength:   binary.LittleEndian.Uint32(data[6:10]),
+		indexChecksum: binary.LittleEndian.Uint32(data[10:14]),
+	}
+	if header.codec != CodecNone && header.codec != CodecZstd {
+		return nil, fmt.Errorf("unsupported output file codec %s", header.codec)
+	}
+	return header, nil
+}
+
+type outputIndexEntry struct {
+	item     *pboutput.Item // without its payload
+	offset   uint64
+	length   uint64
+	checksum uint32
+}
+
+func (e *outputIndexEntry) end() uint64 { return e.offset + e.length }
+
+func decodeOutputIndex(data []byte, header *outputFileHeader) (out []*outputIndexEntry, err error) {
+	if checksum := crc32.Checksum(data, crc32c); checksum != header.indexChecksum {
+		return nil, fmt.Errorf("output file index: %w: got %08x, expected %08x", ErrChecksumMismatch, checksum, header.indexChecksum)
+	}
+
+	for pos := 0; pos < len(data); {
+		metaLen, n := binary.Uvarint(data[pos:])
+		if n <= 0 || uint64(len(data)-pos-n) < metaLen {
+			return nil, fmt.Errorf("corrupted output file index at %d", pos)
+		}
+		pos += n
+		entry := &outputIndexEntry{item: &pboutput.Item{}}
+		if err := entry.item.UnmarshalVT(data[pos : pos+int(metaLen)]); err != nil {
+			return nil, fmt.Errorf("unmarshalling output file index item: %w", err)
+		}
+		pos += int(metaLen)
+
+		if entry.offset, n = binary.Uvarint(data[pos:]); n <= 0 {
+			return nil, fmt.Errorf("corrupted output file index at %d", pos)
+		}
+		pos += n
+		if entry.length, n = binary.Uvarint(data[pos:]); n <= 0 {
+			return nil, fmt.Errorf("corrupted output file index at %d", pos)
+		}
+		pos += n
+		if len(data)-pos < 4 {
+			return nil, fmt.Errorf("corrupted output file index at %d", pos)
+		}
+		entry.checksum = binary.LittleEndian.Uint32(data[pos:])
+		pos += 4
+
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// entriesBetween returns the entries of the blocks in [startBlock, stopBlock).
+func entriesBetween(entries []*outputIndexEntry, startBlock, stopBlock uint64) []*outputIndexEntry {
+	first := sort.Search(len(entries), func(i int) bool { return entries[i].item.BlockNum >= startBlock })
+	last := sort.Search(len(entries), func(i int) bool { return entries[i].item.BlockNum >= stopBlock })
+	if first >= last {
+		return nil
+	}
+	return entries[first:last]
+}
+
+// decodeItem returns the item of `entry` with its payload, decoded from
+// `encoded`.
+func (e *outputIndexEntry) decodeItem(encoded []byte, codec Codec) (*pboutput.Item, error) {
+	if checksum := crc32.Checksum(encoded, crc32c); checksum != e.checksum {
+		return nil, fmt.Errorf("output of block %d: %w: got %08x, expected %08x", e.item.BlockNum, ErrChecksumMismatch, checksum, e.checksum)
+	}
+	payload, err := decodePayload(encoded, codec)
+	if err != nil {
+		return nil, fmt.Errorf("decoding output of block %d: %w", e.item.BlockNum, err)
+	}
+	return &pboutput.Item{
+		BlockNum:  e.item.BlockNum,
+		BlockId:   e.item.BlockId,
+		Timestamp: e.item.Timestamp,
+		Payload:   payload,
+	}, nil
+}
+
+func encodePayload(payload []byte, codec Codec) ([]byte, error) {
+	switch codec {
+	case CodecNone:
+		return payload, nil
+	case CodecZstd:
+		return zstdEncoder().EncodeAll(payload, nil), nil
+	}
+	return nil, fmt.Errorf("unsupported output file codec %s", codec)
+}
+
+func decodePayload(encoded []byte, codec Codec) ([]byte, error) {
+	switch codec {
+	case CodecNone:
+		return encoded, nil
+	case CodecZstd:
+		return zstdDecoder().DecodeAll(encoded, nil)
+	}
+	return nil, fmt.Errorf("unsupported output file codec %s", codec)
+}
+
+var (
+	zstdEncoderOnce sync.Once
+	zstdEncoderInst *zstd.Encoder
+	zstdDecoderOnce sync.Once
+	zstdDecoderInst *zstd.Decoder
+)
+
+// The encoder and decoder are safe for concurrent use with EncodeAll and
+// DecodeAll.
+func zstdEncoder() *zstd.Encoder {
+	zstdEncoderOnce.Do(func() {
+		zstdEncoderInst, _ = zstd.NewWriter(nil)
+	})
+	return zstdEncoderInst
+}
+
+func zstdDecoder() *zstd.Decoder {
+	zstdDecoderOnce.Do(func() {
+		zstdDecoderInst, _ = zstd.NewReader(nil)
+	})
+	return zstdDecoderInst
+}
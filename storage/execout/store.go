@@ -0,0 +1,133 @@
+package execout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"github.com/streamingfast/dstore"
+)
+
+// The output files are written without the compression of the state store,
+// their payloads being compressed on their own (see Codec), so the outputs
+// of a range of blocks are a range of bytes of the object. The stores
+// implementing RangeReader, the local and Google Cloud Storage ones, read
+// only that range, the other stores read the file from its start up to the
+// last block needed.
+
+// RangeReader is implemented by the stores able to read a part of an
+// object, `offset` and `length` being in the object as written.
+type RangeReader interface {
+	OpenObjectRange(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error)
+}
+
+// outputStores are the uncompressed stores of the output files, by URL of
+// the state store, created once as they hold their client.
+var outputStores sync.Map
+
+// outputStore returns the store of the output files of the modules in
+// `stateStore`, writing and reading objects without its compression. The
+// objects keep the names and extension of the state store.
+func outputStore(stateStore dstore.Store) (dstore.Store, error) {
+	switch stateStore.(type) {
+	case *dstore.LocalStore, *dstore.GSStore, *dstore.S3Store, *dstore.AzureStore:
+	default:
+		// The other stores, like the mock ones of the tests, never compress.
+		return stateStore, nil
+	}
+
+	key := stateStore.BaseURL().String()
+	if s, found := outputStores.Load(key); found {
+		return s.(dstore.Store), nil
+	}
+
+	extension := strings.TrimPrefix(strings.TrimPrefix(path.Base(stateStore.ObjectPath("output")), "output"), ".")
+	s, err := dstore.NewStore(key, extension, "", stateStore.Overwrite())
+	if err != nil {
+		return nil, fmt.Errorf("creating uncompressed store %q: %w", key, err)
+	}
+	actual, _ := outputStores.LoadOrStore(key, s)
+	return actual.(dstore.Store), nil
+}
+
+// withRangeReads returns `s` implementing RangeReader when its kind of
+// store supports it.
+func withRangeReads(s dstore.Store) dstore.Store {
+	switch s := s.(type) {
+	case *dstore.LocalStore:
+		return &localRangeStore{LocalStore: s}
+	case *dstore.GSStore:
+		client, err := gsRangeClient()
+		if err != nil {
+			return s
+		}
+		return &gsRangeStore{GSStore: s, client: client}
+	}
+	return s
+}
+
+type localRangeStore struct {
+	*dstore.LocalStore
+}
+
+func (s *localRangeStore) OpenObjectRange(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	f, err := os.Open(s.ObjectPath(name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, dstore.ErrNotFound
+		}
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &limitedReadCloser{Reader: io.LimitReader(f, length), Closer: f}, nil
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+var (
+	gsRangeClientOnce sync.Once
+	gsRangeClientInst *storage.Client
+	gsRangeClientErr  error
+)
+
+// gsRangeClient is the client of the range reads of the Google Cloud
+// Storage stores, which do not expose theirs, with the same credentials.
+func gsRangeClient() (*storage.Client, error) {
+	gsRangeClientOnce.Do(func() {
+		gsRangeClientInst, gsRangeClientErr = storage.NewClient(context.Background())
+	})
+	return gsRangeClientInst, gsRangeClientErr
+}
+
+type gsRangeStore struct {
+	*dstore.GSStore
+	client *storage.Client
+}
+
+func (s *gsRangeStore) OpenObjectRange(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	baseURL := s.BaseURL()
+	bucket := s.client.Bucket(baseURL.Host)
+	if project := baseURL.Query().Get("project"); project != "" {
+		bucket = bucket.UserProject(project)
+	}
+	reader, err := bucket.Object(s.ObjectPath(name)).NewRangeReader(ctx, offset, length)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, dstore.ErrNotFound
+		}
+		return nil, err
+	}
+	return reader, nil
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	pboutput "github.com/streamingfast/substreams/storage/execout/pb"
+)
+
+// OutputsReader reads the outputs of a module cached for a range of blocks,
+// see execout.Config.ReadOutputs.
+type OutputsReader interface {
+	ReadOutputs(ctx context.Context, startBlock, stopBlock uint64, f func(item *pboutput.Item) error) (readUntil uint64, err error)
+}
+
+// LoadAtBlock returns the full store as it was at the end of `blockNum`. It
+// is loaded from its latest full snapshot ending at or before that block,
+// skipping the snapshots whose chain is broken, then the deltas of the
+// following blocks, up to `blockNum` included, are applied from the outputs
+// of the store module cached in `outputs`, evicting the expired keys after
+// each block. It fails when the cached outputs do not cover these blocks.
+func (c *Config) LoadAtBlock(ctx context.Context, outputs OutputsReader, blockNum uint64, logger *zap.Logger) (*FullKV, error) {
+	if blockNum < c.moduleInitialBlock {
+		return nil, fmt.Errorf("store %q starts at block %d, after block %d", c.name, c.moduleInitialBlock, blockNum)
+	}
+
+	files, err := c.ListSnapshotFiles(ctx, blockNum+1)
+	if err != nil {
+		return nil, fmt.Errorf("listing store %q snapshots: %w", c.name, err)
+	}
+	var snapshots []*FileInfo
+	for _, file := range files {
+		if !file.Partial && file.Range.ExclusiveEndBlock <= blockNum+1 {
+			snapshots = append(snapshots, file)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Range.ExclusiveEndBlock > snapshots[j].Range.ExclusiveEndBlock
+	})
+
+	// The snapshots with a broken chain are skipped, the deltas are then
+	// applied from the newest loadable one.
+	s := c.NewFullKV(logger)
+	deltasFrom := c.moduleInitialBlock
+	var snapshot *FileInfo
+	for _, file := range snapshots {
+		err := s.Load(ctx, file)
+		if errors.Is(err, ErrBrokenSnapshotChain) {
+			logger.Warn("skipping store snapshot with a broken snapshot chain", zap.String("store", c.name), zap.String("file", file.Filename), zap.Error(err))
+			s = c.NewFullKV(logger)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("loading store %q snapshot %s: %w", c.name, file.Filename, err)
+		}
+		snapshot = file
+		deltasFrom = file.Range.ExclusiveEndBlock
+		break
+	}
+
+	if deltasFrom <= blockNum {
+		readUntil, err := outputs.ReadOutputs(ctx, deltasFrom, blockNum+1, func(item *pboutput.Item) error {
+			deltas := &pbssinternal.StoreDeltas{}
+			if err := proto.Unmarshal(item.Payload, deltas); err != nil {
+				return fmt.Errorf("store %q: unmarshalling deltas of block %d: %w", c.name, item.BlockNum, err)
+			}
+			s.SetBlock(item.BlockNum)
+			s.SetDeltas(deltas.StoreDeltas)
+			s.EvictExpiredKeys()
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if readUntil <= blockNum {
+			return nil, fmt.Errorf("store %q: no cached outputs with the deltas of blocks [%d, %d]", c.name, readUntil, blockNum)
+		}
+		s.SetBlock(blockNum)
+		s.EvictExpiredKeys()
+	}
+	s.Reset()
+
+	fields := []zap.Field{zap.String("store", c.name), zap.Uint64("block_num", blockNum), zap.Uint64("deltas_from", deltasFrom)}
+	if snapshot != nil {
+		fields = append(fields, zap.String("snapshot", snapshot.Filename))
+	}
+	logger.Debug("store loaded at block", fields...)
+	return s, nil
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	pboutput "github.com/streamingfast/substreams/storage/execout/pb"
+)
+
+// testOutputs holds the deltas of a store per block, cached until `until`.
+type testOutputs struct {
+	items []*pboutput.Item
+	until uint64
+}
+
+func (o *testOutputs) ReadOutputs(ctx context.Context, startBlock, stopBlock uint64, f func(item *pboutput.Item) error) (uint64, error) {
+	for _, item := range o.items {
+		if item.BlockNum < startBlock || item.BlockNum >= stopBlock || item.BlockNum >= o.until {
+			continue
+		}
+		if err := f(item); err != nil {
+			return startBlock, err
+		}
+	}
+	if o.until < startBlock {
+		return startBlock, nil
+	}
+	return o.until, nil
+}
+
+func TestConfig_LoadAtBlock(t *testing.T) {
+	config, _ := newTestIncrementalConfig(t, 0)
+	outputs := &testOutputs{until: 30}
+
+	s := config.NewFullKV(zap.NewNop())
+	var states []map[string][]byte
+	for i := uint64(0); i < 30; i++ {
+		s.Set(i, fmt.Sprintf("key:%02d", i%7), fmt.Sprintf("value %d", i))
+		if i%5 == 4 {
+			s.DeletePrefix(i, fmt.Sprintf("key:%02d", i%3))
+		}
+
+		payload, err := proto.Marshal(&pbssinternal.StoreDeltas{StoreDeltas: s.GetDeltas()})
+		require.NoError(t, err)
+		outputs.items = append(outputs.items, &pboutput.Item{BlockNum: i, Payload: payload})
+		s.Reset()
+		states = append(states, cloneKV(s.kv))
+
+		if i == 9 || i == 19 {
+			_, writer, err := s.Save(i + 1)
+			require.NoError(t, err)
+			require.NoError(t, writer.Write(context.Background()))
+		}
+	}
+
+	for _, blockNum := range []uint64{0, 5, 9, 10, 15, 19, 20, 29} {
+		loaded, err := config.LoadAtBlock(context.Background(), outputs, blockNum, zap.NewNop())
+		require.NoError(t, err, "block %d", blockNum)
+		assert.Equal(t, states[blockNum], loaded.kv, "block %d", blockNum)
+		assert.Empty(t, loaded.GetDeltas(), "block %d", blockNum)
+	}
+
+	outputs.until = 25
+	_, err := config.LoadAtBlock(context.Background(), outputs, 27, zap.NewNop())
+	assert.EqualError(t, err, `store "test": no cached outputs with the deltas of blocks [25, 27]`)
+
+	// Blocks ending a snapshot do not need cached outputs.
+	loaded, err := config.LoadAtBlock(context.Background(), outputs, 19, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, states[19], loaded.kv)
+}
+
+func TestConfig_LoadAtBlock_BrokenChain(t *testing.T) {
+	config, objStore := newTestIncrementalConfig(t, 3)
+	outputs := &testOutputs{until: 40}
+
+	s := config.NewFullKV(zap.NewNop())
+	var states []map[string][]byte
+	var files []*FileInfo
+	for i := uint64(0); i < 40; i++ {
+		s.Set(i, fmt.Sprintf("key:%02d", i%7), fmt.Sprintf("value %d", i))
+
+		payload, err := proto.Marshal(&pbssinternal.StoreDeltas{StoreDeltas: s.GetDeltas()})
+		require.NoError(t, err)
+		outputs.items = append(outputs.items, &pboutput.Item{BlockNum: i, Payload: payload})
+		s.Reset()
+		states = append(states, cloneKV(s.kv))
+
+		if i%10 == 9 {
+			file, writer, err := s.Save(i + 1)
+			require.NoError(t, err)
+			require.NoError(t, writer.Write(context.Background()))
+			files = append(files, file)
+		}
+	}
+
+	// The snapshot at 30 is incremental on top of the one at 20.
+	delete(objStore.Files, files[1].Filename)
+
+	loadable, err := config.LoadableSnapshots(context.Background(), []*FileInfo{files[3], files[2], files[0]}, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, []*FileInfo{files[0], files[3]}, loadable)
+
+	loaded, err := config.LoadAtBlock(context.Background(), outputs, 35, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, states[35], loaded.kv)
+	assert.Equal(t, files[0].Filename, loaded.loadedFrom)
+}
@@ -18,6 +18,20 @@ type baseStore struct {
 	lastOrdinal    uint64
 	marshaller     marshaller.Marshaller
 	totalSizeBytes uint64
+	keyCount       uint64          // keyCount is the number of keys in the state, including the ones of lazy.
+	sortedKeys     []string        // sortedKeys is the ordered view of the keys in kv, see orderedKeys()
+	unsortedKeys   map[string]bool // unsortedKeys are the keys created (true) or deleted (false) since sortedKeys was last sorted.
+
+	lazy        *marshaller.IndexedSnapshot // lazy holds the keys not loaded in kv yet, see lookup()
+	lazyDeleted map[string]bool             // lazyDeleted are the keys of lazy deleted since loaded.
+	lazyErr     error                       // lazyErr is the first error reading lazy, see Err()
+
+	changedKeys map[string]bool // changedKeys are the keys changed since the last snapshot, when saved incrementally.
+
+	block       uint64            // block being processed, see SetBlock()
+	lastWrites  map[string]uint64 // lastWrites is the block of the last write of each key, when the keys expire.
+	firstWrites map[string]uint64 // firstWrites is the block of the first write of each key in the segment of a partial store, when the keys expire.
+	evictedAt   uint64            // evictedAt is the last bundle boundary at which expired keys were evicted.
 
 	logger *zap.Logger
 }
@@ -34,7 +48,7 @@ func (b *baseStore) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	enc.AddString("name", b.name)
 	enc.AddString("hash", b.moduleHash)
 	enc.AddUint64("module_initial_block", b.moduleInitialBlock)
-	enc.AddInt("key_count", len(b.kv))
+	enc.AddUint64("key_count", b.keyCount)
 	enc.AddUint64("total_size_bytes", b.totalSizeBytes)
 
 	return nil
@@ -42,7 +56,7 @@ func (b *baseStore) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 
 func (b *baseStore) Reset() {
 	if tracer.Enabled() {
-		b.logger.Debug("flushing store", zap.Int("delta_count", len(b.deltas)), zap.Int("entry_count", len(b.kv)), zap.Uint64("total_size_bytes", b.totalSizeBytes))
+		b.logger.Debug("flushing store", zap.Int("delta_count", len(b.deltas)), zap.Uint64("entry_count", b.keyCount), zap.Uint64("total_size_bytes", b.totalSizeBytes))
 	}
 	b.deltas = nil
 	b.lastOrdinal = 0
@@ -1,15 +1,19 @@
 package store
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"os"
 
 	"github.com/streamingfast/dmetering"
 
 	"github.com/streamingfast/derr"
 	"github.com/streamingfast/dstore"
+
+	"github.com/streamingfast/substreams/storage/store/marshaller"
 )
 
 func saveStore(ctx context.Context, store dstore.Store, filename string, content []byte) (err error) {
@@ -26,6 +30,38 @@ func saveStore(ctx context.Context, store dstore.Store, filename string, content
 	})
 }
 
+// marshalSnapshot marshals `data` in a snapshot container, see
+// marshaller.EncodeSnapshot.
+func (b *baseStore) marshalSnapshot(data *marshaller.StoreData) ([]byte, error) {
+	payload, err := b.marshaller.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return marshaller.EncodeSnapshot(payload, b.snapshotCodec)
+}
+
+// unmarshalSnapshot verifies the checksum of the snapshot `data` (legacy
+// snapshots have none) before unmarshalling it.
+func (b *baseStore) unmarshalSnapshot(data []byte) (*marshaller.StoreData, uint64, error) {
+	if marshaller.IsIndexedSnapshot(data) {
+		storeData, size, err := marshaller.DecodeIndexedSnapshot(data)
+		if err != nil {
+			return nil, 0, fmt.Errorf("decode indexed snapshot: %w", err)
+		}
+		return storeData, size, nil
+	}
+
+	payload, _, err := marshaller.DecodeSnapshot(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("decode snapshot: %w", err)
+	}
+	storeData, size, err := b.marshaller.Unmarshal(payload)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unmarshal store: %w", err)
+	}
+	return storeData, size, nil
+}
+
 func loadStore(ctx context.Context, store dstore.Store, filename string) (out []byte, err error) {
 	if cloned, ok := store.(dstore.Clonable); ok {
 		store, err = cloned.Clone(ctx)
@@ -52,3 +88,50 @@ func loadStore(ctx context.Context, store dstore.Store, filename string) (out []
 	})
 	return out, err
 }
+
+// loadIndexedStore copies the snapshot `filename` to a temporary file of
+// `localDir` when it is an indexed snapshot, returning the file, removed
+// from `localDir` but still open. Other snapshots are returned in `data`,
+// like loadStore.
+func loadIndexedStore(ctx context.Context, store dstore.Store, filename, localDir string) (data []byte, local *os.File, err error) {
+	if cloned, ok := store.(dstore.Clonable); ok {
+		store, err = cloned.Clone(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cloning store: %w", err)
+		}
+		store.SetMeter(dmetering.GetBytesMeter(ctx))
+	}
+
+	err = derr.RetryContext(ctx, 5, func(ctx context.Context) error {
+		r, err := store.OpenObject(ctx, filename)
+		if err != nil {
+			return fmt.Errorf("opening file: %w", err)
+		}
+		defer r.Close()
+
+		reader := bufio.NewReader(r)
+		head, _ := reader.Peek(4)
+		if !marshaller.IsIndexedSnapshot(head) {
+			data, err = io.ReadAll(reader)
+			if err != nil {
+				return fmt.Errorf("reading data: %w", err)
+			}
+			return nil
+		}
+
+		local, err = os.CreateTemp(localDir, "store-*.kv")
+		if err != nil {
+			return derr.NewFatalError(fmt.Errorf("creating local copy: %w", err))
+		}
+		// The copy is only reachable through `local`, and is deleted once
+		// the file is closed.
+		os.Remove(local.Name())
+		if _, err := io.Copy(local, reader); err != nil {
+			local.Close()
+			local = nil
+			return fmt.Errorf("copying data: %w", err)
+		}
+		return nil
+	})
+	return data, local, err
+}
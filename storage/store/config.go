@@ -25,6 +25,30 @@ type Config struct {
 	appendLimit    uint64
 	totalSizeLimit uint64
 	itemSizeLimit  uint64
+	maxKeys        uint64 // maxKeys is the maximum number of keys of the stores, unlimited when zero, see SetQuota.
+
+	// snapshotCodec compresses the body of the snapshots. They are written
+	// with marshaller.CodecNone: the state stores (dstore) already compress
+	// the snapshots as whole zstd objects, compressing the body again would
+	// only cost CPU. The codec is in the header of each snapshot, so the
+	// snapshots written with marshaller.CodecZstd are read all the same.
+	snapshotCodec marshaller.Codec
+
+	// indexedSnapshots saves the full stores as indexed snapshots, loaded
+	// lazily from a local copy in indexedSnapshotsDir, see SetIndexedSnapshots.
+	indexedSnapshots    bool
+	indexedSnapshotsDir string
+
+	// checkpointInterval makes the full stores save only the keys changed
+	// since their previous snapshot, with a complete snapshot every
+	// checkpointInterval snapshots, see SetIncrementalSnapshots.
+	checkpointInterval uint64
+
+	// ttlBlocks is the number of blocks after which the keys not written
+	// are evicted, at the first multiple of bundleSize past their
+	// expiration, see EvictExpiredKeys.
+	ttlBlocks  uint64
+	bundleSize uint64
 
 	// traceID uniquely identifies the connection ID so that store can be
 	// written to unique filename preventing some races when multiple Substreams
@@ -56,6 +80,7 @@ func NewConfig(
 		appendLimit:        8_388_608,     // 8MiB = 8 * 1024 * 1024,
 		totalSizeLimit:     1_073_741_824, // 1GiB
 		itemSizeLimit:      10_485_760,    // 10MiB
+		snapshotCodec:      marshaller.CodecNone,
 		traceID:            traceID,
 	}, nil
 }
@@ -64,11 +89,21 @@ func (c *Config) newBaseStore(logger *zap.Logger) *baseStore {
 	return &baseStore{
 		Config:     c,
 		kv:         make(map[string][]byte),
+		lastWrites: c.newWrites(),
 		logger:     logger.Named("store").With(zap.String("store_name", c.name), zap.String("module_hash", c.moduleHash)),
 		marshaller: marshaller.Default(),
 	}
 }
 
+// newWrites returns the map tracking the blocks at which the keys are
+// written, nil when the keys never expire.
+func (c *Config) newWrites() map[string]uint64 {
+	if c.ttlBlocks == 0 {
+		return nil
+	}
+	return make(map[string]uint64)
+}
+
 func (c *Config) Name() string {
 	return c.name
 }
@@ -89,15 +124,55 @@ func (c *Config) ModuleInitialBlock() uint64 {
 	return c.moduleInitialBlock
 }
 
+func (c *Config) TTLBlocks() uint64 {
+	return c.ttlBlocks
+}
+
+// SetTTL makes the keys not written for `ttlBlocks` blocks expire, evicted
+// at the first multiple of `bundleSize` past their expiration.
+func (c *Config) SetTTL(ttlBlocks, bundleSize uint64) error {
+	if ttlBlocks != 0 && bundleSize == 0 {
+		return fmt.Errorf("a bundle size is required to evict the keys")
+	}
+	c.ttlBlocks = ttlBlocks
+	c.bundleSize = bundleSize
+	return nil
+}
+
+// SetIndexedSnapshots saves the full stores as indexed snapshots (see
+// marshaller.EncodeIndexedSnapshot), except the stores with a TTL whose
+// snapshots also hold the blocks of the writes. The indexed snapshots loaded
+// by full stores are copied to `localDir` (the default directory for
+// temporary files if empty), and their keys read from the copy as they are
+// looked up.
+func (c *Config) SetIndexedSnapshots(localDir string) {
+	c.indexedSnapshots = true
+	c.indexedSnapshotsDir = localDir
+}
+
+// SetIncrementalSnapshots makes the full stores save only the keys changed
+// since their previous snapshot, except every `checkpointInterval`
+// snapshots, where the complete store is saved. Stores with a TTL always
+// save complete snapshots.
+func (c *Config) SetIncrementalSnapshots(checkpointInterval uint64) {
+	c.checkpointInterval = checkpointInterval
+}
+
 func (c *Config) NewFullKV(logger *zap.Logger) *FullKV {
-	return &FullKV{c.newBaseStore(logger), "N/A"}
+	b := c.newBaseStore(logger)
+	if c.checkpointInterval > 1 && c.ttlBlocks == 0 {
+		b.changedKeys = make(map[string]bool)
+	}
+	return &FullKV{baseStore: b, loadedFrom: "N/A"}
 }
 
 func (c *Config) NewPartialKV(initialBlock uint64, logger *zap.Logger) *PartialKV {
+	b := c.newBaseStore(logger)
+	b.firstWrites = c.newWrites()
 	return &PartialKV{
-		baseStore:    c.newBaseStore(logger),
+		baseStore:    b,
 		initialBlock: initialBlock,
-		seen:         make(map[string]bool),
+		seenRanges:   make(map[marshaller.DeleteRange]bool),
 	}
 }
 
@@ -118,6 +193,35 @@ func (c *Config) FileSize(ctx context.Context, fileInfo *FileInfo) (int64, error
 	return size, nil
 }
 
+// VerifySnapshot reads the snapshot `file` and verifies its checksum. The
+// returned header is nil for legacy snapshots, which have no checksum.
+func (c *Config) VerifySnapshot(ctx context.Context, file *FileInfo) (*marshaller.SnapshotHeader, error) {
+	data, err := loadStore(ctx, c.objStore, file.Filename)
+	if err != nil {
+		return nil, fmt.Errorf("load snapshot %s: %w", file.Filename, err)
+	}
+	if marshaller.IsIncrementalSnapshot(data) {
+		_, header, err := marshaller.DecodeIncrementalSnapshot(data, marshaller.Default())
+		if err != nil {
+			return header, fmt.Errorf("incremental snapshot %s: %w", file.Filename, err)
+		}
+		return header, nil
+	}
+	if marshaller.IsIndexedSnapshot(data) {
+		header, err := marshaller.VerifyIndexedSnapshot(data)
+		if err != nil {
+			return header, fmt.Errorf("indexed snapshot %s: %w", file.Filename, err)
+		}
+		return header, nil
+	}
+
+	_, header, err := marshaller.DecodeSnapshot(data)
+	if err != nil {
+		return header, fmt.Errorf("snapshot %s: %w", file.Filename, err)
+	}
+	return header, nil
+}
+
 func (c *Config) ListSnapshotFiles(ctx context.Context, below uint64) (files []*FileInfo, err error) {
 	if below == 0 {
 		return nil, nil
@@ -10,7 +10,7 @@ import (
 
 type ConfigMap map[string]*Config
 
-func NewConfigMap(baseObjectStore dstore.Store, storeModules []*pbsubstreams.Module, moduleHashes *manifest.ModuleHashes, traceID string) (out ConfigMap, err error) {
+func NewConfigMap(baseObjectStore dstore.Store, storeModules []*pbsubstreams.Module, moduleHashes *manifest.ModuleHashes, stateBundleSize uint64, traceID string) (out ConfigMap, err error) {
 	out = make(ConfigMap)
 	for _, storeModule := range storeModules {
 		c, err := NewConfig(
@@ -25,7 +25,34 @@ func NewConfigMap(baseObjectStore dstore.Store, storeModules []*pbsubstreams.Mod
 		if err != nil {
 			return nil, fmt.Errorf("new store config for %q: %w", storeModule.Name, err)
 		}
+		if err := c.SetTTL(storeModule.GetKindStore().TtlBlocks, stateBundleSize); err != nil {
+			return nil, fmt.Errorf("store config for %q: %w", storeModule.Name, err)
+		}
+		c.SetQuota(storeModule.GetKindStore().MaxSizeBytes, storeModule.GetKindStore().MaxKeys)
 		out[storeModule.Name] = c
 	}
 	return out, nil
 }
+
+// SetQuota calls Config.SetQuota on every store config, the quotas of the
+// modules lower than these being kept.
+func (m ConfigMap) SetQuota(maxSizeBytes, maxKeys uint64) {
+	for _, c := range m {
+		c.SetQuota(maxSizeBytes, maxKeys)
+	}
+}
+
+// SetIncrementalSnapshots calls Config.SetIncrementalSnapshots on every store
+// config.
+func (m ConfigMap) SetIncrementalSnapshots(checkpointInterval uint64) {
+	for _, c := range m {
+		c.SetIncrementalSnapshots(checkpointInterval)
+	}
+}
+
+// SetIndexedSnapshots calls Config.SetIndexedSnapshots on every store config.
+func (m ConfigMap) SetIndexedSnapshots(localDir string) {
+	for _, c := range m {
+		c.SetIndexedSnapshots(localDir)
+	}
+}
@@ -18,6 +18,7 @@ func (b *baseStore) ApplyDelta(delta *pbssinternal.StoreDelta) {
 	newSize := uint64(len(delta.NewValue))
 	oldSize := uint64(len(delta.OldValue))
 	keySize := uint64(len(delta.Key))
+	b.keyChanged(delta.Key)
 	switch delta.Operation {
 	case pbssinternal.StoreDelta_UPDATE:
 		b.kv[delta.Key] = delta.NewValue
@@ -30,18 +31,20 @@ func (b *baseStore) ApplyDelta(delta *pbssinternal.StoreDelta) {
 
 	case pbssinternal.StoreDelta_CREATE:
 		b.kv[delta.Key] = delta.NewValue
+		b.orderedKeyAdded(delta.Key)
 		b.totalSizeBytes += newSize
 		b.totalSizeBytes += keySize
+		b.keyCount++
 
 	case pbssinternal.StoreDelta_DELETE:
 		delete(b.kv, delta.Key)
+		if b.lazy != nil {
+			b.lazyDeleted[delta.Key] = true
+		}
+		b.orderedKeyRemoved(delta.Key)
 		b.totalSizeBytes -= oldSize
 		b.totalSizeBytes -= keySize
-		return
-	}
-
-	if b.totalSizeBytes > b.totalSizeLimit {
-		panic(fmt.Sprintf("store %q became too big at %d, maximum size: %d", b.Name(), b.totalSizeBytes, b.totalSizeLimit))
+		b.keyCount--
 	}
 }
 
@@ -52,6 +55,7 @@ func (b *baseStore) ApplyDeltasReverse(deltas []*pbssinternal.StoreDelta) {
 		newSize := uint64(len(delta.NewValue))
 		oldSize := uint64(len(delta.OldValue))
 		keySize := uint64(len(delta.Key))
+		b.keyChanged(delta.Key)
 		switch delta.Operation {
 		case pbssinternal.StoreDelta_UPDATE:
 			b.kv[delta.Key] = delta.OldValue
@@ -64,14 +68,17 @@ func (b *baseStore) ApplyDeltasReverse(deltas []*pbssinternal.StoreDelta) {
 
 		case pbssinternal.StoreDelta_CREATE:
 			delete(b.kv, delta.Key)
+			b.orderedKeyRemoved(delta.Key)
 			b.totalSizeBytes -= newSize
 			b.totalSizeBytes -= keySize
+			b.keyCount--
 
 		case pbssinternal.StoreDelta_DELETE:
 			b.kv[delta.Key] = delta.OldValue
+			b.orderedKeyAdded(delta.Key)
 			b.totalSizeBytes += oldSize
 			b.totalSizeBytes += keySize
-			return
+			b.keyCount++
 		}
 	}
 }
@@ -84,5 +91,10 @@ func (b *baseStore) SetDeltas(deltas []*pbssinternal.StoreDelta) {
 	b.deltas = deltas
 	for _, delta := range deltas {
 		b.ApplyDelta(delta)
+		if delta.Operation == pbssinternal.StoreDelta_DELETE {
+			b.forget(delta.Key)
+		} else {
+			b.touch(delta.Key)
+		}
 	}
 }
@@ -15,6 +15,9 @@ type FullKV struct {
 	*baseStore
 
 	loadedFrom string
+
+	snapshotEndBlock uint64 // snapshotEndBlock is the exclusive end block of the last snapshot loaded or saved, 0 if none.
+	chainDepth       uint32 // chainDepth is the number of incremental snapshots since the checkpoint of the last snapshot.
 }
 
 func (s *FullKV) Marshaller() marshaller.Marshaller {
@@ -23,15 +26,17 @@ func (s *FullKV) Marshaller() marshaller.Marshaller {
 
 func (s *FullKV) DerivePartialStore(initialBlock uint64) *PartialKV {
 	b := &baseStore{
-		Config:     s.Config,
-		kv:         make(map[string][]byte),
-		logger:     s.logger,
-		marshaller: marshaller.Default(),
+		Config:      s.Config,
+		kv:          make(map[string][]byte),
+		lastWrites:  s.newWrites(),
+		firstWrites: s.newWrites(),
+		logger:      s.logger,
+		marshaller:  marshaller.Default(),
 	}
 	return &PartialKV{
 		baseStore:    b,
 		initialBlock: initialBlock,
-		seen:         make(map[string]bool),
+		seenRanges:   make(map[marshaller.DeleteRange]bool),
 	}
 }
 
@@ -39,21 +44,76 @@ func (s *FullKV) Load(ctx context.Context, file *FileInfo) error {
 	s.loadedFrom = file.Filename
 	s.logger.Debug("loading full store state from file", zap.String("fileName", file.Filename))
 
+	if s.indexedSnapshots && s.ttlBlocks == 0 {
+		return s.loadIndexed(ctx, file)
+	}
+
 	data, err := loadStore(ctx, s.objStore, file.Filename)
 	if err != nil {
 		return fmt.Errorf("load full store %s at %s: %w", s.name, file.Filename, err)
 	}
 
-	storeData, size, err := s.marshaller.Unmarshal(data)
+	return s.loadSnapshot(ctx, file, data)
+}
+
+func (s *FullKV) loadSnapshot(ctx context.Context, file *FileInfo, data []byte) error {
+	if marshaller.IsIncrementalSnapshot(data) {
+		return s.loadChain(ctx, file, data)
+	}
+	if err := s.loadData(file, data); err != nil {
+		return err
+	}
+	s.setSnapshotBase(file, 0)
+	return nil
+}
+
+// loadIndexed loads the store lazily when `file` is an indexed snapshot, see
+// Config.SetIndexedSnapshots.
+func (s *FullKV) loadIndexed(ctx context.Context, file *FileInfo) error {
+	data, local, err := loadIndexedStore(ctx, s.objStore, file.Filename, s.indexedSnapshotsDir)
 	if err != nil {
-		return fmt.Errorf("unmarshal store: %w", err)
+		return fmt.Errorf("load full store %s at %s: %w", s.name, file.Filename, err)
+	}
+	if local == nil {
+		return s.loadSnapshot(ctx, file, data)
+	}
+
+	stat, err := local.Stat()
+	if err != nil {
+		local.Close()
+		return fmt.Errorf("load full store %s at %s: %w", s.name, file.Filename, err)
+	}
+	snapshot, err := marshaller.OpenIndexedSnapshot(local, stat.Size())
+	if err != nil {
+		local.Close()
+		return fmt.Errorf("load full store %s at %s: open indexed snapshot: %w", s.name, file.Filename, err)
+	}
+
+	s.setLazy(snapshot)
+	s.totalSizeBytes = snapshot.SizeBytes()
+	s.keyCount = snapshot.Len()
+	s.setSnapshotBase(file, 0)
+
+	s.logger.Debug("full store loaded lazily", zap.String("fileName", file.Filename), zap.Uint64("key_count", snapshot.Len()), zap.Uint64("data_size", snapshot.SizeBytes()))
+	return nil
+}
+
+func (s *FullKV) loadData(file *FileInfo, data []byte) error {
+	s.setLazy(nil)
+
+	storeData, size, err := s.unmarshalSnapshot(data)
+	if err != nil {
+		return fmt.Errorf("load full store %s at %s: %w", s.name, file.Filename, err)
 	}
 
 	s.kv = storeData.Kv
+	s.resetOrderedKeys()
 	s.totalSizeBytes = size
 	if s.kv == nil {
 		s.kv = make(map[string][]byte)
 	}
+	s.keyCount = uint64(len(s.kv))
+	s.loadWrites(storeData)
 
 	s.logger.Debug("full store loaded", zap.String("fileName", file.Filename), zap.Int("key_count", len(s.kv)), zap.Uint64("data_size", size))
 	return nil
@@ -64,17 +124,33 @@ func (s *FullKV) Load(ctx context.Context, file *FileInfo) error {
 // boundary.
 func (s *FullKV) Save(endBoundaryBlock uint64) (*FileInfo, *fileWriter, error) {
 	s.logger.Debug("writing full store state", zap.Object("store", s))
-
-	stateData := &marshaller.StoreData{
-		Kv: s.kv,
+	if s.lazyErr != nil {
+		return nil, nil, fmt.Errorf("saving store %q: %w", s.name, s.lazyErr)
 	}
 
-	content, err := s.marshaller.Marshal(stateData)
+	var content []byte
+	var err error
+	var depth uint32
+	switch {
+	case s.saveIncrementally(endBoundaryBlock):
+		content, err = s.marshalIncrement()
+		depth = s.chainDepth + 1
+	case s.indexedSnapshots && s.ttlBlocks == 0:
+		s.materialize()
+		content, err = marshaller.EncodeIndexedSnapshot(s.kv, s.snapshotCodec)
+	default:
+		s.materialize()
+		content, err = s.marshalSnapshot(&marshaller.StoreData{
+			Kv:         s.kv,
+			LastWrites: s.lastWrites,
+		})
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("marshal kv state: %w", err)
 	}
 
 	file := NewCompleteFileInfo(s.name, s.moduleInitialBlock, endBoundaryBlock)
+	s.setSnapshotBase(file, depth)
 
 	s.logger.Info("saving store",
 		zap.String("file_name", file.Filename),
@@ -92,12 +168,12 @@ func (s *FullKV) Save(endBoundaryBlock uint64) (*FileInfo, *fileWriter, error) {
 
 func (s *FullKV) Reset() {
 	if tracer.Enabled() {
-		s.logger.Debug("flushing store", zap.Int("delta_count", len(s.deltas)), zap.Int("entry_count", len(s.kv)))
+		s.logger.Debug("flushing store", zap.Int("delta_count", len(s.deltas)), zap.Uint64("entry_count", s.keyCount))
 	}
 	s.deltas = nil
 	s.lastOrdinal = 0
 }
 
 func (s *FullKV) String() string {
-	return fmt.Sprintf("fullKV name %s moduleInitialBlock %d keyCount %d loadedFrom %s deltasCount %d", s.Name(), s.moduleInitialBlock, len(s.kv), s.loadedFrom, len(s.deltas))
+	return fmt.Sprintf("fullKV name %s moduleInitialBlock %d keyCount %d loadedFrom %s deltasCount %d", s.Name(), s.moduleInitialBlock, s.keyCount, s.loadedFrom, len(s.deltas))
 }
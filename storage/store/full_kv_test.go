@@ -3,11 +3,16 @@ package store
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/substreams/manifest"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"github.com/streamingfast/substreams/storage/store/marshaller"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
@@ -60,3 +65,133 @@ func TestFullKV_Save_Load_Empty_MapNotNil(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNilf(t, kvl.kv, "kvl.kv is nil")
 }
+
+func TestFullKV_IndexedSnapshot_LoadLazily(t *testing.T) {
+	var writtenBytes []byte
+	objStore := dstore.NewMockStore(func(base string, f io.Reader) (err error) {
+		writtenBytes, err = io.ReadAll(f)
+		return err
+	})
+	objStore.OpenObjectFunc = func(ctx context.Context, name string) (out io.ReadCloser, err error) {
+		return io.NopCloser(bytes.NewBuffer(writtenBytes)), nil
+	}
+
+	config := &Config{
+		name:                "test",
+		objStore:            objStore,
+		totalSizeLimit:      1_073_741_824,
+		itemSizeLimit:       10_485_760,
+		snapshotCodec:       marshaller.CodecZstd,
+		indexedSnapshots:    true,
+		indexedSnapshotsDir: t.TempDir(),
+	}
+
+	linear := config.NewFullKV(zap.NewNop())
+	for i := 0; i < 5000; i++ {
+		linear.Set(uint64(i), fmt.Sprintf("key:%05d", i), strings.Repeat("v", 100))
+	}
+	linear.Reset()
+
+	file, writer, err := linear.Save(123)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(context.Background()))
+	require.True(t, marshaller.IsIndexedSnapshot(writtenBytes))
+
+	lazy := config.NewFullKV(zap.NewNop())
+	require.NoError(t, lazy.Load(context.Background(), file))
+	require.NotNil(t, lazy.lazy)
+	assert.Empty(t, lazy.kv)
+	assert.Equal(t, linear.SizeBytes(), lazy.SizeBytes())
+
+	for _, s := range []*FullKV{linear, lazy} {
+		s.Set(1, "key:00042", "updated")
+		s.Set(2, "new", "value")
+		s.DeletePrefix(3, "key:001")
+		s.DeleteRange(4, "key:00400", "key:00402")
+		s.SetIfNotExists(5, "key:00100", "recreated")
+		s.SetIfNotExists(6, "key:00200", "ignored")
+	}
+
+	assert.Equal(t, linear.GetDeltas(), lazy.GetDeltas())
+	for _, key := range []string{"key:00042", "key:00100", "key:00101", "key:00200", "key:00400", "key:04999", "new", "missing"} {
+		value, found := lazy.GetLast(key)
+		expectedValue, expectedFound := linear.GetLast(key)
+		assert.Equal(t, expectedFound, found, key)
+		assert.Equal(t, expectedValue, value, key)
+	}
+	require.NotNil(t, lazy.lazy, "point lookups and deletes don't load the snapshot")
+	assert.Equal(t, linear.SizeBytes(), lazy.SizeBytes())
+
+	assert.Equal(t, linear.Length(), lazy.Length())
+	assert.Nil(t, lazy.lazy)
+	assert.Equal(t, linear.kv, lazy.kv)
+}
+
+type failingReaderAt struct {
+	io.ReaderAt
+	fail bool
+}
+
+func (r *failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if r.fail {
+		return 0, fmt.Errorf("disk failure")
+	}
+	return r.ReaderAt.ReadAt(p, off)
+}
+
+func TestFullKV_IndexedSnapshot_ReadError(t *testing.T) {
+	data, err := marshaller.EncodeIndexedSnapshot(map[string][]byte{"a": []byte("1"), "b": []byte("2")}, marshaller.CodecNone)
+	require.NoError(t, err)
+	r := &failingReaderAt{ReaderAt: bytes.NewReader(data)}
+	snapshot, err := marshaller.OpenIndexedSnapshot(r, int64(len(data)))
+	require.NoError(t, err)
+
+	s := (&Config{name: "test", totalSizeLimit: 1_073_741_824, itemSizeLimit: 10_485_760}).NewFullKV(zap.NewNop())
+	s.setLazy(snapshot)
+	s.keyCount = snapshot.Len()
+
+	require.NoError(t, s.Err())
+
+	r.fail = true
+	_, found := s.GetLast("b")
+	assert.False(t, found)
+	assert.EqualError(t, s.Err(), `store "test": reading key "b" from snapshot: reading block 0: disk failure`)
+
+	_, _, err = s.Save(10)
+	assert.ErrorContains(t, err, "disk failure")
+}
+
+func TestFullKV_Load_ZstdSnapshot(t *testing.T) {
+	var writtenBytes []byte
+	objStore := dstore.NewMockStore(func(base string, f io.Reader) (err error) {
+		writtenBytes, err = io.ReadAll(f)
+		return err
+	})
+	objStore.OpenObjectFunc = func(ctx context.Context, name string) (out io.ReadCloser, err error) {
+		return io.NopCloser(bytes.NewBuffer(writtenBytes)), nil
+	}
+
+	config, err := NewConfig("test", 0, "test.module.hash", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, manifest.OutputValueTypeString, objStore, "")
+	require.NoError(t, err)
+	require.Equal(t, marshaller.CodecNone, config.snapshotCodec)
+
+	zstdConfig := *config
+	zstdConfig.snapshotCodec = marshaller.CodecZstd
+	written := zstdConfig.NewFullKV(zap.NewNop())
+	for i := 0; i < 100; i++ {
+		written.Set(uint64(i), fmt.Sprintf("key:%03d", i), strings.Repeat("v", 100))
+	}
+	written.Reset()
+
+	file, writer, err := written.Save(123)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(context.Background()))
+	_, header, err := marshaller.DecodeSnapshot(writtenBytes)
+	require.NoError(t, err)
+	require.Equal(t, marshaller.CodecZstd, header.Codec)
+
+	loaded := config.NewFullKV(zap.NewNop())
+	require.NoError(t, loaded.Load(context.Background(), file))
+	assert.Equal(t, written.kv, loaded.kv)
+	assert.Equal(t, written.SizeBytes(), loaded.SizeBytes())
+}
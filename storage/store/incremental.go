@@ -0,0 +1,233 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/streamingfast/derr"
+	"github.com/streamingfast/dmetering"
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/storage/store/marshaller"
+)
+
+// The full stores with incremental snapshots (see
+// Config.SetIncrementalSnapshots) track the keys changed since the last
+// snapshot loaded or saved, and only save these changes, on top of that
+// snapshot, their base. Every `checkpointInterval` snapshots, a complete
+// snapshot, a checkpoint, is saved instead.
+//
+// Loading an incremental snapshot loads the chain of its bases back to the
+// checkpoint, then applies the changes of each snapshot of the chain.
+
+var ErrBrokenSnapshotChain = errors.New("broken snapshot chain")
+
+// keyChanged records the change of `key` since the last snapshot.
+func (b *baseStore) keyChanged(key string) {
+	if b.changedKeys != nil {
+		b.changedKeys[key] = true
+	}
+}
+
+// setSnapshotBase makes the snapshot `file`, just loaded or saved, at
+// `depth` in its chain, the base of the next incremental snapshot.
+func (s *FullKV) setSnapshotBase(file *FileInfo, depth uint32) {
+	s.snapshotEndBlock = file.Range.ExclusiveEndBlock
+	s.chainDepth = depth
+	if s.changedKeys != nil {
+		s.changedKeys = make(map[string]bool)
+	}
+}
+
+// saveIncrementally tells if the snapshot at `endBoundaryBlock` holds only
+// the changes since the last snapshot.
+func (s *FullKV) saveIncrementally(endBoundaryBlock uint64) bool {
+	return s.changedKeys != nil &&
+		s.snapshotEndBlock != 0 &&
+		s.snapshotEndBlock < endBoundaryBlock &&
+		uint64(s.chainDepth)+1 < s.checkpointInterval
+}
+
+func (s *FullKV) marshalIncrement() ([]byte, error) {
+	increment := &marshaller.IncrementalSnapshot{
+		IncrementalHeader: marshaller.IncrementalHeader{
+			BaseEndBlock: s.snapshotEndBlock,
+			Depth:        s.chainDepth + 1,
+		},
+		Kv: make(map[string][]byte),
+	}
+	for key := range s.changedKeys {
+		value, found, err := s.lookup(key)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			increment.Kv[key] = value
+		} else {
+			increment.DeletedKeys = append(increment.DeletedKeys, key)
+		}
+	}
+	sort.Strings(increment.DeletedKeys)
+
+	return marshaller.EncodeIncrementalSnapshot(increment, s.marshaller, s.snapshotCodec)
+}
+
+// loadChain loads the incremental snapshot `file`, of content `data`.
+func (s *FullKV) loadChain(ctx context.Context, file *FileInfo, data []byte) error {
+	var chain []*marshaller.IncrementalSnapshot
+	current := file
+	for marshaller.IsIncrementalSnapshot(data) {
+		increment, _, err := marshaller.DecodeIncrementalSnapshot(data, s.marshaller)
+		if err != nil {
+			return fmt.Errorf("load full store %s at %s: decode incremental snapshot: %w", s.name, current.Filename, err)
+		}
+		base, err := incrementBase(current, &increment.IncrementalHeader)
+		if err != nil {
+			return fmt.Errorf("load full store %s: %w", s.name, err)
+		}
+		chain = append(chain, increment)
+
+		current = base
+		data, err = loadStore(ctx, s.objStore, current.Filename)
+		if err != nil {
+			return fmt.Errorf("load full store %s at %s: %w: %s", s.name, current.Filename, ErrBrokenSnapshotChain, err)
+		}
+	}
+
+	if err := s.loadData(current, data); err != nil {
+		return err
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		s.applyIncrement(chain[i])
+	}
+	s.resetOrderedKeys()
+	s.setSnapshotBase(file, chain[0].Depth)
+
+	s.logger.Debug("full store loaded from snapshot chain", zap.String("fileName", file.Filename), zap.String("checkpoint", current.Filename), zap.Int("chain_length", len(chain)), zap.Int("key_count", len(s.kv)))
+	return nil
+}
+
+func (b *baseStore) applyIncrement(increment *marshaller.IncrementalSnapshot) {
+	for _, key := range increment.DeletedKeys {
+		if value, found := b.kv[key]; found {
+			b.totalSizeBytes -= uint64(len(key) + len(value))
+			b.keyCount--
+			delete(b.kv, key)
+		}
+	}
+	for key, value := range increment.Kv {
+		b.setKV(key, value)
+	}
+}
+
+// LoadableSnapshots returns `files` without the incremental snapshots whose
+// chain is broken, logged, for the scheduler to recompute them from the
+// newest loadable snapshot. The chains are only checked, reading the header
+// of each full snapshot, when the stores save incremental snapshots.
+func (c *Config) LoadableSnapshots(ctx context.Context, files []*FileInfo, logger *zap.Logger) ([]*FileInfo, error) {
+	if c.checkpointInterval <= 1 {
+		return files, nil
+	}
+
+	sorted := make([]*FileInfo, len(files))
+	copy(sorted, files)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Range.ExclusiveEndBlock < sorted[j].Range.ExclusiveEndBlock })
+
+	// The bases end before their snapshots, so they are checked first.
+	loadable := make(map[string]bool, len(sorted))
+	out := make([]*FileInfo, 0, len(sorted))
+	for _, file := range sorted {
+		if file.Partial {
+			out = append(out, file)
+			continue
+		}
+		base, err := c.SnapshotBase(ctx, file)
+		if err != nil && !errors.Is(err, ErrBrokenSnapshotChain) {
+			return nil, err
+		}
+		if err == nil && (base == nil || loadable[base.Filename]) {
+			loadable[file.Filename] = true
+			out = append(out, file)
+			continue
+		}
+		logger.Warn("ignoring store snapshot with a broken snapshot chain", zap.String("store", c.name), zap.String("file", file.Filename), zap.Error(err))
+	}
+	return out, nil
+}
+
+// incrementBase is the file of the base of the incremental snapshot `file`.
+func incrementBase(file *FileInfo, header *marshaller.IncrementalHeader) (*FileInfo, error) {
+	if header.BaseEndBlock <= file.Range.StartBlock || header.BaseEndBlock >= file.Range.ExclusiveEndBlock {
+		return nil, fmt.Errorf("incremental snapshot %s: invalid base end block %d", file.Filename, header.BaseEndBlock)
+	}
+	return NewCompleteFileInfo(file.ModuleName, file.Range.StartBlock, header.BaseEndBlock), nil
+}
+
+// SnapshotBase returns the base of the snapshot `file` when it is an
+// incremental snapshot, nil otherwise, reading only its header.
+func (c *Config) SnapshotBase(ctx context.Context, file *FileInfo) (*FileInfo, error) {
+	head, err := readSnapshotHead(ctx, c.objStore, file.Filename, marshaller.IncrementalHeaderSize)
+	if err != nil {
+		if errors.Is(err, dstore.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s is missing", ErrBrokenSnapshotChain, file.Filename)
+		}
+		return nil, fmt.Errorf("reading %s: %w", file.Filename, err)
+	}
+	if !marshaller.IsIncrementalSnapshot(head) {
+		return nil, nil
+	}
+
+	header, err := marshaller.DecodeIncrementalHeader(head)
+	if err != nil {
+		return nil, fmt.Errorf("incremental snapshot %s: %w", file.Filename, err)
+	}
+	return incrementBase(file, header)
+}
+
+// SnapshotChain returns the files needed to load the complete snapshot
+// `file`, from `file` back to its checkpoint. The error wraps
+// ErrBrokenSnapshotChain when a file of the chain is missing.
+func (c *Config) SnapshotChain(ctx context.Context, file *FileInfo) (chain []*FileInfo, err error) {
+	for current := file; current != nil; {
+		chain = append(chain, current)
+		if current, err = c.SnapshotBase(ctx, current); err != nil {
+			return chain, err
+		}
+	}
+	return chain, nil
+}
+
+// readSnapshotHead reads at most the first `size` bytes of `filename`.
+func readSnapshotHead(ctx context.Context, store dstore.Store, filename string, size int) (out []byte, err error) {
+	if cloned, ok := store.(dstore.Clonable); ok {
+		store, err = cloned.Clone(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cloning store: %w", err)
+		}
+		store.SetMeter(dmetering.GetBytesMeter(ctx))
+	}
+
+	err = derr.RetryContext(ctx, 5, func(ctx context.Context) error {
+		r, err := store.OpenObject(ctx, filename)
+		if err != nil {
+			if errors.Is(err, dstore.ErrNotFound) {
+				return derr.NewFatalError(err)
+			}
+			return fmt.Errorf("opening file: %w", err)
+		}
+		defer r.Close()
+
+		out = make([]byte, size)
+		n, err := io.ReadFull(r, out)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return fmt.Errorf("reading data: %w", err)
+		}
+		out = out[:n]
+		return nil
+	})
+	return out, err
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/storage/store/marshaller"
+)
+
+func newTestIncrementalConfig(t *testing.T, checkpointInterval uint64) (*Config, *dstore.MockStore) {
+	objStore := dstore.NewMockStore(nil)
+	objStore.OpenObjectFunc = func(ctx context.Context, name string) (io.ReadCloser, error) {
+		content, found := objStore.Files[name]
+		if !found {
+			return nil, dstore.ErrNotFound
+		}
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
+
+	config, err := NewConfig("test", 0, "test.module.hash", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", objStore, "")
+	require.NoError(t, err)
+	config.objStore = objStore
+	config.SetIncrementalSnapshots(checkpointInterval)
+	return config, objStore
+}
+
+func TestFullKV_IncrementalSnapshots(t *testing.T) {
+	config, objStore := newTestIncrementalConfig(t, 3)
+
+	s := config.NewFullKV(zap.NewNop())
+	var files []*FileInfo
+	var states []map[string][]byte
+	for boundary := uint64(10); boundary <= 50; boundary += 10 {
+		for i := boundary - 10; i < boundary; i++ {
+			s.Set(i, fmt.Sprintf("key:%02d", i), fmt.Sprintf("value %d", i))
+			s.Set(i, "counter", fmt.Sprintf("%d", i))
+		}
+		s.DeletePrefix(boundary, fmt.Sprintf("key:%d", boundary/10-1))
+		s.Reset()
+
+		file, writer, err := s.Save(boundary)
+		require.NoError(t, err)
+		require.NoError(t, writer.Write(context.Background()))
+
+		files = append(files, file)
+		states = append(states, cloneKV(s.kv))
+	}
+
+	var kinds []string
+	for _, file := range files {
+		if marshaller.IsIncrementalSnapshot(objStore.Files[file.Filename]) {
+			kinds = append(kinds, "incremental")
+		} else {
+			kinds = append(kinds, "checkpoint")
+		}
+	}
+	assert.Equal(t, []string{"checkpoint", "incremental", "incremental", "checkpoint", "incremental"}, kinds)
+
+	for i, file := range files {
+		loaded := config.NewFullKV(zap.NewNop())
+		require.NoError(t, loaded.Load(context.Background(), file))
+		assert.Equal(t, states[i], loaded.kv, file.Filename)
+		assert.Equal(t, sizeOfKV(states[i]), loaded.SizeBytes(), file.Filename)
+	}
+
+	chain, err := config.SnapshotChain(context.Background(), files[2])
+	require.NoError(t, err)
+	assert.Equal(t, []*FileInfo{files[2], files[1], files[0]}, chain)
+
+	// A store loaded from an incremental snapshot continues its chain.
+	loaded := config.NewFullKV(zap.NewNop())
+	require.NoError(t, loaded.Load(context.Background(), files[1]))
+	loaded.Set(100, "counter", "100")
+	file, _, err := loaded.Save(30)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), loaded.chainDepth)
+	assert.Equal(t, files[2].Filename, file.Filename)
+
+	delete(objStore.Files, files[0].Filename)
+	_, err = config.SnapshotChain(context.Background(), files[2])
+	assert.ErrorIs(t, err, ErrBrokenSnapshotChain)
+}
+
+func cloneKV(kv map[string][]byte) map[string][]byte {
+	out := make(map[string][]byte, len(kv))
+	for k, v := range kv {
+		out[k] = v
+	}
+	return out
+}
+
+func sizeOfKV(kv map[string][]byte) (size uint64) {
+	for k, v := range kv {
+		size += uint64(len(k) + len(v))
+	}
+	return size
+}
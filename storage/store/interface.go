@@ -46,6 +46,11 @@ type Store interface {
 	SumInt64Setter
 	SumFloat64Setter
 	SumBigDecimalSetter
+
+	SetSumInt64Setter
+	SetSumFloat64Setter
+	SetSumBigIntSetter
+	SetSumBigDecimalSetter
 }
 
 type PartialStore interface {
@@ -64,6 +69,30 @@ type Resettable interface {
 	Reset()
 }
 
+// Expirable stores evict the keys not written for a number of blocks, see
+// Config.SetTTL.
+type Expirable interface {
+	// SetBlock sets the block of the writes (or deltas) to come.
+	SetBlock(blockNum uint64)
+	// EvictExpiredKeys evicts the keys expired at the last bundle boundary
+	// reached, to be called after the writes of each block. The evictions
+	// are recorded as DELETE deltas, and it returns their number.
+	EvictExpiredKeys() int
+}
+
+// Failable stores keep the errors of the operations which cannot return one.
+type Failable interface {
+	// Err returns the first error met by such an operation, nil if none.
+	Err() error
+}
+
+// Limited stores have size and key count quotas, see Config.SetQuota.
+type Limited interface {
+	// CheckQuota returns a *QuotaExceededError when the store exceeds its
+	// quota.
+	CheckQuota() error
+}
+
 type Named interface {
 	Name() string
 }
@@ -84,6 +113,7 @@ type Reader interface {
 	fmt.Stringer
 
 	Named
+	Failable
 
 	GetFirst(key string) ([]byte, bool)
 	GetLast(key string) ([]byte, bool)
@@ -92,6 +122,10 @@ type Reader interface {
 	HasFirst(key string) bool
 	HasLast(key string) bool
 	HasAt(ord uint64, key string) bool
+
+	// ScanPrefix iterates, in lexicographical order, over at most `limit` keys starting with `prefix` and
+	// sorting after `cursor`. The returned cursor is to be passed to the next call to continue the scan.
+	ScanPrefix(prefix, cursor string, limit int, f func(key string, value []byte)) (nextCursor string)
 }
 
 type Mergeable interface {
@@ -115,10 +149,10 @@ type Appender interface {
 
 type Deleter interface {
 	DeletePrefix(ord uint64, prefix string)
-	//// Deletes a range of keys, lexicographically between `lowKey` and `highKey`
-	//DeleteRange(lowKey, highKey string)
-	//// Deletes a range of keys, first considering the _value_ of such keys as a _pointerSeparator_-separated list of keys to _also_ delete.
-	//DeleteRangePointers(lowKey, highKey, pointerSeparator string)
+	// Deletes a range of keys, lexicographically between `lowKey` (inclusive) and `highKey` (exclusive). An empty `highKey` means no upper bound.
+	DeleteRange(ord uint64, lowKey, highKey string)
+	// Deletes a range of keys, first considering the _value_ of such keys as a _pointerSeparator_-separated list of keys to _also_ delete.
+	DeleteRangePointers(ord uint64, lowKey, highKey, pointerSeparator string)
 }
 
 type MaxBigIntSetter interface {
@@ -159,3 +193,17 @@ type SumFloat64Setter interface {
 type SumBigDecimalSetter interface {
 	SumBigDecimal(ord uint64, key string, value decimal.Decimal)
 }
+
+// The `set_sum` values are strings prefixed by `set:` or `sum:`, see SetSumSetPrefix.
+type SetSumInt64Setter interface {
+	SetSumInt64(ord uint64, key string, value string) error
+}
+type SetSumFloat64Setter interface {
+	SetSumFloat64(ord uint64, key string, value string) error
+}
+type SetSumBigIntSetter interface {
+	SetSumBigInt(ord uint64, key string, value string) error
+}
+type SetSumBigDecimalSetter interface {
+	SetSumBigDecimal(ord uint64, key string, value string) error
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/storage/store/marshaller"
+)
+
+// The full stores loaded from indexed snapshots (see
+// Config.SetIndexedSnapshots) keep the keys of the snapshot on disk, read as
+// they are looked up. The writes go to `kv`, which then holds the keys
+// written since the load, and the deletes of keys of the snapshot are kept
+// in `lazyDeleted`.
+//
+// Point lookups and range deletes read through to the snapshot. The other
+// operations over the whole store (iteration, prefix scans, merge, save,
+// expiration) first load all the keys of the snapshot in `kv`, see
+// materialize.
+
+// setLazy replaces the state with the keys of `snapshot`, nil dropping the
+// snapshot previously loaded.
+func (b *baseStore) setLazy(snapshot *marshaller.IndexedSnapshot) {
+	if b.lazy != nil {
+		b.lazy.Close()
+	}
+	b.kv = make(map[string][]byte)
+	b.lazy = snapshot
+	b.lazyDeleted = nil
+	if snapshot != nil {
+		b.lazyDeleted = make(map[string]bool)
+	}
+	b.resetOrderedKeys()
+}
+
+// lookup returns the value of `key` in the state, deltas applied.
+func (b *baseStore) lookup(key string) ([]byte, bool, error) {
+	if val, found := b.kv[key]; found {
+		return val, true, nil
+	}
+	if b.lazy == nil || b.lazyDeleted[key] {
+		return nil, false, nil
+	}
+
+	val, found, err := b.lazy.Get(key)
+	if err != nil {
+		return nil, false, fmt.Errorf("store %q: reading key %q from snapshot: %w", b.name, key, err)
+	}
+	return val, found, nil
+}
+
+// get is lookup for the operations which cannot return an error: the key is
+// then reported as not found, and the error kept to be returned by Err.
+func (b *baseStore) get(key string) ([]byte, bool) {
+	val, found, err := b.lookup(key)
+	if err != nil {
+		if b.lazyErr == nil {
+			b.lazyErr = err
+		}
+		return nil, false
+	}
+	return val, found
+}
+
+// Err returns the first error met reading the snapshot of the store by an
+// operation which cannot return it, nil when there was none. The state of
+// the store is then unreliable: it cannot be saved anymore, and the outputs
+// of the block being processed must be discarded.
+func (b *baseStore) Err() error {
+	return b.lazyErr
+}
+
+// scanLazy calls `f` for the keys of the snapshot between `lowKey`
+// (inclusive) and `highKey` (exclusive) which were neither deleted nor
+// written since the load, these being in `kv`.
+func (b *baseStore) scanLazy(lowKey, highKey string, f func(key string, value []byte)) {
+	if b.lazy == nil {
+		return
+	}
+	err := b.lazy.Scan(lowKey, highKey, func(key string, value []byte) error {
+		if _, found := b.kv[key]; found || b.lazyDeleted[key] {
+			return nil
+		}
+		f(key, value)
+		return nil
+	})
+	if err != nil {
+		panic(fmt.Sprintf("store %q: scanning snapshot: %s", b.name, err))
+	}
+}
+
+// materialize loads the keys of the snapshot not read yet in `kv`.
+func (b *baseStore) materialize() {
+	if b.lazy == nil {
+		return
+	}
+
+	b.logger.Debug("loading all keys of indexed snapshot", zap.Uint64("key_count", b.lazy.Len()))
+	b.scanLazy("", "", func(key string, value []byte) {
+		b.kv[key] = value
+	})
+	b.lazy.Close()
+	b.lazy = nil
+	b.lazyDeleted = nil
+	b.resetOrderedKeys()
+}
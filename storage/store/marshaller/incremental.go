@@ -0,0 +1,152 @@
+package marshaller
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// An incremental snapshot holds the keys changed since a previous snapshot
+// of the same store, its base:
+//
+//	magic (4 bytes) | version (1 byte) | base end block (8 bytes) | depth (4 bytes) | header checksum (4 bytes) | snapshot
+//
+// The base end block is the exclusive end block of the base snapshot, and
+// the depth the number of incremental snapshots since the last complete
+// snapshot (the checkpoint), this one included. The header checksum is the
+// CRC-32 (Castagnoli) of the version, base end block and depth, integers
+// being in little endian. The snapshot is a snapshot container (see
+// EncodeSnapshot) of `uvarint(deleted key count) | deleted keys |
+// marshalled StoreData`, each deleted key being `uvarint(len(key)) | key`,
+// and the StoreData holding the new values of the changed keys.
+const (
+	IncrementalSnapshotFormatVersion = 1
+
+	incrementalSnapshotMagic = "\xffSSD"
+	IncrementalHeaderSize    = len(incrementalSnapshotMagic) + 1 + 8 + 4 + 4
+)
+
+type IncrementalSnapshot struct {
+	IncrementalHeader
+
+	Kv          map[string][]byte
+	DeletedKeys []string
+}
+
+type IncrementalHeader struct {
+	BaseEndBlock uint64
+	Depth        uint32
+}
+
+// IsIncrementalSnapshot tells if `data` starts like an incremental snapshot.
+func IsIncrementalSnapshot(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(incrementalSnapshotMagic))
+}
+
+// EncodeIncrementalSnapshot marshals `snapshot` with `m`, its changes
+// encoded with `codec`.
+func EncodeIncrementalSnapshot(snapshot *IncrementalSnapshot, m Marshaller, codec Codec) ([]byte, error) {
+	data, err := m.Marshal(&StoreData{Kv: snapshot.Kv})
+	if err != nil {
+		return nil, err
+	}
+
+	var payload []byte
+	payload = binary.AppendUvarint(payload, uint64(len(snapshot.DeletedKeys)))
+	for _, key := range snapshot.DeletedKeys {
+		payload = binary.AppendUvarint(payload, uint64(len(key)))
+		payload = append(payload, key...)
+	}
+	payload = append(payload, data...)
+
+	body, err := EncodeSnapshot(payload, codec)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, IncrementalHeaderSize, IncrementalHeaderSize+len(body))
+	copy(out, incrementalSnapshotMagic)
+	out[4] = IncrementalSnapshotFormatVersion
+	binary.LittleEndian.PutUint64(out[5:13], snapshot.BaseEndBlock)
+	binary.LittleEndian.PutUint32(out[13:17], snapshot.Depth)
+	binary.LittleEndian.PutUint32(out[17:21], crc32.Checksum(out[4:17], crc32c))
+	return append(out, body...), nil
+}
+
+// DecodeIncrementalHeader reads the header of the incremental snapshot
+// `data`, of which only the first IncrementalHeaderSize bytes are needed.
+func DecodeIncrementalHeader(data []byte) (*IncrementalHeader, error) {
+	if !IsIncrementalSnapshot(data) {
+		return nil, fmt.Errorf("not an incremental snapshot")
+	}
+	if len(data) < IncrementalHeaderSize {
+		return nil, fmt.Errorf("truncated incremental snapshot header: %d bytes", len(data))
+	}
+	if data[4] != IncrementalSnapshotFormatVersion {
+		return nil, fmt.Errorf("unsupported incremental snapshot format version %d", data[4])
+	}
+	expected := binary.LittleEndian.Uint32(data[17:21])
+	if checksum := crc32.Checksum(data[4:17], crc32c); checksum != expected {
+		return nil, fmt.Errorf("header: %w: got %08x, expected %08x", ErrChecksumMismatch, checksum, expected)
+	}
+
+	return &IncrementalHeader{
+		BaseEndBlock: binary.LittleEndian.Uint64(data[5:13]),
+		Depth:        binary.LittleEndian.Uint32(data[13:17]),
+	}, nil
+}
+
+// DecodeIncrementalSnapshot unmarshals the incremental snapshot `data` with
+// `m`, verifying its checksums. The returned header is the one of the
+// snapshot container of the changes.
+func DecodeIncrementalSnapshot(data []byte, m Marshaller) (*IncrementalSnapshot, *SnapshotHeader, error) {
+	header, err := DecodeIncrementalHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload, snapshotHeader, err := DecodeSnapshot(data[IncrementalHeaderSize:])
+	if err != nil {
+		return nil, snapshotHeader, err
+	}
+	if snapshotHeader == nil {
+		return nil, nil, fmt.Errorf("incremental snapshot changes are not in a snapshot container")
+	}
+
+	r := bytes.NewReader(payload)
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, snapshotHeader, fmt.Errorf("reading deleted key count: %w", err)
+	}
+	if count > uint64(r.Len()) {
+		return nil, snapshotHeader, fmt.Errorf("invalid deleted key count %d", count)
+	}
+	deletedKeys := make([]string, 0, count)
+	for i := uint64(0); i < count; i++ {
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, snapshotHeader, fmt.Errorf("reading deleted key: %w", err)
+		}
+		if length > uint64(r.Len()) {
+			return nil, snapshotHeader, fmt.Errorf("reading deleted key: %w", io.ErrUnexpectedEOF)
+		}
+		key := make([]byte, length)
+		if _, err := io.ReadFull(r, key); err != nil {
+			return nil, snapshotHeader, fmt.Errorf("reading deleted key: %w", err)
+		}
+		deletedKeys = append(deletedKeys, string(key))
+	}
+
+	storeData, _, err := m.Unmarshal(payload[len(payload)-r.Len():])
+	if err != nil {
+		return nil, snapshotHeader, err
+	}
+
+	return &IncrementalSnapshot{
+		IncrementalHeader: *header,
+		Kv:                storeData.Kv,
+		DeletedKeys:       deletedKeys,
+	}, snapshotHeader, nil
+}
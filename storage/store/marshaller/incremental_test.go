@@ -0,0 +1,52 @@
+package marshaller
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncrementalSnapshot_EncodeDecode(t *testing.T) {
+	snapshot := &IncrementalSnapshot{
+		IncrementalHeader: IncrementalHeader{BaseEndBlock: 1000, Depth: 2},
+		Kv:                map[string][]byte{"changed": []byte("value"), "created": []byte("other value")},
+		DeletedKeys:       []string{"deleted", "also deleted"},
+	}
+
+	data, err := EncodeIncrementalSnapshot(snapshot, Default(), CodecZstd)
+	require.NoError(t, err)
+	assert.True(t, IsIncrementalSnapshot(data))
+
+	header, err := DecodeIncrementalHeader(data[:IncrementalHeaderSize])
+	require.NoError(t, err)
+	assert.Equal(t, snapshot.IncrementalHeader, *header)
+
+	decoded, snapshotHeader, err := DecodeIncrementalSnapshot(data, Default())
+	require.NoError(t, err)
+	require.NotNil(t, snapshotHeader)
+	assert.Equal(t, CodecZstd, snapshotHeader.Codec)
+	assert.Equal(t, snapshot, decoded)
+}
+
+func TestIncrementalSnapshot_DecodeCorrupted(t *testing.T) {
+	data, err := EncodeIncrementalSnapshot(&IncrementalSnapshot{
+		IncrementalHeader: IncrementalHeader{BaseEndBlock: 1000, Depth: 1},
+		Kv:                map[string][]byte{"key": []byte("value")},
+	}, Default(), CodecNone)
+	require.NoError(t, err)
+
+	corrupted := bytes.Clone(data)
+	corrupted[6] ^= 0xff
+	_, err = DecodeIncrementalHeader(corrupted)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	corrupted = bytes.Clone(data)
+	corrupted[len(corrupted)-1] ^= 0xff
+	_, _, err = DecodeIncrementalSnapshot(corrupted, Default())
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	_, err = DecodeIncrementalHeader(data[:10])
+	assert.Error(t, err)
+}
@@ -0,0 +1,428 @@
+package marshaller
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"sync"
+)
+
+// An indexed snapshot holds the keys of a store sorted and grouped in blocks,
+// followed by an index of the blocks, so a key is read without loading the
+// whole store:
+//
+//	magic (4 bytes) | version (1 byte) | codec (1 byte) | blocks | index | footer
+//
+// A block is a sequence of `uvarint(len(key)) | key | uvarint(len(value)) |
+// value` entries, encoded with the codec. The index lists, for each block,
+// `uvarint(len(first key)) | first key | uvarint(offset) | uvarint(length) |
+// uvarint(entry count) | checksum (4 bytes)`, the checksum being the CRC-32
+// (Castagnoli) of the encoded block. The footer is `index offset (8 bytes) |
+// index length (8 bytes) | index checksum (4 bytes) | key count (8 bytes) |
+// size in bytes (8 bytes) | magic (4 bytes)`, integers in little endian.
+//
+// Indexed snapshots only hold the keys and values of a store.
+const (
+	IndexedSnapshotFormatVersion = 1
+
+	indexedSnapshotMagic      = "\xffSSI"
+	indexedSnapshotHeaderSize = len(indexedSnapshotMagic) + 1 + 1
+	indexedSnapshotFooterSize = 8 + 8 + 4 + 8 + 8 + len(indexedSnapshotMagic)
+
+	indexedBlockTargetSize = 64 * 1024
+	indexedBlockCacheSize  = 64
+)
+
+// IsIndexedSnapshot tells if `data` starts like an indexed snapshot.
+func IsIndexedSnapshot(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(indexedSnapshotMagic))
+}
+
+// EncodeIndexedSnapshot writes `kv` as an indexed snapshot, its blocks
+// encoded with `codec`.
+func EncodeIndexedSnapshot(kv map[string][]byte, codec Codec) ([]byte, error) {
+	keys := make([]string, 0, len(kv))
+	for key := range kv {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := make([]byte, indexedSnapshotHeaderSize, indexedSnapshotHeaderSize+indexedBlockTargetSize)
+	copy(out, indexedSnapshotMagic)
+	out[4] = IndexedSnapshotFormatVersion
+	out[5] = byte(codec)
+
+	var index []byte
+	var sizeBytes uint64
+	var block []byte
+	var firstKey string
+	var entryCount uint64
+	flush := func() error {
+		if entryCount == 0 {
+			return nil
+		}
+		encoded, err := encodeBlock(block, codec)
+		if err != nil {
+			return err
+		}
+		index = binary.AppendUvarint(index, uint64(len(firstKey)))
+		index = append(index, firstKey...)
+		index = binary.AppendUvarint(index, uint64(len(out)))
+		index = binary.AppendUvarint(index, uint64(len(encoded)))
+		index = binary.AppendUvarint(index, entryCount)
+		index = binary.LittleEndian.AppendUint32(index, crc32.Checksum(encoded, crc32c))
+		out = append(out, encoded...)
+
+		block = block[:0]
+		entryCount = 0
+		return nil
+	}
+
+	for _, key := range keys {
+		value := kv[key]
+		if entryCount == 0 {
+			firstKey = key
+		}
+		block = binary.AppendUvarint(block, uint64(len(key)))
+		block = append(block, key...)
+		block = binary.AppendUvarint(block, uint64(len(value)))
+		block = append(block, value...)
+		entryCount++
+		sizeBytes += uint64(len(key) + len(value))
+
+		if len(block) >= indexedBlockTargetSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	indexOffset := uint64(len(out))
+	out = append(out, index...)
+	out = binary.LittleEndian.AppendUint64(out, indexOffset)
+	out = binary.LittleEndian.AppendUint64(out, uint64(len(index)))
+	out = binary.LittleEndian.AppendUint32(out, crc32.Checksum(index, crc32c))
+	out = binary.LittleEndian.AppendUint64(out, uint64(len(keys)))
+	out = binary.LittleEndian.AppendUint64(out, sizeBytes)
+	out = append(out, indexedSnapshotMagic...)
+	return out, nil
+}
+
+// DecodeIndexedSnapshot reads all the keys of the indexed snapshot `data`,
+// verifying the checksums of its blocks. It returns the size in bytes of
+// the keys and values, like Marshaller.Unmarshal.
+func DecodeIndexedSnapshot(data []byte) (*StoreData, uint64, error) {
+	snapshot, err := OpenIndexedSnapshot(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	kv := make(map[string][]byte, snapshot.Len())
+	err = snapshot.Iter(func(key string, value []byte) error {
+		kv[key] = value
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &StoreData{Kv: kv}, snapshot.SizeBytes(), nil
+}
+
+// VerifyIndexedSnapshot verifies the checksums of the index and of every
+// block of the indexed snapshot `data`.
+func VerifyIndexedSnapshot(data []byte) (*SnapshotHeader, error) {
+	snapshot, err := OpenIndexedSnapshot(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for i := range snapshot.index {
+		if _, err := snapshot.readBlock(i); err != nil {
+			return snapshot.header, err
+		}
+	}
+	return snapshot.header, nil
+}
+
+// IndexedSnapshot reads the keys of an indexed snapshot on demand, keeping
+// the most recently read blocks decoded. It is safe for concurrent use.
+type IndexedSnapshot struct {
+	r      io.ReaderAt
+	header *SnapshotHeader
+	index  []indexedBlock
+
+	keyCount  uint64
+	sizeBytes uint64
+
+	lock   sync.Mutex
+	cache  map[int]*list.Element
+	recent *list.List // of *decodedBlock, most recently read first
+}
+
+type indexedBlock struct {
+	firstKey   string
+	offset     uint64
+	length     uint64
+	entryCount uint64
+	checksum   uint32
+}
+
+type decodedBlock struct {
+	index  int
+	keys   []string
+	values [][]byte
+}
+
+// OpenIndexedSnapshot reads the index of the indexed snapshot of `size`
+// bytes held by `r`, verifying its checksum. The blocks are read from `r`
+// as keys are looked up.
+func OpenIndexedSnapshot(r io.ReaderAt, size int64) (*IndexedSnapshot, error) {
+	if size < int64(indexedSnapshotHeaderSize+indexedSnapshotFooterSize) {
+		return nil, fmt.Errorf("truncated indexed snapshot: %d bytes", size)
+	}
+
+	head := make([]byte, indexedSnapshotHeaderSize)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return nil, fmt.Errorf("reading indexed snapshot header: %w", err)
+	}
+	if !IsIndexedSnapshot(head) {
+		return nil, fmt.Errorf("not an indexed snapshot")
+	}
+	header := &SnapshotHeader{Version: head[4], Codec: Codec(head[5])}
+	if header.Version != IndexedSnapshotFormatVersion {
+		return nil, fmt.Errorf("unsupported indexed snapshot format version %d", header.Version)
+	}
+
+	footer := make([]byte, indexedSnapshotFooterSize)
+	if _, err := r.ReadAt(footer, size-int64(indexedSnapshotFooterSize)); err != nil {
+		return nil, fmt.Errorf("reading indexed snapshot footer: %w", err)
+	}
+	if string(footer[36:]) != indexedSnapshotMagic {
+		return nil, fmt.Errorf("truncated indexed snapshot: invalid footer")
+	}
+	indexOffset := binary.LittleEndian.Uint64(footer[0:8])
+	indexLength := binary.LittleEndian.Uint64(footer[8:16])
+	header.Checksum = binary.LittleEndian.Uint32(footer[16:20])
+	keyCount := binary.LittleEndian.Uint64(footer[20:28])
+	header.PayloadSize = binary.LittleEndian.Uint64(footer[28:36])
+
+	if indexOffset < uint64(indexedSnapshotHeaderSize) || indexOffset+indexLength != uint64(size)-uint64(indexedSnapshotFooterSize) {
+		return nil, fmt.Errorf("invalid indexed snapshot index at %d (%d bytes)", indexOffset, indexLength)
+	}
+	rawIndex := make([]byte, indexLength)
+	if _, err := r.ReadAt(rawIndex, int64(indexOffset)); err != nil {
+		return nil, fmt.Errorf("reading indexed snapshot index: %w", err)
+	}
+	if checksum := crc32.Checksum(rawIndex, crc32c); checksum != header.Checksum {
+		return nil, fmt.Errorf("index: %w: got %08x, expected %08x", ErrChecksumMismatch, checksum, header.Checksum)
+	}
+
+	index, err := decodeIndex(rawIndex, indexOffset)
+	if err != nil {
+		return nil, fmt.Errorf("decoding indexed snapshot index: %w", err)
+	}
+
+	return &IndexedSnapshot{
+		r:         r,
+		header:    header,
+		index:     index,
+		keyCount:  keyCount,
+		sizeBytes: header.PayloadSize,
+		cache:     make(map[int]*list.Element),
+		recent:    list.New(),
+	}, nil
+}
+
+func decodeIndex(data []byte, blocksEnd uint64) (out []indexedBlock, err error) {
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		var block indexedBlock
+		keyLength, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if keyLength > uint64(r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		key := make([]byte, keyLength)
+		if _, err := io.ReadFull(r, key); err != nil {
+			return nil, err
+		}
+		block.firstKey = string(key)
+		for _, field := range []*uint64{&block.offset, &block.length, &block.entryCount} {
+			if *field, err = binary.ReadUvarint(r); err != nil {
+				return nil, err
+			}
+		}
+		if err := binary.Read(r, binary.LittleEndian, &block.checksum); err != nil {
+			return nil, err
+		}
+		if block.offset+block.length > blocksEnd {
+			return nil, fmt.Errorf("block at %d (%d bytes) past the end of the blocks", block.offset, block.length)
+		}
+		if len(out) > 0 && out[len(out)-1].firstKey >= block.firstKey {
+			return nil, fmt.Errorf("blocks out of order at key %q", block.firstKey)
+		}
+		out = append(out, block)
+	}
+	return out, nil
+}
+
+func (s *IndexedSnapshot) Header() *SnapshotHeader { return s.header }
+
+// Close closes the reader of the snapshot when it is an io.Closer.
+func (s *IndexedSnapshot) Close() error {
+	if closer, ok := s.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Len is the number of keys of the snapshot.
+func (s *IndexedSnapshot) Len() uint64 { return s.keyCount }
+
+// SizeBytes is the size of the keys and values of the snapshot.
+func (s *IndexedSnapshot) SizeBytes() uint64 { return s.sizeBytes }
+
+// Get returns the value of `key`.
+func (s *IndexedSnapshot) Get(key string) ([]byte, bool, error) {
+	i := sort.Search(len(s.index), func(i int) bool { return s.index[i].firstKey > key }) - 1
+	if i < 0 {
+		return nil, false, nil
+	}
+
+	block, err := s.readBlock(i)
+	if err != nil {
+		return nil, false, err
+	}
+	j := sort.SearchStrings(block.keys, key)
+	if j == len(block.keys) || block.keys[j] != key {
+		return nil, false, nil
+	}
+	return block.values[j], true, nil
+}
+
+// Iter calls `f` for every key of the snapshot, in lexicographical order.
+func (s *IndexedSnapshot) Iter(f func(key string, value []byte) error) error {
+	return s.Scan("", "", f)
+}
+
+// Scan calls `f`, in lexicographical order, for the keys between `lowKey`
+// (inclusive) and `highKey` (exclusive). An empty `highKey` means no upper
+// bound.
+func (s *IndexedSnapshot) Scan(lowKey, highKey string, f func(key string, value []byte) error) error {
+	first := sort.Search(len(s.index), func(i int) bool { return s.index[i].firstKey > lowKey }) - 1
+	if first < 0 {
+		first = 0
+	}
+
+	for i := first; i < len(s.index); i++ {
+		if highKey != "" && s.index[i].firstKey >= highKey {
+			return nil
+		}
+		block, err := s.readBlock(i)
+		if err != nil {
+			return err
+		}
+		for j := sort.SearchStrings(block.keys, lowKey); j < len(block.keys); j++ {
+			if highKey != "" && block.keys[j] >= highKey {
+				return nil
+			}
+			if err := f(block.keys[j], block.values[j]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *IndexedSnapshot) readBlock(i int) (*decodedBlock, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if element, found := s.cache[i]; found {
+		s.recent.MoveToFront(element)
+		return element.Value.(*decodedBlock), nil
+	}
+
+	entry := s.index[i]
+	encoded := make([]byte, entry.length)
+	if _, err := s.r.ReadAt(encoded, int64(entry.offset)); err != nil {
+		return nil, fmt.Errorf("reading block %d: %w", i, err)
+	}
+	if checksum := crc32.Checksum(encoded, crc32c); checksum != entry.checksum {
+		return nil, fmt.Errorf("block %d: %w: got %08x, expected %08x", i, ErrChecksumMismatch, checksum, entry.checksum)
+	}
+	raw, err := decodeBlock(encoded, s.header.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("decoding block %d: %w", i, err)
+	}
+
+	block := &decodedBlock{
+		index:  i,
+		keys:   make([]string, 0, entry.entryCount),
+		values: make([][]byte, 0, entry.entryCount),
+	}
+	for pos := 0; pos < len(raw); {
+		key, next, err := readIndexedField(raw, pos)
+		if err != nil {
+			return nil, fmt.Errorf("decoding block %d: %w", i, err)
+		}
+		value, next, err := readIndexedField(raw, next)
+		if err != nil {
+			return nil, fmt.Errorf("decoding block %d: %w", i, err)
+		}
+		block.keys = append(block.keys, string(key))
+		block.values = append(block.values, value)
+		pos = next
+	}
+	if uint64(len(block.keys)) != entry.entryCount || (len(block.keys) > 0 && block.keys[0] != entry.firstKey) {
+		return nil, fmt.Errorf("block %d does not match its index entry", i)
+	}
+
+	s.cache[i] = s.recent.PushFront(block)
+	if s.recent.Len() > indexedBlockCacheSize {
+		oldest := s.recent.Remove(s.recent.Back()).(*decodedBlock)
+		delete(s.cache, oldest.index)
+	}
+	return block, nil
+}
+
+func readIndexedField(raw []byte, pos int) ([]byte, int, error) {
+	length, n := binary.Uvarint(raw[pos:])
+	if n <= 0 {
+		return nil, 0, fmt.Errorf("invalid length at %d", pos)
+	}
+	pos += n
+	if length > uint64(len(raw)-pos) {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	end := pos + int(length)
+	return raw[pos:end:end], end, nil
+}
+
+func encodeBlock(raw []byte, codec Codec) ([]byte, error) {
+	switch codec {
+	case CodecNone:
+		return append([]byte(nil), raw...), nil
+	case CodecZstd:
+		return zstdEncoder().EncodeAll(raw, nil), nil
+	}
+	return nil, fmt.Errorf("unsupported snapshot codec %s", codec)
+}
+
+func decodeBlock(encoded []byte, codec Codec) ([]byte, error) {
+	switch codec {
+	case CodecNone:
+		return encoded, nil
+	case CodecZstd:
+		return zstdDecoder().DecodeAll(encoded, nil)
+	}
+	return nil, fmt.Errorf("unsupported snapshot codec %s", codec)
+}
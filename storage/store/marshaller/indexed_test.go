@@ -0,0 +1,110 @@
+package marshaller
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testIndexedKV(count int) map[string][]byte {
+	kv := make(map[string][]byte, count)
+	for i := 0; i < count; i++ {
+		kv[fmt.Sprintf("key:%05d", i)] = bytes.Repeat([]byte{byte(i)}, 100)
+	}
+	return kv
+}
+
+func TestIndexedSnapshot_EncodeDecode(t *testing.T) {
+	for _, codec := range []Codec{CodecNone, CodecZstd} {
+		t.Run(codec.String(), func(t *testing.T) {
+			kv := testIndexedKV(5000)
+			data, err := EncodeIndexedSnapshot(kv, codec)
+			require.NoError(t, err)
+			assert.True(t, IsIndexedSnapshot(data))
+			assert.False(t, IsSnapshotContainer(data))
+
+			snapshot, err := OpenIndexedSnapshot(bytes.NewReader(data), int64(len(data)))
+			require.NoError(t, err)
+			assert.Greater(t, len(snapshot.index), 1)
+			assert.Equal(t, uint64(5000), snapshot.Len())
+			assert.Equal(t, uint64(5000*(9+100)), snapshot.SizeBytes())
+
+			value, found, err := snapshot.Get("key:04321")
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, kv["key:04321"], value)
+
+			for _, key := range []string{"a", "key:", "key:99999", "z"} {
+				_, found, err = snapshot.Get(key)
+				require.NoError(t, err)
+				assert.False(t, found, key)
+			}
+
+			storeData, size, err := DecodeIndexedSnapshot(data)
+			require.NoError(t, err)
+			assert.Equal(t, kv, storeData.Kv)
+			assert.Equal(t, snapshot.SizeBytes(), size)
+		})
+	}
+}
+
+func TestIndexedSnapshot_Scan(t *testing.T) {
+	data, err := EncodeIndexedSnapshot(testIndexedKV(5000), CodecZstd)
+	require.NoError(t, err)
+	snapshot, err := OpenIndexedSnapshot(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	scan := func(lowKey, highKey string) (keys []string) {
+		require.NoError(t, snapshot.Scan(lowKey, highKey, func(key string, _ []byte) error {
+			keys = append(keys, key)
+			return nil
+		}))
+		return keys
+	}
+
+	assert.Equal(t, []string{"key:01998", "key:01999", "key:02000"}, scan("key:01998", "key:02001"))
+	assert.Equal(t, []string{"key:04999"}, scan("key:04999", ""))
+	assert.Len(t, scan("", ""), 5000)
+	assert.Len(t, scan("key:01", "key:02"), 1000)
+	assert.Empty(t, scan("key:05", ""))
+	assert.Empty(t, scan("", "key:"))
+}
+
+func TestIndexedSnapshot_Empty(t *testing.T) {
+	data, err := EncodeIndexedSnapshot(nil, CodecZstd)
+	require.NoError(t, err)
+
+	storeData, size, err := DecodeIndexedSnapshot(data)
+	require.NoError(t, err)
+	assert.Empty(t, storeData.Kv)
+	assert.Equal(t, uint64(0), size)
+}
+
+func TestIndexedSnapshot_Corrupted(t *testing.T) {
+	data, err := EncodeIndexedSnapshot(testIndexedKV(5000), CodecZstd)
+	require.NoError(t, err)
+
+	_, err = VerifyIndexedSnapshot(data)
+	require.NoError(t, err)
+
+	corrupted := bytes.Clone(data)
+	corrupted[indexedSnapshotHeaderSize+10] ^= 0xff
+
+	snapshot, err := OpenIndexedSnapshot(bytes.NewReader(corrupted), int64(len(corrupted)))
+	require.NoError(t, err, "the index is intact")
+	_, _, err = snapshot.Get("key:00000")
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	_, err = VerifyIndexedSnapshot(corrupted)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	corrupted = bytes.Clone(data)
+	corrupted[len(corrupted)-indexedSnapshotFooterSize-1] ^= 0xff
+	_, err = OpenIndexedSnapshot(bytes.NewReader(corrupted), int64(len(corrupted)))
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	_, err = OpenIndexedSnapshot(bytes.NewReader(data[:len(data)-1]), int64(len(data)-1))
+	assert.Error(t, err)
+}
@@ -1,8 +1,46 @@
 package marshaller
 
+import (
+	pbstore "github.com/streamingfast/substreams/storage/store/marshaller/pb"
+)
+
 type StoreData struct {
 	Kv             map[string][]byte
 	DeletePrefixes []string
+	DeleteRanges   []DeleteRange
+	LastWrites     map[string]uint64
+	FirstWrites    map[string]uint64
+}
+
+// DeleteRange is a deletion of the keys between `LowKey` (inclusive) and `HighKey`
+// (exclusive). When `PointerSeparator` is not empty, the value of each deleted key
+// is also considered as a `PointerSeparator`-separated list of keys to delete.
+type DeleteRange struct {
+	LowKey           string
+	HighKey          string
+	PointerSeparator string
+}
+
+func deleteRangesToProto(in []DeleteRange) (out []*pbstore.DeleteRange) {
+	for _, r := range in {
+		out = append(out, &pbstore.DeleteRange{
+			LowKey:           r.LowKey,
+			HighKey:          r.HighKey,
+			PointerSeparator: r.PointerSeparator,
+		})
+	}
+	return
+}
+
+func deleteRangesFromProto(in []*pbstore.DeleteRange) (out []DeleteRange) {
+	for _, r := range in {
+		out = append(out, DeleteRange{
+			LowKey:           r.LowKey,
+			HighKey:          r.HighKey,
+			PointerSeparator: r.PointerSeparator,
+		})
+	}
+	return
 }
 
 type Marshaller interface {
@@ -12,6 +12,10 @@ const KVEntryProtoTag = 0x0a
 const KVEntryKeyProtoTag = 0x0a
 const KVEntryValueProtoTag = 0x12
 const DeletePrefixEntryProtoTag = 0x12
+const DeleteRangeEntryProtoTag = 0x1a
+const LastWritesEntryProtoTag = 0x22
+const FirstWritesEntryProtoTag = 0x2a
+const WritesEntryValueProtoTag = 0x10
 
 // ProtoingFast is a custom proto marshaller, that will marshal and unmarshall the storeData into a predefined
 // proto struct (see below). The motivation here is that we want to write a proto message, making it readable by
@@ -20,6 +24,9 @@ const DeletePrefixEntryProtoTag = 0x12
 //	message StoreData {
 //		map<string, bytes> kv = 1;
 //		repeated string delete_prefixes = 2;
+//		repeated DeleteRange delete_ranges = 3;
+//		map<string, uint64> last_writes = 4;
+//		map<string, uint64> first_writes = 5;
 //	}
 type ProtoingFast struct{}
 
@@ -31,16 +38,30 @@ func (p *ProtoingFast) Unmarshal(in []byte) (*StoreData, uint64, error) {
 	return &StoreData{
 		Kv:             stateData.GetKv(),
 		DeletePrefixes: stateData.GetDeletePrefixes(),
+		DeleteRanges:   deleteRangesFromProto(stateData.GetDeleteRanges()),
+		LastWrites:     stateData.GetLastWrites(),
+		FirstWrites:    stateData.GetFirstWrites(),
 	}, 0, nil
 }
 
 func (p *ProtoingFast) Marshal(data *StoreData) ([]byte, error) {
+	deleteRanges := deleteRangesToProto(data.DeleteRanges)
+
 	sizeInBytes := p.kvByteSize(data.Kv)
 	sizeInBytes += p.listByteSize(data.DeletePrefixes)
+	sizeInBytes += p.deleteRangesByteSize(deleteRanges)
+	sizeInBytes += p.writesByteSize(data.LastWrites)
+	sizeInBytes += p.writesByteSize(data.FirstWrites)
 	buffer := make([]byte, sizeInBytes)
 	cursor := buffer
 	cursor = p.writeKV(cursor, data.Kv)
-	p.writeDeletePrefix(cursor, data.DeletePrefixes)
+	cursor = p.writeDeletePrefix(cursor, data.DeletePrefixes)
+	cursor, err := p.writeDeleteRanges(cursor, deleteRanges)
+	if err != nil {
+		return nil, fmt.Errorf("marshal delete ranges: %w", err)
+	}
+	cursor = p.writeWrites(cursor, LastWritesEntryProtoTag, data.LastWrites)
+	p.writeWrites(cursor, FirstWritesEntryProtoTag, data.FirstWrites)
 	return buffer, nil
 
 }
@@ -118,3 +139,77 @@ func (p *ProtoingFast) writeDeletePrefix(cursor []byte, entries []string) []byte
 	}
 	return cursor
 }
+
+func (p *ProtoingFast) deleteRangesByteSize(ranges []*pbsubstreams.DeleteRange) int {
+	size := 0
+	for _, r := range ranges {
+		entrySize := r.SizeVT()
+		size += 1                                   // List element proto tag 0x1a (field number 3 [the DeleteRanges field], type LEN [message])
+		size += uvarintByteCount(uint64(entrySize)) // Number of bytes of the message
+		size += entrySize
+	}
+	return size
+}
+
+func (p *ProtoingFast) writeDeleteRanges(cursor []byte, ranges []*pbsubstreams.DeleteRange) ([]byte, error) {
+	for _, r := range ranges {
+		copy(cursor, []byte{DeleteRangeEntryProtoTag})
+		cursor = cursor[1:]
+
+		size := r.SizeVT()
+		written := binary.PutUvarint(cursor, uint64(size))
+		cursor = cursor[written:]
+
+		if _, err := r.MarshalToSizedBufferVT(cursor[:size]); err != nil {
+			return nil, err
+		}
+		cursor = cursor[size:]
+	}
+	return cursor, nil
+}
+
+func (p *ProtoingFast) writesByteSize(entries map[string]uint64) int {
+	size := 0
+	for k, v := range entries {
+		entrySize := writesEntryByteSize(k, v)
+		size += 1                                   // Map Key/Value proto tag 0x22 or 0x2a (field number 4 or 5 [the LastWrites or FirstWrites field], type LEN)
+		size += uvarintByteCount(uint64(entrySize)) // Number of bytes to represent both key and value
+		size += entrySize
+	}
+	return size
+}
+
+func writesEntryByteSize(key string, value uint64) int {
+	size := 1                                  // Key proto tag 0x0a (field number 1 [the key], type LEN [string])
+	size += uvarintByteCount(uint64(len(key))) // Number of bytes (characters) in the key
+	size += len(key)                           // key
+	size += 1                                  // Value proto tag 0x10 (field number 2 [the value], type VARINT)
+	size += uvarintByteCount(value)            // value
+	return size
+}
+
+func (p *ProtoingFast) writeWrites(cursor []byte, tag byte, entries map[string]uint64) []byte {
+	for key, value := range entries {
+		copy(cursor, []byte{tag})
+		cursor = cursor[1:]
+
+		written := binary.PutUvarint(cursor, uint64(writesEntryByteSize(key, value)))
+		cursor = cursor[written:]
+
+		copy(cursor, []byte{KVEntryKeyProtoTag})
+		cursor = cursor[1:]
+
+		written = binary.PutUvarint(cursor, uint64(len(key)))
+		cursor = cursor[written:]
+
+		copy(cursor, unsafeGetBytes(key))
+		cursor = cursor[len(key):]
+
+		copy(cursor, []byte{WritesEntryValueProtoTag})
+		cursor = cursor[1:]
+
+		written = binary.PutUvarint(cursor, value)
+		cursor = cursor[written:]
+	}
+	return cursor
+}
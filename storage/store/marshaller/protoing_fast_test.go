@@ -53,6 +53,27 @@ func TestProtoFast_CompareMarshal(t *testing.T) {
 				DeletePrefixes: []string{"22"},
 			},
 		},
+		{
+			name: "only delete ranges",
+			data: &StoreData{
+				DeleteRanges: []DeleteRange{
+					{LowKey: "a", HighKey: "b"},
+					{LowKey: "owner:", HighKey: "owner;", PointerSeparator: ","},
+				},
+			},
+		},
+		{
+			name: "only last writes",
+			data: &StoreData{
+				LastWrites: map[string]uint64{"a": 1_000_042},
+			},
+		},
+		{
+			name: "only first writes",
+			data: &StoreData{
+				FirstWrites: map[string]uint64{"a": 0},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -77,6 +98,11 @@ func TestProtoFast_CompareMarshal(t *testing.T) {
 
 			assert.Equal(t, test.data, v)
 
+			v, _, err = vp.Unmarshal(vtProtoData)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.data, v)
+
 		})
 	}
 }
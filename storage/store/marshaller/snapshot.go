@@ -0,0 +1,148 @@
+package marshaller
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// A snapshot is a marshalled StoreData wrapped in a container:
+//
+//	magic (4 bytes) | version (1 byte) | codec (1 byte) | checksum (4 bytes) | payload size (8 bytes) | body
+//
+// The checksum is the CRC-32 (Castagnoli) of the marshalled StoreData, and the
+// body is that payload encoded with the codec. Legacy snapshots, written
+// before the container, are the bare marshalled StoreData: a marshalled
+// StoreData starts with the tag of one of its fields, never with the 0xff of
+// the magic.
+const (
+	SnapshotFormatVersion = 1
+
+	snapshotMagic      = "\xffSSN"
+	snapshotHeaderSize = len(snapshotMagic) + 1 + 1 + 4 + 8
+)
+
+// Codec is the compression of the body of a snapshot.
+type Codec uint8
+
+const (
+	CodecNone Codec = 0
+	CodecZstd Codec = 1
+)
+
+func (c Codec) String() string {
+	switch c {
+	case CodecNone:
+		return "none"
+	case CodecZstd:
+		return "zstd"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(c))
+}
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+type SnapshotHeader struct {
+	Version     uint8
+	Codec       Codec
+	Checksum    uint32
+	PayloadSize uint64
+}
+
+// EncodeSnapshot wraps `payload`, a marshalled StoreData, in a snapshot
+// container, its body encoded with `codec`.
+func EncodeSnapshot(payload []byte, codec Codec) ([]byte, error) {
+	header := make([]byte, snapshotHeaderSize, snapshotHeaderSize+len(payload))
+	copy(header, snapshotMagic)
+	header[4] = SnapshotFormatVersion
+	header[5] = byte(codec)
+	binary.LittleEndian.PutUint32(header[6:10], crc32.Checksum(payload, crc32c))
+	binary.LittleEndian.PutUint64(header[10:18], uint64(len(payload)))
+
+	switch codec {
+	case CodecNone:
+		return append(header, payload...), nil
+	case CodecZstd:
+		return zstdEncoder().EncodeAll(payload, header), nil
+	}
+	return nil, fmt.Errorf("unsupported snapshot codec %s", codec)
+}
+
+// DecodeSnapshot returns the marshalled StoreData held by the snapshot
+// `data`, verifying its checksum. Legacy snapshots are returned as is, with
+// a nil header.
+func DecodeSnapshot(data []byte) (payload []byte, header *SnapshotHeader, err error) {
+	if !IsSnapshotContainer(data) {
+		return data, nil, nil
+	}
+	if len(data) < snapshotHeaderSize {
+		return nil, nil, fmt.Errorf("truncated snapshot header: %d bytes", len(data))
+	}
+
+	header = &SnapshotHeader{
+		Version:     data[4],
+		Codec:       Codec(data[5]),
+		Checksum:    binary.LittleEndian.Uint32(data[6:10]),
+		PayloadSize: binary.LittleEndian.Uint64(data[10:18]),
+	}
+	if header.Version != SnapshotFormatVersion {
+		return nil, header, fmt.Errorf("unsupported snapshot format version %d", header.Version)
+	}
+
+	body := data[snapshotHeaderSize:]
+	switch header.Codec {
+	case CodecNone:
+		payload = body
+	case CodecZstd:
+		payload, err = zstdDecoder().DecodeAll(body, nil)
+		if err != nil {
+			return nil, header, fmt.Errorf("decompressing snapshot: %w", err)
+		}
+	default:
+		return nil, header, fmt.Errorf("unsupported snapshot codec %s", header.Codec)
+	}
+
+	if uint64(len(payload)) != header.PayloadSize {
+		return nil, header, fmt.Errorf("%w: payload of %d bytes, expected %d", ErrChecksumMismatch, len(payload), header.PayloadSize)
+	}
+	if checksum := crc32.Checksum(payload, crc32c); checksum != header.Checksum {
+		return nil, header, fmt.Errorf("%w: got %08x, expected %08x", ErrChecksumMismatch, checksum, header.Checksum)
+	}
+	return payload, header, nil
+}
+
+// IsSnapshotContainer tells if `data` is a snapshot container, as opposed to
+// a legacy snapshot.
+func IsSnapshotContainer(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(snapshotMagic))
+}
+
+var (
+	zstdEncoderOnce sync.Once
+	zstdEncoderInst *zstd.Encoder
+	zstdDecoderOnce sync.Once
+	zstdDecoderInst *zstd.Decoder
+)
+
+// The encoder and decoder are safe for concurrent use with EncodeAll and
+// DecodeAll.
+func zstdEncoder() *zstd.Encoder {
+	zstdEncoderOnce.Do(func() {
+		zstdEncoderInst, _ = zstd.NewWriter(nil)
+	})
+	return zstdEncoderInst
+}
+
+func zstdDecoder() *zstd.Decoder {
+	zstdDecoderOnce.Do(func() {
+		zstdDecoderInst, _ = zstd.NewReader(nil)
+	})
+	return zstdDecoderInst
+}
@@ -0,0 +1,118 @@
+package marshaller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_EncodeDecode(t *testing.T) {
+	data := &StoreData{
+		Kv:             map[string][]byte{"key": []byte("value"), "other": []byte("other value")},
+		DeletePrefixes: []string{"prefix:"},
+	}
+	payload, err := Default().Marshal(data)
+	require.NoError(t, err)
+
+	for _, codec := range []Codec{CodecNone, CodecZstd} {
+		t.Run(codec.String(), func(t *testing.T) {
+			snapshot, err := EncodeSnapshot(payload, codec)
+			require.NoError(t, err)
+			assert.True(t, IsSnapshotContainer(snapshot))
+
+			decoded, header, err := DecodeSnapshot(snapshot)
+			require.NoError(t, err)
+			require.NotNil(t, header)
+			assert.Equal(t, codec, header.Codec)
+			assert.Equal(t, uint8(SnapshotFormatVersion), header.Version)
+			assert.Equal(t, payload, decoded)
+
+			out, _, err := Default().Unmarshal(decoded)
+			require.NoError(t, err)
+			assert.Equal(t, data.Kv, out.Kv)
+		})
+	}
+}
+
+func TestSnapshot_DecodeLegacy(t *testing.T) {
+	payload, err := Default().Marshal(&StoreData{Kv: map[string][]byte{"key": []byte("value")}})
+	require.NoError(t, err)
+	assert.False(t, IsSnapshotContainer(payload))
+
+	decoded, header, err := DecodeSnapshot(payload)
+	require.NoError(t, err)
+	assert.Nil(t, header)
+	assert.Equal(t, payload, decoded)
+
+	decoded, header, err = DecodeSnapshot(nil)
+	require.NoError(t, err)
+	assert.Nil(t, header)
+	assert.Empty(t, decoded)
+}
+
+func TestSnapshot_DecodeCorrupted(t *testing.T) {
+	payload, err := Default().Marshal(&StoreData{Kv: map[string][]byte{"key": []byte("value")}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		codec         Codec
+		corrupt       func(snapshot []byte) []byte
+		expectedError error
+	}{
+		{
+			name:  "flipped payload byte",
+			codec: CodecNone,
+			corrupt: func(snapshot []byte) []byte {
+				snapshot[len(snapshot)-1] ^= 0xff
+				return snapshot
+			},
+			expectedError: ErrChecksumMismatch,
+		},
+		{
+			name:  "flipped checksum byte",
+			codec: CodecZstd,
+			corrupt: func(snapshot []byte) []byte {
+				snapshot[6] ^= 0xff
+				return snapshot
+			},
+			expectedError: ErrChecksumMismatch,
+		},
+		{
+			name:  "truncated body",
+			codec: CodecZstd,
+			corrupt: func(snapshot []byte) []byte {
+				return snapshot[:len(snapshot)-4]
+			},
+		},
+		{
+			name:  "truncated header",
+			codec: CodecZstd,
+			corrupt: func(snapshot []byte) []byte {
+				return snapshot[:8]
+			},
+		},
+		{
+			name:  "unknown version",
+			codec: CodecNone,
+			corrupt: func(snapshot []byte) []byte {
+				snapshot[4] = 42
+				return snapshot
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			snapshot, err := EncodeSnapshot(payload, test.codec)
+			require.NoError(t, err)
+
+			_, _, err = DecodeSnapshot(test.corrupt(snapshot))
+			require.Error(t, err)
+			if test.expectedError != nil {
+				assert.ErrorIs(t, err, test.expectedError)
+			}
+		})
+	}
+}
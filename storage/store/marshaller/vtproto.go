@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 
+	"google.golang.org/protobuf/encoding/protowire"
+
 	pbstore "github.com/streamingfast/substreams/storage/store/marshaller/pb"
 )
 
@@ -18,6 +20,9 @@ func (p *VTproto) Unmarshal(in []byte) (*StoreData, uint64, error) {
 	return &StoreData{
 		Kv:             stateData.GetKv(),
 		DeletePrefixes: stateData.GetDeletePrefixes(),
+		DeleteRanges:   deleteRangesFromProto(stateData.GetDeleteRanges()),
+		LastWrites:     stateData.GetLastWrites(),
+		FirstWrites:    stateData.GetFirstWrites(),
 	}, dataSize, nil
 }
 
@@ -25,6 +30,9 @@ func (p *VTproto) Marshal(data *StoreData) ([]byte, error) {
 	stateData := &pbstore.StoreData{
 		Kv:             data.Kv,
 		DeletePrefixes: data.DeletePrefixes,
+		DeleteRanges:   deleteRangesToProto(data.DeleteRanges),
+		LastWrites:     data.LastWrites,
+		FirstWrites:    data.FirstWrites,
 	}
 
 	return stateData.MarshalVT()
@@ -236,6 +244,65 @@ func unmarshalVT(m *pbstore.StoreData, dAtA []byte) (dataSize uint64, err error)
 			//m.DeletePrefixes = append(m.DeletePrefixes, string(dAtA[iNdEx:postIndex]))
 			m.DeletePrefixes = append(m.DeletePrefixes, unsafeGetString(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return 0, fmt.Errorf("proto: wrong wireType = %d for field DeleteRanges", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, pbstore.ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return 0, pbstore.ErrInvalidLength
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return 0, pbstore.ErrInvalidLength
+			}
+			if postIndex > l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			deleteRange := &pbstore.DeleteRange{}
+			if err := deleteRange.UnmarshalVT(dAtA[iNdEx:postIndex]); err != nil {
+				return 0, err
+			}
+			m.DeleteRanges = append(m.DeleteRanges, deleteRange)
+			iNdEx = postIndex
+		case 4, 5:
+			if wireType != 2 {
+				return 0, fmt.Errorf("proto: wrong wireType = %d for field %d", wireType, fieldNum)
+			}
+			entry, n := protowire.ConsumeBytes(dAtA[iNdEx:])
+			if n < 0 {
+				return 0, protowire.ParseError(n)
+			}
+			mapkey, mapvalue, err := unmarshalStringUint64Entry(entry)
+			if err != nil {
+				return 0, err
+			}
+			if fieldNum == 4 {
+				if m.LastWrites == nil {
+					m.LastWrites = make(map[string]uint64)
+				}
+				m.LastWrites[mapkey] = mapvalue
+			} else {
+				if m.FirstWrites == nil {
+					m.FirstWrites = make(map[string]uint64)
+				}
+				m.FirstWrites[mapkey] = mapvalue
+			}
+			iNdEx += n
 		default:
 			iNdEx = preIndex
 			skippy, err := skip(dAtA[iNdEx:])
@@ -259,6 +326,33 @@ func unmarshalVT(m *pbstore.StoreData, dAtA []byte) (dataSize uint64, err error)
 	return
 }
 
+// unmarshalStringUint64Entry decodes an entry of a `map<string, uint64>` field.
+func unmarshalStringUint64Entry(entry []byte) (key string, value uint64, err error) {
+	for len(entry) > 0 {
+		num, typ, n := protowire.ConsumeTag(entry)
+		if n < 0 {
+			return "", 0, protowire.ParseError(n)
+		}
+		entry = entry[n:]
+
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(entry)
+			key = string(v)
+		case num == 2 && typ == protowire.VarintType:
+			value, n = protowire.ConsumeVarint(entry)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, entry)
+		}
+		if n < 0 {
+			return "", 0, protowire.ParseError(n)
+		}
+		entry = entry[n:]
+	}
+	return key, value, nil
+}
+
 func skip(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -19,18 +19,23 @@ func (b *baseStore) setKV(k string, v []byte) {
 		b.totalSizeBytes -= uint64(len(prev))
 	} else {
 		b.totalSizeBytes += uint64(len(k))
+		b.keyCount++
 	}
 	b.totalSizeBytes += uint64(len(v))
 	b.kv[k] = v
+	b.keyChanged(k)
 }
 
 func (b *baseStore) setNewKV(k string, v []byte) {
 	b.totalSizeBytes += uint64(len(k) + len(v))
+	b.keyCount++
 	b.kv[k] = v
+	b.keyChanged(k)
 }
 
 // Merge nextStore _into_ `s`, where nextStore is for the next contiguous segment's store output.
 func (b *baseStore) Merge(kvPartialStore *PartialKV) error {
+	b.materialize()
 	b.logger.Debug("merging store", zap.Int("current_key_count", len(b.kv)), zap.Uint64("mod_init_block", b.moduleInitialBlock), zap.Int("partial_key_count", len(kvPartialStore.kv)), zap.Uint64("partial_start_block", kvPartialStore.initialBlock))
 
 	if kvPartialStore.updatePolicy != b.updatePolicy {
@@ -41,6 +46,10 @@ func (b *baseStore) Merge(kvPartialStore *PartialKV) error {
 		return fmt.Errorf("incompatible value types: cannot merge %q and %q", b.valueType, kvPartialStore.valueType)
 	}
 
+	b.resetOrderedKeys()
+
+	// The prefixes of the partial stores written before the prefix deletes
+	// were recorded in DeletedRanges.
 	partialKvTime := time.Now()
 	for _, prefix := range kvPartialStore.DeletedPrefixes {
 		b.DeletePrefix(kvPartialStore.lastOrdinal, prefix)
@@ -49,6 +58,26 @@ func (b *baseStore) Merge(kvPartialStore *PartialKV) error {
 		b.logger.Info("merging: applied delete prefixes", zap.Duration("duration", time.Since(partialKvTime)))
 	}
 
+	// Ranges, prefix deletes included, are replayed in order. The pointers of the keys held by the
+	// partial store were resolved when it deleted the range (see
+	// PartialKV.DeleteRangePointers), the other pointers are resolved
+	// against the values known to the full store.
+	deleteRangesTime := time.Now()
+	for _, r := range kvPartialStore.DeletedRanges {
+		if r.PointerSeparator != "" {
+			b.DeleteRangePointers(kvPartialStore.lastOrdinal, r.LowKey, r.HighKey, r.PointerSeparator)
+		} else {
+			b.DeleteRange(kvPartialStore.lastOrdinal, r.LowKey, r.HighKey)
+		}
+	}
+	if len(kvPartialStore.DeletedRanges) > 0 {
+		b.logger.Info("merging: applied delete ranges", zap.Duration("duration", time.Since(deleteRangesTime)))
+	}
+
+	if b.ttlBlocks > 0 {
+		b.dropExpiredBeforeWrite(kvPartialStore)
+	}
+
 	intoValueTypeLower := strings.ToLower(b.valueType)
 
 	switch b.updatePolicy {
@@ -280,12 +309,24 @@ func (b *baseStore) Merge(kvPartialStore *PartialKV) error {
 		default:
 			return fmt.Errorf("update policy %q not supported for value type %q", b.updatePolicy, b.valueType)
 		}
+	case pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM:
+		sum, err := setSumAdderFor(b.valueType)
+		if err != nil {
+			return err
+		}
+		if err := b.mergeSetSum(kvPartialStore.kv, sum); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("update policy %q not supported", b.updatePolicy) // should have been validated already
 	}
 
+	if b.ttlBlocks > 0 {
+		b.mergeWrites(kvPartialStore)
+	}
+
 	b.Reset() // Merge should never keep deltas or ordinals
-	return nil
+	return b.CheckQuota()
 }
 
 func foundOrZeroInt64(in []byte, found bool) int64 {
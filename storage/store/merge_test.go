@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/storage/store/marshaller"
 )
 
 func TestStore_Merge(t *testing.T) {
@@ -334,6 +335,78 @@ func TestStore_Merge(t *testing.T) {
 				"t:1": []byte("bar"),
 			},
 		},
+		{
+			name: "delete key ranges",
+			latest: func() *PartialKV {
+				p := newPartialStore(map[string][]byte{
+					"t:1": []byte("bar"),
+				}, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, manifest.OutputValueTypeString, nil)
+				p.DeletedRanges = []marshaller.DeleteRange{
+					{LowKey: "p:", HighKey: "p;"},
+					{LowKey: "idx:", HighKey: "idx;", PointerSeparator: ";"},
+				}
+				return p
+			}(),
+			prev: newStore(map[string][]byte{
+				"t:1":   []byte("baz"),
+				"t:2":   []byte("baz"),
+				"t:3":   []byte("baz"),
+				"p:3":   []byte("lol"),
+				"idx:1": []byte("t:2"),
+			}, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, manifest.OutputValueTypeString),
+			expectedError: false,
+			expectedKV: map[string][]byte{
+				"t:1": []byte("bar"),
+				"t:3": []byte("baz"),
+			},
+		},
+		{
+			name: "set_sum int64",
+			latest: newPartialStore(map[string][]byte{
+				"set_over_set": []byte("set:5"),
+				"sum_over_set": []byte("sum:5"),
+				"set_over_sum": []byte("set:5"),
+				"sum_over_sum": []byte("sum:5"),
+				"new":          []byte("sum:5"),
+			}, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, manifest.OutputValueTypeInt64, nil),
+			prev: newStore(map[string][]byte{
+				"set_over_set": []byte("set:10"),
+				"sum_over_set": []byte("set:10"),
+				"set_over_sum": []byte("sum:10"),
+				"sum_over_sum": []byte("sum:10"),
+				"untouched":    []byte("sum:10"),
+			}, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, manifest.OutputValueTypeInt64),
+			expectedError: false,
+			expectedKV: map[string][]byte{
+				"set_over_set": []byte("set:5"),
+				"sum_over_set": []byte("set:15"),
+				"set_over_sum": []byte("set:5"),
+				"sum_over_sum": []byte("sum:15"),
+				"new":          []byte("sum:5"),
+				"untouched":    []byte("sum:10"),
+			},
+		},
+		{
+			name: "set_sum bigint",
+			latest: newPartialStore(map[string][]byte{
+				"one": []byte("sum:-100000000000000000000"),
+			}, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, manifest.OutputValueTypeBigInt, nil),
+			prev: newStore(map[string][]byte{
+				"one": []byte("set:300000000000000000000"),
+			}, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, manifest.OutputValueTypeBigInt),
+			expectedError: false,
+			expectedKV: map[string][]byte{
+				"one": []byte("set:200000000000000000000"),
+			},
+		},
+		{
+			name: "set_sum invalid value",
+			latest: newPartialStore(map[string][]byte{
+				"one": []byte("5"),
+			}, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, manifest.OutputValueTypeInt64, nil),
+			prev:          newStore(map[string][]byte{}, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, manifest.OutputValueTypeInt64),
+			expectedError: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -389,7 +462,7 @@ func newPartialStore(kv map[string][]byte, updatePolicy pbsubstreams.Module_Kind
 		},
 	}
 
-	return &PartialKV{baseStore: b, DeletedPrefixes: deletedPrefixes, seen: make(map[string]bool)}
+	return &PartialKV{baseStore: b, DeletedPrefixes: deletedPrefixes, seenRanges: make(map[marshaller.DeleteRange]bool)}
 }
 
 func newStore(kv map[string][]byte, updatePolicy pbsubstreams.Module_KindStore_UpdatePolicy, valueType string) *FullKV {
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"github.com/streamingfast/substreams/storage/store/marshaller"
 	"go.uber.org/zap"
@@ -13,16 +14,27 @@ var _ Store = (*PartialKV)(nil)
 type PartialKV struct {
 	*baseStore
 
-	initialBlock    uint64 // block at which we initialized this store
+	initialBlock uint64 // block at which we initialized this store
+	endBlock     uint64 // exclusive end block of the segment, once loaded
+	// DeletedPrefixes are only found in the partial stores written before
+	// the prefix deletes were recorded in DeletedRanges.
 	DeletedPrefixes []string
+	// DeletedRanges are the prefix and range deletes of the segment, in the
+	// order they happened, see recordDeletedRange.
+	DeletedRanges []marshaller.DeleteRange
 
 	loadedFrom string
-	seen       map[string]bool
+	seenRanges map[marshaller.DeleteRange]bool
 }
 
 func (p *PartialKV) Roll(lastBlock uint64) {
 	p.initialBlock = lastBlock
 	p.baseStore.kv = map[string][]byte{}
+	p.totalSizeBytes = 0
+	p.keyCount = 0
+	p.lastWrites = p.newWrites()
+	p.firstWrites = p.newWrites()
+	p.resetOrderedKeys()
 }
 
 func (p *PartialKV) InitialBlock() uint64 { return p.initialBlock }
@@ -36,17 +48,22 @@ func (p *PartialKV) Load(ctx context.Context, file *FileInfo) error {
 		return fmt.Errorf("load partial store %s at %s: %w", p.name, file.Filename, err)
 	}
 
-	storeData, size, err := p.marshaller.Unmarshal(data)
+	storeData, size, err := p.unmarshalSnapshot(data)
 	if err != nil {
-		return fmt.Errorf("unmarshal store: %w", err)
+		return fmt.Errorf("load partial store %s at %s: %w", p.name, file.Filename, err)
 	}
 
 	p.kv = storeData.Kv
+	p.resetOrderedKeys()
 	if p.kv == nil {
 		p.kv = map[string][]byte{}
 	}
 	p.totalSizeBytes = size
+	p.keyCount = uint64(len(p.kv))
 	p.DeletedPrefixes = storeData.DeletePrefixes
+	p.DeletedRanges = storeData.DeleteRanges
+	p.endBlock = file.Range.ExclusiveEndBlock
+	p.loadWrites(storeData)
 
 	p.logger.Debug("partial store loaded", zap.String("filename", file.Filename), zap.Int("key_count", len(p.kv)), zap.Uint64("data_size", size))
 	return nil
@@ -58,9 +75,12 @@ func (p *PartialKV) Save(endBoundaryBlock uint64) (*FileInfo, *fileWriter, error
 	stateData := &marshaller.StoreData{
 		Kv:             p.kv,
 		DeletePrefixes: p.DeletedPrefixes,
+		DeleteRanges:   p.DeletedRanges,
+		LastWrites:     p.lastWrites,
+		FirstWrites:    p.firstWrites,
 	}
 
-	content, err := p.marshaller.Marshal(stateData)
+	content, err := p.marshalSnapshot(stateData)
 	if err != nil {
 		return nil, nil, fmt.Errorf("marshal partial data: %w", err)
 	}
@@ -77,12 +97,64 @@ func (p *PartialKV) Save(endBoundaryBlock uint64) (*FileInfo, *fileWriter, error
 	return file, fw, nil
 }
 
+// DeletePrefix records the deletion as the range of the keys starting with
+// `prefix`, for the merge to replay it in order with the range deletes.
 func (p *PartialKV) DeletePrefix(ord uint64, prefix string) {
 	p.baseStore.DeletePrefix(ord, prefix)
+	p.recordDeletedRange(marshaller.DeleteRange{LowKey: prefix, HighKey: prefixEnd(prefix)})
+}
+
+func (p *PartialKV) DeleteRange(ord uint64, lowKey, highKey string) {
+	p.baseStore.DeleteRange(ord, lowKey, highKey)
+	p.recordDeletedRange(marshaller.DeleteRange{LowKey: lowKey, HighKey: highKey})
+}
+
+// DeleteRangePointers records the deletion so that the merge resolves the
+// pointers as a linear run would: the values of the keys held by this partial
+// are the current ones, so their pointers are resolved now and replayed as
+// single key deletions, while the pointers of the other keys in range are
+// resolved against the full store, on the sub-ranges between the held keys.
+func (p *PartialKV) DeleteRangePointers(ord uint64, lowKey, highKey, pointerSeparator string) {
+	var held []string
+	for key := range p.kv {
+		if key >= lowKey && (highKey == "" || key < highKey) {
+			held = append(held, key)
+		}
+	}
+	sort.Strings(held)
+	resolved := p.keysInRange(lowKey, highKey, pointerSeparator)
+	sort.Strings(resolved)
+
+	p.baseStore.DeleteRangePointers(ord, lowKey, highKey, pointerSeparator)
+
+	low := lowKey
+	for _, key := range held {
+		if low < key {
+			p.recordDeletedRange(marshaller.DeleteRange{LowKey: low, HighKey: key, PointerSeparator: pointerSeparator})
+		}
+		low = singleKeyRangeEnd(key)
+	}
+	if highKey == "" || low < highKey {
+		p.recordDeletedRange(marshaller.DeleteRange{LowKey: low, HighKey: highKey, PointerSeparator: pointerSeparator})
+	}
+	for _, key := range resolved {
+		p.recordDeletedRange(marshaller.DeleteRange{LowKey: key, HighKey: singleKeyRangeEnd(key)})
+	}
+}
+
+// singleKeyRangeEnd is the exclusive end of the range holding only `key`.
+func singleKeyRangeEnd(key string) string {
+	return key + "\x00"
+}
 
-	if !p.seen[prefix] {
-		p.DeletedPrefixes = append(p.DeletedPrefixes, prefix)
-		p.seen[prefix] = true
+// recordDeletedRange keeps track of the range so it can be applied again when
+// merging into the full store, where the keys (and pointers) previous to this
+// partial's segment live. The deletes only remove keys, so a range already
+// recorded is skipped: replaying it again later would find nothing left.
+func (p *PartialKV) recordDeletedRange(deleteRange marshaller.DeleteRange) {
+	if !p.seenRanges[deleteRange] {
+		p.DeletedRanges = append(p.DeletedRanges, deleteRange)
+		p.seenRanges[deleteRange] = true
 	}
 }
 
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"fmt"
+)
+
+// QuotaExceededError is returned when a store exceeds its size or key count
+// quota, see Config.SetQuota. The quotas are checked on the store of a module
+// after each block it processes, partial stores included, and on the full
+// stores when merging the partial stores of a segment, see CheckQuota. It is
+// deterministic: the same writes on the same store always exceed the same
+// quota. A partial store only holds the keys of its segment, so a store
+// computed in parallel can exceed its quota at the merge of the segment
+// rather than at the block which exceeded it linearly. The replays of cached
+// deltas are never checked.
+type QuotaExceededError struct {
+	Store     string
+	SizeBytes uint64
+	KeyCount  uint64
+
+	MaxSizeBytes uint64 // MaxSizeBytes is set when the size quota is exceeded.
+	MaxKeys      uint64 // MaxKeys is set when the key count quota is exceeded.
+}
+
+func (e *QuotaExceededError) Error() string {
+	if e.MaxKeys != 0 {
+		return fmt.Sprintf("store %q exceeded its quota of %d keys, at %d keys (%d bytes)", e.Store, e.MaxKeys, e.KeyCount, e.SizeBytes)
+	}
+	return fmt.Sprintf("store %q exceeded its quota of %d bytes, at %d bytes (%d keys)", e.Store, e.MaxSizeBytes, e.SizeBytes, e.KeyCount)
+}
+
+// SetQuota lowers the maximum size in bytes (keys and values) and the
+// maximum number of keys of the stores, zero leaving a limit unchanged. A
+// quota is never raised: the stores get the lowest of the quotas set, the
+// server-wide ones and the ones of their module.
+func (c *Config) SetQuota(maxSizeBytes, maxKeys uint64) {
+	if maxSizeBytes != 0 && (c.totalSizeLimit == 0 || maxSizeBytes < c.totalSizeLimit) {
+		c.totalSizeLimit = maxSizeBytes
+	}
+	if maxKeys != 0 && (c.maxKeys == 0 || maxKeys < c.maxKeys) {
+		c.maxKeys = maxKeys
+	}
+}
+
+func (c *Config) MaxSizeBytes() uint64 {
+	return c.totalSizeLimit
+}
+
+func (c *Config) MaxKeys() uint64 {
+	return c.maxKeys
+}
+
+// CheckQuota returns a *QuotaExceededError when the store exceeds its
+// quota, to check after each block processed by its module.
+func (b *baseStore) CheckQuota() error {
+	switch {
+	case b.totalSizeLimit != 0 && b.totalSizeBytes > b.totalSizeLimit:
+		return &QuotaExceededError{Store: b.name, SizeBytes: b.totalSizeBytes, KeyCount: b.keyCount, MaxSizeBytes: b.totalSizeLimit}
+	case b.maxKeys != 0 && b.keyCount > b.maxKeys:
+		return &QuotaExceededError{Store: b.name, SizeBytes: b.totalSizeBytes, KeyCount: b.keyCount, MaxKeys: b.maxKeys}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+func newTestQuotaConfig(t *testing.T, maxSizeBytes, maxKeys uint64) *Config {
+	config, err := NewConfig("test", 0, "test.module.hash", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", dstore.NewMockStore(nil), "")
+	require.NoError(t, err)
+	config.SetQuota(maxSizeBytes, maxKeys)
+	return config
+}
+
+func TestConfig_SetQuota(t *testing.T) {
+	config := newTestQuotaConfig(t, 0, 0)
+	assert.Equal(t, uint64(1_073_741_824), config.MaxSizeBytes())
+	assert.Equal(t, uint64(0), config.MaxKeys())
+
+	config.SetQuota(1000, 10)
+	config.SetQuota(2000, 0)
+	config.SetQuota(0, 20)
+	assert.Equal(t, uint64(1000), config.MaxSizeBytes())
+	assert.Equal(t, uint64(10), config.MaxKeys())
+
+	config.SetQuota(500, 5)
+	assert.Equal(t, uint64(500), config.MaxSizeBytes())
+	assert.Equal(t, uint64(5), config.MaxKeys())
+}
+
+func TestStoreQuota_Keys(t *testing.T) {
+	s := newTestQuotaConfig(t, 0, 3).NewFullKV(zap.NewNop())
+
+	for i := 0; i < 3; i++ {
+		s.Set(uint64(i), fmt.Sprintf("key:%d", i), "value")
+	}
+	s.Set(3, "key:0", "updated")
+	s.DeletePrefix(4, "key:2")
+	s.Set(5, "key:3", "value")
+	require.NoError(t, s.CheckQuota())
+
+	// The writes never fail, the quota is checked after the block.
+	s.Set(6, "key:4", "value")
+	var err *QuotaExceededError
+	require.ErrorAs(t, s.CheckQuota(), &err)
+	assert.Equal(t, &QuotaExceededError{Store: "test", SizeBytes: 42, KeyCount: 4, MaxKeys: 3}, err)
+	assert.Equal(t, `store "test" exceeded its quota of 3 keys, at 4 keys (42 bytes)`, err.Error())
+}
+
+func TestStoreQuota_Size(t *testing.T) {
+	s := newTestQuotaConfig(t, 20, 0).NewFullKV(zap.NewNop())
+
+	s.Set(0, "key:0", "value")
+	s.Set(1, "key:1", "value")
+	require.NoError(t, s.CheckQuota())
+
+	s.Set(2, "key:1", "longer value")
+	assert.EqualError(t, s.CheckQuota(), `store "test" exceeded its quota of 20 bytes, at 27 bytes (2 keys)`)
+}
+
+func TestStoreQuota_Merge(t *testing.T) {
+	config := newTestQuotaConfig(t, 0, 3)
+
+	s := config.NewFullKV(zap.NewNop())
+	s.Set(0, "key:0", "value")
+	s.Set(1, "key:1", "value")
+
+	partial := config.NewPartialKV(10, zap.NewNop())
+	partial.Set(0, "key:1", "updated")
+	require.NoError(t, s.Merge(partial))
+
+	partial.Roll(20)
+	partial.Set(0, "key:2", "value")
+	partial.Set(1, "key:3", "value")
+	assert.Equal(t, uint64(2), partial.keyCount)
+
+	var quotaErr *QuotaExceededError
+	require.ErrorAs(t, s.Merge(partial), &quotaErr)
+	assert.Equal(t, uint64(4), quotaErr.KeyCount)
+}
+
+// The quotas are checked after the writes of each block (here one per
+// segment), not within it, on the full store linearly, and on the partial
+// store then at the merge in parallel: both fail at the same segment with the
+// same error when only the full store exceeds the quota.
+func TestStoreQuota_LinearMatchesParallel(t *testing.T) {
+	type writer interface {
+		Set(ord uint64, key string, value string)
+		DeletePrefix(ord uint64, prefix string)
+	}
+	segments := []func(s writer){
+		func(s writer) {
+			s.Set(0, "key:0", "value")
+			s.Set(1, "key:1", "value")
+		},
+		func(s writer) {
+			s.Set(0, "key:2", "value")
+			s.Set(1, "key:3", "value")
+			s.DeletePrefix(2, "key:0")
+		},
+		func(s writer) {
+			s.Set(0, "key:4", "value")
+		},
+	}
+	config := newTestQuotaConfig(t, 0, 3)
+
+	checkLinear := func() (int, error) {
+		s := config.NewFullKV(zap.NewNop())
+		for i, segment := range segments {
+			segment(s)
+			s.Reset()
+			if err := s.CheckQuota(); err != nil {
+				return i, err
+			}
+		}
+		return -1, nil
+	}
+	checkParallel := func() (int, error) {
+		s := config.NewFullKV(zap.NewNop())
+		partial := config.NewPartialKV(0, zap.NewNop())
+		for i, segment := range segments {
+			segment(partial)
+			partial.Reset()
+			if err := partial.CheckQuota(); err != nil {
+				return i, err
+			}
+			if err := s.Merge(partial); err != nil {
+				return i, err
+			}
+			partial.Roll(uint64(i+1) * 10)
+		}
+		return -1, nil
+	}
+
+	linearSegment, linearErr := checkLinear()
+	parallelSegment, parallelErr := checkParallel()
+	assert.Equal(t, 2, linearSegment)
+	assert.Equal(t, linearSegment, parallelSegment)
+	assert.Equal(t, &QuotaExceededError{Store: "test", SizeBytes: 40, KeyCount: 4, MaxKeys: 3}, linearErr)
+	assert.Equal(t, linearErr, parallelErr)
+}
+
+func TestStoreQuota_ReplayNotChecked(t *testing.T) {
+	s := newTestQuotaConfig(t, 0, 1).NewFullKV(zap.NewNop())
+
+	assert.NotPanics(t, func() {
+		s.SetDeltas([]*pbssinternal.StoreDelta{
+			{Operation: pbssinternal.StoreDelta_CREATE, Key: "key:0", NewValue: []byte("value")},
+			{Operation: pbssinternal.StoreDelta_CREATE, Key: "key:1", NewValue: []byte("value")},
+		})
+	})
+	assert.Equal(t, uint64(2), s.keyCount)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 
+	"github.com/streamingfast/substreams/reqctx"
 	"github.com/streamingfast/substreams/storage/store"
 )
 
@@ -15,6 +16,10 @@ func listSnapshots(ctx context.Context, storeConfig *store.Config, below uint64)
 	if err != nil {
 		return nil, fmt.Errorf("list snapshots: %w", err)
 	}
+	files, err = storeConfig.LoadableSnapshots(ctx, files, reqctx.Logger(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("checking snapshot chains: %w", err)
+	}
 
 	for _, file := range files {
 		if file.Partial {
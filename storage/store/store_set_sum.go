@@ -0,0 +1,170 @@
+package store
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/streamingfast/substreams/manifest"
+)
+
+// The values of the `set_sum` stores are prefixed by the operation which
+// produced them: `set:` values are absolute, they replace the previous value
+// of the key, `sum:` values are deltas, added to the previous value of the
+// key. A key summed after being set keeps its `set:` prefix, so a partial
+// store tells, for each key, whether its value replaces or adds to the value
+// of the previous segment when merged.
+const (
+	SetSumSetPrefix = "set:"
+	SetSumSumPrefix = "sum:"
+)
+
+func (b *baseStore) SetSumInt64(ord uint64, key string, value string) error {
+	return b.setSum(ord, key, value, sumInt64)
+}
+
+func (b *baseStore) SetSumFloat64(ord uint64, key string, value string) error {
+	return b.setSum(ord, key, value, sumFloat64)
+}
+
+func (b *baseStore) SetSumBigInt(ord uint64, key string, value string) error {
+	return b.setSum(ord, key, value, sumBigInt)
+}
+
+func (b *baseStore) SetSumBigDecimal(ord uint64, key string, value string) error {
+	return b.setSum(ord, key, value, sumBigDecimal)
+}
+
+func (b *baseStore) setSum(ord uint64, key string, value string, sum setSumAdder) error {
+	prefix, number, err := parseSetSumValue(value)
+	if err != nil {
+		return err
+	}
+	// Normalizes and validates the number
+	number, err = sum("0", number)
+	if err != nil {
+		return fmt.Errorf("invalid value %q: %w", value, err)
+	}
+
+	if prefix == SetSumSumPrefix {
+		if prev, found := b.GetAt(ord, key); found {
+			prevPrefix, prevNumber, err := parseSetSumValue(string(prev))
+			if err != nil {
+				return fmt.Errorf("invalid previous value of key %q: %w", key, err)
+			}
+			if number, err = sum(prevNumber, number); err != nil {
+				return fmt.Errorf("invalid previous value of key %q: %w", key, err)
+			}
+			prefix = prevPrefix
+		}
+	}
+
+	b.set(ord, key, []byte(prefix+number))
+	return nil
+}
+
+// mergeSetSum merges the `set_sum` values of a partial store, the `set:`
+// values replacing the values of the store and the `sum:` values being added
+// to them.
+func (b *baseStore) mergeSetSum(kv map[string][]byte, sum setSumAdder) error {
+	for k, v := range kv {
+		prefix, number, err := parseSetSumValue(string(v))
+		if err != nil {
+			return fmt.Errorf("invalid value of key %q: %w", k, err)
+		}
+
+		prev, found := b.kv[k]
+		if prefix == SetSumSetPrefix || !found {
+			b.setKV(k, v)
+			continue
+		}
+
+		prevPrefix, prevNumber, err := parseSetSumValue(string(prev))
+		if err != nil {
+			return fmt.Errorf("invalid value of key %q: %w", k, err)
+		}
+		total, err := sum(prevNumber, number)
+		if err != nil {
+			return fmt.Errorf("summing values of key %q: %w", k, err)
+		}
+		b.setKV(k, []byte(prevPrefix+total))
+	}
+	return nil
+}
+
+func parseSetSumValue(value string) (prefix string, number string, err error) {
+	switch {
+	case strings.HasPrefix(value, SetSumSetPrefix):
+		return SetSumSetPrefix, value[len(SetSumSetPrefix):], nil
+	case strings.HasPrefix(value, SetSumSumPrefix):
+		return SetSumSumPrefix, value[len(SetSumSumPrefix):], nil
+	}
+	return "", "", fmt.Errorf("value %q must be prefixed by %q or %q", value, SetSumSetPrefix, SetSumSumPrefix)
+}
+
+type setSumAdder func(a, b string) (string, error)
+
+func setSumAdderFor(valueType string) (setSumAdder, error) {
+	switch strings.ToLower(valueType) {
+	case manifest.OutputValueTypeInt64:
+		return sumInt64, nil
+	case manifest.OutputValueTypeFloat64:
+		return sumFloat64, nil
+	case manifest.OutputValueTypeBigInt:
+		return sumBigInt, nil
+	case manifest.OutputValueTypeBigFloat, manifest.OutputValueTypeBigDecimal:
+		return sumBigDecimal, nil
+	}
+	return nil, fmt.Errorf("update policy %q not supported for value type %q", manifest.UpdatePolicySetSum, valueType)
+}
+
+func sumInt64(a, b string) (string, error) {
+	v0, err := strconv.ParseInt(a, 10, 64)
+	if err != nil {
+		return "", err
+	}
+	v1, err := strconv.ParseInt(b, 10, 64)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(v0+v1, 10), nil
+}
+
+func sumFloat64(a, b string) (string, error) {
+	v0, err := strconv.ParseFloat(a, 64)
+	if err != nil {
+		return "", err
+	}
+	v1, err := strconv.ParseFloat(b, 64)
+	if err != nil {
+		return "", err
+	}
+	return floatToStr(v0 + v1), nil
+}
+
+func sumBigInt(a, b string) (string, error) {
+	v0, ok := new(big.Int).SetString(a, 10)
+	if !ok {
+		return "", fmt.Errorf("invalid bigint %q", a)
+	}
+	v1, ok := new(big.Int).SetString(b, 10)
+	if !ok {
+		return "", fmt.Errorf("invalid bigint %q", b)
+	}
+	return v0.Add(v0, v1).String(), nil
+}
+
+func sumBigDecimal(a, b string) (string, error) {
+	v0, err := decimal.NewFromString(a)
+	if err != nil {
+		return "", err
+	}
+	v1, err := decimal.NewFromString(b)
+	if err != nil {
+		return "", err
+	}
+	return v0.Truncate(34).Add(v1.Truncate(34)).String(), nil
+}
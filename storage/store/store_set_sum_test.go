@@ -0,0 +1,152 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/streamingfast/substreams/manifest"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+func TestStoreSetSum(t *testing.T) {
+	type op struct {
+		key   string
+		value string
+	}
+	tests := []struct {
+		name          string
+		valueType     string
+		ops           []op
+		expectedValue string
+		expectedError bool
+	}{
+		{
+			name:          "sum not found",
+			valueType:     manifest.OutputValueTypeInt64,
+			ops:           []op{{"key", "sum:4"}},
+			expectedValue: "sum:4",
+		},
+		{
+			name:          "sum over sum",
+			valueType:     manifest.OutputValueTypeInt64,
+			ops:           []op{{"key", "sum:4"}, {"key", "sum:-1"}},
+			expectedValue: "sum:3",
+		},
+		{
+			name:          "sum over set",
+			valueType:     manifest.OutputValueTypeInt64,
+			ops:           []op{{"key", "set:10"}, {"key", "sum:4"}},
+			expectedValue: "set:14",
+		},
+		{
+			name:          "set over sum",
+			valueType:     manifest.OutputValueTypeInt64,
+			ops:           []op{{"key", "sum:4"}, {"key", "set:10"}},
+			expectedValue: "set:10",
+		},
+		{
+			name:          "float64",
+			valueType:     manifest.OutputValueTypeFloat64,
+			ops:           []op{{"key", "set:1.5"}, {"key", "sum:2.25"}},
+			expectedValue: "set:3.75",
+		},
+		{
+			name:          "bigint",
+			valueType:     manifest.OutputValueTypeBigInt,
+			ops:           []op{{"key", "sum:100000000000000000000"}, {"key", "sum:1"}},
+			expectedValue: "sum:100000000000000000001",
+		},
+		{
+			name:          "bigdecimal",
+			valueType:     manifest.OutputValueTypeBigDecimal,
+			ops:           []op{{"key", "set:0.1"}, {"key", "sum:0.2"}},
+			expectedValue: "set:0.3",
+		},
+		{
+			name:          "normalized",
+			valueType:     manifest.OutputValueTypeInt64,
+			ops:           []op{{"key", "set:007"}},
+			expectedValue: "set:7",
+		},
+		{
+			name:          "missing prefix",
+			valueType:     manifest.OutputValueTypeInt64,
+			ops:           []op{{"key", "4"}},
+			expectedError: true,
+		},
+		{
+			name:          "invalid number",
+			valueType:     manifest.OutputValueTypeInt64,
+			ops:           []op{{"key", "sum:4.5"}},
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newTestBaseStore(t, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, test.valueType, nil)
+
+			var err error
+			for i, op := range test.ops {
+				switch test.valueType {
+				case manifest.OutputValueTypeInt64:
+					err = b.SetSumInt64(uint64(i), op.key, op.value)
+				case manifest.OutputValueTypeFloat64:
+					err = b.SetSumFloat64(uint64(i), op.key, op.value)
+				case manifest.OutputValueTypeBigInt:
+					err = b.SetSumBigInt(uint64(i), op.key, op.value)
+				case manifest.OutputValueTypeBigDecimal:
+					err = b.SetSumBigDecimal(uint64(i), op.key, op.value)
+				}
+				if err != nil {
+					break
+				}
+			}
+			if test.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			actual, found := b.GetLast("key")
+			require.True(t, found)
+			assert.Equal(t, test.expectedValue, string(actual))
+		})
+	}
+}
+
+// Merging the partial stores of contiguous segments gives the same values as
+// applying all the operations to a single store.
+func TestStoreSetSum_MergeMatchesLinear(t *testing.T) {
+	segments := [][]struct {
+		key   string
+		value string
+	}{
+		{{"a", "set:10"}, {"b", "sum:1"}, {"c", "sum:1"}},
+		{{"a", "sum:5"}, {"b", "sum:2"}, {"c", "set:100"}, {"c", "sum:3"}},
+		{{"a", "sum:-1"}, {"b", "set:0"}, {"b", "sum:7"}, {"d", "sum:9"}},
+	}
+
+	linear := newTestBaseStore(t, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, manifest.OutputValueTypeInt64, nil)
+	merged := newStore(map[string][]byte{}, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, manifest.OutputValueTypeInt64)
+	for _, ops := range segments {
+		partial := newTestBaseStore(t, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, manifest.OutputValueTypeInt64, nil)
+		for i, op := range ops {
+			require.NoError(t, linear.SetSumInt64(uint64(i), op.key, op.value))
+			require.NoError(t, partial.SetSumInt64(uint64(i), op.key, op.value))
+		}
+		linear.Reset()
+		partial.Reset()
+		require.NoError(t, merged.Merge(newPartialStore(partial.kv, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, manifest.OutputValueTypeInt64, nil)))
+	}
+
+	assert.Equal(t, map[string][]byte{
+		"a": []byte("set:14"),
+		"b": []byte("set:7"),
+		"c": []byte("set:103"),
+		"d": []byte("sum:9"),
+	}, linear.kv)
+	assert.Equal(t, linear.kv, merged.kv)
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"math"
+	"sort"
+
+	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	"github.com/streamingfast/substreams/storage/store/marshaller"
+)
+
+// The keys of the stores with a TTL (see Config.SetTTL) expire when they are
+// not written for `ttlBlocks` blocks. They are evicted at the first bundle
+// boundary past their expiration, after the writes of that block.
+//
+// The evictions are recorded as DELETE deltas of the boundary block, after
+// its writes. Only the deltas of full stores are output: a partial store
+// cannot see the keys of the full store it is merged into, so it records the
+// evictions of the keys of its segment only, and the evictions done while
+// merging it are not recorded, the merged store being loaded at the end of
+// the segment. Replaying the deltas (see SetDeltas) must still be followed by
+// EvictExpiredKeys after each block, to keep the last boundary reached.
+//
+// Any write counts, even one leaving the value unchanged (like a
+// `set_if_not_exists` of an existing key), so the expiration of a key only
+// depends on the calls made by the module. A partial store thus knows when
+// the keys it wrote expire, and keeps the block of the first write of each
+// key in its segment so the store it is merged into knows which of its keys
+// expired before being written again.
+
+// droppedInSegment is the first write of the keys deleted in the segment of
+// a partial store: whatever their expiration, their previous value is gone.
+const droppedInSegment = math.MaxUint64
+
+// SetBlock sets the block of the writes to come.
+func (b *baseStore) SetBlock(blockNum uint64) {
+	b.block = blockNum
+}
+
+// EvictExpiredKeys evicts the keys expired at the last bundle boundary
+// reached, to be called after the writes (or deltas) of each block. The
+// evictions are recorded in the deltas, and it returns their number.
+func (b *baseStore) EvictExpiredKeys() int {
+	deltas := b.evictExpiredKeys()
+	b.deltas = append(b.deltas, deltas...)
+	return len(deltas)
+}
+
+// evictExpiredKeys evicts the keys expired at the last bundle boundary
+// reached, and returns the deltas of the evictions without recording them.
+func (b *baseStore) evictExpiredKeys() []*pbssinternal.StoreDelta {
+	if b.ttlBlocks == 0 {
+		return nil
+	}
+
+	boundary := b.block - b.block%b.bundleSize
+	if boundary <= b.evictedAt {
+		return nil
+	}
+	b.evictedAt = boundary
+
+	var keys []string
+	for key, lastWrite := range b.lastWrites {
+		if b.expirationBoundary(lastWrite) <= boundary {
+			keys = append(keys, key)
+		}
+	}
+	return b.evictKeys(keys)
+}
+
+// evictKeys deletes the existing keys among `keys`, sorted to keep the
+// deltas deterministic, and returns the deltas without recording them.
+func (b *baseStore) evictKeys(keys []string) []*pbssinternal.StoreDelta {
+	sort.Strings(keys)
+
+	var deltas []*pbssinternal.StoreDelta
+	for _, key := range keys {
+		val, found := b.get(key)
+		if !found {
+			continue
+		}
+		delta := &pbssinternal.StoreDelta{
+			Operation: pbssinternal.StoreDelta_DELETE,
+			Ordinal:   b.lastOrdinal,
+			Key:       key,
+			OldValue:  val,
+		}
+		b.ApplyDelta(delta)
+		b.forget(key)
+		deltas = append(deltas, delta)
+	}
+	return deltas
+}
+
+// expirationBoundary is the bundle boundary at which a key last written at
+// `lastWrite` is evicted.
+func (b *baseStore) expirationBoundary(lastWrite uint64) uint64 {
+	expiration := lastWrite + b.ttlBlocks
+	if remainder := expiration % b.bundleSize; remainder != 0 {
+		expiration += b.bundleSize - remainder
+	}
+	return expiration
+}
+
+func (b *baseStore) touch(key string) {
+	if b.ttlBlocks == 0 {
+		return
+	}
+	b.lastWrites[key] = b.block
+	if b.firstWrites != nil {
+		if _, found := b.firstWrites[key]; !found {
+			b.firstWrites[key] = b.block
+		}
+	}
+}
+
+func (b *baseStore) forget(key string) {
+	if b.ttlBlocks == 0 {
+		return
+	}
+	delete(b.lastWrites, key)
+	if b.firstWrites != nil {
+		b.firstWrites[key] = droppedInSegment
+	}
+}
+
+func (b *baseStore) loadWrites(storeData *marshaller.StoreData) {
+	if b.ttlBlocks == 0 {
+		return
+	}
+	b.lastWrites = storeData.LastWrites
+	if b.lastWrites == nil {
+		b.lastWrites = make(map[string]uint64)
+	}
+	if b.firstWrites != nil {
+		b.firstWrites = storeData.FirstWrites
+		if b.firstWrites == nil {
+			b.firstWrites = make(map[string]uint64)
+		}
+	}
+}
+
+// dropExpiredBeforeWrite deletes the keys which expired before the partial
+// store wrote them again, the values of the partial store replacing them.
+func (b *baseStore) dropExpiredBeforeWrite(partial *PartialKV) {
+	var keys []string
+	for key, firstWrite := range partial.firstWrites {
+		if lastWrite, found := b.lastWrites[key]; found && firstWrite > b.expirationBoundary(lastWrite) {
+			keys = append(keys, key)
+		}
+	}
+	b.evictKeys(keys)
+}
+
+// mergeWrites takes the writes of the partial store, then evicts the keys
+// expired at the end of its segment.
+func (b *baseStore) mergeWrites(partial *PartialKV) {
+	for key, lastWrite := range partial.lastWrites {
+		b.lastWrites[key] = lastWrite
+	}
+	if partial.endBlock > 0 {
+		b.SetBlock(partial.endBlock - 1)
+		b.evictExpiredKeys()
+	}
+}
@@ -0,0 +1,221 @@
+package store
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/manifest"
+	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/storage/store/marshaller"
+)
+
+func newTestTTLConfig(t *testing.T, updatePolicy pbsubstreams.Module_KindStore_UpdatePolicy, valueType string, ttlBlocks, bundleSize uint64) *Config {
+	config, err := NewConfig("test", 0, "test.module.hash", updatePolicy, valueType, dstore.NewMockStore(nil), "")
+	require.NoError(t, err)
+	require.NoError(t, config.SetTTL(ttlBlocks, bundleSize))
+	return config
+}
+
+func TestStoreTTL_EvictExpiredKeys(t *testing.T) {
+	config := newTestTTLConfig(t, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, manifest.OutputValueTypeString, 15, 10)
+	s := config.NewFullKV(zap.NewNop())
+
+	writes := map[uint64][]string{
+		2:  {"a", "b"},
+		12: {"c"},
+		18: {"b"},
+	}
+	evictions := map[uint64][]string{}
+	for blockNum := uint64(0); blockNum < 40; blockNum++ {
+		s.SetBlock(blockNum)
+		for _, key := range writes[blockNum] {
+			s.Set(1, key, "value")
+		}
+		var existing []string
+		for key := range s.kv {
+			existing = append(existing, key)
+		}
+		sort.Strings(existing)
+		assert.Equal(t, len(existing), int(s.Length()))
+
+		evicted := s.EvictExpiredKeys()
+		for _, key := range existing {
+			if _, found := s.GetLast(key); !found {
+				evictions[blockNum] = append(evictions[blockNum], key)
+			}
+		}
+		assert.Len(t, evictions[blockNum], evicted)
+		var deleted []string
+		for _, delta := range s.GetDeltas() {
+			if delta.Operation == pbssinternal.StoreDelta_DELETE {
+				deleted = append(deleted, delta.Key)
+			}
+		}
+		assert.Equal(t, evictions[blockNum], deleted, "evictions are recorded in the deltas")
+		s.Reset()
+	}
+
+	assert.Equal(t, map[uint64][]string{
+		20: {"a"},
+		30: {"c"},
+	}, evictions)
+	_, found := s.GetLast("b")
+	assert.True(t, found, "b was written at 18, it expires at 40")
+	assert.Equal(t, uint64(len("b")+len("value")), s.SizeBytes())
+}
+
+// The stores merged from partial stores evict the same keys, at the same
+// blocks, as a store built linearly.
+func TestStoreTTL_MergeMatchesLinear(t *testing.T) {
+	writes := map[uint64][]string{
+		1:  {"a", "b"},
+		5:  {"c"},
+		15: {"a"},
+		19: {"d"},
+		20: {"b"}, // written at its expiration boundary, kept
+		25: {"c"}, // expired at 20
+		31: {"j"},
+		38: {"k"},
+		40: {"j"}, // kept, but expires at 50 within the segment
+		42: {"k"},
+		45: {"a"},
+		55: {"j"},
+	}
+
+	tests := []struct {
+		name         string
+		updatePolicy pbsubstreams.Module_KindStore_UpdatePolicy
+		valueType    string
+		write        func(s *baseStore, blockNum uint64, key string)
+		expectedKV   map[string][]byte
+	}{
+		{
+			name:         "add",
+			updatePolicy: pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD,
+			valueType:    manifest.OutputValueTypeInt64,
+			write: func(s *baseStore, blockNum uint64, key string) {
+				s.SumInt64(1, key, 1)
+			},
+			expectedKV: map[string][]byte{"a": []byte("1"), "j": []byte("1"), "k": []byte("2")},
+		},
+		{
+			name:         "set_if_not_exists",
+			updatePolicy: pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_IF_NOT_EXISTS,
+			valueType:    manifest.OutputValueTypeString,
+			write: func(s *baseStore, blockNum uint64, key string) {
+				s.SetIfNotExists(1, key, strconv.FormatUint(blockNum, 10))
+			},
+			expectedKV: map[string][]byte{"a": []byte("45"), "j": []byte("55"), "k": []byte("38")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := newTestTTLConfig(t, test.updatePolicy, test.valueType, 10, 10)
+			process := func(s *baseStore, blockNum uint64) {
+				s.SetBlock(blockNum)
+				for _, key := range writes[blockNum] {
+					test.write(s, blockNum, key)
+				}
+				s.EvictExpiredKeys()
+				s.Reset()
+			}
+
+			linear := config.NewFullKV(zap.NewNop())
+			for blockNum := uint64(0); blockNum < 60; blockNum++ {
+				process(linear.baseStore, blockNum)
+			}
+
+			merged := config.NewFullKV(zap.NewNop())
+			for start := uint64(0); start < 60; start += 20 {
+				partial := config.NewPartialKV(start, zap.NewNop())
+				partial.endBlock = start + 20
+				for blockNum := start; blockNum < start+20; blockNum++ {
+					process(partial.baseStore, blockNum)
+				}
+				require.NoError(t, merged.Merge(partial))
+			}
+
+			assert.Equal(t, test.expectedKV, linear.kv)
+			assert.Equal(t, linear.kv, merged.kv)
+			assert.Equal(t, linear.lastWrites, merged.lastWrites)
+			assert.Equal(t, linear.totalSizeBytes, merged.totalSizeBytes)
+		})
+	}
+}
+
+// The deltas of a full store, evictions included, do not depend on whether
+// it is built linearly or loaded from the merge of the partial stores of the
+// previous segments, and replaying them rebuilds the same store.
+func TestStoreTTL_DeltasMatchLinear(t *testing.T) {
+	writes := map[uint64][]string{
+		1:  {"a", "b"},
+		5:  {"a"},
+		15: {"c"},
+		25: {"a"}, // expired at 20, created again
+		31: {"d"},
+		45: {"c"}, // expired at 30, created again
+		52: {"e"},
+	}
+	config := newTestTTLConfig(t, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, manifest.OutputValueTypeString, 10, 10)
+	process := func(s *baseStore, blockNum uint64) []*pbssinternal.StoreDelta {
+		s.SetBlock(blockNum)
+		for _, key := range writes[blockNum] {
+			s.Set(1, key, strconv.FormatUint(blockNum, 10))
+		}
+		s.EvictExpiredKeys()
+		deltas := s.GetDeltas()
+		s.Reset()
+		return deltas
+	}
+
+	linear := config.NewFullKV(zap.NewNop())
+	linearDeltas := map[uint64][]*pbssinternal.StoreDelta{}
+	for blockNum := uint64(0); blockNum < 60; blockNum++ {
+		linearDeltas[blockNum] = process(linear.baseStore, blockNum)
+	}
+	assert.Equal(t, []*pbssinternal.StoreDelta{
+		{Operation: pbssinternal.StoreDelta_DELETE, Key: "a", OldValue: []byte("5")},
+		{Operation: pbssinternal.StoreDelta_DELETE, Key: "b", OldValue: []byte("1")},
+	}, linearDeltas[20])
+
+	parallelDeltas := map[uint64][]*pbssinternal.StoreDelta{}
+	merged := config.NewFullKV(zap.NewNop())
+	for start := uint64(0); start < 60; start += 20 {
+		snapshot, err := merged.marshalSnapshot(&marshaller.StoreData{Kv: merged.kv, LastWrites: merged.lastWrites})
+		require.NoError(t, err)
+		full := config.NewFullKV(zap.NewNop())
+		require.NoError(t, full.loadData(NewCompleteFileInfo("test", 0, start), snapshot))
+		full.SetBlock(start)
+		for blockNum := start; blockNum < start+20; blockNum++ {
+			parallelDeltas[blockNum] = process(full.baseStore, blockNum)
+		}
+
+		partial := config.NewPartialKV(start, zap.NewNop())
+		partial.endBlock = start + 20
+		for blockNum := start; blockNum < start+20; blockNum++ {
+			process(partial.baseStore, blockNum)
+		}
+		require.NoError(t, merged.Merge(partial))
+	}
+	assert.Equal(t, linearDeltas, parallelDeltas)
+
+	replayed := config.NewFullKV(zap.NewNop())
+	for blockNum := uint64(0); blockNum < 60; blockNum++ {
+		replayed.SetBlock(blockNum)
+		replayed.SetDeltas(parallelDeltas[blockNum])
+		replayed.EvictExpiredKeys()
+		replayed.Reset()
+	}
+	assert.Equal(t, map[string][]byte{"c": []byte("45"), "e": []byte("52")}, linear.kv)
+	assert.Equal(t, linear.kv, replayed.kv)
+	assert.Equal(t, linear.lastWrites, replayed.lastWrites)
+	assert.Equal(t, linear.totalSizeBytes, replayed.totalSizeBytes)
+}
@@ -27,9 +27,74 @@ import (
 func (b *baseStore) DeletePrefix(ord uint64, prefix string) {
 	b.bumpOrdinal(ord)
 
-	var deltas []*pbssinternal.StoreDelta
+	var keys []string
+	for key := range b.kv {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	if b.lazy != nil {
+		b.scanLazy(prefix, prefixEnd(prefix), func(key string, _ []byte) {
+			keys = append(keys, key)
+		})
+	}
+	b.deleteKeys(ord, keys)
+}
+
+func (b *baseStore) DeleteRange(ord uint64, lowKey, highKey string) {
+	b.bumpOrdinal(ord)
+	b.deleteKeys(ord, b.keysInRange(lowKey, highKey, ""))
+}
+
+func (b *baseStore) DeleteRangePointers(ord uint64, lowKey, highKey, pointerSeparator string) {
+	if pointerSeparator == "" {
+		panic("pointer separator cannot be empty")
+	}
+	b.bumpOrdinal(ord)
+	b.deleteKeys(ord, b.keysInRange(lowKey, highKey, pointerSeparator))
+}
+
+// keysInRange returns the keys between `lowKey` (inclusive) and `highKey`
+// (exclusive). When `pointerSeparator` is set, the keys listed in the values
+// of the keys in range are returned too.
+func (b *baseStore) keysInRange(lowKey, highKey, pointerSeparator string) (out []string) {
+	seen := map[string]bool{}
+	add := func(key string) {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			out = append(out, key)
+		}
+	}
+
+	visit := func(key string, val []byte) {
+		add(key)
+		if pointerSeparator == "" {
+			return
+		}
+		for _, pointer := range strings.Split(string(val), pointerSeparator) {
+			add(pointer)
+		}
+	}
+
 	for key, val := range b.kv {
-		if !strings.HasPrefix(key, prefix) {
+		if key < lowKey || (highKey != "" && key >= highKey) {
+			continue
+		}
+		visit(key, val)
+	}
+	b.scanLazy(lowKey, highKey, visit)
+	return out
+}
+
+// deleteKeys deletes the existing keys among `keys`, recording a delta for
+// each of them. Deltas are sorted by key to keep the output deterministic.
+func (b *baseStore) deleteKeys(ord uint64, keys []string) {
+	sort.Strings(keys)
+
+	var deltas []*pbssinternal.StoreDelta
+	for _, key := range keys {
+		val, found := b.get(key)
+		if !found {
 			continue
 		}
 		delta := &pbssinternal.StoreDelta{
@@ -40,10 +105,21 @@ func (b *baseStore) DeletePrefix(ord uint64, prefix string) {
 			NewValue:  nil,
 		}
 		b.ApplyDelta(delta)
+		b.forget(key)
 		deltas = append(deltas, delta)
 	}
-	sort.Slice(deltas, func(i, j int) bool {
-		return deltas[i].Key < deltas[j].Key
-	})
 	b.deltas = append(b.deltas, deltas...)
 }
+
+// prefixEnd is the smallest key sorting after all the keys starting with
+// `prefix`, empty if there is none.
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
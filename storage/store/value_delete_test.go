@@ -0,0 +1,249 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+
+	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/storage/store/marshaller"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_DeleteRange(t *testing.T) {
+	tests := []struct {
+		name        string
+		lowKey      string
+		highKey     string
+		pointerSep  string
+		expectedKV  map[string]string
+		deletedKeys []string
+	}{
+		{
+			name:    "bounded range",
+			lowKey:  "b:2",
+			highKey: "b:4",
+			expectedKV: map[string]string{
+				"a:1":   "b:2",
+				"b:1":   "b1",
+				"b:4":   "b4",
+				"c:1":   "c1",
+				"idx:1": "b:3;c:1",
+			},
+			deletedKeys: []string{"b:2", "b:3"},
+		},
+		{
+			name:   "unbounded range",
+			lowKey: "b:3",
+			expectedKV: map[string]string{
+				"a:1": "b:2",
+				"b:1": "b1",
+				"b:2": "b2",
+			},
+			deletedKeys: []string{"b:3", "b:4", "c:1", "idx:1"},
+		},
+		{
+			name:       "pointers",
+			lowKey:     "idx:",
+			highKey:    "idx;",
+			pointerSep: ";",
+			expectedKV: map[string]string{
+				"a:1": "b:2",
+				"b:1": "b1",
+				"b:2": "b2",
+				"b:4": "b4",
+			},
+			deletedKeys: []string{"b:3", "c:1", "idx:1"},
+		},
+		{
+			name:       "pointers are not followed recursively",
+			lowKey:     "a:",
+			highKey:    "a;",
+			pointerSep: ";",
+			expectedKV: map[string]string{
+				"b:1":   "b1",
+				"b:3":   "b3",
+				"b:4":   "b4",
+				"c:1":   "c1",
+				"idx:1": "b:3;c:1",
+			},
+			deletedKeys: []string{"a:1", "b:2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestBaseStore(t, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", nil)
+			s.Set(0, "a:1", "b:2")
+			s.Set(0, "b:1", "b1")
+			s.Set(0, "b:2", "b2")
+			s.Set(0, "b:3", "b3")
+			s.Set(0, "b:4", "b4")
+			s.Set(0, "c:1", "c1")
+			s.Set(0, "idx:1", "b:3;c:1")
+			s.Reset()
+
+			if test.pointerSep != "" {
+				s.DeleteRangePointers(1, test.lowKey, test.highKey, test.pointerSep)
+			} else {
+				s.DeleteRange(1, test.lowKey, test.highKey)
+			}
+
+			kv := map[string]string{}
+			for k, v := range s.kv {
+				kv[k] = string(v)
+			}
+			assert.Equal(t, test.expectedKV, kv)
+
+			var deletedKeys []string
+			for _, delta := range s.deltas {
+				assert.Equal(t, pbssinternal.StoreDelta_DELETE, delta.Operation)
+				assert.Equal(t, uint64(1), delta.Ordinal)
+				deletedKeys = append(deletedKeys, delta.Key)
+			}
+			assert.Equal(t, test.deletedKeys, deletedKeys)
+
+			s.ApplyDeltasReverse(s.deltas)
+			assert.Len(t, s.kv, 7)
+		})
+	}
+}
+
+func TestStore_DeleteRangePointers_EmptySeparator(t *testing.T) {
+	s := newTestBaseStore(t, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", nil)
+	assert.Panics(t, func() {
+		s.DeleteRangePointers(0, "a", "b", "")
+	})
+}
+
+func TestPartialKV_DeleteRange(t *testing.T) {
+	p := &PartialKV{
+		baseStore:  newTestBaseStore(t, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", nil),
+		seenRanges: map[marshaller.DeleteRange]bool{},
+	}
+
+	p.Set(0, "a:1", "1")
+	p.DeleteRange(1, "a:", "a;")
+	p.DeleteRange(2, "a:", "a;")
+	p.DeleteRangePointers(3, "idx:", "", ";")
+
+	require.Empty(t, p.kv)
+	assert.Equal(t, []marshaller.DeleteRange{
+		{LowKey: "a:", HighKey: "a;"},
+		{LowKey: "idx:", PointerSeparator: ";"},
+	}, p.DeletedRanges)
+}
+
+func TestPartialKV_DeleteRangePointers_MergeMatchesLinear(t *testing.T) {
+	config, err := NewConfig("test", 0, "test.module.hash", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", dstore.NewMockStore(nil), "")
+	require.NoError(t, err)
+
+	initial := map[string]string{
+		"idx:1": "t:1",
+		"idx:2": "t:2",
+		"t:1":   "1",
+		"t:2":   "2",
+		"t:3":   "3",
+		"t:4":   "4",
+		"t:5":   "5",
+	}
+	segment := func(s Store) {
+		s.Set(1, "idx:1", "t:3") // rewrites a pointer key of the full store
+		s.Set(2, "idx:3", "t:4")
+		s.DeleteRangePointers(3, "idx:", "idx;", ";")
+		s.Set(4, "t:3", "new")
+	}
+	newFull := func() *FullKV {
+		full := config.NewFullKV(zap.NewNop())
+		for k, v := range initial {
+			full.Set(0, k, v)
+		}
+		full.Reset()
+		return full
+	}
+
+	linear := newFull()
+	segment(linear)
+
+	merged := newFull()
+	partial := config.NewPartialKV(10, zap.NewNop())
+	segment(partial)
+	require.NoError(t, merged.Merge(partial))
+
+	expected := map[string][]byte{
+		"t:1": []byte("1"),
+		"t:3": []byte("new"),
+		"t:5": []byte("5"),
+	}
+	assert.Equal(t, expected, linear.kv)
+	assert.Equal(t, expected, merged.kv)
+}
+
+func TestPartialKV_MixedDeletes_MergeMatchesLinear(t *testing.T) {
+	config, err := NewConfig("test", 0, "test.module.hash", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", dstore.NewMockStore(nil), "")
+	require.NoError(t, err)
+
+	initial := map[string]string{
+		"idx:1": "t:1",
+		"t:1":   "1",
+		"t:2":   "2",
+	}
+	newFull := func() *FullKV {
+		full := config.NewFullKV(zap.NewNop())
+		for k, v := range initial {
+			full.Set(0, k, v)
+		}
+		full.Reset()
+		return full
+	}
+
+	tests := []struct {
+		name     string
+		segment  func(s Store)
+		expected map[string][]byte
+	}{
+		{
+			name: "range pointers then prefix",
+			segment: func(s Store) {
+				s.DeleteRangePointers(1, "idx:", "idx;", ";")
+				s.DeletePrefix(2, "idx:")
+			},
+			expected: map[string][]byte{"t:2": []byte("2")},
+		},
+		{
+			name: "prefix then range pointers",
+			segment: func(s Store) {
+				s.DeletePrefix(1, "idx:")
+				s.DeleteRangePointers(2, "idx:", "idx;", ";")
+			},
+			expected: map[string][]byte{"t:1": []byte("1"), "t:2": []byte("2")},
+		},
+		{
+			name: "prefix then write then range pointers",
+			segment: func(s Store) {
+				s.DeletePrefix(1, "t:")
+				s.Set(2, "t:3", "3")
+				s.DeleteRange(3, "idx:", "idx;")
+			},
+			expected: map[string][]byte{"t:3": []byte("3")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			linear := newFull()
+			test.segment(linear)
+
+			merged := newFull()
+			partial := config.NewPartialKV(10, zap.NewNop())
+			test.segment(partial)
+			require.NoError(t, merged.Merge(partial))
+
+			assert.Equal(t, test.expected, linear.kv)
+			assert.Equal(t, test.expected, merged.kv)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"sort"
+	"strings"
+)
+
+// ScanPrefix calls `f`, in lexicographical order, for the keys starting with
+// `prefix` that sort strictly after `cursor`, stopping after `limit` keys (0
+// means no limit). The returned cursor is the last key visited when more
+// keys match the prefix, and is empty otherwise.
+func (b *baseStore) ScanPrefix(prefix, cursor string, limit int, f func(key string, value []byte)) (nextCursor string) {
+	keys := b.orderedKeys()
+
+	start := prefix
+	if cursor > start {
+		start = cursor
+	}
+	i := sort.SearchStrings(keys, start)
+	if i < len(keys) && keys[i] == cursor {
+		i++
+	}
+
+	count := 0
+	for ; i < len(keys) && strings.HasPrefix(keys[i], prefix); i++ {
+		if limit != 0 && count == limit {
+			return keys[i-1]
+		}
+		f(keys[i], b.kv[keys[i]])
+		count++
+	}
+	return ""
+}
+
+// orderedKeys returns the keys of the store in lexicographical order. The
+// view is built on first use, then the keys created and deleted through
+// deltas are only recorded, and merged into it on the next scan, so writes
+// do not pay for the ordering. Wholesale replacements of `kv` (load, merge,
+// roll) drop it.
+func (b *baseStore) orderedKeys() []string {
+	if b.sortedKeys == nil {
+		b.materialize()
+		b.sortedKeys = make([]string, 0, len(b.kv))
+		for key := range b.kv {
+			b.sortedKeys = append(b.sortedKeys, key)
+		}
+		sort.Strings(b.sortedKeys)
+		b.unsortedKeys = nil
+	}
+	if len(b.unsortedKeys) != 0 {
+		b.sortUnsortedKeys()
+	}
+	return b.sortedKeys
+}
+
+// sortUnsortedKeys merges the keys created and deleted since the last sort
+// into sortedKeys.
+func (b *baseStore) sortUnsortedKeys() {
+	var created []string
+	for key, exists := range b.unsortedKeys {
+		if exists {
+			created = append(created, key)
+		}
+	}
+	sort.Strings(created)
+
+	out := make([]string, 0, len(b.sortedKeys)+len(created))
+	for _, key := range b.sortedKeys {
+		if _, changed := b.unsortedKeys[key]; changed {
+			continue
+		}
+		for len(created) != 0 && created[0] < key {
+			out = append(out, created[0])
+			created = created[1:]
+		}
+		out = append(out, key)
+	}
+	b.sortedKeys = append(out, created...)
+	b.unsortedKeys = nil
+}
+
+func (b *baseStore) orderedKeyAdded(key string) {
+	b.orderedKeyChanged(key, true)
+}
+
+func (b *baseStore) orderedKeyRemoved(key string) {
+	b.orderedKeyChanged(key, false)
+}
+
+func (b *baseStore) orderedKeyChanged(key string, exists bool) {
+	if b.sortedKeys == nil {
+		return
+	}
+	if b.unsortedKeys == nil {
+		b.unsortedKeys = make(map[string]bool)
+	}
+	b.unsortedKeys[key] = exists
+}
+
+func (b *baseStore) resetOrderedKeys() {
+	b.sortedKeys = nil
+	b.unsortedKeys = nil
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"testing"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_ScanPrefix(t *testing.T) {
+	s := newTestBaseStore(t, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", nil)
+	s.Set(0, "owner:a:3", "3")
+	s.Set(0, "owner:a:1", "1")
+	s.Set(0, "owner:b:1", "b1")
+	s.Set(0, "owner:a:2", "2")
+	s.Set(0, "other", "x")
+	s.Reset()
+
+	scan := func(prefix, cursor string, limit int) (keys []string, nextCursor string) {
+		nextCursor = s.ScanPrefix(prefix, cursor, limit, func(key string, value []byte) {
+			keys = append(keys, key+"="+string(value))
+		})
+		return
+	}
+
+	keys, cursor := scan("owner:a:", "", 0)
+	assert.Equal(t, []string{"owner:a:1=1", "owner:a:2=2", "owner:a:3=3"}, keys)
+	assert.Equal(t, "", cursor)
+
+	keys, cursor = scan("owner:a:", "", 2)
+	assert.Equal(t, []string{"owner:a:1=1", "owner:a:2=2"}, keys)
+	assert.Equal(t, "owner:a:2", cursor)
+
+	keys, cursor = scan("owner:a:", cursor, 2)
+	assert.Equal(t, []string{"owner:a:3=3"}, keys)
+	assert.Equal(t, "", cursor)
+
+	keys, cursor = scan("owner:a:", "", 3)
+	assert.Len(t, keys, 3)
+	assert.Equal(t, "", cursor, "no cursor when the prefix is exhausted")
+
+	keys, _ = scan("nothing", "", 0)
+	assert.Empty(t, keys)
+
+	s.Set(1, "owner:a:0", "0")
+	s.DeletePrefix(2, "owner:a:2")
+	keys, _ = scan("owner:", "", 0)
+	assert.Equal(t, []string{"owner:a:0=0", "owner:a:1=1", "owner:a:3=3", "owner:b:1=b1"}, keys)
+
+	s.ApplyDeltasReverse(s.deltas)
+	keys, _ = scan("owner:a:", "", 0)
+	assert.Equal(t, []string{"owner:a:1=1", "owner:a:2=2", "owner:a:3=3"}, keys)
+}
+
+func TestStore_ScanPrefix_ChangesBetweenScans(t *testing.T) {
+	s := newTestBaseStore(t, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", nil)
+	keys := func() (out []string) {
+		s.ScanPrefix("", "", 0, func(key string, value []byte) { out = append(out, key) })
+		return
+	}
+
+	s.Set(0, "b", "1")
+	s.Set(0, "d", "1")
+	assert.Equal(t, []string{"b", "d"}, keys())
+
+	// Created, deleted and created again, in any order, between two scans.
+	s.Set(1, "a", "1")
+	s.Set(1, "e", "1")
+	s.Set(1, "c", "1")
+	s.DeletePrefix(2, "d")
+	s.Set(3, "d", "2")
+	s.DeletePrefix(4, "b")
+	s.DeletePrefix(5, "e")
+	s.Set(6, "e", "2")
+	s.DeletePrefix(7, "e")
+	assert.Equal(t, []string{"a", "c", "d"}, keys())
+	assert.Empty(t, s.unsortedKeys)
+
+	s.DeletePrefix(8, "")
+	s.Set(9, "z", "1")
+	assert.Equal(t, []string{"z"}, keys())
+}
@@ -38,6 +38,7 @@ func (b *baseStore) set(ord uint64, key string, value []byte) {
 	}
 
 	b.bumpOrdinal(ord)
+	b.touch(key)
 
 	cpValue := make([]byte, len(value))
 	copy(cpValue, value)
@@ -67,6 +68,7 @@ func (b *baseStore) set(ord uint64, key string, value []byte) {
 }
 
 func (b *baseStore) setIfNotExists(ord uint64, key string, value []byte) {
+	b.touch(key)
 	_, found := b.GetLast(key)
 	if found {
 		return
@@ -187,7 +187,7 @@ func (g LinearBlockGenerator) Generate() []*GeneratedBlock {
 			Id:         block.Id,
 			Number:     block.Number,
 			PreviousId: "",
-			Timestamp:  time.Now(),
+			Timestamp:  time.Unix(int64(i), 0), // deterministic, so the clocks are the same across runs
 			LibNum:     blockLIBRef.Num(),
 		}
 		bsBlock, err = bstream.MemoryBlockPayloadSetter(bsBlock, bytesBlock)
@@ -2,6 +2,7 @@ package integration
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"math/big"
 	"os"
@@ -17,7 +18,10 @@ import (
 	"github.com/streamingfast/substreams/block"
 	"github.com/streamingfast/substreams/orchestrator/stage"
 	"github.com/streamingfast/substreams/orchestrator/work"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"github.com/streamingfast/substreams/reqctx"
+	"github.com/streamingfast/substreams/service"
+	"github.com/streamingfast/substreams/wasm"
 
 	//_ "github.com/streamingfast/substreams/wasm/wasmtime"
 	_ "github.com/streamingfast/substreams/wasm/wazero"
@@ -488,6 +492,110 @@ func cancelledContext(delay time.Duration) context.Context {
 	return ctx
 }
 
+// (module
+//
+//	(import "test_ext" "echo" (func $echo (param i32 i32 i32)))
+//	(import "env" "output" (func $output (param i32 i32)))
+//	(memory (export "memory") 1)
+//	(global $next (mut i32) (i32.const 1024))
+//	(func (export "alloc") (param $size i32) (result i32)
+//	  (local $ptr i32)
+//	  (local.set $ptr (global.get $next))
+//	  (global.set $next (i32.add (global.get $next) (local.get $size)))
+//	  (local.get $ptr))
+//	(func (export "dealloc") (param i32 i32))
+//	(func (export "map_ext") (param $ptr i32) (param $len i32)
+//	  (call $echo (local.get $ptr) (local.get $len) (i32.const 0))
+//	  (call $output (i32.load (i32.const 0)) (i32.load (i32.const 4)))))
+var extensionTestModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x11, 0x03, 0x60, 0x03, 0x7f, 0x7f, 0x7f,
+	0x00, 0x60, 0x02, 0x7f, 0x7f, 0x00, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x02, 0x1e, 0x02, 0x08, 0x74,
+	0x65, 0x73, 0x74, 0x5f, 0x65, 0x78, 0x74, 0x04, 0x65, 0x63, 0x68, 0x6f, 0x00, 0x00, 0x03, 0x65,
+	0x6e, 0x76, 0x06, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x00, 0x01, 0x03, 0x04, 0x03, 0x02, 0x01,
+	0x01, 0x05, 0x03, 0x01, 0x00, 0x01, 0x06, 0x07, 0x01, 0x7f, 0x01, 0x41, 0x80, 0x08, 0x0b, 0x07,
+	0x26, 0x04, 0x06, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x02, 0x00, 0x05, 0x61, 0x6c, 0x6c, 0x6f,
+	0x63, 0x00, 0x02, 0x07, 0x64, 0x65, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x00, 0x03, 0x07, 0x6d, 0x61,
+	0x70, 0x5f, 0x65, 0x78, 0x74, 0x00, 0x04, 0x0a, 0x2d, 0x03, 0x11, 0x01, 0x01, 0x7f, 0x23, 0x00,
+	0x21, 0x01, 0x23, 0x00, 0x20, 0x00, 0x6a, 0x24, 0x00, 0x20, 0x01, 0x0b, 0x02, 0x00, 0x0b, 0x16,
+	0x00, 0x20, 0x00, 0x20, 0x01, 0x41, 0x00, 0x10, 0x00, 0x41, 0x00, 0x28, 0x02, 0x00, 0x41, 0x04,
+	0x28, 0x02, 0x00, 0x10, 0x01, 0x0b,
+}
+
+// extensionTestPackage has a single `map_ext` module, outputting the response
+// of the `test_ext::echo` extension to the clock of each block.
+func extensionTestPackage() *pbsubstreams.Package {
+	return &pbsubstreams.Package{
+		Modules: &pbsubstreams.Modules{
+			Binaries: []*pbsubstreams.Binary{{Type: "wasm/rust-v1", Content: extensionTestModule}},
+			Modules: []*pbsubstreams.Module{{
+				Name:             "map_ext",
+				Kind:             &pbsubstreams.Module_KindMap_{KindMap: &pbsubstreams.Module_KindMap{OutputType: "bytes"}},
+				BinaryEntrypoint: "map_ext",
+				Inputs: []*pbsubstreams.Module_Input{{
+					Input: &pbsubstreams.Module_Input_Source_{Source: &pbsubstreams.Module_Input_Source{Type: wasm.ClockType}},
+				}},
+				Output: &pbsubstreams.Module_Output{Type: "bytes"},
+			}},
+		},
+	}
+}
+
+type testExtension func(clock *pbsubstreams.Clock, in []byte) ([]byte, error)
+
+func (e testExtension) WASMExtensions() map[string]map[string]wasm.WASMExtension {
+	return map[string]map[string]wasm.WASMExtension{
+		"test_ext": {
+			"echo": func(_ context.Context, _ string, clock *pbsubstreams.Clock, in []byte) ([]byte, error) {
+				return e(clock, in)
+			},
+		},
+	}
+}
+
+func TestExtensionCallsRecordReplay(t *testing.T) {
+	var liveCalls int
+	record := &testRun{Package: extensionTestPackage(), StartBlock: 1, LinearHandoffBlockNum: 1, ExclusiveEndBlock: 6, ModuleName: "map_ext"}
+	record.ServiceOptions = []service.Option{
+		service.WithWASMExtension(testExtension(func(clock *pbsubstreams.Clock, in []byte) ([]byte, error) {
+			liveCalls++
+			return append([]byte(fmt.Sprintf("live-%d:", clock.Number)), in...), nil
+		})),
+		service.WithWASMExtensionCalls(wasm.ExtensionCallsRecord),
+	}
+	require.NoError(t, record.Run(t, "record"))
+	assert.Equal(t, 5, liveCalls)
+
+	recorded := listFiles(t, filepath.Join(record.TempDir, "test.store", "tag", "extensions"))
+	assert.Len(t, recorded, 5)
+
+	replay := &testRun{Package: extensionTestPackage(), StartBlock: 1, LinearHandoffBlockNum: 1, ExclusiveEndBlock: 6, ModuleName: "map_ext"}
+	replay.ServiceOptions = []service.Option{
+		service.WithWASMExtension(testExtension(func(clock *pbsubstreams.Clock, in []byte) ([]byte, error) {
+			return nil, fmt.Errorf("extension called at block %d while replaying", clock.Number)
+		})),
+		service.WithWASMExtensionCalls(wasm.ExtensionCallsReplay),
+	}
+	replay.PreWork = func(t *testing.T, run *testRun, _ work.WorkerFactory) {
+		src := filepath.Join(record.TempDir, "test.store", "tag", "extensions")
+		dst := filepath.Join(run.TempDir, "test.store", "tag", "extensions")
+		for _, f := range recorded {
+			content, err := os.ReadFile(filepath.Join(src, f))
+			require.NoError(t, err)
+			require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dst, f)), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(dst, f), content, 0644))
+		}
+	}
+	require.NoError(t, replay.Run(t, "replay"))
+
+	assert.Equal(t, 5, liveCalls)
+	assert.Contains(t, record.MapOutput("map_ext"), hex.EncodeToString([]byte("live-5:")))
+	assert.Equal(t, record.MapOutput("map_ext"), replay.MapOutput("map_ext"))
+
+	missing := &testRun{Package: extensionTestPackage(), StartBlock: 1, LinearHandoffBlockNum: 1, ExclusiveEndBlock: 6, ModuleName: "map_ext"}
+	missing.ServiceOptions = replay.ServiceOptions
+	assert.ErrorContains(t, missing.Run(t, "missing"), "no recorded response to replay")
+}
+
 func listFiles(t *testing.T, tempDir string) []string {
 	var storedFiles []string
 	require.NoError(t, filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
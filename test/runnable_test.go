@@ -51,6 +51,8 @@ type testRun struct {
 	// pre-existing data is available in different conditions
 	PreWork testPreWork
 	Context context.Context // custom top-level context, defaults to context.Background()
+	// ServiceOptions are applied to the tier1 service handling the request
+	ServiceOptions []service.Option
 
 	Params map[string]string
 
@@ -142,7 +144,7 @@ func (f *testRun) Run(t *testing.T, testName string) error {
 		f.PreWork(t, f, workerFactory)
 	}
 
-	if err := processRequest(t, ctx, request, workerFactory, newBlockGenerator, responseCollector, false, f.BlockProcessedCallback, testTempDir, f.ParallelSubrequests, f.LinearHandoffBlockNum); err != nil {
+	if err := processRequest(t, ctx, request, workerFactory, newBlockGenerator, responseCollector, false, f.BlockProcessedCallback, testTempDir, f.ParallelSubrequests, f.LinearHandoffBlockNum, f.ServiceOptions...); err != nil {
 		return fmt.Errorf("running test: %w", err)
 	}
 
@@ -278,6 +280,7 @@ func processRequest(
 	testTempDir string,
 	parallelSubrequests uint64,
 	linearHandoffBlockNum uint64,
+	serviceOptions ...service.Option,
 ) error {
 	t.Helper()
 
@@ -302,7 +305,7 @@ func processRequest(
 		"tag",
 		workerFactory,
 	)
-	svc := service.TestNewService(runtimeConfig, linearHandoffBlockNum, tr.StreamFactory)
+	svc := service.TestNewService(runtimeConfig, linearHandoffBlockNum, tr.StreamFactory, serviceOptions...)
 	return svc.TestBlocks(ctx, isSubRequest, request, responseCollector.Collect)
 }
 
@@ -0,0 +1,178 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/streamingfast/bstream"
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams"
+	"github.com/streamingfast/substreams/block"
+	"github.com/streamingfast/substreams/manifest"
+	"github.com/streamingfast/substreams/metrics"
+	"github.com/streamingfast/substreams/orchestrator"
+	"github.com/streamingfast/substreams/orchestrator/loop"
+	"github.com/streamingfast/substreams/orchestrator/plan"
+	"github.com/streamingfast/substreams/orchestrator/response"
+	"github.com/streamingfast/substreams/orchestrator/stage"
+	"github.com/streamingfast/substreams/orchestrator/work"
+	"github.com/streamingfast/substreams/pipeline/outputmodules"
+	"github.com/streamingfast/substreams/reqctx"
+	"github.com/streamingfast/substreams/service"
+	"github.com/streamingfast/substreams/service/config"
+	"github.com/streamingfast/substreams/storage/execout"
+	"github.com/streamingfast/substreams/storage/store"
+)
+
+// warm runs orchestrator.Warm over [startBlock, stopBlock) in the cache
+// of the test workers, with the `newWorker` ones.
+func warm(t *testing.T, testTempDir, moduleName string, startBlock, stopBlock uint64, newWorker work.WorkerFactory) error {
+	pkg := manifest.TestReadManifest(t, "./testdata/substreams-test-v0.1.0.spkg")
+	outputGraph, err := outputmodules.NewOutputModuleGraph(moduleName, true, pkg.Modules)
+	require.NoError(t, err)
+
+	baseStore, err := dstore.NewStore(filepath.Join(testTempDir, "test.store"), "", "none", true)
+	require.NoError(t, err)
+	cacheStore, err := baseStore.SubStore("tag")
+	require.NoError(t, err)
+
+	ctx := reqctx.WithLogger(context.Background(), zlog)
+	ctx = reqctx.WithRequest(ctx, &reqctx.RequestDetails{
+		Modules:               pkg.Modules,
+		OutputModule:          moduleName,
+		ResolvedStartBlockNum: startBlock,
+		LinearHandoffBlockNum: stopBlock,
+		StopBlockNum:          stopBlock,
+		CacheTag:              "tag",
+		ProductionMode:        true,
+	})
+	ctx = reqctx.WithReqStats(ctx, metrics.NewReqStats(&metrics.Config{OutputModule: moduleName, ProductionMode: true}, zlog))
+
+	scheduleStores := outputGraph.StagedUsedModules()[0].LastLayer().IsStoreLayer()
+	reqPlan, err := plan.BuildTier1RequestPlan(true, 10, outputGraph.LowestInitBlock(), startBlock, stopBlock, stopBlock, scheduleStores)
+	require.NoError(t, err)
+
+	execoutConfigs, err := execout.NewConfigs(cacheStore, outputGraph.UsedModules(), outputGraph.ModuleHashes(), 10, zlog)
+	require.NoError(t, err)
+	storeConfigs, err := store.NewConfigMap(cacheStore, outputGraph.Stores(), outputGraph.ModuleHashes(), 10, service.TestTraceID)
+	require.NoError(t, err)
+
+	runtimeConfig := config.NewRuntimeConfig(10, 5, 10, 0, baseStore, "tag", newWorker)
+	respFunc := func(substreams.ResponseFromAnyTier) error { return nil }
+	return orchestrator.Warm(ctx, reqPlan, runtimeConfig, 5, outputGraph, execoutConfigs, respFunc, storeConfigs, service.TestTraceID)
+}
+
+// recordingWorker runs the jobs with a TestWorker, recording them.
+type recordingWorker struct {
+	*TestWorker
+	jobs *[]string
+}
+
+func (w *recordingWorker) Work(ctx context.Context, unit stage.Unit, workRange *block.Range, moduleNames []string, upstream *response.Stream) loop.Cmd {
+	*w.jobs = append(*w.jobs, fmt.Sprintf("%d:%s", unit.Stage, workRange))
+	cmd := w.TestWorker.Work(ctx, unit, workRange, moduleNames, upstream)
+	return func() loop.Msg {
+		msg := cmd()
+		if succeeded, ok := msg.(work.MsgJobSucceeded); ok {
+			succeeded.Worker = w
+			return succeeded
+		}
+		return msg
+	}
+}
+
+func recordingWorkerFactory(t *testing.T, testTempDir string, jobs *[]string) work.WorkerFactory {
+	newBlockGenerator := func(startBlock uint64, inclusiveStopBlock uint64) TestBlockGenerator {
+		return &LinearBlockGenerator{startBlock: startBlock, inclusiveStopBlock: inclusiveStopBlock}
+	}
+	return func(_ *zap.Logger) work.Worker {
+		return &recordingWorker{
+			TestWorker: &TestWorker{
+				t:                 t,
+				responseCollector: newResponseCollector(),
+				newBlockGenerator: newBlockGenerator,
+				testTempDir:       testTempDir,
+				id:                workerID.Inc(),
+			},
+			jobs: jobs,
+		}
+	}
+}
+
+func TestWarm(t *testing.T) {
+	testTempDir := t.TempDir()
+
+	// An interrupted run left the partial store of its first job.
+	var jobs []string
+	pkg := manifest.TestReadManifest(t, "./testdata/substreams-test-v0.1.0.spkg")
+	ctx := reqctx.WithRequest(reqctx.WithLogger(context.Background(), zlog), &reqctx.RequestDetails{Modules: pkg.Modules, OutputModule: "assert_test_store_add_i64", CacheTag: "tag"})
+	worker := recordingWorkerFactory(t, testTempDir, &jobs)(zlog)
+	require.IsType(t, work.MsgJobSucceeded{}, worker.Work(ctx, stage.Unit{Segment: 0, Stage: 0}, block.NewRange(1, 10), []string{"setup_test_store_add_i64"}, nil)())
+
+	jobs = nil
+	require.NoError(t, warm(t, testTempDir, "assert_test_store_add_i64", 1, 29, recordingWorkerFactory(t, testTempDir, &jobs)))
+	assert.ElementsMatch(t, []string{
+		"0:[10, 20)",
+		"1:[1, 10)", "1:[10, 20)", "1:[20, 29)",
+	}, jobs)
+	assertCachedFiles(t, testTempDir,
+		"states/0000000010-0000000001.kv",
+		"states/0000000020-0000000001.kv",
+		"outputs/0000000001-0000000010.output",
+		"outputs/0000000010-0000000020.output",
+		"outputs/0000000020-0000000029.output",
+	)
+
+	// Everything is in the cache, nothing is scheduled again.
+	jobs = nil
+	require.NoError(t, warm(t, testTempDir, "assert_test_store_add_i64", 1, 29, recordingWorkerFactory(t, testTempDir, &jobs)))
+	assert.Empty(t, jobs)
+
+	// Extending the range only schedules the new segments.
+	require.NoError(t, warm(t, testTempDir, "assert_test_store_add_i64", 1, 40, recordingWorkerFactory(t, testTempDir, &jobs)))
+	assert.ElementsMatch(t, []string{"0:[20, 30)", "1:[20, 30)", "1:[30, 40)"}, jobs)
+}
+
+func TestWarm_LocalWorker(t *testing.T) {
+	testTempDir := t.TempDir()
+
+	baseStore, err := dstore.NewStore(filepath.Join(testTempDir, "test.store"), "", "none", true)
+	require.NoError(t, err)
+	streamFactory := func(ctx context.Context, h bstream.Handler, startBlockNum int64, stopBlockNum uint64, cursor string, finalBlocksOnly bool, cursorIsTarget bool, logger *zap.Logger) (service.Streamable, error) {
+		runner := &TestRunner{
+			t: t,
+			blockGeneratorFactory: func(startBlock uint64, inclusiveStopBlock uint64) TestBlockGenerator {
+				return &LinearBlockGenerator{startBlock: startBlock, inclusiveStopBlock: inclusiveStopBlock}
+			},
+		}
+		return runner.StreamFactory(ctx, h, startBlockNum, stopBlockNum, cursor, finalBlocksOnly, cursorIsTarget, logger)
+	}
+	tier2 := service.TestNewServiceTier2(config.NewRuntimeConfig(10, 0, 0, 0, baseStore, "tag", nil), streamFactory)
+
+	require.NoError(t, warm(t, testTempDir, "assert_test_store_add_i64", 1, 29, service.NewLocalWorkerFactory(tier2)))
+	assertCachedFiles(t, testTempDir,
+		"states/0000000010-0000000001.kv",
+		"states/0000000020-0000000001.kv",
+		"outputs/0000000001-0000000010.output",
+		"outputs/0000000010-0000000020.output",
+		"outputs/0000000020-0000000029.output",
+	)
+}
+
+func assertCachedFiles(t *testing.T, tempDir string, wantedFiles ...string) {
+	t.Helper()
+	var actualFiles []string
+	for _, f := range listFiles(t, tempDir) {
+		parts := strings.Split(f, string(os.PathSeparator))
+		actualFiles = append(actualFiles, filepath.Join(parts[4:]...))
+	}
+	assert.ElementsMatch(t, wantedFiles, actualFiles)
+}
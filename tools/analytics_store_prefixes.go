@@ -0,0 +1,301 @@
+package tools
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/storage/store"
+)
+
+// PrefixStats is a node of the prefix tree of a store: the keys sharing a
+// prefix of `:`-separated segments, like `pool:` or `pool:0xabc:`. The root
+// node, with an empty prefix, holds all the keys of the store.
+type PrefixStats struct {
+	Prefix     string `json:"prefix"`
+	KeysCount  uint64 `json:"count"`
+	KeysSize   uint64 `json:"keys_size_bytes"`
+	ValuesSize uint64 `json:"values_size_bytes"`
+	TotalSize  uint64 `json:"total_size_bytes"`
+
+	// Growth holds the count and size of the prefix in the previous
+	// snapshots, then in the latest one, with `--prefix-growth`.
+	Growth     []*PrefixSnapshot `json:"growth,omitempty"`
+	SizeGrowth float64           `json:"size_growth,omitempty"`
+
+	// Children are the largest sub-prefixes, up to `--prefix-children`, the
+	// others being summed up in OtherCount and OtherSize.
+	Children   []*PrefixStats `json:"children,omitempty"`
+	OtherCount uint64         `json:"other_count,omitempty"`
+	OtherSize  uint64         `json:"other_total_size_bytes,omitempty"`
+
+	children map[string]*PrefixStats
+}
+
+type PrefixSnapshot struct {
+	Block     uint64 `json:"block"`
+	KeysCount uint64 `json:"count"`
+	TotalSize uint64 `json:"total_size_bytes"`
+}
+
+type KeySize struct {
+	Key       string `json:"key"`
+	SizeBytes uint64 `json:"size_bytes"`
+}
+
+type ValueKindStats struct {
+	Count     uint64 `json:"count"`
+	TotalSize uint64 `json:"total_size_bytes"`
+}
+
+// prefixProfiler profiles the key space of a store: the bytes and key count
+// per prefix, the largest keys (key and value bytes) and the kinds of values.
+type prefixProfiler struct {
+	depth       int
+	maxChildren int
+	topKeys     int
+}
+
+type prefixProfile struct {
+	root        *PrefixStats
+	largestKeys keySizeHeap
+	valueKinds  map[string]*ValueKindStats
+}
+
+func (p *prefixProfiler) profile(s store.Store) (*prefixProfile, error) {
+	out := &prefixProfile{
+		root:       newPrefixStats(""),
+		valueKinds: make(map[string]*ValueKindStats),
+	}
+
+	err := s.Iter(func(key string, value []byte) error {
+		keySize, valueSize := uint64(len(key)), uint64(len(value))
+
+		node := out.root
+		node.add(keySize, valueSize)
+		for end, level := 0, 0; level < p.depth; level++ {
+			next := strings.IndexByte(key[end:], ':')
+			if next == -1 {
+				break
+			}
+			end += next + 1
+			node = node.child(key[:end])
+			node.add(keySize, valueSize)
+		}
+
+		kind := valueKind(value)
+		if out.valueKinds[kind] == nil {
+			out.valueKinds[kind] = &ValueKindStats{}
+		}
+		out.valueKinds[kind].Count++
+		out.valueKinds[kind].TotalSize += keySize + valueSize
+
+		if p.topKeys > 0 {
+			size := keySize + valueSize
+			if len(out.largestKeys) < p.topKeys {
+				heap.Push(&out.largestKeys, &KeySize{Key: key, SizeBytes: size})
+			} else if smallest := out.largestKeys[0]; size > smallest.SizeBytes || (size == smallest.SizeBytes && key < smallest.Key) {
+				out.largestKeys[0] = &KeySize{Key: key, SizeBytes: size}
+				heap.Fix(&out.largestKeys, 0)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("iterating store: %w", err)
+	}
+	return out, nil
+}
+
+// calculatePrefixStats fills the prefix tree, largest keys and value kinds
+// of `stats`. With `growth`, the earlier snapshots of `files` (sorted from
+// the latest, which `stateStore` holds) are loaded to track the count and
+// size of each prefix over time.
+func (p *prefixProfiler) calculatePrefixStats(ctx context.Context, conf *store.Config, stateStore store.Store, files []*store.FileInfo, growth bool, stats *StoreStats) error {
+	start := time.Now()
+	defer func() {
+		zlog.Debug("calculating store prefix stats", zap.String("module", conf.Name()), zap.Duration("duration", time.Now().Sub(start)))
+	}()
+
+	latest, err := p.profile(stateStore)
+	if err != nil {
+		return err
+	}
+	latest.root.prune(p.maxChildren)
+
+	stats.ValueKinds = latest.valueKinds
+	stats.LargestKeys = make([]*KeySize, len(latest.largestKeys))
+	copy(stats.LargestKeys, latest.largestKeys)
+	sort.Slice(stats.LargestKeys, func(i, j int) bool {
+		if stats.LargestKeys[i].SizeBytes == stats.LargestKeys[j].SizeBytes {
+			return stats.LargestKeys[i].Key < stats.LargestKeys[j].Key
+		}
+		return stats.LargestKeys[i].SizeBytes > stats.LargestKeys[j].SizeBytes
+	})
+	stats.Prefixes = latest.root
+
+	if !growth || len(files) < 2 {
+		return nil
+	}
+
+	// Count the prefixes kept in the latest profile only, from the oldest
+	// snapshot to the latest one.
+	previous := &prefixProfiler{depth: p.depth}
+	for i := len(files) - 1; i >= 1; i-- {
+		s := conf.NewFullKV(zlog)
+		if err := s.Load(ctx, files[i]); err != nil {
+			return fmt.Errorf("loading store snapshot %s: %w", files[i].Filename, err)
+		}
+		profile, err := previous.profile(s)
+		if err != nil {
+			return err
+		}
+		latest.root.addGrowth(profile.root, files[i].Range.ExclusiveEndBlock)
+	}
+	latest.root.addGrowth(latest.root, files[0].Range.ExclusiveEndBlock)
+	latest.root.computeSizeGrowth()
+	return nil
+}
+
+func newPrefixStats(prefix string) *PrefixStats {
+	return &PrefixStats{Prefix: prefix, children: make(map[string]*PrefixStats)}
+}
+
+func (n *PrefixStats) add(keySize, valueSize uint64) {
+	n.KeysCount++
+	n.KeysSize += keySize
+	n.ValuesSize += valueSize
+	n.TotalSize += keySize + valueSize
+}
+
+func (n *PrefixStats) child(prefix string) *PrefixStats {
+	c := n.children[prefix]
+	if c == nil {
+		c = newPrefixStats(prefix)
+		n.children[prefix] = c
+	}
+	return c
+}
+
+// prune keeps the `maxChildren` largest children of each node, a zero
+// `maxChildren` keeping them all.
+func (n *PrefixStats) prune(maxChildren int) {
+	n.Children = make([]*PrefixStats, 0, len(n.children))
+	for _, c := range n.children {
+		n.Children = append(n.Children, c)
+	}
+	sort.Slice(n.Children, func(i, j int) bool {
+		if n.Children[i].TotalSize == n.Children[j].TotalSize {
+			return n.Children[i].Prefix < n.Children[j].Prefix
+		}
+		return n.Children[i].TotalSize > n.Children[j].TotalSize
+	})
+	if maxChildren > 0 && len(n.Children) > maxChildren {
+		for _, c := range n.Children[maxChildren:] {
+			n.OtherCount += c.KeysCount
+			n.OtherSize += c.TotalSize
+		}
+		n.Children = n.Children[:maxChildren]
+	}
+
+	n.children = nil
+	for _, c := range n.Children {
+		c.prune(maxChildren)
+	}
+}
+
+// addGrowth appends the count and size of the prefixes of `n` found in
+// `snapshot`, an unpruned profile or `n` itself, zero for the ones it does
+// not have.
+func (n *PrefixStats) addGrowth(snapshot *PrefixStats, block uint64) {
+	point := &PrefixSnapshot{Block: block}
+	if snapshot != nil {
+		point.KeysCount, point.TotalSize = snapshot.KeysCount, snapshot.TotalSize
+	}
+	n.Growth = append(n.Growth, point)
+
+	for _, c := range n.Children {
+		var snapshotChild *PrefixStats
+		switch {
+		case snapshot == n:
+			snapshotChild = c
+		case snapshot != nil:
+			snapshotChild = snapshot.children[c.Prefix]
+		}
+		c.addGrowth(snapshotChild, block)
+	}
+}
+
+// computeSizeGrowth sets SizeGrowth to the slope of the size of each prefix
+// over its snapshots, in bytes per snapshot.
+func (n *PrefixStats) computeSizeGrowth() {
+	if len(n.Growth) >= 2 {
+		xs := make([]float64, len(n.Growth))
+		ys := make([]float64, len(n.Growth))
+		for i, point := range n.Growth {
+			xs[i], ys[i] = float64(i), float64(point.TotalSize)
+		}
+		n.SizeGrowth, _ = leastSquareRegression(xs, ys)
+	}
+	for _, c := range n.Children {
+		c.computeSizeGrowth()
+	}
+}
+
+// valueKind classifies a value as stored by the store update policies:
+// "empty", "integer" or "decimal" (numbers in their string form), "string"
+// (other UTF-8 text) or "bytes".
+func valueKind(value []byte) string {
+	switch {
+	case len(value) == 0:
+		return "empty"
+	case !utf8.Valid(value):
+		return "bytes"
+	}
+	text := string(value)
+	if _, ok := new(big.Int).SetString(text, 10); ok {
+		return "integer"
+	}
+	if _, _, err := big.ParseFloat(text, 10, 0, big.ToNearestEven); err == nil && !isNonFinite(text) {
+		return "decimal"
+	}
+	for _, r := range text {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return "bytes"
+		}
+	}
+	return "string"
+}
+
+// isNonFinite tells if `text` spells an infinity or NaN, which are not
+// decimals of the stores even though big.ParseFloat accepts "Inf".
+func isNonFinite(text string) bool {
+	lower := strings.ToLower(text)
+	return strings.Contains(lower, "inf") || strings.Contains(lower, "nan")
+}
+
+// keySizeHeap is a min-heap of the largest keys found so far.
+type keySizeHeap []*KeySize
+
+func (h keySizeHeap) Len() int { return len(h) }
+func (h keySizeHeap) Less(i, j int) bool {
+	if h[i].SizeBytes == h[j].SizeBytes {
+		return h[i].Key > h[j].Key
+	}
+	return h[i].SizeBytes < h[j].SizeBytes
+}
+func (h keySizeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *keySizeHeap) Push(x any)   { *h = append(*h, x.(*KeySize)) }
+func (h *keySizeHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
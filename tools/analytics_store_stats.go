@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/streamingfast/cli"
 	"github.com/streamingfast/dstore"
 	"github.com/streamingfast/substreams/block"
 	"github.com/streamingfast/substreams/manifest"
@@ -23,11 +24,26 @@ import (
 var analyticsStoreStatsCmd = &cobra.Command{
 	Use:   "store-stats <manifest> <store>",
 	Short: "Prints stats about a store",
-	Args:  cobra.ExactArgs(2),
-	RunE:  StoreStatsE,
+	Long: cli.Dedent(`
+		Prints, as JSON, stats about the latest snapshot of each store of the package: key and
+		value sizes, file size growth, and a profile of its key space. The keys are grouped in a
+		tree of prefixes of ':'-separated segments ('pool:', then 'pool:0xabc:', ...), with the key
+		count and bytes of each prefix, along with the largest keys and the kinds of values
+		('integer', 'decimal', 'string', 'bytes' or 'empty').
+
+		With --prefix-growth, the previous snapshots (up to 4) are loaded to report the count
+		and size of each prefix over time.
+	`),
+	Args: cobra.ExactArgs(2),
+	RunE: StoreStatsE,
 }
 
 func init() {
+	analyticsStoreStatsCmd.Flags().Uint64("prefix-depth", 2, "Number of ':'-separated key segments in the prefix tree, 0 to only profile the whole store")
+	analyticsStoreStatsCmd.Flags().Uint64("prefix-children", 20, "Maximum number of sub-prefixes reported per prefix, the largest ones, 0 for all")
+	analyticsStoreStatsCmd.Flags().Uint64("top-keys", 10, "Number of largest keys (key and value bytes) reported")
+	analyticsStoreStatsCmd.Flags().Bool("prefix-growth", false, "Load the previous snapshots to report the growth of each prefix")
+
 	analyticsCmd.AddCommand(analyticsStoreStatsCmd)
 }
 
@@ -38,6 +54,12 @@ func StoreStatsE(cmd *cobra.Command, args []string) error {
 
 	manifestPath := args[0]
 	storePath := args[1]
+	profiler := &prefixProfiler{
+		depth:       int(mustGetUint64(cmd, "prefix-depth")),
+		maxChildren: int(mustGetUint64(cmd, "prefix-children")),
+		topKeys:     int(mustGetUint64(cmd, "top-keys")),
+	}
+	prefixGrowth := mustGetBool(cmd, "prefix-growth")
 
 	baseDStore, err := dstore.NewStore(storePath, "zst", "zstd", false)
 	if err != nil {
@@ -163,6 +185,12 @@ func StoreStatsE(cmd *cobra.Command, args []string) error {
 				return
 			}
 
+			err = profiler.calculatePrefixStats(ctx, conf, stateStore, fileInfos, prefixGrowth, storeStats)
+			if err != nil {
+				zlog.Error("getting store prefix stats", zap.Error(err))
+				return
+			}
+
 			statsStream <- storeStats
 			return
 		}(module)
@@ -203,6 +231,10 @@ type StoreStats struct {
 	FileInfo   *FileInfo   `json:"file_info,omitempty"`
 	KeyStats   *KeyStats   `json:"keys,inline,omitempty"`
 	ValueStats *ValueStats `json:"values,inline,omitempty"`
+
+	Prefixes    *PrefixStats               `json:"prefixes,omitempty"`
+	LargestKeys []*KeySize                 `json:"largest_keys,omitempty"`
+	ValueKinds  map[string]*ValueKindStats `json:"value_kinds,omitempty"`
 }
 
 type FileInfo struct {
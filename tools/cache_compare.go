@@ -0,0 +1,205 @@
+package tools
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/cli"
+	"github.com/streamingfast/dstore"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/streamingfast/substreams/manifest"
+	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/storage/cachecompare"
+)
+
+var cacheCompareCmd = &cobra.Command{
+	Use:   "compare <manifest> <cache_url_a> <cache_url_b>",
+	Short: "Compare the store snapshots and outputs of the modules of a package cached in two caches",
+	Long: cli.Dedent(`
+		Compares the cached full store snapshots and outputs of the modules of <manifest>, or of
+		the --module ones, written in <cache_url_a> and <cache_url_b>, the URLs of a cache tag
+		(the state store of the servers followed by the cache tag), from two cache tags or two
+		object stores, for instance before and after a provider or runtime migration.
+
+		The files present in both caches, under the same module hash and range, are compared in
+		block order: the outputs item by item, then the store snapshots key by key. The first
+		divergence of each module is printed with both values, decoded with the protobuf
+		definitions of the package, and the command fails if any is found. The files present in
+		a single cache are counted but not compared.
+	`),
+	Example: string(cli.ExamplePrefixed("substreams tools cache compare", `
+		uniswap-v3.spkg gs://[bucket-url-path]/default gs://[bucket-url-path]/migrated
+		uniswap-v3.spkg gs://[bucket-url-path]/default s3://[other-bucket-url-path]/default --module store_pools
+	`)),
+	Args: cobra.ExactArgs(3),
+	RunE: cacheCompareE,
+}
+
+func init() {
+	cacheCompareCmd.Flags().StringSlice("module", nil, "Only compare these modules, all the modules of the package if empty")
+	cacheCompareCmd.Flags().StringArrayP("params", "p", nil, "Set a params for parameterizable modules. Can be specified multiple times. Ex: -p module1=valA -p module2=valX&valY")
+
+	cacheCmd.AddCommand(cacheCompareCmd)
+}
+
+func cacheCompareE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	manifestPath := args[0]
+
+	manifestReader, err := manifest.NewReader(manifestPath)
+	if err != nil {
+		return fmt.Errorf("manifest reader: %w", err)
+	}
+	pkg, err := manifestReader.Read()
+	if err != nil {
+		return fmt.Errorf("read manifest %q: %w", manifestPath, err)
+	}
+	if err := manifest.ApplyParams(mustGetStringArray(cmd, "params"), pkg); err != nil {
+		return fmt.Errorf("apply params: %w", err)
+	}
+	graph, err := manifest.NewModuleGraph(pkg.Modules.Modules)
+	if err != nil {
+		return fmt.Errorf("processing module graph: %w", err)
+	}
+
+	modules := pkg.Modules.Modules
+	if names := mustGetStringSlice(cmd, "module"); len(names) > 0 {
+		modules = nil
+		for _, name := range names {
+			module, err := graph.Module(name)
+			if err != nil {
+				return fmt.Errorf("module %q: %w", name, err)
+			}
+			modules = append(modules, module)
+		}
+	}
+
+	cacheA, err := dstore.NewStore(args[1], "zst", "zstd", false)
+	if err != nil {
+		return fmt.Errorf("creating cache store A: %w", err)
+	}
+	cacheB, err := dstore.NewStore(args[2], "zst", "zstd", false)
+	if err != nil {
+		return fmt.Errorf("creating cache store B: %w", err)
+	}
+
+	hashes := manifest.NewModuleHashes()
+	var diverging []string
+	for _, module := range modules {
+		hash, err := hashes.HashModule(pkg.Modules, module, graph)
+		if err != nil {
+			return fmt.Errorf("hashing module %q: %w", module.Name, err)
+		}
+
+		report, err := cachecompare.CompareModule(ctx, module, hex.EncodeToString(hash), cacheA, cacheB, zlog)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Module %q (%s): %d store snapshots and %d output files compared, %d only in A, %d only in B\n",
+			report.ModuleName, report.ModuleHash, report.ComparedSnapshots, report.ComparedOutputs, len(report.OnlyInA), len(report.OnlyInB))
+		if report.Divergence == nil {
+			continue
+		}
+		diverging = append(diverging, module.Name)
+
+		decoder, err := newCompareValueDecoder(module, pkg.ProtoFiles)
+		if err != nil {
+			return fmt.Errorf("module %q: %w", module.Name, err)
+		}
+		fmt.Printf("  Divergence in %s\n", report.Divergence)
+		isStoreValue := report.Divergence.Key != ""
+		fmt.Printf("    A: %s\n", decoder.decode(report.Divergence.A, isStoreValue))
+		fmt.Printf("    B: %s\n", decoder.decode(report.Divergence.B, isStoreValue))
+	}
+
+	if len(diverging) > 0 {
+		return fmt.Errorf("caches diverge for modules %s", strings.Join(diverging, ", "))
+	}
+	return nil
+}
+
+// compareValueDecoder decodes the diverging values of a module, store
+// values or outputs, for display.
+type compareValueDecoder struct {
+	values *storeValueDecoder
+	output *desc.MessageDescriptor
+}
+
+func newCompareValueDecoder(module *pbsubstreams.Module, protoFiles []*descriptorpb.FileDescriptorProto) (*compareValueDecoder, error) {
+	d := &compareValueDecoder{}
+	if module.GetKindStore() != nil {
+		values, err := newStoreValueDecoder(module, protoFiles)
+		if err != nil {
+			return nil, err
+		}
+		d.values = values
+		return d, nil
+	}
+
+	outputType := strings.TrimPrefix(module.Output.GetType(), "proto:")
+	fileDescriptors, err := desc.CreateFileDescriptors(protoFiles)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find file descriptors: %w", err)
+	}
+	for _, file := range fileDescriptors {
+		if d.output = file.FindMessage(outputType); d.output != nil {
+			break
+		}
+	}
+	return d, nil
+}
+
+// decode returns the diverging `value` decoded, a store value if
+// `isStoreValue`, an output otherwise.
+func (d *compareValueDecoder) decode(value []byte, isStoreValue bool) string {
+	if value == nil {
+		return "<missing>"
+	}
+
+	var text string
+	var err error
+	switch {
+	case isStoreValue:
+		text, _, err = d.values.decode(value)
+	case d.values != nil:
+		text, err = d.decodeDeltas(value)
+	case d.output != nil:
+		text, err = unmarshalData(value, dynamic.NewMessageFactoryWithDefaults().NewDynamicMessage(d.output))
+	default:
+		text = hex.EncodeToString(value)
+	}
+	if err != nil {
+		return fmt.Sprintf("%s (undecodable: %s)", hex.EncodeToString(value), err)
+	}
+	return text
+}
+
+// decodeDeltas decodes the output of a store, its deltas.
+func (d *compareValueDecoder) decodeDeltas(value []byte) (string, error) {
+	deltas := &pbssinternal.StoreDeltas{}
+	if err := proto.Unmarshal(value, deltas); err != nil {
+		return "", fmt.Errorf("unmarshalling store deltas: %w", err)
+	}
+
+	var lines []string
+	for _, delta := range deltas.StoreDeltas {
+		oldValue, _, err := d.values.decode(delta.OldValue)
+		if err != nil {
+			return "", err
+		}
+		newValue, _, err := d.values.decode(delta.NewValue)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, fmt.Sprintf("%s %q (ordinal %d): %s -> %s", delta.Operation, delta.Key, delta.Ordinal, oldValue, newValue))
+	}
+	return strings.Join(lines, "; "), nil
+}
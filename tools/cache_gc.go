@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/cli"
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/storage/cachegc"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:          "cache",
+	Short:        "Manage the state and output cache of the Substreams servers",
+	SilenceUsage: true,
+}
+
+var cacheGCCmd = &cobra.Command{
+	Use:   "gc <base_store_url>",
+	Short: "Delete the cached files of the modules not accessed recently, by age and size",
+	Long: cli.Dedent(`
+		Walks the cache of the Substreams servers, <base_store_url> being their base state
+		store (all the cache tags, or the --cache-tag ones), groups its files by cache tag and
+		module hash, and deletes:
+
+		- the modules not accessed for more than --max-age;
+		- the partial store files, left by crashed jobs, older than --partial-max-age;
+		- the least recently accessed modules, until the cache fits in --max-size bytes.
+
+		The last access of a module is the last write of its access marker, rewritten by the
+		servers while a request reads the module when they run with cache access markers. The
+		modules without a marker are never deleted, unless --force is set: their last access is
+		then the last write of their files. The modules accessed within --in-flight-grace, which
+		must exceed the refresh interval of the markers, are never deleted, and neither are the
+		ones accessed while the collection runs. The full store snapshots of a module are
+		deleted from the newest to the oldest, the bases of incremental snapshots last.
+
+		A JSON report of the decision taken on each module is printed, with --dry-run nothing
+		is deleted.
+	`),
+	Example: string(cli.ExamplePrefixed("substreams tools cache gc", `
+		gs://[bucket-url-path] --max-age 2160h --partial-max-age 168h --dry-run
+		gs://[bucket-url-path] --cache-tag default --max-size 5000000000000 > gc-report.json
+	`)),
+	Args: cobra.ExactArgs(1),
+	RunE: cacheGCE,
+}
+
+func init() {
+	cacheGCCmd.Flags().StringSlice("cache-tag", nil, "Only collect these cache tags, all of them if empty")
+	cacheGCCmd.Flags().Duration("max-age", 0, "Delete the modules not accessed for longer than this, disabled if 0")
+	cacheGCCmd.Flags().Duration("partial-max-age", 0, "Delete the partial store files older than this, disabled if 0")
+	cacheGCCmd.Flags().Uint64("max-size", 0, "Delete the least recently accessed modules until the cache fits in this many bytes, disabled if 0")
+	cacheGCCmd.Flags().Duration("in-flight-grace", time.Hour, "Never delete the modules accessed more recently than this")
+	cacheGCCmd.Flags().Bool("force", false, "Also delete the modules without an access marker, by the last write of their files")
+	cacheGCCmd.Flags().Bool("dry-run", false, "Only print the report, without deleting anything")
+	cacheGCCmd.Flags().Uint64("parallel", 16, "Number of files read or deleted concurrently")
+
+	cacheCmd.AddCommand(cacheGCCmd)
+	Cmd.AddCommand(cacheCmd)
+}
+
+func cacheGCE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	parallel := int(mustGetUint64(cmd, "parallel"))
+	policy := &cachegc.Policy{
+		MaxAge:        mustGetDuration(cmd, "max-age"),
+		PartialMaxAge: mustGetDuration(cmd, "partial-max-age"),
+		MaxSizeBytes:  mustGetUint64(cmd, "max-size"),
+		InFlightGrace: mustGetDuration(cmd, "in-flight-grace"),
+
+		CollectUnmarked: mustGetBool(cmd, "force"),
+	}
+
+	baseStore, err := dstore.NewStore(args[0], "zst", "zstd", false)
+	if err != nil {
+		return fmt.Errorf("creating base store: %w", err)
+	}
+
+	start := time.Now()
+	modules, err := cachegc.Scan(ctx, baseStore, mustGetStringSlice(cmd, "cache-tag"), parallel, zlog)
+	if err != nil {
+		return fmt.Errorf("scanning cache: %w", err)
+	}
+	zlog.Info("scanned cache", zap.Int("module_count", len(modules)), zap.Duration("duration", time.Since(start)))
+
+	report := cachegc.Plan(modules, policy, time.Now())
+	report.DryRun = mustGetBool(cmd, "dry-run")
+	if !report.DryRun {
+		if err := cachegc.Delete(ctx, baseStore, report, parallel, zlog); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling report to json: %w", err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(data))
+
+	verb := "Deleted"
+	if report.DryRun {
+		verb = "Would delete"
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s %d files (%d bytes) of %d modules, %d of them entirely, out of %d bytes in the cache\n", verb, report.DeletedFileCount, report.DeletedBytes, countDecisionsWithDeletes(report), report.DeletedModuleCount, report.SizeBytes)
+	return nil
+}
+
+func countDecisionsWithDeletes(report *cachegc.Report) (out int) {
+	for _, d := range report.Decisions {
+		if d.DeletedFileCount != 0 {
+			out++
+		}
+	}
+	return out
+}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"math"
 
@@ -8,6 +9,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/spf13/cobra"
+	"github.com/streamingfast/cli"
 	"github.com/streamingfast/dstore"
 	"github.com/streamingfast/substreams/block"
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
@@ -16,11 +18,19 @@ import (
 var checkCmd = &cobra.Command{
 	Use:   "check <store_url>",
 	Short: "checks the integrity of the kv files in a given store",
-	Args:  cobra.ExactArgs(1),
-	RunE:  checkE,
+	Long: cli.Dedent(`
+		Checks that the partial kv files of the store cover contiguous ranges,
+		that the base of every incremental full kv file, back to its checkpoint,
+		is present in the store, and verifies the checksum of every kv file (full
+		and partial) of the store. Legacy kv files, written before checksums, are
+		only counted.
+	`),
+	Args: cobra.ExactArgs(1),
+	RunE: checkE,
 }
 
 func init() {
+	checkCmd.Flags().Bool("skip-checksums", false, "Only check the ranges of the partial kv files and the snapshot chains, without reading the files to verify their checksum")
 	Cmd.AddCommand(checkCmd)
 }
 
@@ -55,7 +65,81 @@ func checkE(cmd *cobra.Command, args []string) error {
 		prevRange = currentRange
 	}
 
-	return err
+	if err := checkSnapshotChains(ctx, stateStore, files); err != nil {
+		return err
+	}
+
+	if mustGetBool(cmd, "skip-checksums") {
+		return nil
+	}
+
+	var legacyCount, corruptedCount int
+	for _, file := range files {
+		header, err := stateStore.VerifySnapshot(ctx, file)
+		if err != nil {
+			corruptedCount++
+			fmt.Printf("**corrupted file** %s: %s\n", file.Filename, err)
+			continue
+		}
+		if header == nil {
+			legacyCount++
+		}
+	}
+	fmt.Printf("Verified %d kv files (%d legacy files without checksum)\n", len(files)-corruptedCount, legacyCount)
+
+	if corruptedCount > 0 {
+		return fmt.Errorf("%d corrupted kv files found", corruptedCount)
+	}
+	return nil
+}
+
+// checkSnapshotChains reports the full kv files whose chain of incremental
+// snapshots does not reach a checkpoint within the files of the store.
+func checkSnapshotChains(ctx context.Context, stateStore *store2.FullKV, files []*store2.FileInfo) error {
+	complete := map[string]bool{}
+	for _, file := range files {
+		if !file.Partial {
+			complete[file.Filename] = true
+		}
+	}
+
+	bases := map[string]*store2.FileInfo{}
+	var checkpointCount, incrementalCount, brokenCount int
+	for _, file := range files {
+		if file.Partial {
+			continue
+		}
+		base, err := stateStore.SnapshotBase(ctx, file)
+		if err != nil {
+			brokenCount++
+			fmt.Printf("**broken chain** %s: %s\n", file.Filename, err)
+			continue
+		}
+		if base == nil {
+			checkpointCount++
+			continue
+		}
+		incrementalCount++
+		bases[file.Filename] = base
+	}
+
+	for _, file := range files {
+		base, found := bases[file.Filename]
+		for found {
+			if !complete[base.Filename] {
+				brokenCount++
+				fmt.Printf("**broken chain** %s: base %s is missing\n", file.Filename, base.Filename)
+				break
+			}
+			base, found = bases[base.Filename]
+		}
+	}
+	fmt.Printf("Checked %d full kv files (%d checkpoints, %d incremental)\n", checkpointCount+incrementalCount, checkpointCount, incrementalCount)
+
+	if brokenCount > 0 {
+		return fmt.Errorf("%d broken snapshot chains found", brokenCount)
+	}
+	return nil
 }
 
 func newStore(storeURL string) (*store2.FullKV, dstore.Store, error) {
@@ -0,0 +1,437 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/bstream"
+	"github.com/streamingfast/cli"
+	"github.com/streamingfast/dstore"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/streamingfast/substreams/manifest"
+	"github.com/streamingfast/substreams/metrics"
+	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/exec"
+	"github.com/streamingfast/substreams/reqctx"
+	"github.com/streamingfast/substreams/storage/execout"
+	pboutput "github.com/streamingfast/substreams/storage/execout/pb"
+	"github.com/streamingfast/substreams/storage/store"
+	"github.com/streamingfast/substreams/wasm"
+	_ "github.com/streamingfast/substreams/wasm/wazero"
+)
+
+var checkDeterminismCmd = &cobra.Command{
+	Use:   "check-determinism <manifest|spkg_path> <module_name> <substreams_state_store_url> <start_block> <stop_block>",
+	Short: "Executes a module twice on its cached inputs and reports the first divergence",
+	Long: cli.Dedent(`
+		Executes a module on the outputs of its input modules found in the cache, for the blocks in
+		[<start_block>, <stop_block>). Each execution is made twice, on fresh instances of the same runtime or
+		on another runtime with --against, and the outputs and store deltas are compared. The outputs are also
+		compared to the module's own cached outputs, when present.
+
+		Only the cached outputs of modules are replayed: modules reading the chain's blocks directly (rather than
+		'sf.substreams.v1.Clock') cannot be checked. Stores read in 'get' mode, and the module's own store, are
+		loaded from their latest full snapshot before <start_block>, then kept up to date with their cached deltas.
+
+		The wasmtime runtime, which links cgo, is only available in binaries built with '-tags wasmtime'.
+	`),
+	Args: cobra.ExactArgs(5),
+	RunE: checkDeterminismE,
+}
+
+func init() {
+	checkDeterminismCmd.Flags().String("runtime", "wazero", "WASM runtime executing the module")
+	checkDeterminismCmd.Flags().String("against", "", "WASM runtime of the second execution, the same as --runtime if empty")
+	checkDeterminismCmd.Flags().Uint64("state-bundle-size", 1000, "State bundle size of the server that wrote the state store, the boundaries at which the keys of stores with 'ttlBlocks' expire")
+	checkDeterminismCmd.Flags().StringArrayP("params", "p", nil, "Set a params for parameterizable modules. Can be specified multiple times. Ex: -p module1=valA -p module2=valX&valY")
+
+	Cmd.AddCommand(checkDeterminismCmd)
+}
+
+func checkDeterminismE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	manifestPath, moduleName, stateStoreURL := args[0], args[1], args[2]
+	startBlock, err := strconv.ParseUint(args[3], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid start block %q: %w", args[3], err)
+	}
+	stopBlock, err := strconv.ParseUint(args[4], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid stop block %q: %w", args[4], err)
+	}
+
+	manifestReader, err := manifest.NewReader(manifestPath)
+	if err != nil {
+		return fmt.Errorf("manifest reader: %w", err)
+	}
+	pkg, err := manifestReader.Read()
+	if err != nil {
+		return fmt.Errorf("read manifest %q: %w", manifestPath, err)
+	}
+	if err := manifest.ApplyParams(mustGetStringArray(cmd, "params"), pkg); err != nil {
+		return fmt.Errorf("apply params: %w", err)
+	}
+
+	stateStore, err := dstore.NewStore(stateStoreURL, "zst", "zstd", false)
+	if err != nil {
+		return fmt.Errorf("creating state store: %w", err)
+	}
+
+	replay, err := newCachedReplay(pkg, stateStore, mustGetUint64(cmd, "state-bundle-size"))
+	if err != nil {
+		return err
+	}
+	module, err := replay.graph.Module(moduleName)
+	if err != nil {
+		return fmt.Errorf("module %q: %w", moduleName, err)
+	}
+	if startBlock < module.InitialBlock {
+		startBlock = module.InitialBlock
+	}
+	if startBlock >= stopBlock {
+		return fmt.Errorf("nothing to check, module %q starts at block %d", moduleName, module.InitialBlock)
+	}
+
+	stats := metrics.NewReqStats(&metrics.Config{}, zlog)
+	ctx = reqctx.WithReqStats(reqctx.WithLogger(ctx, zlog), stats)
+
+	runtime, against := mustGetString(cmd, "runtime"), mustGetString(cmd, "against")
+	for _, name := range []string{runtime, against} {
+		if name == "" {
+			continue
+		}
+		if err := wasm.ValidateRuntime(name); err != nil {
+			return fmt.Errorf("%w, the wasmtime runtime requires building with '-tags wasmtime'", err)
+		}
+	}
+	registry := wasm.NewRegistryWithRuntime(runtime, nil, 0, wasm.WithDeterminismCheck(against))
+	executor, err := replay.newExecutor(ctx, registry, module, startBlock, stopBlock)
+	if err != nil {
+		return err
+	}
+	defer executor.Close(ctx)
+
+	blocks := replay.blocks(module)
+	if len(blocks) == 0 {
+		return fmt.Errorf("no cached inputs found for module %q in [%d, %d)", moduleName, startBlock, stopBlock)
+	}
+
+	expected := replay.outputs[module.Name]
+	var checked, matchedCache int
+	for _, blockNum := range blocks {
+		replay.setBlock(blockNum)
+		if err := replay.applyInputStoreDeltas(blockNum); err != nil {
+			return err
+		}
+
+		_, output, err := exec.RunModule(ctx, executor, replay)
+		if err != nil {
+			var determinismErr *wasm.DeterminismError
+			if errors.As(err, &determinismErr) {
+				fmt.Printf("Divergence found after %d deterministic executions:\n  %s\n", checked, determinismErr)
+				return fmt.Errorf("module %q is not deterministic", moduleName)
+			}
+			return fmt.Errorf("executing module %q at block %d: %w", moduleName, blockNum, err)
+		}
+		checked++
+
+		if cached, found := expected[blockNum]; found {
+			if !bytes.Equal(cached.Payload, output) {
+				fmt.Printf("Output differs from the cached output at block %d (%d bytes != %d cached bytes)\n", blockNum, len(output), len(cached.Payload))
+				return fmt.Errorf("module %q output does not match the cache", moduleName)
+			}
+			matchedCache++
+		}
+	}
+
+	fmt.Printf("Module %q executed deterministically on %d blocks in [%d, %d), %d outputs matched the cache\n", moduleName, checked, startBlock, stopBlock, matchedCache)
+	return nil
+}
+
+// cachedReplay feeds a module with the outputs of its input modules read
+// from the cache, acting as the execout.ExecutionOutputGetter of its
+// executions.
+type cachedReplay struct {
+	pkg        *pbsubstreams.Package
+	graph      *manifest.ModuleGraph
+	hashes     *manifest.ModuleHashes
+	stateStore dstore.Store
+	bundleSize uint64
+
+	outputs     map[string]map[uint64]*pboutput.Item // by module name, then block number
+	inputStores map[string]store.DeltaAccessor
+	clocks      map[uint64]*pboutput.Item // the input items giving the clock of each block
+	clock       *pbsubstreams.Clock
+}
+
+var _ execout.ExecutionOutputGetter = (*cachedReplay)(nil)
+
+func newCachedReplay(pkg *pbsubstreams.Package, stateStore dstore.Store, bundleSize uint64) (*cachedReplay, error) {
+	graph, err := manifest.NewModuleGraph(pkg.Modules.Modules)
+	if err != nil {
+		return nil, fmt.Errorf("creating module graph: %w", err)
+	}
+	return &cachedReplay{
+		pkg:         pkg,
+		graph:       graph,
+		hashes:      manifest.NewModuleHashes(),
+		stateStore:  stateStore,
+		bundleSize:  bundleSize,
+		outputs:     make(map[string]map[uint64]*pboutput.Item),
+		inputStores: make(map[string]store.DeltaAccessor),
+	}, nil
+}
+
+func (r *cachedReplay) Clock() *pbsubstreams.Clock { return r.clock }
+
+func (r *cachedReplay) Get(name string) ([]byte, bool, error) {
+	if name == wasm.ClockType {
+		data, err := proto.Marshal(r.clock)
+		return data, false, err
+	}
+	item, found := r.outputs[name][r.clock.Number]
+	if !found {
+		return nil, false, execout.NotFound
+	}
+	return item.Payload, true, nil
+}
+
+func (r *cachedReplay) moduleHash(module *pbsubstreams.Module) (string, error) {
+	hash, err := r.hashes.HashModule(r.pkg.Modules, module, r.graph)
+	if err != nil {
+		return "", fmt.Errorf("hashing module %q: %w", module.Name, err)
+	}
+	return hex.EncodeToString(hash), nil
+}
+
+// newExecutor loads the inputs of `module` for [startBlock, stopBlock), the
+// stores it needs at `startBlock`, and returns its executor.
+func (r *cachedReplay) newExecutor(ctx context.Context, registry *wasm.Registry, module *pbsubstreams.Module, startBlock, stopBlock uint64) (exec.ModuleExecutor, error) {
+	var inputs []wasm.Argument
+	for _, input := range module.Inputs {
+		switch in := input.Input.(type) {
+		case *pbsubstreams.Module_Input_Params_:
+			inputs = append(inputs, wasm.NewParamsInput(in.Params.Value))
+		case *pbsubstreams.Module_Input_Source_:
+			if in.Source.Type != wasm.ClockType {
+				return nil, fmt.Errorf("module %q reads source %q, which is not cached", module.Name, in.Source.Type)
+			}
+			inputs = append(inputs, wasm.NewSourceInput(in.Source.Type))
+		case *pbsubstreams.Module_Input_Map_:
+			if err := r.loadOutputs(ctx, in.Map.ModuleName, startBlock, stopBlock); err != nil {
+				return nil, err
+			}
+			inputs = append(inputs, wasm.NewMapInput(in.Map.ModuleName))
+		case *pbsubstreams.Module_Input_Store_:
+			if err := r.loadOutputs(ctx, in.Store.ModuleName, startBlock, stopBlock); err != nil {
+				return nil, err
+			}
+			if in.Store.Mode == pbsubstreams.Module_Input_Store_DELTAS {
+				inputs = append(inputs, wasm.NewMapInput(in.Store.ModuleName))
+				continue
+			}
+			inputStore, err := r.loadStore(ctx, in.Store.ModuleName, startBlock)
+			if err != nil {
+				return nil, err
+			}
+			r.inputStores[in.Store.ModuleName] = inputStore
+			inputs = append(inputs, wasm.NewStoreReaderInput(in.Store.ModuleName, inputStore))
+		default:
+			return nil, fmt.Errorf("invalid input struct for module %q", module.Name)
+		}
+	}
+	if err := r.loadOutputs(ctx, module.Name, startBlock, stopBlock); err != nil {
+		return nil, err
+	}
+
+	code := r.pkg.Modules.Binaries[module.BinaryIndex]
+	wasmModule, err := registry.NewModuleWithBinaryType(ctx, code.Content, code.Type)
+	if err != nil {
+		return nil, fmt.Errorf("new wasm module: %w", err)
+	}
+	tracer := otel.GetTracerProvider().Tracer("executor")
+
+	switch kind := module.Kind.(type) {
+	case *pbsubstreams.Module_KindMap_:
+		baseExecutor := exec.NewBaseExecutor(ctx, module.Name, wasmModule, false, inputs, module.BinaryEntrypoint, tracer)
+		return exec.NewMapperModuleExecutor(baseExecutor, strings.TrimPrefix(module.Output.Type, "proto:")), nil
+	case *pbsubstreams.Module_KindStore_:
+		outputStore, err := r.loadStore(ctx, module.Name, startBlock)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, wasm.NewStoreWriterOutput(module.Name, outputStore, kind.KindStore.UpdatePolicy, kind.KindStore.ValueType))
+		baseExecutor := exec.NewBaseExecutor(ctx, module.Name, wasmModule, false, inputs, module.BinaryEntrypoint, tracer)
+		return exec.NewStoreModuleExecutor(baseExecutor, outputStore), nil
+	default:
+		return nil, fmt.Errorf("invalid kind %q for module %q", module.Kind, module.Name)
+	}
+}
+
+// loadOutputs reads the cached outputs of `moduleName` from the output files
+// overlapping [startBlock, stopBlock). It is a no-op for outputs already loaded.
+func (r *cachedReplay) loadOutputs(ctx context.Context, moduleName string, startBlock, stopBlock uint64) error {
+	if _, found := r.outputs[moduleName]; found {
+		return nil
+	}
+	items, err := r.readOutputs(ctx, moduleName, startBlock, stopBlock)
+	if err != nil {
+		return err
+	}
+	r.outputs[moduleName] = items
+	return nil
+}
+
+func (r *cachedReplay) readOutputs(ctx context.Context, moduleName string, startBlock, stopBlock uint64) (map[uint64]*pboutput.Item, error) {
+	module, err := r.graph.Module(moduleName)
+	if err != nil {
+		return nil, fmt.Errorf("module %q: %w", moduleName, err)
+	}
+	hash, err := r.moduleHash(module)
+	if err != nil {
+		return nil, err
+	}
+	config, err := execout.NewConfig(module.Name, module.InitialBlock, module.ModuleKind(), hash, r.stateStore, zlog)
+	if err != nil {
+		return nil, fmt.Errorf("module %q output config: %w", moduleName, err)
+	}
+
+	files, err := config.ListSnapshotFiles(ctx, bstream.NewOpenRange(0))
+	if err != nil {
+		return nil, fmt.Errorf("listing module %q outputs: %w", moduleName, err)
+	}
+
+	items := make(map[uint64]*pboutput.Item)
+	for _, fileInfo := range files {
+		if fileInfo.BlockRange.ExclusiveEndBlock <= startBlock || fileInfo.BlockRange.StartBlock >= stopBlock {
+			continue
+		}
+		file := config.NewFile(fileInfo.BlockRange)
+		if err := file.Load(ctx); err != nil {
+			return nil, fmt.Errorf("loading module %q outputs %s: %w", moduleName, fileInfo.Filename, err)
+		}
+		for _, item := range file.SortedItems() {
+			if item.BlockNum >= startBlock && item.BlockNum < stopBlock {
+				items[item.BlockNum] = item
+			}
+		}
+	}
+	zlog.Info("loaded cached outputs", zap.String("module", moduleName), zap.Int("block_count", len(items)))
+	return items, nil
+}
+
+// loadStore returns the store `moduleName` as it was before `startBlock`,
+// from its latest full snapshot and the cached deltas following it.
+func (r *cachedReplay) loadStore(ctx context.Context, moduleName string, startBlock uint64) (*store.FullKV, error) {
+	module, err := r.graph.Module(moduleName)
+	if err != nil {
+		return nil, fmt.Errorf("module %q: %w", moduleName, err)
+	}
+	hash, err := r.moduleHash(module)
+	if err != nil {
+		return nil, err
+	}
+	config, err := store.NewConfig(module.Name, module.InitialBlock, hash, module.GetKindStore().UpdatePolicy, module.GetKindStore().ValueType, r.stateStore, "")
+	if err != nil {
+		return nil, fmt.Errorf("store %q config: %w", moduleName, err)
+	}
+	if err := config.SetTTL(module.GetKindStore().TtlBlocks, r.bundleSize); err != nil {
+		return nil, fmt.Errorf("store %q config: %w", moduleName, err)
+	}
+	if startBlock <= module.InitialBlock {
+		return config.NewFullKV(zlog), nil
+	}
+
+	outputs, err := execout.NewConfig(module.Name, module.InitialBlock, module.ModuleKind(), hash, r.stateStore, zlog)
+	if err != nil {
+		return nil, fmt.Errorf("store %q output config: %w", moduleName, err)
+	}
+	fullKV, err := config.LoadAtBlock(ctx, outputs, startBlock-1, zlog)
+	if err != nil {
+		return nil, err
+	}
+	zlog.Info("loaded store", zap.String("module", moduleName), zap.Uint64("start_block", startBlock), zap.Uint64("length", fullKV.Length()))
+	return fullKV, nil
+}
+
+// blocks returns the blocks at which the inputs of `module` have cached
+// outputs, in order.
+func (r *cachedReplay) blocks(module *pbsubstreams.Module) []uint64 {
+	seen := make(map[uint64]*pboutput.Item)
+	for _, input := range module.Inputs {
+		var name string
+		switch in := input.Input.(type) {
+		case *pbsubstreams.Module_Input_Map_:
+			name = in.Map.ModuleName
+		case *pbsubstreams.Module_Input_Store_:
+			name = in.Store.ModuleName
+		default:
+			continue
+		}
+		for blockNum, item := range r.outputs[name] {
+			seen[blockNum] = item
+		}
+	}
+	r.clocks = seen
+	return sortedBlocks(seen)
+}
+
+func (r *cachedReplay) setBlock(blockNum uint64) {
+	item := r.clocks[blockNum]
+	r.clock = &pbsubstreams.Clock{
+		Id:        item.BlockId,
+		Number:    item.BlockNum,
+		Timestamp: item.Timestamp,
+	}
+}
+
+// applyInputStoreDeltas brings the stores read in `get` mode to their state
+// at the end of `blockNum`, as the module sees them.
+func (r *cachedReplay) applyInputStoreDeltas(blockNum uint64) error {
+	for name, inputStore := range r.inputStores {
+		if item, found := r.outputs[name][blockNum]; found {
+			if err := applyCachedDeltas(inputStore, item); err != nil {
+				return fmt.Errorf("store %q at block %d: %w", name, blockNum, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyCachedDeltas applies the deltas of `item` to `s`, then reaches its
+// bundle boundary to evict its expired keys, as when running the module.
+func applyCachedDeltas(s store.DeltaAccessor, item *pboutput.Item) error {
+	deltas := &pbssinternal.StoreDeltas{}
+	if err := proto.Unmarshal(item.Payload, deltas); err != nil {
+		return fmt.Errorf("unmarshalling deltas: %w", err)
+	}
+	expirable, isExpirable := s.(store.Expirable)
+	if isExpirable {
+		expirable.SetBlock(item.BlockNum)
+	}
+	s.SetDeltas(deltas.StoreDeltas)
+	if isExpirable {
+		expirable.EvictExpiredKeys()
+	}
+	return nil
+}
+
+func sortedBlocks(items map[uint64]*pboutput.Item) []uint64 {
+	blocks := make([]uint64, 0, len(items))
+	for blockNum := range items {
+		blocks = append(blocks, blockNum)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+	return blocks
+}
@@ -0,0 +1,7 @@
+//go:build wasmtime
+
+package tools
+
+// The wasmtime runtime links cgo, it is only registered for the
+// determinism check of binaries built with `-tags wasmtime`.
+import _ "github.com/streamingfast/substreams/wasm/wasmtime"
@@ -1,19 +1,31 @@
 package tools
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
+	"sort"
 
 	"github.com/abourget/llerrgroup"
 	"github.com/spf13/cobra"
+	"github.com/streamingfast/cli"
 	"go.uber.org/zap"
+
+	store2 "github.com/streamingfast/substreams/storage/store"
 )
 
 var cleanUpCmd = &cobra.Command{
 	Use:   "cleanup <store_url>",
 	Short: "Checks for partial files which have already merged into a full kv store and purges them",
-	Args:  cobra.ExactArgs(1),
-	RunE:  cleanUpE,
+	Long: cli.Dedent(`
+		Deletes the partial kv files ending before the highest full kv file of the
+		store. When the full kv files are incremental snapshots, the highest one
+		whose chain reaches a checkpoint is used. Full kv files are never deleted,
+		as they may be the base of an incremental snapshot.
+	`),
+	Args: cobra.ExactArgs(1),
+	RunE: cleanUpE,
 }
 
 func init() {
@@ -30,8 +42,8 @@ func cleanUpE(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("creating store: %w", err)
 	}
 
-	highestKVBlock := uint64(0)
 	partialFiles := map[uint64]string{}
+	var completeFiles []*store2.FileInfo
 
 	files, err := store.ListSnapshotFiles(ctx, math.MaxUint64)
 	if err != nil {
@@ -41,12 +53,14 @@ func cleanUpE(cmd *cobra.Command, args []string) error {
 	for _, file := range files {
 		if file.Partial {
 			partialFiles[file.Range.ExclusiveEndBlock] = file.Filename
+		} else {
+			completeFiles = append(completeFiles, file)
 		}
+	}
 
-		if !file.Partial && file.Range.ExclusiveEndBlock > highestKVBlock {
-			highestKVBlock = file.Range.ExclusiveEndBlock
-			return nil
-		}
+	highestKVBlock, err := highestLoadableKVBlock(ctx, store, completeFiles)
+	if err != nil {
+		return err
 	}
 
 	if len(partialFiles) == 0 {
@@ -85,3 +99,24 @@ func cleanUpE(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// highestLoadableKVBlock returns the end block of the highest of `files`
+// whose chain of incremental snapshots reaches a checkpoint, 0 if none.
+func highestLoadableKVBlock(ctx context.Context, store *store2.FullKV, files []*store2.FileInfo) (uint64, error) {
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Range.ExclusiveEndBlock > files[j].Range.ExclusiveEndBlock
+	})
+
+	for _, file := range files {
+		_, err := store.SnapshotChain(ctx, file)
+		if errors.Is(err, store2.ErrBrokenSnapshotChain) {
+			zlog.Warn("skipping full kv file with a broken snapshot chain", zap.String("filename", file.Filename), zap.Error(err))
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("resolving snapshot chain of %s: %w", file.Filename, err)
+		}
+		return file.Range.ExclusiveEndBlock, nil
+	}
+	return 0, nil
+}
@@ -261,7 +261,7 @@ func searchOutputsModule(
 	rng := block.NewRange(startBlock, startBlock-startBlock%saveInterval+saveInterval)
 	outputCache := modStore.NewFile(rng)
 	zlog.Info("loading block from store", zap.Uint64("start_block", startBlock), zap.Uint64("block_num", blockNumber))
-	if err := outputCache.Load(ctx); err != nil {
+	if err := outputCache.LoadRange(ctx, blockNumber, blockNumber+1); err != nil {
 		if err == dstore.ErrNotFound {
 			return fmt.Errorf("can't find cache at block %d storeURL %q", blockNumber, moduleStore.BaseURL().String())
 		}
@@ -0,0 +1,170 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/dstore"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/streamingfast/substreams/manifest"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/storage/store"
+)
+
+var storeCmd = &cobra.Command{
+	Use:          "store",
+	Short:        "Inspect the stores of a Substreams package in a state store",
+	SilenceUsage: true,
+}
+
+func init() {
+	storeCmd.PersistentFlags().Uint64("state-bundle-size", 1000, "State bundle size of the server that wrote the state store, the boundaries at which the keys of stores with 'ttlBlocks' expire")
+
+	Cmd.AddCommand(storeCmd)
+}
+
+// storeModule is a store module of a package, with what is needed to read
+// its snapshots and cached outputs from the state store.
+type storeModule struct {
+	pkg        *pbsubstreams.Package
+	module     *pbsubstreams.Module
+	hash       string
+	stateStore dstore.Store
+	config     *store.Config
+}
+
+func newStoreModule(manifestPath, moduleName, stateStoreURL string, stateBundleSize uint64) (*storeModule, error) {
+	manifestReader, err := manifest.NewReader(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("manifest reader: %w", err)
+	}
+	pkg, err := manifestReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read manifest %q: %w", manifestPath, err)
+	}
+
+	graph, err := manifest.NewModuleGraph(pkg.Modules.Modules)
+	if err != nil {
+		return nil, fmt.Errorf("creating module graph: %w", err)
+	}
+	module, err := graph.Module(moduleName)
+	if err != nil {
+		return nil, fmt.Errorf("module %q: %w", moduleName, err)
+	}
+	if module.GetKindStore() == nil {
+		return nil, fmt.Errorf("module %q is not a store", moduleName)
+	}
+	hash, err := manifest.NewModuleHashes().HashModule(pkg.Modules, module, graph)
+	if err != nil {
+		return nil, fmt.Errorf("hashing module %q: %w", moduleName, err)
+	}
+	moduleHash := hex.EncodeToString(hash)
+
+	stateStore, err := dstore.NewStore(stateStoreURL, "zst", "zstd", false)
+	if err != nil {
+		return nil, fmt.Errorf("creating state store: %w", err)
+	}
+
+	config, err := store.NewConfig(module.Name, module.InitialBlock, moduleHash, module.GetKindStore().UpdatePolicy, module.GetKindStore().ValueType, stateStore, "")
+	if err != nil {
+		return nil, fmt.Errorf("store %q config: %w", moduleName, err)
+	}
+	if err := config.SetTTL(module.GetKindStore().TtlBlocks, stateBundleSize); err != nil {
+		return nil, fmt.Errorf("store %q config: %w", moduleName, err)
+	}
+
+	return &storeModule{
+		pkg:        pkg,
+		module:     module,
+		hash:       moduleHash,
+		stateStore: stateStore,
+		config:     config,
+	}, nil
+}
+
+// latestSnapshot returns the latest full snapshot of the store, nil when
+// there is none.
+func (m *storeModule) latestSnapshot(ctx context.Context) (*store.FileInfo, error) {
+	files, err := m.config.ListSnapshotFiles(ctx, ^uint64(0))
+	if err != nil {
+		return nil, fmt.Errorf("listing store %q snapshots: %w", m.module.Name, err)
+	}
+
+	var snapshot *store.FileInfo
+	for _, file := range files {
+		if file.Partial {
+			continue
+		}
+		if snapshot == nil || file.Range.ExclusiveEndBlock > snapshot.Range.ExclusiveEndBlock {
+			snapshot = file
+		}
+	}
+	return snapshot, nil
+}
+
+// storeValueDecoder decodes the values of a store according to its value
+// type: `proto:` values to their JSON representation, `bytes` values to
+// base64 and the other types, kept as strings in the stores, as is.
+type storeValueDecoder struct {
+	valueType string
+	msgDesc   *desc.MessageDescriptor
+}
+
+func newStoreValueDecoder(module *pbsubstreams.Module, protoFiles []*descriptorpb.FileDescriptorProto) (*storeValueDecoder, error) {
+	d := &storeValueDecoder{valueType: module.GetKindStore().ValueType}
+	if !strings.HasPrefix(d.valueType, "proto:") {
+		return d, nil
+	}
+
+	fileDescriptors, err := desc.CreateFileDescriptors(protoFiles)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find file descriptors: %w", err)
+	}
+	messageType := strings.TrimPrefix(d.valueType, "proto:")
+	for _, file := range fileDescriptors {
+		if d.msgDesc = file.FindMessage(messageType); d.msgDesc != nil {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("message type %q of store %q not found in the package protobuf definitions", messageType, module.Name)
+}
+
+// decode returns the text of `value`, which is a JSON document when isJSON.
+func (d *storeValueDecoder) decode(value []byte) (text string, isJSON bool, err error) {
+	switch {
+	case d.msgDesc != nil:
+		msg := dynamic.NewMessageFactoryWithDefaults().NewDynamicMessage(d.msgDesc)
+		if err := msg.Unmarshal(value); err != nil {
+			return "", false, fmt.Errorf("unmarshalling %s: %w", d.msgDesc.GetFullyQualifiedName(), err)
+		}
+		data, err := msg.MarshalJSON()
+		if err != nil {
+			return "", false, fmt.Errorf("marshalling json: %w", err)
+		}
+		return string(data), true, nil
+	case d.valueType == "bytes":
+		return base64.StdEncoding.EncodeToString(value), false, nil
+	}
+	return string(value), false, nil
+}
+
+// decodeJSON returns `value` decoded as a JSON value, a string unless its
+// value type is a protobuf message.
+func (d *storeValueDecoder) decodeJSON(value []byte) (json.RawMessage, error) {
+	text, isJSON, err := d.decode(value)
+	if err != nil {
+		return nil, err
+	}
+	if isJSON {
+		return json.RawMessage(text), nil
+	}
+	return json.Marshal(text)
+}
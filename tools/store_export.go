@@ -0,0 +1,221 @@
+package tools
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/cli"
+	"github.com/xitongsys/parquet-go/writer"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/storage/execout"
+	"github.com/streamingfast/substreams/storage/store"
+)
+
+var storeExportCmd = &cobra.Command{
+	Use:   "export <manifest> <store> <store_url>",
+	Short: "Export the keys and values of a store at a block, as JSONL, CSV or Parquet",
+	Long: cli.Dedent(`
+		Exports the keys and values of a store, in key order, as it was at the end of --block
+		or else at its latest full snapshot. At --block, the store is loaded from its latest
+		full snapshot at or before the block, and the deltas of the following blocks are
+		applied from the outputs of the store module in the cache, which must cover these
+		blocks, like 'substreams tools store query' does.
+
+		Values of 'proto:' stores are decoded to JSON with the protobuf definitions of the
+		package, 'bytes' values are encoded in base64, and the other values are written as is.
+
+		Each row holds the 'key' and its 'value': in JSONL, the value of a 'proto:' store is
+		a JSON object, in CSV and Parquet it is its JSON text.
+	`),
+	Example: string(cli.ExamplePrefixed("substreams tools store export", `
+		substreams.yaml store_pools gs://[bucket-url-path] --block 12500000 > pools.jsonl
+		uniswap-v3.spkg store_pools gs://[bucket-url-path] --format parquet --prefix pool: --output pools.parquet
+	`)),
+	Args: cobra.ExactArgs(3),
+	RunE: storeExportE,
+}
+
+func init() {
+	storeExportCmd.Flags().Uint64("block", 0, "Export the store as it was at the end of this block, the latest full snapshot if 0")
+	storeExportCmd.Flags().String("format", "jsonl", "Output format, one of 'jsonl', 'csv' or 'parquet'")
+	storeExportCmd.Flags().String("prefix", "", "Only export the keys starting with this prefix")
+	storeExportCmd.Flags().StringP("output", "o", "", "Write to this file instead of the standard output")
+
+	storeCmd.AddCommand(storeExportCmd)
+}
+
+func storeExportE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	blockNum := mustGetUint64(cmd, "block")
+	prefix := mustGetString(cmd, "prefix")
+
+	m, err := newStoreModule(args[0], args[1], args[2], mustGetUint64(cmd, "state-bundle-size"))
+	if err != nil {
+		return err
+	}
+	decoder, err := newStoreValueDecoder(m.module, m.pkg.ProtoFiles)
+	if err != nil {
+		return err
+	}
+
+	var s *store.FullKV
+	if blockNum != 0 {
+		outputs, err := execout.NewConfig(m.module.Name, m.module.InitialBlock, m.module.ModuleKind(), m.hash, m.stateStore, zlog)
+		if err != nil {
+			return fmt.Errorf("store %q output config: %w", m.module.Name, err)
+		}
+		if s, err = m.config.LoadAtBlock(ctx, outputs, blockNum, zlog); err != nil {
+			return err
+		}
+	} else {
+		snapshot, err := m.latestSnapshot(ctx)
+		if err != nil {
+			return err
+		}
+		if snapshot == nil {
+			return fmt.Errorf("no full snapshot of store %q found", m.module.Name)
+		}
+		s = m.config.NewFullKV(zlog)
+		if err := s.Load(ctx, snapshot); err != nil {
+			return fmt.Errorf("loading store %q snapshot %s: %w", m.module.Name, snapshot.Filename, err)
+		}
+		zlog.Info("loaded store snapshot", zap.String("store", m.module.Name), zap.String("file", snapshot.Filename), zap.Uint64("size_bytes", s.SizeBytes()))
+		blockNum = snapshot.Range.ExclusiveEndBlock - 1
+	}
+
+	var out io.Writer = cmd.OutOrStdout()
+	var file *os.File
+	if path := mustGetString(cmd, "output"); path != "" {
+		if file, err = os.Create(path); err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		// Only closes the file on the errors, it is closed below otherwise.
+		defer func() {
+			if file != nil {
+				file.Close()
+			}
+		}()
+		out = file
+	}
+
+	rows, err := newStoreRowWriter(mustGetString(cmd, "format"), out, decoder)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	s.ScanPrefix(prefix, "", 0, func(key string, value []byte) {
+		if err != nil {
+			return
+		}
+		if err = rows.write(key, value); err != nil {
+			err = fmt.Errorf("exporting key %q: %w", key, err)
+			return
+		}
+		count++
+	})
+	if err != nil {
+		return err
+	}
+	if err := rows.close(); err != nil {
+		return fmt.Errorf("closing %s output: %w", mustGetString(cmd, "format"), err)
+	}
+	if file != nil {
+		f := file
+		file = nil
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("closing output file: %w", err)
+		}
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "Exported %d keys of store %q at the end of block %d\n", count, m.module.Name, blockNum)
+	return nil
+}
+
+type storeRowWriter interface {
+	write(key string, value []byte) error
+	close() error
+}
+
+func newStoreRowWriter(format string, out io.Writer, decoder *storeValueDecoder) (storeRowWriter, error) {
+	switch format {
+	case "jsonl":
+		return &jsonlStoreRowWriter{encoder: json.NewEncoder(out), decoder: decoder}, nil
+	case "csv":
+		w := csv.NewWriter(out)
+		if err := w.Write([]string{"key", "value"}); err != nil {
+			return nil, err
+		}
+		return &csvStoreRowWriter{writer: w, decoder: decoder}, nil
+	case "parquet":
+		w, err := writer.NewParquetWriterFromWriter(out, new(parquetStoreRow), 1)
+		if err != nil {
+			return nil, fmt.Errorf("creating parquet writer: %w", err)
+		}
+		return &parquetStoreRowWriter{writer: w, decoder: decoder}, nil
+	}
+	return nil, fmt.Errorf("invalid format %q, must be one of 'jsonl', 'csv' or 'parquet'", format)
+}
+
+type jsonlStoreRowWriter struct {
+	encoder *json.Encoder
+	decoder *storeValueDecoder
+}
+
+func (w *jsonlStoreRowWriter) write(key string, value []byte) error {
+	decoded, err := w.decoder.decodeJSON(value)
+	if err != nil {
+		return err
+	}
+	return w.encoder.Encode(struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	}{key, decoded})
+}
+
+func (w *jsonlStoreRowWriter) close() error { return nil }
+
+type csvStoreRowWriter struct {
+	writer  *csv.Writer
+	decoder *storeValueDecoder
+}
+
+func (w *csvStoreRowWriter) write(key string, value []byte) error {
+	decoded, _, err := w.decoder.decode(value)
+	if err != nil {
+		return err
+	}
+	return w.writer.Write([]string{key, decoded})
+}
+
+func (w *csvStoreRowWriter) close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type parquetStoreRow struct {
+	Key   string `parquet:"name=key, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Value string `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type parquetStoreRowWriter struct {
+	writer  *writer.ParquetWriter
+	decoder *storeValueDecoder
+}
+
+func (w *parquetStoreRowWriter) write(key string, value []byte) error {
+	decoded, _, err := w.decoder.decode(value)
+	if err != nil {
+		return err
+	}
+	return w.writer.Write(parquetStoreRow{Key: key, Value: decoded})
+}
+
+func (w *parquetStoreRowWriter) close() error {
+	return w.writer.WriteStop()
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/cli"
+
+	"github.com/streamingfast/substreams/storage/execout"
+)
+
+var storeQueryCmd = &cobra.Command{
+	Use:   "query <manifest> <store> <store_url> <block> [<key>]",
+	Short: "Print the values of keys of a store at the end of any block",
+	Long: cli.Dedent(`
+		Prints the value of <key>, or of the keys starting with --prefix, as the store was at
+		the end of <block>. The store is loaded from its latest full snapshot at or before the
+		block, and the deltas of the following blocks are applied from the outputs of the store
+		module in the cache, which must cover these blocks.
+
+		Each key is printed as a JSON line holding the 'key' and its 'value', decoded like
+		'substreams tools store export' does.
+	`),
+	Example: string(cli.ExamplePrefixed("substreams tools store query", `
+		substreams.yaml store_pools gs://[bucket-url-path] 17123456 pool:c772a65917d5da983b7fc3c9cfbfb53ef01aef7e
+		uniswap-v3.spkg store_pools gs://[bucket-url-path] 17123456 --prefix pool:
+	`)),
+	Args: cobra.RangeArgs(4, 5),
+	RunE: storeQueryE,
+}
+
+func init() {
+	storeQueryCmd.Flags().String("prefix", "", "Print the keys starting with this prefix, instead of a single <key>")
+
+	storeCmd.AddCommand(storeQueryCmd)
+}
+
+func storeQueryE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	blockNum, err := strconv.ParseUint(args[3], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid block %q: %w", args[3], err)
+	}
+	prefix := mustGetString(cmd, "prefix")
+	if (len(args) == 5) == (prefix != "") {
+		return fmt.Errorf("either a <key> or a --prefix is required")
+	}
+
+	m, err := newStoreModule(args[0], args[1], args[2], mustGetUint64(cmd, "state-bundle-size"))
+	if err != nil {
+		return err
+	}
+	decoder, err := newStoreValueDecoder(m.module, m.pkg.ProtoFiles)
+	if err != nil {
+		return err
+	}
+
+	outputs, err := execout.NewConfig(m.module.Name, m.module.InitialBlock, m.module.ModuleKind(), m.hash, m.stateStore, zlog)
+	if err != nil {
+		return fmt.Errorf("store %q output config: %w", m.module.Name, err)
+	}
+	s, err := m.config.LoadAtBlock(ctx, outputs, blockNum, zlog)
+	if err != nil {
+		return err
+	}
+
+	rows := &jsonlStoreRowWriter{encoder: json.NewEncoder(cmd.OutOrStdout()), decoder: decoder}
+	if len(args) == 5 {
+		key := args[4]
+		value, found := s.GetLast(key)
+		if !found {
+			return fmt.Errorf("key %q not found in store %q at block %d", key, m.module.Name, blockNum)
+		}
+		return rows.write(key, value)
+	}
+
+	var count int
+	s.ScanPrefix(prefix, "", 0, func(key string, value []byte) {
+		if err != nil {
+			return
+		}
+		if err = rows.write(key, value); err != nil {
+			err = fmt.Errorf("printing key %q: %w", key, err)
+			return
+		}
+		count++
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Found %d keys with prefix %q in store %q at block %d\n", count, prefix, m.module.Name, blockNum)
+	return nil
+}
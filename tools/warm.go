@@ -0,0 +1,312 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/cli"
+	"github.com/streamingfast/dauth"
+	"github.com/streamingfast/dstore"
+	tracing "github.com/streamingfast/sf-tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	ttrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams"
+	"github.com/streamingfast/substreams/block"
+	"github.com/streamingfast/substreams/client"
+	"github.com/streamingfast/substreams/manifest"
+	"github.com/streamingfast/substreams/metrics"
+	"github.com/streamingfast/substreams/orchestrator"
+	"github.com/streamingfast/substreams/orchestrator/plan"
+	"github.com/streamingfast/substreams/orchestrator/work"
+	pbsubstreamsrpc "github.com/streamingfast/substreams/pb/sf/substreams/rpc/v2"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/pipeline/outputmodules"
+	"github.com/streamingfast/substreams/reqctx"
+	"github.com/streamingfast/substreams/service"
+	"github.com/streamingfast/substreams/service/config"
+	"github.com/streamingfast/substreams/storage/execout"
+	"github.com/streamingfast/substreams/storage/store"
+	"github.com/streamingfast/substreams/wasm"
+)
+
+var warmCmd = &cobra.Command{
+	Use:   "warm <manifest> <module>",
+	Short: "Fill the state and output caches of a module over a range of blocks, without streaming its outputs",
+	Long: cli.Dedent(`
+		Schedules the tier2 jobs that a production mode request of <module> over --range would
+		schedule, and merges their partial stores, like tier1 does, but streams nothing and exits
+		once the outputs of <module> over the range are written, with the full stores needed to
+		produce them, up to the last segment boundary of the range.
+
+		The jobs are sent to the tier2 --substreams-endpoint, which must share the cache given
+		by --state-store and --cache-tag, or run in-process, reading the blocks from
+		--merged-blocks-store. The stores and outputs already in the cache are not
+		processed again, and interrupting the command then running it again with the same
+		arguments resumes it, reusing the partial stores written by the completed jobs.
+	`),
+	Example: string(cli.ExamplePrefixed("substreams tools warm", `
+		uniswap-v3.spkg map_pools_created --range 12369621:17000000 --state-store gs://[bucket-url-path] -e tier2.internal:9000 --plaintext
+		uniswap-v3.spkg map_pools_created --range 12369621:17000000 --state-store gs://[bucket-url-path] --merged-blocks-store gs://[merged-blocks-path] --parallel-jobs 4
+	`)),
+	Args: cobra.ExactArgs(2),
+	RunE: warmE,
+}
+
+func init() {
+	warmCmd.Flags().String("range", "", "Range of blocks to warm, as <start>:<stop> with <stop> exclusive")
+	warmCmd.Flags().String("state-store", "", "Base URL of the cache of the Substreams servers (their state store)")
+	warmCmd.Flags().String("cache-tag", "default", "Cache tag of the cache to fill, under the --state-store")
+	warmCmd.Flags().Uint64("state-bundle-size", 1000, "Interval in blocks of the segments of the jobs, must match the state bundle size of the servers")
+	warmCmd.Flags().Uint64("parallel-jobs", 10, "Number of tier2 jobs run concurrently")
+	warmCmd.Flags().StringP("substreams-endpoint", "e", "", "Tier2 gRPC endpoint the jobs are sent to, exclusive with --merged-blocks-store")
+	warmCmd.Flags().String("merged-blocks-store", "", "Base URL of the merged blocks of the chain, to run the jobs in-process instead of sending them to a tier2 endpoint")
+	warmCmd.Flags().String("block-type", "", "Protobuf type of the blocks of the chain, for the jobs run in-process, the type of the block sources of the modules if empty")
+	warmCmd.Flags().String("substreams-api-token-envvar", "SUBSTREAMS_API_TOKEN", "name of variable containing Substreams Authentication token")
+	warmCmd.Flags().Bool("insecure", false, "Skip certificate validation on GRPC connection")
+	warmCmd.Flags().Bool("plaintext", false, "Establish GRPC connection in plaintext")
+	warmCmd.Flags().StringArrayP("params", "p", nil, "Set a params for parameterizable modules. Can be specified multiple times. Ex: -p module1=valA -p module2=valX&valY")
+	warmCmd.Flags().Duration("progress-interval", 30*time.Second, "Interval at which the completed ranges of each stage are printed")
+
+	Cmd.AddCommand(warmCmd)
+}
+
+func warmE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	manifestPath, outputModule := args[0], args[1]
+
+	warmRange, err := parseWarmRange(mustGetString(cmd, "range"))
+	if err != nil {
+		return err
+	}
+	if mustGetString(cmd, "state-store") == "" {
+		return fmt.Errorf("the --state-store flag is required")
+	}
+	endpoint := mustGetString(cmd, "substreams-endpoint")
+	mergedBlocksStoreURL := mustGetString(cmd, "merged-blocks-store")
+	if (endpoint == "") == (mergedBlocksStoreURL == "") {
+		return fmt.Errorf("exactly one of the --substreams-endpoint and --merged-blocks-store flags is required")
+	}
+	cacheTag := mustGetString(cmd, "cache-tag")
+	segmentInterval := mustGetUint64(cmd, "state-bundle-size")
+
+	manifestReader, err := manifest.NewReader(manifestPath)
+	if err != nil {
+		return fmt.Errorf("manifest reader: %w", err)
+	}
+	pkg, err := manifestReader.Read()
+	if err != nil {
+		return fmt.Errorf("read manifest %q: %w", manifestPath, err)
+	}
+	if err := manifest.ApplyParams(mustGetStringArray(cmd, "params"), pkg); err != nil {
+		return fmt.Errorf("apply params: %w", err)
+	}
+
+	outputGraph, err := outputmodules.NewOutputModuleGraph(outputModule, true, pkg.Modules)
+	if err != nil {
+		return fmt.Errorf("creating output module graph: %w", err)
+	}
+	outputModuleHash := outputGraph.ModuleHashes().Get(outputModule)
+
+	// Like tier1 does for the start block of a request, no outputs exist
+	// before the initial block of the module.
+	startBlock := max(warmRange.StartBlock, outputGraph.OutputModule().InitialBlock)
+	if startBlock >= warmRange.ExclusiveEndBlock {
+		return fmt.Errorf("range %s ends before the initial block %d of module %q", warmRange, outputGraph.OutputModule().InitialBlock, outputModule)
+	}
+	stopBlock := warmRange.ExclusiveEndBlock
+
+	baseStore, err := dstore.NewStore(mustGetString(cmd, "state-store"), "zst", "zstd", false)
+	if err != nil {
+		return fmt.Errorf("creating state store: %w", err)
+	}
+	cacheStore, err := baseStore.SubStore(cacheTag)
+	if err != nil {
+		return fmt.Errorf("creating cache store for tag %q: %w", cacheTag, err)
+	}
+
+	// The tier2 servers name their partial stores after the trace ID of
+	// the jobs, propagated by the client. Deriving it from the arguments
+	// makes a later run find the partials of an interrupted one.
+	traceID := warmTraceID(cacheTag, outputModuleHash, startBlock, stopBlock, segmentInterval)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	ctx = tracing.WithTraceID(ctx, traceID)
+	ctx = dauth.WithTrustedHeaders(ctx, dauth.TrustedHeaders{"X-Sf-Substreams-Cache-Tag": cacheTag})
+	ctx = reqctx.WithLogger(ctx, zlog)
+
+	requestDetails := &reqctx.RequestDetails{
+		Modules:               pkg.Modules,
+		OutputModule:          outputModule,
+		ResolvedStartBlockNum: startBlock,
+		LinearHandoffBlockNum: stopBlock,
+		StopBlockNum:          stopBlock,
+		MaxParallelJobs:       mustGetUint64(cmd, "parallel-jobs"),
+		CacheTag:              cacheTag,
+		ProductionMode:        true,
+	}
+	ctx = reqctx.WithRequest(ctx, requestDetails)
+
+	stats := metrics.NewReqStats(&metrics.Config{
+		OutputModule:     outputModule,
+		OutputModuleHash: outputModuleHash,
+		ProductionMode:   true,
+	}, zlog)
+	ctx = reqctx.WithReqStats(ctx, stats)
+	defer stats.LogAndClose()
+
+	// This is the plan of a tier1 request ending at the stop block, without
+	// linear processing, so later requests over the range find everything
+	// they need in the cache.
+	scheduleStores := outputGraph.StagedUsedModules()[0].LastLayer().IsStoreLayer()
+	reqPlan, err := plan.BuildTier1RequestPlan(true, segmentInterval, outputGraph.LowestInitBlock(), startBlock, stopBlock, stopBlock, scheduleStores)
+	if err != nil {
+		return fmt.Errorf("building request plan: %w", err)
+	}
+	if !reqPlan.RequiresParallelProcessing() {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Nothing to warm for module %q over %s\n", outputModule, block.NewRange(startBlock, stopBlock))
+		return nil
+	}
+
+	execoutConfigs, err := execout.NewConfigs(cacheStore, outputGraph.UsedModules(), outputGraph.ModuleHashes(), segmentInterval, zlog)
+	if err != nil {
+		return fmt.Errorf("new config map: %w", err)
+	}
+	storeConfigs, err := store.NewConfigMap(cacheStore, outputGraph.Stores(), outputGraph.ModuleHashes(), segmentInterval, traceID.String())
+	if err != nil {
+		return fmt.Errorf("configuring stores: %w", err)
+	}
+
+	var workerFactory work.WorkerFactory
+	if endpoint != "" {
+		clientFactory := client.NewInternalClientFactory(client.NewSubstreamsClientConfig(
+			endpoint,
+			ReadAPIToken(cmd, "substreams-api-token-envvar"),
+			mustGetBool(cmd, "insecure"),
+			mustGetBool(cmd, "plaintext"),
+		))
+		workerFactory = func(logger *zap.Logger) work.Worker {
+			return work.NewRemoteWorker(clientFactory, logger)
+		}
+	} else {
+		mergedBlocksStore, err := dstore.NewDBinStore(mergedBlocksStoreURL)
+		if err != nil {
+			return fmt.Errorf("creating merged blocks store: %w", err)
+		}
+		blockType := mustGetString(cmd, "block-type")
+		if blockType == "" {
+			if blockType, err = warmBlockType(pkg.Modules); err != nil {
+				return err
+			}
+		}
+		tier2 := service.NewTier2(zlog, mergedBlocksStore, baseStore, cacheTag, segmentInterval, blockType)
+		workerFactory = service.NewLocalWorkerFactory(tier2)
+	}
+	runtimeConfig := config.RuntimeConfig{
+		StateBundleSize: segmentInterval,
+		BaseObjectStore: baseStore,
+		DefaultCacheTag: cacheTag,
+		WorkerFactory:   workerFactory,
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "Warming module %q (%s) over %s in cache %q, trace ID %s\n", outputModule, outputModuleHash, block.NewRange(startBlock, stopBlock), cacheTag, traceID)
+	fmt.Fprintf(cmd.ErrOrStderr(), "Stores up to %s, outputs over %s\n", reqPlan.BuildStores, reqPlan.WriteExecOut)
+
+	progressCtx, stopProgress := context.WithCancel(ctx)
+	defer stopProgress()
+	go printWarmProgress(progressCtx, cmd, stats, mustGetDuration(cmd, "progress-interval"))
+
+	start := time.Now()
+	respFunc := func(resp substreams.ResponseFromAnyTier) error {
+		if failure := resp.(*pbsubstreamsrpc.Response).GetFatalError(); failure != nil {
+			zlog.Warn("job failed", zap.String("reason", failure.Reason), zap.Strings("logs", failure.Logs))
+		}
+		return nil
+	}
+	if err := orchestrator.Warm(ctx, reqPlan, runtimeConfig, int(requestDetails.MaxParallelJobs), outputGraph, execoutConfigs, respFunc, storeConfigs, traceID.String()); err != nil {
+		return fmt.Errorf("warming module %q: %w", outputModule, err)
+	}
+
+	stopProgress()
+	printWarmStages(cmd, stats.Stages())
+	fmt.Fprintf(cmd.ErrOrStderr(), "Warmed module %q over %s in %s\n", outputModule, block.NewRange(startBlock, stopBlock), time.Since(start).Round(time.Second))
+	return nil
+}
+
+// parseWarmRange parses a `<start>:<stop>` range, `stop` being exclusive.
+func parseWarmRange(in string) (*block.Range, error) {
+	startStr, stopStr, found := strings.Cut(in, ":")
+	if !found {
+		return nil, fmt.Errorf("invalid range %q, expected <start>:<stop>", in)
+	}
+	start, err := strconv.ParseUint(startStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start block in range %q: %w", in, err)
+	}
+	stop, err := strconv.ParseUint(stopStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stop block in range %q: %w", in, err)
+	}
+	if stop <= start {
+		return nil, fmt.Errorf("invalid range %q, the stop block must be after the start block", in)
+	}
+	return block.NewRange(start, stop), nil
+}
+
+// warmBlockType returns the type of the block sources of `modules`, which
+// must all be of the same type.
+func warmBlockType(modules *pbsubstreams.Modules) (string, error) {
+	var blockType string
+	for _, module := range modules.Modules {
+		for _, input := range module.Inputs {
+			source := input.GetSource()
+			if source == nil || source.Type == wasm.ClockType {
+				continue
+			}
+			if blockType != "" && source.Type != blockType {
+				return "", fmt.Errorf("modules have block sources of types %q and %q, set the --block-type flag", blockType, source.Type)
+			}
+			blockType = source.Type
+		}
+	}
+	if blockType == "" {
+		return "", fmt.Errorf("no block source in the modules, set the --block-type flag")
+	}
+	return blockType, nil
+}
+
+func warmTraceID(cacheTag, moduleHash string, startBlock, stopBlock, segmentInterval uint64) (out ttrace.TraceID) {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("warm/%s/%s/%d-%d/%d", cacheTag, moduleHash, startBlock, stopBlock, segmentInterval)))
+	copy(out[:], sum[:])
+	return out
+}
+
+func printWarmProgress(ctx context.Context, cmd *cobra.Command, stats *metrics.Stats, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+			printWarmStages(cmd, stats.Stages())
+		}
+	}
+}
+
+func printWarmStages(cmd *cobra.Command, stages []*pbsubstreamsrpc.Stage) {
+	for i, stage := range stages {
+		ranges := make([]string, len(stage.CompletedRanges))
+		for j, r := range stage.CompletedRanges {
+			ranges[j] = block.NewRange(r.StartBlock, r.EndBlock).String()
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Stage %d (%s): completed %s\n", i, strings.Join(stage.Modules, ", "), strings.Join(ranges, ","))
+	}
+}
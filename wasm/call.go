@@ -11,6 +11,7 @@ import (
 	"github.com/streamingfast/substreams/metrics"
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 	"github.com/streamingfast/substreams/storage/store"
+	"google.golang.org/protobuf/proto"
 )
 
 type Call struct {
@@ -31,6 +32,14 @@ type Call struct {
 	LogsByteCount  uint64
 	ExecutionStack []string
 	stats          *metrics.Stats
+
+	wasiRandomSeed    []byte
+	wasiRandomCounter uint64
+	wasiStdout        []byte
+	wasiStderr        []byte
+
+	profile        *callProfile
+	extensionCalls *extensionCalls
 }
 
 func NewCall(clock *pbsubstreams.Clock, moduleName string, entrypoint string, stats *metrics.Stats, arguments []Argument) *Call {
@@ -65,14 +74,6 @@ func NewCall(clock *pbsubstreams.Clock, moduleName string, entrypoint string, st
 //if m.isClosed {
 //	panic("module is closed")
 //}
-
-// FIXME: Replace by `context.Context`, and should speed up execution.
-//if i.registry.maxFuel != 0 {
-//	if remaining, _ := i.wasmStore.ConsumeFuel(i.registry.maxFuel); remaining != 0 {
-//		i.wasmStore.ConsumeFuel(remaining) // don't accumulate fuel from previous executions
-//	}
-//	i.wasmStore.AddFuel(i.registry.maxFuel)
-//}
 //}
 
 func (c *Call) Err() error {
@@ -82,6 +83,22 @@ func (c *Call) Err() error {
 	return nil
 }
 
+// StoreErr returns the error of a store of the call failing to read its
+// snapshot, see store.Failable. Unlike Err, it is not deterministic.
+func (c *Call) StoreErr() error {
+	if c.outputStore != nil {
+		if err := c.outputStore.Err(); err != nil {
+			return err
+		}
+	}
+	for _, s := range c.inputStores {
+		if err := s.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *Call) Output() []byte {
 	return c.returnValue
 }
@@ -117,36 +134,73 @@ func (c *Call) ReachedLogsMaxByteCount() bool {
 }
 
 func (c *Call) DoSet(ord uint64, key string, value []byte) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateSimple("set", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, key)
 	c.outputStore.SetBytes(ord, key, value)
 }
 func (c *Call) DoSetIfNotExists(ord uint64, key string, value []byte) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateSimple("set_if_not_exists", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_IF_NOT_EXISTS, key)
 	c.outputStore.SetBytesIfNotExists(ord, key, value)
 }
 func (c *Call) DoAppend(ord uint64, key string, value []byte) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateSimple("append", pbsubstreams.Module_KindStore_UPDATE_POLICY_APPEND, key)
 	if err := c.outputStore.Append(ord, key, value); err != nil {
 		c.ReturnError(fmt.Errorf("appending to store: %w", err))
 	}
 }
 func (c *Call) DoDeletePrefix(ord uint64, prefix string) {
-	defer c.stats.RecordModuleWasmStoreDeletePrefix(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreDeletePrefix(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.traceStateWrites("delete_prefix", prefix)
 	c.outputStore.DeletePrefix(ord, prefix)
 }
+func (c *Call) DoDeleteRange(ord uint64, lowKey, highKey string) {
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreDeleteRange(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
+	c.traceStateWrites("delete_range", fmt.Sprintf("%s..%s", lowKey, highKey))
+	c.outputStore.DeleteRange(ord, lowKey, highKey)
+}
+func (c *Call) DoDeleteRangePointers(ord uint64, lowKey, highKey, pointerSeparator string) {
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreDeleteRangePointers(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
+	c.traceStateWrites("delete_range_pointers", fmt.Sprintf("%s..%s", lowKey, highKey))
+	if pointerSeparator == "" {
+		c.ReturnError(fmt.Errorf("delete_range_pointers: pointer separator cannot be empty"))
+	}
+	c.outputStore.DeleteRangePointers(ord, lowKey, highKey, pointerSeparator)
+}
 func (c *Call) DoAddBigInt(ord uint64, key string, value string) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateWithValueType("add_bigint", pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD, "bigint", key)
 
 	toAdd, _ := new(big.Int).SetString(value, 10)
 	c.outputStore.SumBigInt(ord, key, toAdd)
 }
 func (c *Call) DoAddBigDecimal(ord uint64, key string, value string) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateWithTwoValueTypes("add_bigdecimal", pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD, "bigdecimal", "bigfloat", key)
 
 	toAdd, err := decimal.NewFromString(string(value))
@@ -156,33 +210,51 @@ func (c *Call) DoAddBigDecimal(ord uint64, key string, value string) {
 	c.outputStore.SumBigDecimal(ord, key, toAdd.Truncate(34))
 }
 func (c *Call) DoAddInt64(ord uint64, key string, value int64) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateWithValueType("add_int64", pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD, "int64", key)
 	c.outputStore.SumInt64(ord, key, value)
 }
 func (c *Call) DoAddFloat64(ord uint64, key string, value float64) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateWithValueType("add_float64", pbsubstreams.Module_KindStore_UPDATE_POLICY_ADD, "float64", key)
 	c.outputStore.SumFloat64(ord, key, value)
 }
 func (c *Call) DoSetMinInt64(ord uint64, key string, value int64) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateWithValueType("set_min_int64", pbsubstreams.Module_KindStore_UPDATE_POLICY_MIN, "int64", key)
 	c.outputStore.SetMinInt64(ord, key, value)
 }
 func (c *Call) DoSetMinBigInt(ord uint64, key string, value string) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateWithValueType("set_min_bigint", pbsubstreams.Module_KindStore_UPDATE_POLICY_MIN, "bigint", key)
 	toSet, _ := new(big.Int).SetString(value, 10)
 	c.outputStore.SetMinBigInt(ord, key, toSet)
 }
 func (c *Call) DoSetMinFloat64(ord uint64, key string, value float64) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateWithValueType("set_min_float64", pbsubstreams.Module_KindStore_UPDATE_POLICY_MIN, "float64", key)
 	c.outputStore.SetMinFloat64(ord, key, value)
 }
 func (c *Call) DoSetMinBigDecimal(ord uint64, key string, value string) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateWithTwoValueTypes("set_min_bigdecimal", pbsubstreams.Module_KindStore_UPDATE_POLICY_MIN, "bigdecimal", "bigfloat", key)
 	toAdd, err := decimal.NewFromString(value)
 	if err != nil {
@@ -191,24 +263,36 @@ func (c *Call) DoSetMinBigDecimal(ord uint64, key string, value string) {
 	c.outputStore.SetMinBigDecimal(ord, key, toAdd.Truncate(34))
 }
 func (c *Call) DoSetMaxInt64(ord uint64, key string, value int64) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateWithValueType("set_max_int64", pbsubstreams.Module_KindStore_UPDATE_POLICY_MAX, "int64", key)
 	c.outputStore.SetMaxInt64(ord, key, value)
 }
 func (c *Call) DoSetMaxBigInt(ord uint64, key string, value string) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateWithValueType("set_max_bigint", pbsubstreams.Module_KindStore_UPDATE_POLICY_MAX, "bigint", key)
 	toSet, _ := new(big.Int).SetString(value, 10)
 	c.outputStore.SetMaxBigInt(ord, key, toSet)
 
 }
 func (c *Call) DoSetMaxFloat64(ord uint64, key string, value float64) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateWithValueType("set_max_float64", pbsubstreams.Module_KindStore_UPDATE_POLICY_MAX, "float64", key)
 	c.outputStore.SetMaxFloat64(ord, key, value)
 }
 func (c *Call) DoSetMaxBigDecimal(ord uint64, key string, value string) {
-	defer c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
 	c.validateWithTwoValueTypes("set_max_bigdecimal", pbsubstreams.Module_KindStore_UPDATE_POLICY_MAX, "bigdecimal", "bigfloat", key)
 	toAdd, err := decimal.NewFromString(value)
 	if err != nil {
@@ -217,8 +301,54 @@ func (c *Call) DoSetMaxBigDecimal(ord uint64, key string, value string) {
 	c.outputStore.SetMaxBigDecimal(ord, key, toAdd.Truncate(34))
 }
 
+// The `set_sum` values are prefixed by `set:` to replace the value of the
+// key, or by `sum:` to add to it, see store.SetSumSetPrefix.
+func (c *Call) DoSetSumInt64(ord uint64, key string, value string) {
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
+	c.validateWithValueType("set_sum_int64", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, "int64", key)
+	if err := c.outputStore.SetSumInt64(ord, key, value); err != nil {
+		c.ReturnError(fmt.Errorf("set_sum_int64: %w", err))
+	}
+}
+func (c *Call) DoSetSumFloat64(ord uint64, key string, value string) {
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
+	c.validateWithValueType("set_sum_float64", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, "float64", key)
+	if err := c.outputStore.SetSumFloat64(ord, key, value); err != nil {
+		c.ReturnError(fmt.Errorf("set_sum_float64: %w", err))
+	}
+}
+func (c *Call) DoSetSumBigInt(ord uint64, key string, value string) {
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
+	c.validateWithValueType("set_sum_bigint", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, "bigint", key)
+	if err := c.outputStore.SetSumBigInt(ord, key, value); err != nil {
+		c.ReturnError(fmt.Errorf("set_sum_bigint: %w", err))
+	}
+}
+func (c *Call) DoSetSumBigDecimal(ord uint64, key string, value string) {
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreWrite(c.ModuleName, c.outputStore.SizeBytes(), time.Since(t0))
+	}()
+	c.validateWithTwoValueTypes("set_sum_bigdecimal", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM, "bigdecimal", "bigfloat", key)
+	if err := c.outputStore.SetSumBigDecimal(ord, key, value); err != nil {
+		c.ReturnError(fmt.Errorf("set_sum_bigdecimal: %w", err))
+	}
+}
+
 func (c *Call) DoGetAt(storeIndex int, ord uint64, key string) (value []byte, found bool) {
-	defer c.stats.RecordModuleWasmStoreRead(c.ModuleName, time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreRead(c.ModuleName, time.Since(t0))
+	}()
 	c.validateStoreIndex(storeIndex, "get_at")
 	readStore := c.inputStores[storeIndex]
 	c.traceStateReads("get_at", storeIndex, found, key)
@@ -226,7 +356,10 @@ func (c *Call) DoGetAt(storeIndex int, ord uint64, key string) (value []byte, fo
 }
 
 func (c *Call) DoHasAt(storeIndex int, ord uint64, key string) (found bool) {
-	defer c.stats.RecordModuleWasmStoreRead(c.ModuleName, time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreRead(c.ModuleName, time.Since(t0))
+	}()
 	c.validateStoreIndex(storeIndex, "has_at")
 	readStore := c.inputStores[storeIndex]
 	c.traceStateReads("has_at", storeIndex, found, key)
@@ -234,7 +367,10 @@ func (c *Call) DoHasAt(storeIndex int, ord uint64, key string) (found bool) {
 }
 
 func (c *Call) DoGetFirst(storeIndex int, key string) (value []byte, found bool) {
-	defer c.stats.RecordModuleWasmStoreRead(c.ModuleName, time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreRead(c.ModuleName, time.Since(t0))
+	}()
 	c.validateStoreIndex(storeIndex, "get_first")
 	readStore := c.inputStores[storeIndex]
 	c.traceStateReads("get_first", storeIndex, found, key)
@@ -242,7 +378,10 @@ func (c *Call) DoGetFirst(storeIndex int, key string) (value []byte, found bool)
 }
 
 func (c *Call) DoHasFirst(storeIndex int, key string) (found bool) {
-	defer c.stats.RecordModuleWasmStoreRead(c.ModuleName, time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreRead(c.ModuleName, time.Since(t0))
+	}()
 	c.validateStoreIndex(storeIndex, "has_first")
 	readStore := c.inputStores[storeIndex]
 	c.traceStateReads("has_first", storeIndex, found, key)
@@ -250,7 +389,10 @@ func (c *Call) DoHasFirst(storeIndex int, key string) (found bool) {
 }
 
 func (c *Call) DoGetLast(storeIndex int, key string) (value []byte, found bool) {
-	defer c.stats.RecordModuleWasmStoreRead(c.ModuleName, time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreRead(c.ModuleName, time.Since(t0))
+	}()
 	c.validateStoreIndex(storeIndex, "get_last")
 	readStore := c.inputStores[storeIndex]
 	c.traceStateReads("get_last", storeIndex, found, key)
@@ -258,13 +400,43 @@ func (c *Call) DoGetLast(storeIndex int, key string) (value []byte, found bool)
 }
 
 func (c *Call) DoHasLast(storeIndex int, key string) (found bool) {
-	defer c.stats.RecordModuleWasmStoreRead(c.ModuleName, time.Since(time.Now()))
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreRead(c.ModuleName, time.Since(t0))
+	}()
 	c.validateStoreIndex(storeIndex, "has_last")
 	readStore := c.inputStores[storeIndex]
 	c.traceStateReads("has_last", storeIndex, found, key)
 	return readStore.HasLast(key)
 }
 
+// DoScanPrefix returns the encoded `pbsubstreams.StoreScan` of the keys
+// starting with `prefix` after `cursor`, at most `limit` of them (0 means no
+// limit). `found` is false when no key matched.
+func (c *Call) DoScanPrefix(storeIndex int, prefix, cursor string, limit uint32) (value []byte, found bool) {
+	t0 := time.Now()
+	defer func() {
+		c.stats.RecordModuleWasmStoreRead(c.ModuleName, time.Since(t0))
+	}()
+	c.validateStoreIndex(storeIndex, "scan_prefix")
+	readStore := c.inputStores[storeIndex]
+	scan := &pbsubstreams.StoreScan{}
+	scan.Cursor = readStore.ScanPrefix(prefix, cursor, int(limit), func(key string, value []byte) {
+		scan.Entries = append(scan.Entries, &pbsubstreams.StoreScanEntry{Key: key, Value: value})
+	})
+	found = len(scan.Entries) != 0
+	c.traceStateReads("scan_prefix", storeIndex, found, prefix)
+	if !found {
+		return nil, false
+	}
+
+	value, err := proto.Marshal(scan)
+	if err != nil {
+		c.ReturnError(fmt.Errorf("marshalling scan result: %w", err))
+	}
+	return value, true
+}
+
 func (c *Call) validateStoreIndex(storeIndex int, stateFunc string) {
 	if storeIndex+1 > len(c.inputStores) {
 		c.ReturnError(fmt.Errorf("%q failed: invalid store index %d, %d stores declared", stateFunc, storeIndex, len(c.inputStores)))
@@ -325,4 +497,5 @@ var policyMap = map[pbsubstreams.Module_KindStore_UpdatePolicy]string{
 	pbsubstreams.Module_KindStore_UPDATE_POLICY_MIN:               "min",
 	pbsubstreams.Module_KindStore_UPDATE_POLICY_MAX:               "max",
 	pbsubstreams.Module_KindStore_UPDATE_POLICY_APPEND:            "append",
+	pbsubstreams.Module_KindStore_UPDATE_POLICY_SET_SUM:           "set_sum",
 }
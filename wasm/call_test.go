@@ -5,6 +5,7 @@ import (
 
 	"github.com/streamingfast/dstore"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
 	"github.com/streamingfast/substreams/metrics"
@@ -480,6 +481,25 @@ func Test_CallStoreOps(t *testing.T) {
 	}
 }
 
+func Test_CallStoreDeleteStats(t *testing.T) {
+	c := newTestCall(pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string")
+	c.ModuleName = "test"
+	c.DoSet(0, "a:1", []byte("1"))
+	c.DoSet(1, "b:1", []byte("1"))
+	c.DoSet(2, "c:1", []byte("d:1"))
+	c.DoDeletePrefix(3, "a:")
+	c.DoDeleteRange(4, "b:", "b:~")
+	c.DoDeleteRange(5, "b:", "b:~")
+	c.DoDeleteRangePointers(6, "c:", "c:~", ":")
+
+	stats := c.stats.LocalModulesStats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, uint64(3), stats[0].StoreWriteCount)
+	assert.Equal(t, uint64(1), stats[0].StoreDeleteprefixCount)
+	assert.Equal(t, uint64(2), stats[0].StoreDeleterangeCount)
+	assert.Equal(t, uint64(1), stats[0].StoreDeleterangepointersCount)
+}
+
 func newTestCall(updatePolicy pbsubstreams.Module_KindStore_UpdatePolicy, valueType string) *Call {
 	myStore := dstore.NewMockStore(nil)
 	storeConf, err := store.NewConfig("test", 0, "", updatePolicy, valueType, myStore, "test")
@@ -0,0 +1,341 @@
+package wasm
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/metrics"
+)
+
+// DefaultCompilationCacheMaxBytes bounds the size of the artifacts kept by a
+// CompilationCache, see WithCompilationCacheMaxBytes.
+const DefaultCompilationCacheMaxBytes = 1 << 30
+
+// CompilationCache holds the compiled artifacts of WASM modules, so that a
+// binary shared by many requests is compiled only once per process. It is
+// meant to outlive registries (which are created for each request), and is
+// attached to them with WithCompilationCache.
+//
+// When `dir` is set, the artifacts are also written to disk so they survive
+// restarts. The directory must be protected from external changes, artifacts
+// read from it are trusted as-is.
+//
+// The artifacts, and the modules compiled by runtimes keeping them in their
+// own caches (see Hold), are bounded in size: past the limit, the least
+// recently used ones are evicted, from memory and from disk.
+type CompilationCache struct {
+	dir      string
+	maxBytes uint64
+
+	lock      sync.Mutex
+	artifacts map[string]*list.Element // of *cachedArtifact, most recently used first
+	lru       *list.List
+	size      uint64
+	pending   map[string]*cachedArtifact // pending are the held modules evicted, dropped once released, see Hold
+	shared    map[string]any
+}
+
+type cachedArtifact struct {
+	key      string
+	size     uint64
+	artifact []byte // nil when only on disk, or for modules held in a runtime's cache
+	holders  int    // holders of the module, see Hold
+	drop     func() // drop removes the module from the runtime's cache, see Hold
+}
+
+type CompilationCacheOption func(*CompilationCache)
+
+// WithCompilationCacheMaxBytes bounds the total size of the artifacts, on
+// disk and in memory, to `bytes` (DefaultCompilationCacheMaxBytes by default).
+func WithCompilationCacheMaxBytes(bytes uint64) CompilationCacheOption {
+	return func(c *CompilationCache) {
+		c.maxBytes = bytes
+	}
+}
+
+func NewCompilationCache(dir string, opts ...CompilationCacheOption) (*CompilationCache, error) {
+	c := &CompilationCache{
+		dir:       dir,
+		maxBytes:  DefaultCompilationCacheMaxBytes,
+		artifacts: make(map[string]*list.Element),
+		lru:       list.New(),
+		pending:   make(map[string]*cachedArtifact),
+		shared:    make(map[string]any),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("creating wasm compilation cache directory %q: %w", dir, err)
+		}
+		if err := c.loadDir(); err != nil {
+			return nil, fmt.Errorf("listing wasm compilation cache directory %q: %w", dir, err)
+		}
+	}
+	return c, nil
+}
+
+// loadDir tracks the artifacts already on disk, the most recently modified
+// being the most recently used, and evicts them past the size limit. The
+// modules that runtimes keep in sub-directories (see Hold) are tracked under
+// their path relative to the directory.
+func (c *CompilationCache) loadDir() error {
+	type onDisk struct {
+		key  string
+		info os.FileInfo
+	}
+	var files []onDisk
+	var list func(sub string) error
+	list = func(sub string) error {
+		entries, err := os.ReadDir(filepath.Join(c.dir, sub))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if strings.Contains(entry.Name(), ".tmp-") {
+				continue
+			}
+			if entry.IsDir() {
+				if sub == "" {
+					if err := list(entry.Name()); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			files = append(files, onDisk{key: filepath.Join(sub, entry.Name()), info: info})
+		}
+		return nil
+	}
+	if err := list(""); err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].info.ModTime().Before(files[j].info.ModTime())
+	})
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, file := range files {
+		c.add(file.key, uint64(file.info.Size()), nil)
+	}
+	return nil
+}
+
+// Dir is the on-disk location of the cache, empty when the cache is
+// in-memory only.
+func (c *CompilationCache) Dir() string { return c.dir }
+
+// Key identifies the artifact of `code` compiled by `runtime`. The `flags`
+// must list the runtime settings affecting compilation (e.g. fuel metering).
+func (c *CompilationCache) Key(runtime string, code []byte, flags ...string) string {
+	h := sha256.New()
+	h.Write(code)
+	for _, flag := range flags {
+		h.Write([]byte{0})
+		h.Write([]byte(flag))
+	}
+	return runtime + "-" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the artifact for `key`, looking up the disk when it is not
+// in memory. The runtimes count the hits and misses of the compilations in
+// the metrics, see CountCompilation.
+func (c *CompilationCache) Get(key string) ([]byte, bool) {
+	c.lock.Lock()
+	var artifact []byte
+	elem, found := c.artifacts[key]
+	if found {
+		c.lru.MoveToFront(elem)
+		artifact = elem.Value.(*cachedArtifact).artifact
+	}
+	c.lock.Unlock()
+
+	if artifact == nil && c.dir != "" {
+		var err error
+		artifact, err = os.ReadFile(filepath.Join(c.dir, key))
+		if err == nil {
+			found = true
+			c.lock.Lock()
+			c.add(key, uint64(len(artifact)), artifact)
+			c.lock.Unlock()
+		} else {
+			found = false
+			if !os.IsNotExist(err) {
+				zlog.Warn("reading wasm compilation cache artifact", zap.String("key", key), zap.Error(err))
+			}
+		}
+	}
+
+	return artifact, found
+}
+
+// CountCompilation counts a module loaded from the cache (`cached`) or
+// compiled in the metrics.
+func CountCompilation(cached bool) {
+	if cached {
+		metrics.WasmCompilationCacheHits.Inc()
+	} else {
+		metrics.WasmCompilationCacheMisses.Inc()
+	}
+}
+
+// Contains tells if `key`, an artifact or a held module, is in the cache.
+func (c *CompilationCache) Contains(key string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, found := c.artifacts[key]
+	return found || c.pending[key] != nil
+}
+
+// Hold tracks a module of `size` bytes that a runtime compiled in its own
+// cache, so that it counts in the size limit like the artifacts. The `key`
+// of a module the runtime writes to disk is its path relative to Dir, so
+// that the file is removed on eviction. The module is used until `release`
+// is called: once evicted, it is only dropped from the runtime's cache with
+// `drop` when released by all its holders.
+func (c *CompilationCache) Hold(key string, size uint64, drop func()) (release func()) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var entry *cachedArtifact
+	if elem, found := c.artifacts[key]; found {
+		entry = elem.Value.(*cachedArtifact)
+		c.size -= entry.size
+		c.lru.MoveToFront(elem)
+	} else {
+		entry = c.pending[key]
+		delete(c.pending, key)
+		if entry == nil {
+			entry = &cachedArtifact{key: key}
+		}
+		c.artifacts[key] = c.lru.PushFront(entry)
+	}
+	entry.size = size
+	c.size += size
+	if entry.drop == nil {
+		entry.drop = drop
+	}
+	entry.holders++
+	c.evict()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			entry.holders--
+			if entry.holders == 0 && c.pending[key] == entry {
+				delete(c.pending, key)
+				entry.drop()
+			}
+		})
+	}
+}
+
+// Put saves the artifact for `key`. Failing to write it to disk is not fatal,
+// the artifact is then only kept in memory. An artifact larger than the size
+// limit is not kept.
+func (c *CompilationCache) Put(key string, artifact []byte) {
+	if uint64(len(artifact)) > c.maxBytes {
+		return
+	}
+
+	c.lock.Lock()
+	c.add(key, uint64(len(artifact)), artifact)
+	c.lock.Unlock()
+
+	if c.dir == "" {
+		return
+	}
+	if err := writeFileAtomic(filepath.Join(c.dir, key), artifact); err != nil {
+		zlog.Warn("writing wasm compilation cache artifact", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// add makes `key` the most recently used artifact, then evicts the least
+// recently used ones past the size limit. The lock must be held.
+func (c *CompilationCache) add(key string, size uint64, artifact []byte) {
+	if elem, found := c.artifacts[key]; found {
+		entry := elem.Value.(*cachedArtifact)
+		c.size -= entry.size
+		entry.size, entry.artifact = size, artifact
+		c.size += size
+		c.lru.MoveToFront(elem)
+	} else {
+		c.artifacts[key] = c.lru.PushFront(&cachedArtifact{key: key, size: size, artifact: artifact})
+		c.size += size
+	}
+	c.evict()
+}
+
+// evict removes the least recently used artifacts and modules past the size
+// limit. The lock must be held.
+func (c *CompilationCache) evict() {
+	for c.size > c.maxBytes && c.lru.Len() > 0 {
+		entry := c.lru.Remove(c.lru.Back()).(*cachedArtifact)
+		delete(c.artifacts, entry.key)
+		c.size -= entry.size
+		switch {
+		case entry.holders > 0:
+			c.pending[entry.key] = entry
+		case entry.drop != nil:
+			entry.drop()
+		}
+		if c.dir == "" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, entry.key)); err != nil && !os.IsNotExist(err) {
+			zlog.Warn("removing evicted wasm compilation cache artifact", zap.String("key", entry.key), zap.Error(err))
+		}
+	}
+}
+
+// Shared returns the runtime-specific object stored under `name`, creating it
+// on first use. Runtimes use it to keep their own caches alongside this one.
+func (c *CompilationCache) Shared(name string, create func() (any, error)) (any, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if obj, found := c.shared[name]; found {
+		return obj, nil
+	}
+	obj, err := create()
+	if err != nil {
+		return nil, err
+	}
+	c.shared[name] = obj
+	return obj, nil
+}
+
+func writeFileAtomic(filename string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
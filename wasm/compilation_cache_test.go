@@ -0,0 +1,121 @@
+package wasm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompilationCache(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := NewCompilationCache(dir)
+	require.NoError(t, err)
+
+	key := cache.Key("runtime", []byte("code"), "fuel=true")
+	assert.NotEqual(t, key, cache.Key("runtime", []byte("code"), "fuel=false"))
+	assert.NotEqual(t, key, cache.Key("other", []byte("code"), "fuel=true"))
+
+	_, found := cache.Get(key)
+	assert.False(t, found)
+
+	cache.Put(key, []byte("artifact"))
+	artifact, found := cache.Get(key)
+	assert.True(t, found)
+	assert.Equal(t, []byte("artifact"), artifact)
+
+	restarted, err := NewCompilationCache(dir)
+	require.NoError(t, err)
+	artifact, found = restarted.Get(key)
+	assert.True(t, found, "artifact should be loaded from disk")
+	assert.Equal(t, []byte("artifact"), artifact)
+
+	inMemory, err := NewCompilationCache("")
+	require.NoError(t, err)
+	_, found = inMemory.Get(key)
+	assert.False(t, found)
+}
+
+func TestCompilationCache_Eviction(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := NewCompilationCache(dir, WithCompilationCacheMaxBytes(10))
+	require.NoError(t, err)
+
+	cache.Put("a", []byte("aaaa"))
+	cache.Put("b", []byte("bbbb"))
+	_, found := cache.Get("a") // b becomes the least recently used
+	require.True(t, found)
+	cache.Put("c", []byte("cccc"))
+
+	_, found = cache.Get("b")
+	assert.False(t, found, "least recently used artifact should be evicted")
+	assert.NoFileExists(t, filepath.Join(dir, "b"))
+	_, found = cache.Get("a")
+	assert.True(t, found)
+	_, found = cache.Get("c")
+	assert.True(t, found)
+
+	cache.Put("big", []byte("0123456789a"))
+	_, found = cache.Get("big")
+	assert.False(t, found, "artifact larger than the limit should not be kept")
+
+	restarted, err := NewCompilationCache(dir, WithCompilationCacheMaxBytes(4))
+	require.NoError(t, err)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "artifacts on disk past the limit should be evicted on startup")
+	_, found = restarted.Get(entries[0].Name())
+	assert.True(t, found)
+}
+
+func TestCompilationCache_Hold(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "runtime"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "runtime", "old"), []byte("0123"), 0o600))
+
+	cache, err := NewCompilationCache(dir, WithCompilationCacheMaxBytes(10))
+	require.NoError(t, err)
+	assert.True(t, cache.Contains(filepath.Join("runtime", "old")), "modules of the runtimes on disk should be tracked")
+
+	dropped := map[string]int{}
+	hold := func(key string, size uint64) func() {
+		return cache.Hold(key, size, func() { dropped[key]++ })
+	}
+
+	releaseA := hold("a", 4)
+	releaseB := hold("b", 4)
+	assert.False(t, cache.Contains(filepath.Join("runtime", "old")))
+	assert.NoFileExists(t, filepath.Join(dir, "runtime", "old"), "evicted modules should be removed from disk")
+
+	releaseC := hold("c", 4) // evicts a, still held
+	assert.True(t, cache.Contains("a"), "evicted modules should be contained until dropped")
+	assert.Empty(t, dropped, "held modules should not be dropped")
+
+	releaseA()
+	releaseA()
+	assert.Equal(t, map[string]int{"a": 1}, dropped, "evicted modules should be dropped once released")
+	assert.False(t, cache.Contains("a"))
+
+	releaseC()
+	releaseB()
+	assert.Equal(t, map[string]int{"a": 1}, dropped, "modules in the cache should not be dropped when released")
+
+	releaseD := hold("d", 4) // evicts b
+	assert.Equal(t, map[string]int{"a": 1, "b": 1}, dropped)
+
+	releaseE := hold("e", 4)  // evicts c
+	releaseC2 := hold("c", 4) // evicts d, still held
+	assert.Equal(t, map[string]int{"a": 1, "b": 1, "c": 1}, dropped)
+
+	releaseD2 := hold("d", 4) // takes d back, evicts e, still held
+	releaseD()
+	releaseD2()
+	releaseC2()
+	assert.Equal(t, map[string]int{"a": 1, "b": 1, "c": 1}, dropped, "modules held again should be back in the cache")
+	releaseE()
+	assert.Equal(t, map[string]int{"a": 1, "b": 1, "c": 1, "e": 1}, dropped)
+}
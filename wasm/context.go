@@ -8,6 +8,9 @@ func WithContext(ctx context.Context, call *Call) context.Context {
 	return context.WithValue(ctx, "call", call)
 }
 
+// FromContext returns nil when no call is running, like while a module is
+// being instantiated.
 func FromContext(ctx context.Context) *Call {
-	return ctx.Value("call").(*Call)
+	call, _ := ctx.Value("call").(*Call)
+	return call
 }
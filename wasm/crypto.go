@@ -0,0 +1,77 @@
+package wasm
+
+import (
+	"crypto/sha256"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+// CryptoNamespace is the module name of the built-in cryptographic host
+// functions, provided to all modules by both runtimes:
+//
+//   - `keccak256(ptr, len, output_ptr)` and `sha256(ptr, len, output_ptr)`
+//     hash the input and write the 32 bytes digest at `output_ptr`.
+//   - `secp256k1_recover(hash_ptr, signature_ptr, output_ptr) -> i32` recovers
+//     the public key which signed the 32 bytes hash with the 65 bytes
+//     signature (`r || s || v`, with `v` in 0-3 or 27-30), and writes it
+//     uncompressed (65 bytes, `0x04 || x || y`) at `output_ptr`. It returns
+//     1 on success, and 0 without writing anything when the signature is
+//     invalid.
+//
+// Calls are reported in the module stats as external calls, named
+// `crypto:<function>`.
+const CryptoNamespace = "crypto"
+
+const (
+	CryptoDigestSize          = 32
+	Secp256k1SignatureSize    = 65
+	Secp256k1PublicKeySize    = 65
+	secp256k1RecoveryCodeBase = 27
+)
+
+func (c *Call) DoKeccak256(data []byte) []byte {
+	defer c.recordCryptoCall("keccak256", time.Now())
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (c *Call) DoSha256(data []byte) []byte {
+	defer c.recordCryptoCall("sha256", time.Now())
+	digest := sha256.Sum256(data)
+	return digest[:]
+}
+
+// DoSecp256k1Recover returns false when the signature is invalid.
+func (c *Call) DoSecp256k1Recover(hash, signature []byte) ([]byte, bool) {
+	defer c.recordCryptoCall("secp256k1_recover", time.Now())
+	if len(hash) != CryptoDigestSize || len(signature) != Secp256k1SignatureSize {
+		return nil, false
+	}
+
+	recoveryID := signature[64]
+	if recoveryID >= secp256k1RecoveryCodeBase {
+		recoveryID -= secp256k1RecoveryCodeBase
+	}
+	if recoveryID > 3 {
+		return nil, false
+	}
+
+	// The compact format puts the recovery code first
+	compact := make([]byte, 0, Secp256k1SignatureSize)
+	compact = append(compact, secp256k1RecoveryCodeBase+recoveryID)
+	compact = append(compact, signature[:64]...)
+	publicKey, _, err := ecdsa.RecoverCompact(compact, hash)
+	if err != nil {
+		return nil, false
+	}
+	return publicKey.SerializeUncompressed(), true
+}
+
+func (c *Call) recordCryptoCall(function string, start time.Time) {
+	if c.stats != nil {
+		c.stats.RecordModuleWasmExternalCall(c.ModuleName, CryptoNamespace+":"+function, time.Since(start))
+	}
+}
@@ -0,0 +1,53 @@
+package wasm
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCall_Hashes(t *testing.T) {
+	call := &Call{ModuleName: "test"}
+
+	assert.Equal(t, "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470", hex.EncodeToString(call.DoKeccak256(nil)))
+	assert.Equal(t, "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45", hex.EncodeToString(call.DoKeccak256([]byte("abc"))))
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", hex.EncodeToString(call.DoSha256([]byte("abc"))))
+}
+
+func TestCall_Secp256k1Recover(t *testing.T) {
+	call := &Call{ModuleName: "test"}
+
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	hash := call.DoKeccak256([]byte("message"))
+
+	// r || s || v, from the compact format which has the recovery code first
+	compact := ecdsa.SignCompact(key, hash, false)
+	signature := append(append([]byte{}, compact[1:]...), compact[0]-27)
+
+	publicKey, ok := call.DoSecp256k1Recover(hash, signature)
+	require.True(t, ok)
+	assert.Equal(t, key.PubKey().SerializeUncompressed(), publicKey)
+
+	signature[64] += 27
+	publicKey, ok = call.DoSecp256k1Recover(hash, signature)
+	require.True(t, ok, "v can be 27 or 28")
+	assert.Equal(t, key.PubKey().SerializeUncompressed(), publicKey)
+
+	otherHash := call.DoKeccak256([]byte("other message"))
+	publicKey, ok = call.DoSecp256k1Recover(otherHash, signature)
+	if ok {
+		assert.NotEqual(t, key.PubKey().SerializeUncompressed(), publicKey, "another hash recovers another key")
+	}
+
+	signature[64] = 35
+	_, ok = call.DoSecp256k1Recover(hash, signature)
+	assert.False(t, ok, "invalid recovery id")
+
+	_, ok = call.DoSecp256k1Recover(hash, make([]byte, Secp256k1SignatureSize))
+	assert.False(t, ok, "zero signature")
+}
@@ -0,0 +1,119 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/streamingfast/substreams/metrics"
+	pbssinternal "github.com/streamingfast/substreams/pb/sf/substreams/intern/v2"
+)
+
+// DeterminismError is returned when the two executions of a call made by a
+// module created with WithDeterminismCheck diverge.
+type DeterminismError struct {
+	Module string
+	Block  uint64
+	// Key is the first store key whose deltas differ, empty when the
+	// divergence is on the output or the outcome of the call.
+	Key    string
+	Reason string
+}
+
+func (e *DeterminismError) Error() string {
+	if e.Key != "" {
+		return fmt.Sprintf("module %q: non-deterministic execution at block %d, key %q: %s", e.Module, e.Block, e.Key, e.Reason)
+	}
+	return fmt.Sprintf("module %q: non-deterministic execution at block %d: %s", e.Module, e.Block, e.Reason)
+}
+
+// determinismCheckModule executes each call on a fresh instance of `shadow`
+// before executing it for real, and compares the output and store deltas of
+// both executions. The store changes of the shadow execution are reverted
+// before the real one.
+//
+// Host functions are thus called twice for each call, which doubles the
+// store operations counted in the stats and the calls made to WASM
+// extensions.
+type determinismCheckModule struct {
+	Module
+	shadow Module
+}
+
+func (m *determinismCheckModule) ExecuteNewCall(ctx context.Context, call *Call, cachedInstance Instance, arguments []Argument) (Instance, error) {
+	shadowCall := NewCall(call.Clock, call.ModuleName, call.Entrypoint, call.stats, arguments)
+	shadowInst, shadowErr := m.shadow.ExecuteNewCall(ctx, shadowCall, nil, arguments)
+	if shadowInst != nil {
+		if err := shadowInst.Close(ctx); err != nil {
+			return nil, fmt.Errorf("closing determinism check instance: %w", err)
+		}
+	}
+
+	var shadowDeltas []*pbssinternal.StoreDelta
+	if outputStore := shadowCall.outputStore; outputStore != nil {
+		shadowDeltas = outputStore.GetDeltas()
+		outputStore.ApplyDeltasReverse(shadowDeltas)
+		outputStore.Reset()
+	}
+
+	inst, err := m.Module.ExecuteNewCall(ctx, call, cachedInstance, arguments)
+	if err != nil && shadowErr != nil {
+		return inst, err
+	}
+
+	if divergence := compareCalls(call, err, shadowCall, shadowErr, shadowDeltas); divergence != nil {
+		metrics.WasmDeterminismDivergences.Inc()
+		return inst, divergence
+	}
+	return inst, err
+}
+
+func compareCalls(call *Call, err error, shadowCall *Call, shadowErr error, shadowDeltas []*pbssinternal.StoreDelta) *DeterminismError {
+	divergence := func(key, reason string, args ...any) *DeterminismError {
+		return &DeterminismError{
+			Module: call.ModuleName,
+			Block:  call.Clock.GetNumber(),
+			Key:    key,
+			Reason: fmt.Sprintf(reason, args...),
+		}
+	}
+
+	if (err == nil) != (shadowErr == nil) {
+		return divergence("", "one execution failed (%v), the other did not (%v)", err, shadowErr)
+	}
+	if errMsg, shadowErrMsg := fmt.Sprint(call.Err()), fmt.Sprint(shadowCall.Err()); errMsg != shadowErrMsg {
+		return divergence("", "panics differ: %s != %s", errMsg, shadowErrMsg)
+	}
+	if !bytes.Equal(call.Output(), shadowCall.Output()) {
+		return divergence("", "outputs differ (%d bytes != %d bytes)", len(call.Output()), len(shadowCall.Output()))
+	}
+
+	if call.outputStore == nil {
+		return nil
+	}
+	deltas := call.outputStore.GetDeltas()
+	for i := 0; i < len(deltas) || i < len(shadowDeltas); i++ {
+		switch {
+		case i >= len(shadowDeltas):
+			return divergence(deltas[i].Key, "unexpected delta %s", deltas[i].Operation)
+		case i >= len(deltas):
+			return divergence(shadowDeltas[i].Key, "missing delta %s", shadowDeltas[i].Operation)
+		case !proto.Equal(deltas[i], shadowDeltas[i]):
+			key := deltas[i].Key
+			if key != shadowDeltas[i].Key {
+				key = min(key, shadowDeltas[i].Key)
+			}
+			return divergence(key, "store deltas differ at index %d", i)
+		}
+	}
+	return nil
+}
+
+func (m *determinismCheckModule) Close(ctx context.Context) error {
+	if err := m.shadow.Close(ctx); err != nil {
+		return err
+	}
+	return m.Module.Close(ctx)
+}
@@ -0,0 +1,110 @@
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/streamingfast/substreams/metrics"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/storage/store"
+)
+
+// testModule runs `execute` with the number of executions so far, so tests
+// can make it non-deterministic.
+type testModule struct {
+	executions *int
+	execute    func(call *Call, execution int)
+}
+
+type testInstance struct{}
+
+func (testInstance) Cleanup(ctx context.Context) error { return nil }
+func (testInstance) Close(ctx context.Context) error   { return nil }
+
+func (m *testModule) NewInstance(ctx context.Context) (Instance, error) { return testInstance{}, nil }
+func (m *testModule) Close(ctx context.Context) error                   { return nil }
+func (m *testModule) ExecuteNewCall(ctx context.Context, call *Call, cachedInstance Instance, arguments []Argument) (Instance, error) {
+	*m.executions++
+	m.execute(call, *m.executions)
+	return testInstance{}, nil
+}
+
+func TestDeterminismCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		execute     func(call *Call, execution int)
+		expectError string
+	}{
+		{
+			name: "deterministic",
+			execute: func(call *Call, execution int) {
+				call.DoSet(0, "a", []byte("1"))
+				call.DoSet(1, "b", []byte("2"))
+				call.SetReturnValue([]byte("out"))
+			},
+		},
+		{
+			name: "output differs",
+			execute: func(call *Call, execution int) {
+				call.SetReturnValue([]byte(fmt.Sprint(execution)))
+			},
+			expectError: `module "test": non-deterministic execution at block 42: outputs differ (1 bytes != 1 bytes)`,
+		},
+		{
+			name: "delta differs",
+			execute: func(call *Call, execution int) {
+				call.DoSet(0, "a", []byte("1"))
+				call.DoSet(1, "b", []byte(fmt.Sprint(execution)))
+			},
+			expectError: `module "test": non-deterministic execution at block 42, key "b": store deltas differ at index 1`,
+		},
+		{
+			name: "missing delta",
+			execute: func(call *Call, execution int) {
+				if execution == 1 {
+					call.DoSet(0, "a", []byte("1"))
+				}
+			},
+			expectError: `module "test": non-deterministic execution at block 42, key "a": missing delta CREATE`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			executions := 0
+			RegisterModuleFactory("determinism-test", ModuleFactoryFunc(func(ctx context.Context, code []byte, registry *Registry) (Module, error) {
+				return &testModule{executions: &executions, execute: test.execute}, nil
+			}))
+			defer delete(runtimes, "determinism-test")
+
+			storeConf, err := store.NewConfig("test", 0, "", pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string", dstore.NewMockStore(nil), "test")
+			require.NoError(t, err)
+			outputStore := storeConf.NewFullKV(zap.NewNop())
+			arguments := []Argument{NewStoreWriterOutput("test", outputStore, pbsubstreams.Module_KindStore_UPDATE_POLICY_SET, "string")}
+
+			ctx := context.Background()
+			registry := NewRegistryWithRuntime("determinism-test", nil, 0, WithDeterminismCheck(""))
+			module, err := registry.NewModule(ctx, nil)
+			require.NoError(t, err)
+
+			call := NewCall(&pbsubstreams.Clock{Number: 42}, "test", "run", metrics.NewReqStats(&metrics.Config{}, zap.NewNop()), arguments)
+			_, err = module.ExecuteNewCall(ctx, call, nil, arguments)
+			assert.Equal(t, 2, executions)
+			if test.expectError != "" {
+				var determinismErr *DeterminismError
+				require.ErrorAs(t, err, &determinismErr)
+				assert.Equal(t, test.expectError, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, outputStore.GetDeltas(), 2, "deltas of the check execution are discarded")
+			assert.Equal(t, uint64(2), outputStore.Length())
+		})
+	}
+}
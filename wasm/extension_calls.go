@@ -0,0 +1,188 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/streamingfast/dstore"
+	"go.uber.org/zap"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+// ExtensionCallsMode tells how the calls to WASM extensions are recorded, see
+// WithExtensionCalls.
+type ExtensionCallsMode string
+
+const (
+	// ExtensionCallsRecord serves the responses already recorded, and calls
+	// the extension then records its response otherwise.
+	ExtensionCallsRecord ExtensionCallsMode = "record"
+	// ExtensionCallsReplay only serves the responses already recorded, the
+	// extensions are never called: a call which was not recorded fails.
+	ExtensionCallsReplay ExtensionCallsMode = "replay"
+)
+
+func ParseExtensionCallsMode(mode string) (ExtensionCallsMode, error) {
+	switch ExtensionCallsMode(mode) {
+	case ExtensionCallsRecord, ExtensionCallsReplay:
+		return ExtensionCallsMode(mode), nil
+	}
+	return "", fmt.Errorf("invalid wasm extension calls mode %q, valid values are %q and %q", mode, ExtensionCallsRecord, ExtensionCallsReplay)
+}
+
+// WithExtensionCalls records the responses of the WASM extensions in
+// `store`, keyed by module, block and a hash of the input, so the same calls
+// are served from the store afterwards (by any request using that store),
+// or replayed without calling the extensions at all.
+//
+// The responses of an extension must depend only on the block and input
+// for the recording to be reproducible. Failed calls are not recorded.
+//
+// The responses of a module for a block are buffered during its execution:
+// they are read in one object on the first extension call of the block, and
+// the new ones written in one object once the module has run.
+func WithExtensionCalls(mode ExtensionCallsMode, store dstore.Store) RegistryOption {
+	return func(r *Registry) {
+		r.extensionCallsMode = mode
+		r.extensionCallsStore = store
+	}
+}
+
+// extensionCallsModule gives each call of the module the buffer of the
+// responses recorded for its block, and writes the new ones once executed.
+type extensionCallsModule struct {
+	Module
+	mode  ExtensionCallsMode
+	store dstore.Store
+}
+
+func (m *extensionCallsModule) ExecuteNewCall(ctx context.Context, call *Call, cachedInstance Instance, arguments []Argument) (Instance, error) {
+	calls := &extensionCalls{
+		store:    m.store,
+		filename: ExtensionCallsFilename(call.ModuleName, call.Clock),
+	}
+	call.extensionCalls = calls
+
+	inst, err := m.Module.ExecuteNewCall(ctx, call, cachedInstance, arguments)
+	if m.mode == ExtensionCallsRecord {
+		calls.write(ctx)
+	}
+	return inst, err
+}
+
+// extensionCalls holds the responses recorded for a module at a block, read
+// from the store on first use.
+type extensionCalls struct {
+	store    dstore.Store
+	filename string
+
+	loaded    bool
+	responses map[string][]byte
+	dirty     bool
+}
+
+func (c *extensionCalls) get(ctx context.Context, key string) ([]byte, bool, error) {
+	if !c.loaded {
+		responses, err := readExtensionCalls(ctx, c.store, c.filename)
+		if err != nil {
+			return nil, false, fmt.Errorf("reading recorded extension calls %q: %w", c.filename, err)
+		}
+		c.responses = responses
+		c.loaded = true
+	}
+	out, found := c.responses[key]
+	return out, found, nil
+}
+
+func (c *extensionCalls) add(key string, out []byte) {
+	if c.responses == nil {
+		c.responses = make(map[string][]byte)
+	}
+	c.responses[key] = out
+	c.dirty = true
+}
+
+func (c *extensionCalls) write(ctx context.Context) {
+	if !c.dirty {
+		return
+	}
+	content, err := json.Marshal(c.responses)
+	if err == nil {
+		err = c.store.WriteObject(ctx, c.filename, bytes.NewReader(content))
+	}
+	if err != nil {
+		zlog.Warn("cannot record wasm extension calls", zap.String("filename", c.filename), zap.Error(err))
+		return
+	}
+	c.dirty = false
+}
+
+func recordedExtension(mode ExtensionCallsMode, namespace, importName string, ext WASMExtension) WASMExtension {
+	return func(ctx context.Context, requestID string, clock *pbsubstreams.Clock, in []byte) ([]byte, error) {
+		call := FromContext(ctx)
+		if call == nil || call.extensionCalls == nil {
+			return nil, fmt.Errorf("no module call to record the extension call of")
+		}
+		calls := call.extensionCalls
+
+		key := extensionCallKey(namespace, importName, in)
+		out, found, err := calls.get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return out, nil
+		}
+		if mode == ExtensionCallsReplay {
+			return nil, fmt.Errorf("no recorded response to replay at block %d (%s in %s)", clock.Number, key, calls.filename)
+		}
+
+		out, err = ext(ctx, requestID, clock, in)
+		if err != nil {
+			return nil, err
+		}
+		calls.add(key, out)
+		return out, nil
+	}
+}
+
+// ExtensionCallsFilename is the name, in the store of WithExtensionCalls, of
+// the responses of the extensions recorded for `moduleName` at `clock`.
+func ExtensionCallsFilename(moduleName string, clock *pbsubstreams.Clock) string {
+	return fmt.Sprintf("%s/%010d-%s.json", moduleName, clock.Number, clock.Id)
+}
+
+// extensionCallKey identifies, in the responses recorded for a block, the
+// response of the extension `namespace::importName` to `in`.
+func extensionCallKey(namespace, importName string, in []byte) string {
+	hash := sha256.Sum256(in)
+	return fmt.Sprintf("%s.%s.%s", namespace, importName, hex.EncodeToString(hash[:]))
+}
+
+func readExtensionCalls(ctx context.Context, store dstore.Store, filename string) (map[string][]byte, error) {
+	reader, err := store.OpenObject(ctx, filename)
+	if err != nil {
+		if errors.Is(err, dstore.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	responses := make(map[string][]byte)
+	if err := json.Unmarshal(content, &responses); err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
@@ -0,0 +1,92 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+func TestExtensionCalls_OneObjectPerBlock(t *testing.T) {
+	ctx := context.Background()
+	objects := dstore.NewMockStore(nil)
+	var reads, writes, liveCalls int
+	objects.OpenObjectFunc = func(ctx context.Context, name string) (io.ReadCloser, error) {
+		reads++
+		content, found := objects.Files[name]
+		if !found {
+			return nil, dstore.ErrNotFound
+		}
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
+	objects.WriteObjectFunc = func(ctx context.Context, base string, f io.Reader) error {
+		writes++
+		content, err := io.ReadAll(f)
+		objects.Files[base] = content
+		return err
+	}
+
+	live := func(ctx context.Context, requestID string, clock *pbsubstreams.Clock, in []byte) ([]byte, error) {
+		liveCalls++
+		return append([]byte(fmt.Sprintf("live-%d:", clock.Number)), in...), nil
+	}
+	replayFails := func(ctx context.Context, requestID string, clock *pbsubstreams.Clock, in []byte) ([]byte, error) {
+		return nil, fmt.Errorf("extension called while replaying")
+	}
+
+	run := func(mode ExtensionCallsMode, ext WASMExtension, inputs ...string) (outputs []string, err error) {
+		recorded := recordedExtension(mode, "test_ext", "echo", ext)
+		executions := 0
+		module := &extensionCallsModule{
+			Module: &testModule{executions: &executions, execute: func(call *Call, _ int) {
+				for _, in := range inputs {
+					out, callErr := recorded(WithContext(ctx, call), "", call.Clock, []byte(in))
+					if callErr != nil {
+						err = callErr
+						return
+					}
+					outputs = append(outputs, string(out))
+				}
+			}},
+			mode:  mode,
+			store: objects,
+		}
+		call := NewCall(&pbsubstreams.Clock{Number: 42, Id: "abc"}, "map_ext", "map_ext", nil, nil)
+		_, execErr := module.ExecuteNewCall(ctx, call, nil, nil)
+		require.NoError(t, execErr)
+		return outputs, err
+	}
+
+	outputs, err := run(ExtensionCallsRecord, live, "a", "b", "a")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"live-42:a", "live-42:b", "live-42:a"}, outputs)
+	assert.Equal(t, 2, liveCalls)
+	assert.Equal(t, 1, reads)
+	assert.Equal(t, 1, writes)
+	assert.Len(t, objects.Files, 1)
+	assert.Contains(t, objects.Files, "map_ext/0000000042-abc.json")
+
+	outputs, err = run(ExtensionCallsRecord, live, "a", "b", "c")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"live-42:a", "live-42:b", "live-42:c"}, outputs)
+	assert.Equal(t, 3, liveCalls)
+	assert.Equal(t, 2, reads)
+	assert.Equal(t, 2, writes)
+
+	outputs, err = run(ExtensionCallsReplay, replayFails, "c", "a")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"live-42:c", "live-42:a"}, outputs)
+	assert.Equal(t, 3, reads)
+	assert.Equal(t, 2, writes)
+
+	_, err = run(ExtensionCallsReplay, replayFails, "d")
+	assert.ErrorContains(t, err, "no recorded response to replay at block 42")
+	assert.Equal(t, 2, writes)
+}
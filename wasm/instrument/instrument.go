@@ -0,0 +1,395 @@
+// Package instrument rewrites WASM binaries so the runtimes can enforce
+// execution limits deterministically, independently of the features each
+// runtime offers natively.
+package instrument
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// FuelGlobal is the name under which an instrumented module exports the
+// mutable i64 global holding the fuel left for the current call.
+const FuelGlobal = "__substreams_fuel"
+
+// FuelExhausted is written to the fuel global by the instrumented code right
+// before it traps, so the host can tell a fuel trap apart from any other
+// `unreachable` reached by the module.
+const FuelExhausted = math.MaxUint64
+
+// MemoryGrowGlobal is the name under which an instrumented module exports the
+// mutable i32 global holding the result of its last `memory.grow`.
+const MemoryGrowGlobal = "__substreams_memory_grow"
+
+// MemoryGrowFailed is the value of the memory grow global after a
+// `memory.grow` was refused, the i32 -1 as an unsigned value.
+const MemoryGrowFailed = math.MaxUint32
+
+// PageSize is the size of a WASM memory page.
+const PageSize = 65536
+
+type Config struct {
+	// Fuel makes every function decrement the fuel global while it runs, see
+	// FuelGlobal.
+	Fuel bool
+
+	// MaxMemoryPages caps the maximum size of the memories of the module, 0
+	// meaning no cap. The outcome of `memory.grow` is then tracked in the
+	// memory grow global, see MemoryGrowGlobal.
+	MaxMemoryPages uint32
+}
+
+func (c Config) enabled() bool {
+	return c.Fuel || c.MaxMemoryPages != 0
+}
+
+const (
+	sectionCustom    = 0
+	sectionImport    = 2
+	sectionMemory    = 5
+	sectionGlobal    = 6
+	sectionExport    = 7
+	sectionCode      = 10
+	importKindMemory = 2
+	importKindGlobal = 3
+	exportKindGlobal = 3
+)
+
+// sectionOrder gives the position each known section must have in a module,
+// custom sections can appear anywhere.
+var sectionOrder = map[byte]int{1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 13: 6, 6: 7, 7: 8, 8: 9, 9: 10, 12: 11, 10: 12, 11: 13}
+
+// Instrument rewrites the WASM binary `code` according to `config`. The code
+// is returned as-is when nothing is enabled.
+//
+// Fuel charging happens at the start of the function body and of every
+// `block`, `loop`, `if` and `else`, for the count of instructions directly
+// contained in that block; loops are therefore charged on each iteration.
+// The accounting is static and only depends on the code path taken, which
+// makes it deterministic across runs and machines.
+func Instrument(code []byte, config Config) ([]byte, error) {
+	if !config.enabled() {
+		return code, nil
+	}
+	if len(code) < 8 || !bytes.Equal(code[0:4], []byte("\x00asm")) {
+		return nil, errors.New("invalid wasm binary: missing magic header")
+	}
+
+	type section struct {
+		id      byte
+		content []byte
+	}
+
+	r := &reader{buf: code, pos: 8}
+	var sections []section
+	var importedGlobals, definedGlobals uint32
+	for r.pos < len(r.buf) && r.err == nil {
+		id := r.byte()
+		size := r.u32()
+		content := r.bytes(int(size))
+		if r.err != nil {
+			break
+		}
+		if id == sectionCustom {
+			if name := (&reader{buf: content}).name(); strings.HasPrefix(name, ".debug_") {
+				// DWARF sections refer to code offsets, which are shifted by the instrumentation
+				continue
+			}
+		}
+		switch id {
+		case sectionImport:
+			count, err := countImportedGlobals(content)
+			if err != nil {
+				return nil, fmt.Errorf("reading import section: %w", err)
+			}
+			importedGlobals = count
+		case sectionGlobal:
+			definedGlobals = (&reader{buf: content}).u32()
+		}
+		sections = append(sections, section{id: id, content: content})
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("reading sections: %w", r.err)
+	}
+
+	var newGlobals, newExports [][]byte
+	addGlobal := func(name string, global []byte) uint32 {
+		index := importedGlobals + definedGlobals + uint32(len(newGlobals))
+		newGlobals = append(newGlobals, global)
+		export := appendName(nil, name)
+		export = append(export, exportKindGlobal)
+		newExports = append(newExports, appendU32(export, index))
+		return index
+	}
+
+	body := &bodyInstrumentation{}
+	if config.Fuel {
+		body.fuel = true
+		body.fuelIndex = addGlobal(FuelGlobal, []byte{0x7E, 0x01, 0x42, 0x00, 0x0B}) // mut i64, init expr: i64.const 0
+	}
+	if config.MaxMemoryPages != 0 {
+		body.memoryGrow = true
+		body.memoryGrowIndex = addGlobal(MemoryGrowGlobal, []byte{0x7F, 0x01, 0x41, 0x00, 0x0B}) // mut i32, init expr: i32.const 0
+	}
+
+	out := append([]byte{}, code[0:8]...)
+	var globalDone, exportDone bool
+	emitMissing := func(beforeID byte) {
+		if !globalDone && sectionOrder[beforeID] > sectionOrder[sectionGlobal] {
+			out = appendSection(out, sectionGlobal, appendVecEntries(appendU32(nil, 0), newGlobals))
+			globalDone = true
+		}
+		if !exportDone && sectionOrder[beforeID] > sectionOrder[sectionExport] {
+			out = appendSection(out, sectionExport, appendVecEntries(appendU32(nil, 0), newExports))
+			exportDone = true
+		}
+	}
+
+	for _, s := range sections {
+		if s.id != sectionCustom {
+			emitMissing(s.id)
+		}
+
+		content := s.content
+		var err error
+		switch s.id {
+		case sectionImport:
+			if config.MaxMemoryPages != 0 {
+				if content, err = capImportedMemories(content, config.MaxMemoryPages); err != nil {
+					return nil, fmt.Errorf("capping imported memories: %w", err)
+				}
+			}
+		case sectionMemory:
+			if config.MaxMemoryPages != 0 {
+				if content, err = capMemories(content, config.MaxMemoryPages); err != nil {
+					return nil, fmt.Errorf("capping memories: %w", err)
+				}
+			}
+		case sectionGlobal:
+			content = appendVecEntries(content, newGlobals)
+			globalDone = true
+		case sectionExport:
+			content = appendVecEntries(content, newExports)
+			exportDone = true
+		case sectionCode:
+			if content, err = body.instrumentCodeSection(content); err != nil {
+				return nil, fmt.Errorf("instrumenting code section: %w", err)
+			}
+		}
+		out = appendSection(out, s.id, content)
+	}
+	emitMissing(math.MaxUint8)
+
+	return out, nil
+}
+
+func countImportedGlobals(content []byte) (uint32, error) {
+	r := &reader{buf: content}
+	var globals uint32
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		r.name()
+		r.name()
+		switch kind := r.byte(); kind {
+		case 0x00: // func
+			r.u32()
+		case 0x01: // table
+			r.byte()
+			r.limits()
+		case importKindMemory:
+			r.limits()
+		case importKindGlobal:
+			r.byte()
+			r.byte()
+			globals++
+		default:
+			return 0, fmt.Errorf("unknown import kind 0x%02x", kind)
+		}
+	}
+	return globals, r.err
+}
+
+func capImportedMemories(content []byte, maxPages uint32) ([]byte, error) {
+	r := &reader{buf: content}
+	count := r.u32()
+	out := appendU32(nil, count)
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		start := r.pos
+		r.name()
+		r.name()
+		kind := r.byte()
+		if kind == importKindMemory {
+			out = append(out, content[start:r.pos]...)
+			var err error
+			if out, err = r.capLimits(out, maxPages); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		switch kind {
+		case 0x00: // func
+			r.u32()
+		case 0x01: // table
+			r.byte()
+			r.limits()
+		case importKindGlobal:
+			r.byte()
+			r.byte()
+		default:
+			return nil, fmt.Errorf("unknown import kind 0x%02x", kind)
+		}
+		out = append(out, content[start:r.pos]...)
+	}
+	return out, r.err
+}
+
+func capMemories(content []byte, maxPages uint32) ([]byte, error) {
+	r := &reader{buf: content}
+	count := r.u32()
+	out := appendU32(nil, count)
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		var err error
+		if out, err = r.capLimits(out, maxPages); err != nil {
+			return nil, err
+		}
+	}
+	return out, r.err
+}
+
+type bodyInstrumentation struct {
+	fuel            bool
+	fuelIndex       uint32
+	memoryGrow      bool
+	memoryGrowIndex uint32
+}
+
+func (b *bodyInstrumentation) instrumentCodeSection(content []byte) ([]byte, error) {
+	r := &reader{buf: content}
+	count := r.u32()
+	out := appendU32(nil, count)
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		size := r.u32()
+		body := r.bytes(int(size))
+		if r.err != nil {
+			break
+		}
+		instrumented, err := b.instrumentFunctionBody(body)
+		if err != nil {
+			return nil, fmt.Errorf("function #%d: %w", i, err)
+		}
+		out = appendU32(out, uint32(len(instrumented)))
+		out = append(out, instrumented...)
+	}
+	return out, r.err
+}
+
+type meteredBlock struct {
+	start int
+	cost  uint64
+}
+
+type insertion struct {
+	pos  int
+	code []byte
+}
+
+func (b *bodyInstrumentation) instrumentFunctionBody(body []byte) ([]byte, error) {
+	r := &reader{buf: body}
+	localGroups := r.u32()
+	for i := uint32(0); i < localGroups && r.err == nil; i++ {
+		r.u32()
+		r.byte()
+	}
+
+	var insertions []insertion
+	stack := []*meteredBlock{{start: r.pos}}
+	var blocks []*meteredBlock
+	for len(stack) > 0 && r.err == nil {
+		op := r.byte()
+		if r.err != nil {
+			break
+		}
+		stack[len(stack)-1].cost++
+
+		switch op {
+		case 0x02, 0x03, 0x04: // block, loop, if
+			r.blockType()
+			stack = append(stack, &meteredBlock{start: r.pos})
+		case 0x05: // else
+			blocks = append(blocks, stack[len(stack)-1])
+			stack[len(stack)-1] = &meteredBlock{start: r.pos}
+		case 0x0B: // end
+			blocks = append(blocks, stack[len(stack)-1])
+			stack = stack[:len(stack)-1]
+		default:
+			if err := r.skipImmediates(op); err != nil {
+				return nil, fmt.Errorf("at offset %d: %w", r.pos, err)
+			}
+			if op == 0x40 && b.memoryGrow {
+				insertions = append(insertions, insertion{pos: r.pos, code: appendMemoryGrowTracking(nil, b.memoryGrowIndex)})
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.pos != len(body) {
+		return nil, fmt.Errorf("unexpected %d bytes after function end", len(body)-r.pos)
+	}
+
+	if b.fuel {
+		for _, block := range blocks {
+			insertions = append(insertions, insertion{pos: block.start, code: appendFuelCharge(nil, b.fuelIndex, block.cost)})
+		}
+	}
+	sort.SliceStable(insertions, func(i, j int) bool { return insertions[i].pos < insertions[j].pos })
+
+	out := make([]byte, 0, len(body)+len(insertions)*24)
+	prev := 0
+	for _, ins := range insertions {
+		out = append(out, body[prev:ins.pos]...)
+		out = append(out, ins.code...)
+		prev = ins.pos
+	}
+	return append(out, body[prev:]...), nil
+}
+
+// appendFuelCharge emits the equivalent of:
+//
+//	if fuel < cost { fuel = FuelExhausted; unreachable }
+//	fuel -= cost
+func appendFuelCharge(out []byte, fuelIndex uint32, cost uint64) []byte {
+	out = append(out, 0x23) // global.get
+	out = appendU32(out, fuelIndex)
+	out = append(out, 0x42) // i64.const
+	out = appendS64(out, int64(cost))
+	out = append(out, 0x54)       // i64.lt_u
+	out = append(out, 0x04, 0x40) // if (empty block type)
+	out = append(out, 0x42, 0x7F) // i64.const -1
+	out = append(out, 0x24)       // global.set
+	out = appendU32(out, fuelIndex)
+	out = append(out, 0x00) // unreachable
+	out = append(out, 0x0B) // end
+	out = append(out, 0x23) // global.get
+	out = appendU32(out, fuelIndex)
+	out = append(out, 0x42) // i64.const
+	out = appendS64(out, int64(cost))
+	out = append(out, 0x7D) // i64.sub
+	out = append(out, 0x24) // global.set
+	out = appendU32(out, fuelIndex)
+	return out
+}
+
+// appendMemoryGrowTracking emits, right after a `memory.grow`, the
+// equivalent of `memoryGrow = result` while leaving the result on the stack.
+func appendMemoryGrowTracking(out []byte, memoryGrowIndex uint32) []byte {
+	out = append(out, 0x24) // global.set
+	out = appendU32(out, memoryGrowIndex)
+	out = append(out, 0x23) // global.get
+	out = appendU32(out, memoryGrowIndex)
+	return out
+}
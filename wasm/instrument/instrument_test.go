@@ -0,0 +1,138 @@
+package instrument
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// (module
+//
+//	(func (export "spin") (loop (br 0)))
+//	(func (export "add") (param i32 i32) (result i32) (i32.add (local.get 0) (local.get 1))))
+var fuelTestModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x0a, 0x02, 0x60, 0x00, 0x00, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f,
+	0x03, 0x03, 0x02, 0x00, 0x01,
+	0x07, 0x0e, 0x02, 0x04, 's', 'p', 'i', 'n', 0x00, 0x00, 0x03, 'a', 'd', 'd', 0x00, 0x01,
+	0x0a, 0x11, 0x02,
+	0x07, 0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b,
+	0x07, 0x00, 0x20, 0x00, 0x20, 0x01, 0x6a, 0x0b,
+}
+
+// (module
+//
+//	(memory 1)
+//	(func (export "grow") (param i32) (result i32) (memory.grow (local.get 0))))
+var memoryTestModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x06, 0x01, 0x60, 0x01, 0x7f, 0x01, 0x7f,
+	0x03, 0x02, 0x01, 0x00,
+	0x05, 0x03, 0x01, 0x00, 0x01,
+	0x07, 0x08, 0x01, 0x04, 'g', 'r', 'o', 'w', 0x00, 0x00,
+	0x0a, 0x08, 0x01,
+	0x06, 0x00, 0x20, 0x00, 0x40, 0x00, 0x0b,
+}
+
+func setFuel(mod api.Module, fuel uint64) {
+	mod.ExportedGlobal(FuelGlobal).(api.MutableGlobal).Set(fuel)
+}
+
+func isFuelExhausted(mod api.Module) bool {
+	return mod.ExportedGlobal(FuelGlobal).Get() == FuelExhausted
+}
+
+func TestInstrument_Fuel(t *testing.T) {
+	ctx := context.Background()
+
+	code, err := Instrument(fuelTestModule, Config{Fuel: true})
+	require.NoError(t, err)
+
+	runtime := wazero.NewRuntime(ctx)
+	defer runtime.Close(ctx)
+
+	mod, err := runtime.Instantiate(ctx, code)
+	require.NoError(t, err)
+
+	setFuel(mod, 1000)
+	res, err := mod.ExportedFunction("add").Call(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3}, res)
+	assert.Equal(t, uint64(996), mod.ExportedGlobal(FuelGlobal).Get(), "local.get, local.get, i32.add and end should be charged")
+	assert.False(t, isFuelExhausted(mod))
+
+	setFuel(mod, 1000)
+	_, err = mod.ExportedFunction("spin").Call(ctx)
+	require.Error(t, err)
+	assert.True(t, isFuelExhausted(mod))
+
+	setFuel(mod, 1)
+	_, err = mod.ExportedFunction("add").Call(ctx, 1, 2)
+	require.Error(t, err)
+	assert.True(t, isFuelExhausted(mod))
+}
+
+func TestInstrument_MaxMemoryPages(t *testing.T) {
+	ctx := context.Background()
+
+	code, err := Instrument(memoryTestModule, Config{Fuel: true, MaxMemoryPages: 3})
+	require.NoError(t, err)
+
+	runtime := wazero.NewRuntime(ctx)
+	defer runtime.Close(ctx)
+
+	mod, err := runtime.Instantiate(ctx, code)
+	require.NoError(t, err)
+	setFuel(mod, 1000)
+	grow := mod.ExportedFunction("grow")
+
+	res, err := grow.Call(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1}, res, "previous size in pages")
+	assert.Equal(t, uint64(1), mod.ExportedGlobal(MemoryGrowGlobal).Get())
+
+	res, err = grow.Call(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{MemoryGrowFailed}, res)
+	assert.Equal(t, uint64(MemoryGrowFailed), mod.ExportedGlobal(MemoryGrowGlobal).Get())
+	assert.Equal(t, uint32(3*PageSize), mod.Memory().Size())
+
+	_, err = Instrument(memoryTestModule, Config{MaxMemoryPages: 0})
+	require.NoError(t, err)
+
+	code, err = Instrument(memoryTestModule, Config{})
+	require.NoError(t, err)
+	assert.Equal(t, memoryTestModule, code, "nothing to instrument")
+}
+
+func TestInstrument_MaxMemoryPages_BelowInitial(t *testing.T) {
+	_, err := Instrument(memoryTestModule, Config{MaxMemoryPages: 1})
+	require.NoError(t, err)
+
+	module := append([]byte{}, memoryTestModule...)
+	module[24] = 0x02 // initial memory of 2 pages
+	_, err = Instrument(module, Config{MaxMemoryPages: 1})
+	require.EqualError(t, err, "capping memories: memory requires 2 pages initially, limit is 1 pages")
+}
+
+func TestInstrument_RustModule(t *testing.T) {
+	ctx := context.Background()
+
+	original, err := os.ReadFile("../bench/substreams_wasm/substreams.wasm")
+	require.NoError(t, err)
+
+	code, err := Instrument(original, Config{Fuel: true, MaxMemoryPages: 1024})
+	require.NoError(t, err)
+
+	runtime := wazero.NewRuntime(ctx)
+	defer runtime.Close(ctx)
+
+	mod, err := runtime.CompileModule(ctx, code)
+	require.NoError(t, err)
+	assert.Contains(t, mod.ExportedFunctions(), "map_block")
+}
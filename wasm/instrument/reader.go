@@ -0,0 +1,251 @@
+package instrument
+
+import (
+	"errors"
+	"fmt"
+)
+
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+var errUnexpectedEnd = errors.New("unexpected end of wasm binary")
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.buf) {
+		r.err = errUnexpectedEnd
+		return 0
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.buf) {
+		r.err = errUnexpectedEnd
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) u32() uint32 {
+	var result uint32
+	for shift := 0; shift < 35; shift += 7 {
+		b := r.byte()
+		result |= uint32(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return result
+		}
+	}
+	if r.err == nil {
+		r.err = errors.New("invalid unsigned LEB128 value")
+	}
+	return 0
+}
+
+func (r *reader) skipSigned() {
+	for i := 0; i < 10; i++ {
+		if b := r.byte(); b&0x80 == 0 {
+			return
+		}
+	}
+	if r.err == nil {
+		r.err = errors.New("invalid signed LEB128 value")
+	}
+}
+
+func (r *reader) name() string {
+	return string(r.bytes(int(r.u32())))
+}
+
+func (r *reader) limits() {
+	if flags := r.byte(); flags&0x01 != 0 {
+		r.u32()
+		r.u32()
+		return
+	}
+	r.u32()
+}
+
+// capLimits reads memory limits and appends them to `out` with their maximum
+// lowered to `maxPages`.
+func (r *reader) capLimits(out []byte, maxPages uint32) ([]byte, error) {
+	flags := r.byte()
+	if flags&0x04 != 0 {
+		return nil, errors.New("64-bit memories are not supported")
+	}
+	min := r.u32()
+	max := maxPages
+	if flags&0x01 != 0 {
+		if declared := r.u32(); declared < max {
+			max = declared
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if min > maxPages {
+		return nil, fmt.Errorf("memory requires %d pages initially, limit is %d pages", min, maxPages)
+	}
+	out = append(out, flags|0x01)
+	out = appendU32(out, min)
+	return appendU32(out, max), nil
+}
+
+func (r *reader) memArg() {
+	r.u32() // align
+	r.u32() // offset
+}
+
+func (r *reader) blockType() {
+	if r.err != nil || r.pos >= len(r.buf) {
+		r.err = errUnexpectedEnd
+		return
+	}
+	switch r.buf[r.pos] {
+	case 0x40, 0x7F, 0x7E, 0x7D, 0x7C, 0x7B, 0x70, 0x6F:
+		r.pos++
+	default:
+		r.skipSigned() // type index
+	}
+}
+
+func (r *reader) skipImmediates(op byte) error {
+	switch {
+	case op == 0x00, op == 0x01, op == 0x0F, op == 0x1A, op == 0x1B, op == 0xD1:
+	case op >= 0x45 && op <= 0xC4: // numeric instructions
+	case op == 0x0C, op == 0x0D, op == 0x10, op == 0x12, op == 0xD2:
+		r.u32()
+	case op == 0x0E: // br_table
+		count := r.u32()
+		for i := uint32(0); i <= count && r.err == nil; i++ {
+			r.u32()
+		}
+	case op == 0x11, op == 0x13: // call_indirect, return_call_indirect
+		r.u32()
+		r.u32()
+	case op == 0x1C: // select with types
+		r.bytes(int(r.u32()))
+	case op >= 0x20 && op <= 0x26: // locals, globals, table.get/set
+		r.u32()
+	case op >= 0x28 && op <= 0x3E: // loads and stores
+		r.memArg()
+	case op == 0x3F, op == 0x40: // memory.size, memory.grow
+		r.byte()
+	case op == 0x41, op == 0x42:
+		r.skipSigned()
+	case op == 0x43:
+		r.bytes(4)
+	case op == 0x44:
+		r.bytes(8)
+	case op == 0xD0: // ref.null
+		r.byte()
+	case op == 0xFC:
+		return r.skipMiscImmediates()
+	case op == 0xFD:
+		return r.skipVectorImmediates()
+	case op == 0xFE: // atomics
+		if sub := r.u32(); sub == 0x03 {
+			r.byte() // atomic.fence
+		} else {
+			r.memArg()
+		}
+	default:
+		return fmt.Errorf("unsupported opcode 0x%02x", op)
+	}
+	return r.err
+}
+
+func (r *reader) skipMiscImmediates() error {
+	switch sub := r.u32(); {
+	case sub <= 7: // saturating truncations
+	case sub == 8: // memory.init
+		r.u32()
+		r.byte()
+	case sub == 9, sub == 13, sub == 15, sub == 16, sub == 17: // data.drop, elem.drop, table.grow/size/fill
+		r.u32()
+	case sub == 10: // memory.copy
+		r.byte()
+		r.byte()
+	case sub == 11: // memory.fill
+		r.byte()
+	case sub == 12, sub == 14: // table.init, table.copy
+		r.u32()
+		r.u32()
+	default:
+		return fmt.Errorf("unsupported opcode 0xFC %d", sub)
+	}
+	return r.err
+}
+
+func (r *reader) skipVectorImmediates() error {
+	switch sub := r.u32(); {
+	case sub <= 11, sub == 92, sub == 93: // loads and stores
+		r.memArg()
+	case sub == 12, sub == 13: // v128.const, i8x16.shuffle
+		r.bytes(16)
+	case sub >= 21 && sub <= 34: // lane extraction and replacement
+		r.byte()
+	case sub >= 84 && sub <= 91: // lane loads and stores
+		r.memArg()
+		r.byte()
+	}
+	return r.err
+}
+
+func appendU32(out []byte, v uint32) []byte {
+	for {
+		b := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func appendS64(out []byte, v int64) []byte {
+	for {
+		b := byte(v & 0x7F)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func appendName(out []byte, name string) []byte {
+	out = appendU32(out, uint32(len(name)))
+	return append(out, name...)
+}
+
+func appendSection(out []byte, id byte, content []byte) []byte {
+	out = append(out, id)
+	out = appendU32(out, uint32(len(content)))
+	return append(out, content...)
+}
+
+// appendVecEntries adds `entries` to the WASM vector encoded in `content`,
+// which is a count followed by the entries.
+func appendVecEntries(content []byte, entries [][]byte) []byte {
+	r := &reader{buf: content}
+	count := r.u32()
+	out := appendU32(nil, count+uint32(len(entries)))
+	out = append(out, content[r.pos:]...)
+	for _, entry := range entries {
+		out = append(out, entry...)
+	}
+	return out
+}
@@ -2,6 +2,10 @@ package wasm
 
 import (
 	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/maps"
 
 	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
 )
@@ -33,6 +37,24 @@ func (f ModuleFactoryFunc) NewModule(ctx context.Context, wasmCode []byte, regis
 	return f(ctx, wasmCode, registry)
 }
 
+// BinaryTypeModuleFactory is implemented by the ModuleFactory supporting
+// other binary types than BinaryTypeRustV1. The `binaryType` is one of the
+// BinaryType* constants, and tells which host imports the code expects.
+type BinaryTypeModuleFactory interface {
+	ModuleFactory
+	NewModuleWithBinaryType(ctx context.Context, code []byte, binaryType string, registry *Registry) (module Module, err error)
+}
+
+type BinaryTypeModuleFactoryFunc func(ctx context.Context, wasmCode []byte, binaryType string, registry *Registry) (module Module, err error)
+
+func (f BinaryTypeModuleFactoryFunc) NewModule(ctx context.Context, wasmCode []byte, registry *Registry) (module Module, err error) {
+	return f(ctx, wasmCode, BinaryTypeRustV1, registry)
+}
+
+func (f BinaryTypeModuleFactoryFunc) NewModuleWithBinaryType(ctx context.Context, wasmCode []byte, binaryType string, registry *Registry) (module Module, err error) {
+	return f(ctx, wasmCode, binaryType, registry)
+}
+
 // A Module is a cached or pre-compiled version able to generate new isolated
 // instances, and execute calls on them. It lives for the duration of a stream.
 type Module interface {
@@ -69,3 +91,12 @@ var runtimes = map[string]ModuleFactory{}
 func RegisterModuleFactory(name string, factory ModuleFactory) {
 	runtimes[name] = factory
 }
+
+// ValidateRuntime returns an error when no runtime `name` is registered, a
+// runtime being registered by importing its package.
+func ValidateRuntime(name string) error {
+	if _, found := runtimes[name]; !found {
+		return fmt.Errorf("could not find wasm runtime %q (valid values are %q)", name, strings.Join(maps.Keys(runtimes), ", "))
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package wasm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Profiler records, for the modules it is set up for, the time spent in
+// each stack of guest functions, and produces a pprof profile per module.
+//
+// Runtimes supporting profiling report the guest functions entered and exited
+// by a call with Enter and Exit, then Flush the call once its entrypoint
+// returns. The time elapsed between two events is charged to the stack
+// current at the time, so each frame gets its self time (host functions
+// included). Only the wazero runtime supports profiling.
+type Profiler struct {
+	modules map[string]bool
+	start   time.Time
+
+	lock     sync.Mutex
+	profiles map[string]map[string]*profileSample // by module, then by stack key
+}
+
+type profileSample struct {
+	stack    []string // leaf first, as in pprof
+	calls    int64
+	duration time.Duration
+}
+
+// callProfile holds the samples of a call until it is flushed.
+type callProfile struct {
+	stack   []string // root first
+	last    time.Time
+	samples map[string]*profileSample
+}
+
+func NewProfiler(modules []string) *Profiler {
+	p := &Profiler{
+		modules:  make(map[string]bool, len(modules)),
+		start:    time.Now(),
+		profiles: make(map[string]map[string]*profileSample),
+	}
+	for _, module := range modules {
+		p.modules[module] = true
+	}
+	return p
+}
+
+func (p *Profiler) Enter(call *Call, function string) {
+	if !p.modules[call.ModuleName] {
+		return
+	}
+	if call.profile == nil {
+		call.profile = &callProfile{samples: make(map[string]*profileSample)}
+	}
+	call.profile.charge(time.Now(), false)
+	call.profile.stack = append(call.profile.stack, function)
+}
+
+func (p *Profiler) Exit(call *Call) {
+	if call.profile == nil || len(call.profile.stack) == 0 {
+		return
+	}
+	call.profile.charge(time.Now(), true)
+	call.profile.stack = call.profile.stack[:len(call.profile.stack)-1]
+}
+
+// Flush adds the samples of `call` to the profile of its module. The frames
+// still on the stack, when the call failed, are charged up to now.
+func (p *Profiler) Flush(call *Call) {
+	profile := call.profile
+	if profile == nil {
+		return
+	}
+	call.profile = nil
+	if len(profile.stack) != 0 {
+		profile.charge(time.Now(), false)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	samples := p.profiles[call.ModuleName]
+	if samples == nil {
+		samples = make(map[string]*profileSample)
+		p.profiles[call.ModuleName] = samples
+	}
+	for key, sample := range profile.samples {
+		if existing, found := samples[key]; found {
+			existing.calls += sample.calls
+			existing.duration += sample.duration
+			continue
+		}
+		samples[key] = sample
+	}
+}
+
+func (c *callProfile) charge(now time.Time, exit bool) {
+	if len(c.stack) != 0 {
+		key := strings.Join(c.stack, "\x00")
+		sample, found := c.samples[key]
+		if !found {
+			sample = &profileSample{stack: make([]string, len(c.stack))}
+			for i, function := range c.stack {
+				sample.stack[len(c.stack)-1-i] = function
+			}
+			c.samples[key] = sample
+		}
+		sample.duration += now.Sub(c.last)
+		if exit {
+			sample.calls++
+		}
+	}
+	c.last = now
+}
+
+// Modules returns the modules having a profile, sorted.
+func (p *Profiler) Modules() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var out []string
+	for module, samples := range p.profiles {
+		if len(samples) != 0 {
+			out = append(out, module)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Profile returns the gzipped pprof profile of `module`, nil when it has no
+// samples. Samples have two values, `calls/count` (the calls which returned)
+// and `cpu/nanoseconds` (the self time).
+func (p *Profiler) Profile(module string) []byte {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	samples := p.profiles[module]
+	if len(samples) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(samples))
+	for key := range samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	enc := &pprofEncoder{strings: map[string]int64{"": 0}, stringTable: []string{""}, functions: make(map[string]uint64)}
+	var out []byte
+	out = protowire.AppendTag(out, 1, protowire.BytesType) // sample_type
+	out = protowire.AppendBytes(out, enc.valueType("calls", "count"))
+	out = protowire.AppendTag(out, 1, protowire.BytesType)
+	out = protowire.AppendBytes(out, enc.valueType("cpu", "nanoseconds"))
+
+	for _, key := range keys {
+		sample := samples[key]
+		var locations, values []byte
+		for _, function := range sample.stack {
+			locations = protowire.AppendVarint(locations, enc.location(function))
+		}
+		values = protowire.AppendVarint(values, uint64(sample.calls))
+		values = protowire.AppendVarint(values, uint64(sample.duration.Nanoseconds()))
+
+		var msg []byte
+		msg = protowire.AppendTag(msg, 1, protowire.BytesType) // location_id
+		msg = protowire.AppendBytes(msg, locations)
+		msg = protowire.AppendTag(msg, 2, protowire.BytesType) // value
+		msg = protowire.AppendBytes(msg, values)
+
+		out = protowire.AppendTag(out, 2, protowire.BytesType) // sample
+		out = protowire.AppendBytes(out, msg)
+	}
+
+	out = append(out, enc.locations...)
+	out = append(out, enc.functionsMsg...)
+	periodType := enc.valueType("cpu", "nanoseconds")
+	for _, s := range enc.stringTable {
+		out = protowire.AppendTag(out, 6, protowire.BytesType) // string_table
+		out = protowire.AppendString(out, s)
+	}
+	out = protowire.AppendTag(out, 9, protowire.VarintType) // time_nanos
+	out = protowire.AppendVarint(out, uint64(p.start.UnixNano()))
+	out = protowire.AppendTag(out, 10, protowire.VarintType) // duration_nanos
+	out = protowire.AppendVarint(out, uint64(time.Since(p.start).Nanoseconds()))
+	out = protowire.AppendTag(out, 11, protowire.BytesType) // period_type
+	out = protowire.AppendBytes(out, periodType)
+	out = protowire.AppendTag(out, 12, protowire.VarintType) // period
+	out = protowire.AppendVarint(out, 1)
+
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	gz.Write(out)
+	gz.Close()
+	return buf.Bytes()
+}
+
+// pprofEncoder builds the string table, and a location with a single
+// function for each guest function, of a pprof profile.
+type pprofEncoder struct {
+	strings      map[string]int64
+	stringTable  []string
+	functions    map[string]uint64
+	locations    []byte
+	functionsMsg []byte
+}
+
+func (e *pprofEncoder) str(s string) uint64 {
+	idx, found := e.strings[s]
+	if !found {
+		idx = int64(len(e.stringTable))
+		e.strings[s] = idx
+		e.stringTable = append(e.stringTable, s)
+	}
+	return uint64(idx)
+}
+
+func (e *pprofEncoder) valueType(typ, unit string) []byte {
+	var msg []byte
+	msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, e.str(typ))
+	msg = protowire.AppendTag(msg, 2, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, e.str(unit))
+	return msg
+}
+
+// location returns the ID of the location of `function`, which is also the
+// ID of the function.
+func (e *pprofEncoder) location(function string) uint64 {
+	if id, found := e.functions[function]; found {
+		return id
+	}
+	id := uint64(len(e.functions) + 1)
+	e.functions[function] = id
+
+	var fn []byte
+	fn = protowire.AppendTag(fn, 1, protowire.VarintType) // id
+	fn = protowire.AppendVarint(fn, id)
+	fn = protowire.AppendTag(fn, 2, protowire.VarintType) // name
+	fn = protowire.AppendVarint(fn, e.str(function))
+	fn = protowire.AppendTag(fn, 3, protowire.VarintType) // system_name
+	fn = protowire.AppendVarint(fn, e.str(function))
+	e.functionsMsg = protowire.AppendTag(e.functionsMsg, 5, protowire.BytesType)
+	e.functionsMsg = protowire.AppendBytes(e.functionsMsg, fn)
+
+	var line []byte
+	line = protowire.AppendTag(line, 1, protowire.VarintType) // function_id
+	line = protowire.AppendVarint(line, id)
+	var loc []byte
+	loc = protowire.AppendTag(loc, 1, protowire.VarintType) // id
+	loc = protowire.AppendVarint(loc, id)
+	loc = protowire.AppendTag(loc, 4, protowire.BytesType) // line
+	loc = protowire.AppendBytes(loc, line)
+	e.locations = protowire.AppendTag(e.locations, 4, protowire.BytesType)
+	e.locations = protowire.AppendBytes(e.locations, loc)
+	return id
+}
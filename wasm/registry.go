@@ -3,11 +3,17 @@ package wasm
 import (
 	"context"
 	"fmt"
+	"math"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/streamingfast/dstore"
 	"go.uber.org/zap"
 	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
+	"github.com/streamingfast/substreams/wasm/instrument"
 )
 
 // Registry from Substreams's perspective is a singleton that is
@@ -16,19 +22,81 @@ import (
 type Registry struct {
 	Extensions           map[string]map[string]WASMExtension
 	maxFuel              uint64
+	runtimeName          string
 	runtimeStack         ModuleFactory
 	instanceCacheEnabled bool
+	compilationCache     *CompilationCache
+	maxMemory            uint64
+	executionTimeout     time.Duration
+	determinismCheck     bool
+	determinismRuntime   string
+	profiler             *Profiler
+	extensionCallsMode   ExtensionCallsMode
+	extensionCallsStore  dstore.Store
+}
+
+type RegistryOption func(r *Registry)
+
+// WithCompilationCache makes the runtime reuse the modules compiled by
+// previous requests, see CompilationCache.
+func WithCompilationCache(cache *CompilationCache) RegistryOption {
+	return func(r *Registry) {
+		r.compilationCache = cache
+	}
+}
+
+// WithMaxMemory caps the linear memory of each module instance to `bytes`,
+// rounded down to a whole number of WASM pages. A module failing to grow its
+// memory past that limit fails with a deterministic error. 0 means no limit.
+func WithMaxMemory(bytes uint64) RegistryOption {
+	return func(r *Registry) {
+		r.maxMemory = bytes
+	}
+}
+
+// WithExecutionTimeout bounds the wall-clock time of each module execution
+// (one call for one block). 0 means no limit.
+func WithExecutionTimeout(timeout time.Duration) RegistryOption {
+	return func(r *Registry) {
+		r.executionTimeout = timeout
+	}
 }
 
-func (r *Registry) registerWASMExtension(namespace string, importName string, ext WASMExtension) {
-	if namespace == "state" {
-		panic("cannot extend 'state' wasm namespace")
+// WithDeterminismCheck executes every call twice, the second time on the
+// `runtime` given (the runtime of the registry if empty) with a fresh
+// instance, and fails with a DeterminismError when the outputs or store
+// deltas of both executions differ.
+func WithDeterminismCheck(runtime string) RegistryOption {
+	return func(r *Registry) {
+		r.determinismCheck = true
+		r.determinismRuntime = runtime
 	}
-	if namespace == "env" {
-		panic("cannot extend 'env' wasm namespace")
+}
+
+// WithProfiler records the time spent in the guest functions of the modules
+// set up in `profiler`, see Profiler.
+func WithProfiler(profiler *Profiler) RegistryOption {
+	return func(r *Registry) {
+		r.profiler = profiler
 	}
-	if namespace == "logger" {
-		panic("cannot extend 'logger' wasm namespace")
+}
+
+// reservedNamespaces are the module names of the host functions provided
+// by the runtimes, which the WASM extensions cannot use.
+var reservedNamespaces = []string{"state", "env", "logger", CryptoNamespace, WASINamespace}
+
+// ValidateExtensionNamespace returns an error when `namespace` is reserved
+// for the host functions provided by the runtimes.
+func ValidateExtensionNamespace(namespace string) error {
+	if slices.Contains(reservedNamespaces, namespace) {
+		return fmt.Errorf("cannot extend reserved %q wasm namespace (reserved namespaces are %q)", namespace, strings.Join(reservedNamespaces, ", "))
+	}
+	return nil
+}
+
+func (r *Registry) registerWASMExtension(namespace string, importName string, ext WASMExtension) error {
+	if err := ValidateExtensionNamespace(namespace); err != nil {
+		return err
 	}
 
 	if r.Extensions == nil {
@@ -38,18 +106,88 @@ func (r *Registry) registerWASMExtension(namespace string, importName string, ex
 		r.Extensions[namespace] = map[string]WASMExtension{}
 	}
 	if r.Extensions[namespace][importName] != nil {
-		panic(fmt.Sprintf("wasm extension namespace %q function %q already defined", namespace, importName))
+		return fmt.Errorf("wasm extension namespace %q function %q already defined", namespace, importName)
 	}
 	r.Extensions[namespace][importName] = ext
+	return nil
 }
+
 func (r *Registry) MaxFuel() uint64            { return r.maxFuel }
 func (r *Registry) InstanceCacheEnabled() bool { return r.instanceCacheEnabled }
 
+func (r *Registry) MaxMemory() uint64               { return r.maxMemory }
+func (r *Registry) ExecutionTimeout() time.Duration { return r.executionTimeout }
+
+// MaxMemoryPages is MaxMemory in WASM pages, at least 1 when a limit is set.
+func (r *Registry) MaxMemoryPages() uint32 {
+	if r.maxMemory == 0 {
+		return 0
+	}
+	pages := r.maxMemory / instrument.PageSize
+	if pages == 0 {
+		return 1
+	}
+	if pages > math.MaxUint16+1 {
+		return math.MaxUint16 + 1 // the 4GiB addressable by 32-bit memories
+	}
+	return uint32(pages)
+}
+
+// CompilationCache returns nil when no cache was configured.
+func (r *Registry) CompilationCache() *CompilationCache { return r.compilationCache }
+
+// Profiler is nil when profiling is disabled.
+func (r *Registry) Profiler() *Profiler { return r.profiler }
+
 func (r *Registry) NewModule(ctx context.Context, wasmCode []byte) (Module, error) {
-	return r.runtimeStack.NewModule(ctx, wasmCode, r)
+	return r.NewModuleWithBinaryType(ctx, wasmCode, BinaryTypeRustV1)
+}
+
+// NewModuleWithBinaryType is NewModule for code of `binaryType`, one of the
+// BinaryType* constants.
+func (r *Registry) NewModuleWithBinaryType(ctx context.Context, wasmCode []byte, binaryType string) (Module, error) {
+	if err := ValidateBinaryType(binaryType); err != nil {
+		return nil, err
+	}
+	module, err := r.newModule(ctx, r.runtimeStack, wasmCode, binaryType)
+	if err != nil || !r.determinismCheck {
+		return module, err
+	}
+
+	runtimeName := r.determinismRuntime
+	if runtimeName == "" {
+		runtimeName = r.runtimeName
+	}
+	shadowFactory, found := runtimes[runtimeName]
+	if !found {
+		module.Close(ctx)
+		return nil, fmt.Errorf("could not find wasm runtime %q for the determinism check (valid values are %q)", runtimeName, strings.Join(maps.Keys(runtimes), ", "))
+	}
+	shadow, err := r.newModule(ctx, shadowFactory, wasmCode, binaryType)
+	if err != nil {
+		module.Close(ctx)
+		return nil, fmt.Errorf("creating %s module for the determinism check: %w", runtimeName, err)
+	}
+	return &determinismCheckModule{Module: module, shadow: shadow}, nil
 }
 
-func NewRegistry(extensions []WASMExtensioner, maxFuel uint64) *Registry {
+func (r *Registry) newModule(ctx context.Context, factory ModuleFactory, wasmCode []byte, binaryType string) (Module, error) {
+	var module Module
+	var err error
+	if typedFactory, ok := factory.(BinaryTypeModuleFactory); ok {
+		module, err = typedFactory.NewModuleWithBinaryType(ctx, wasmCode, binaryType, r)
+	} else if binaryType == BinaryTypeRustV1 {
+		module, err = factory.NewModule(ctx, wasmCode, r)
+	} else {
+		return nil, fmt.Errorf("wasm runtime does not support binary type %q", binaryType)
+	}
+	if err != nil || r.extensionCallsMode == "" {
+		return module, err
+	}
+	return &extensionCallsModule{Module: module, mode: r.extensionCallsMode, store: r.extensionCallsStore}, nil
+}
+
+func NewRegistry(extensions []WASMExtensioner, maxFuel uint64, opts ...RegistryOption) *Registry {
 	runtimeName := "wazero" // default
 
 	if selectRuntime := os.Getenv("SUBSTREAMS_WASM_RUNTIME"); selectRuntime != "" {
@@ -61,18 +199,27 @@ func NewRegistry(extensions []WASMExtensioner, maxFuel uint64) *Registry {
 		zlog.Info("using default wasm runtime", zap.String("runtime", runtimeName))
 	}
 
-	return NewRegistryWithRuntime(runtimeName, extensions, maxFuel)
+	return NewRegistryWithRuntime(runtimeName, extensions, maxFuel, opts...)
 }
 
-func NewRegistryWithRuntime(runtimeName string, extensions []WASMExtensioner, maxFuel uint64) *Registry {
+func NewRegistryWithRuntime(runtimeName string, extensions []WASMExtensioner, maxFuel uint64, opts ...RegistryOption) *Registry {
 	r := &Registry{
-		maxFuel: maxFuel,
+		maxFuel:     maxFuel,
+		runtimeName: runtimeName,
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
 
 	for _, ext := range extensions {
 		for ns, exts := range ext.WASMExtensions() {
 			for name, ext := range exts {
-				r.registerWASMExtension(ns, name, ext)
+				if r.extensionCallsMode != "" {
+					ext = recordedExtension(r.extensionCallsMode, ns, name, ext)
+				}
+				if err := r.registerWASMExtension(ns, name, ext); err != nil {
+					panic(err)
+				}
 			}
 		}
 	}
@@ -82,11 +229,10 @@ func NewRegistryWithRuntime(runtimeName string, extensions []WASMExtensioner, ma
 		r.instanceCacheEnabled = true
 	}
 
-	var found bool
-	r.runtimeStack, found = runtimes[runtimeName]
-	if !found {
-		panic(fmt.Errorf("could not find wasm runtime %q (valid values are %q)", runtimeName, strings.Join(maps.Keys(runtimes), ", ")))
+	if err := ValidateRuntime(runtimeName); err != nil {
+		panic(err)
 	}
+	r.runtimeStack = runtimes[runtimeName]
 
 	return r
 }
@@ -0,0 +1,55 @@
+package wasm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+)
+
+func TestRegistry_RegisterWASMExtension(t *testing.T) {
+	ext := func(ctx context.Context, requestID string, clock *pbsubstreams.Clock, in []byte) ([]byte, error) {
+		return in, nil
+	}
+
+	r := &Registry{}
+	require.NoError(t, r.registerWASMExtension("eth", "rpc", ext))
+	assert.EqualError(t, r.registerWASMExtension("eth", "rpc", ext), `wasm extension namespace "eth" function "rpc" already defined`)
+
+	for _, namespace := range []string{"state", "env", "logger", "crypto", "wasi_snapshot_preview1"} {
+		assert.ErrorContains(t, r.registerWASMExtension(namespace, "rpc", ext), `cannot extend reserved "`+namespace+`" wasm namespace`)
+	}
+	assert.Len(t, r.Extensions, 1)
+}
+
+func TestRegistry_NewModuleWithBinaryType(t *testing.T) {
+	var created []string
+	RegisterModuleFactory("legacy-test", ModuleFactoryFunc(func(ctx context.Context, code []byte, registry *Registry) (Module, error) {
+		created = append(created, BinaryTypeRustV1)
+		return &testModule{}, nil
+	}))
+	defer delete(runtimes, "legacy-test")
+	RegisterModuleFactory("typed-test", BinaryTypeModuleFactoryFunc(func(ctx context.Context, code []byte, binaryType string, registry *Registry) (Module, error) {
+		created = append(created, binaryType)
+		return &testModule{}, nil
+	}))
+	defer delete(runtimes, "typed-test")
+
+	ctx := context.Background()
+	legacy := NewRegistryWithRuntime("legacy-test", nil, 0)
+	_, err := legacy.NewModule(ctx, nil)
+	require.NoError(t, err)
+	_, err = legacy.NewModuleWithBinaryType(ctx, nil, BinaryTypeWASIV1)
+	assert.EqualError(t, err, `wasm runtime does not support binary type "wasm/wasi-v1"`)
+
+	typed := NewRegistryWithRuntime("typed-test", nil, 0)
+	_, err = typed.NewModule(ctx, nil)
+	require.NoError(t, err)
+	_, err = typed.NewModuleWithBinaryType(ctx, nil, BinaryTypeWASIV1)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{BinaryTypeRustV1, BinaryTypeRustV1, BinaryTypeWASIV1}, created)
+}
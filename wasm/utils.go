@@ -2,6 +2,7 @@ package wasm
 
 import (
 	"fmt"
+	"time"
 )
 
 type PanicError struct {
@@ -18,3 +19,35 @@ func (e *PanicError) Error() string {
 func NewPanicError(message, filename string, lineNumber, columnNumber int) *PanicError {
 	return &PanicError{message, filename, lineNumber, columnNumber}
 }
+
+// Error is returned by the runtimes when the host aborted the execution of a
+// module for a deterministic reason, like exhausting its fuel.
+type Error struct {
+	Module string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("module %q: %s", e.Module, e.Reason)
+}
+
+func NewFuelExhaustedError(call *Call, maxFuel uint64) *Error {
+	return &Error{
+		Module: call.ModuleName,
+		Reason: fmt.Sprintf("fuel exhausted at block %d, limit is %d per block", call.Clock.GetNumber(), maxFuel),
+	}
+}
+
+func NewMemoryLimitExceededError(call *Call, maxMemory uint64) *Error {
+	return &Error{
+		Module: call.ModuleName,
+		Reason: fmt.Sprintf("memory limit exceeded at block %d, limit is %d bytes", call.Clock.GetNumber(), maxMemory),
+	}
+}
+
+func NewExecutionTimeoutError(call *Call, timeout time.Duration) *Error {
+	return &Error{
+		Module: call.ModuleName,
+		Reason: fmt.Sprintf("execution time limit exceeded at block %d, limit is %s per block", call.Clock.GetNumber(), timeout),
+	}
+}
@@ -0,0 +1,245 @@
+package wasm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// Binary types supported in `Binary.Type` of the manifest.
+const (
+	BinaryTypeRustV1 = "wasm/rust-v1"
+	// BinaryTypeWASIV1 modules are instantiated with the WASINamespace
+	// imports, so they can be written in any language targeting wasip1
+	// (TinyGo, AssemblyScript, ...). They must export `alloc` and `dealloc`,
+	// and are initialized through `_initialize` (the WASI reactor model).
+	BinaryTypeWASIV1 = "wasm/wasi-v1"
+)
+
+func ValidateBinaryType(binaryType string) error {
+	switch binaryType {
+	case BinaryTypeRustV1, BinaryTypeWASIV1:
+		return nil
+	}
+	return fmt.Errorf("unsupported binary type: %q, please use %q or %q", binaryType, BinaryTypeRustV1, BinaryTypeWASIV1)
+}
+
+// WASINamespace is the module name of the wasip1 imports. The functions
+// implemented here are deterministic: clocks return the timestamp of the
+// block, random bytes derive from the block ID and module name, there is no
+// filesystem, network, arguments or environment, and stdout/stderr lines go
+// to the module logs.
+//
+// The `call` given to the WASI functions is nil while the module runs its
+// initialization, which then sees a zero clock and a fixed random seed.
+const WASINamespace = "wasi_snapshot_preview1"
+
+// WASI errno values returned by the functions.
+const (
+	WASIErrnoSuccess uint32 = 0
+	WASIErrnoBadf    uint32 = 8
+	WASIErrnoFault   uint32 = 21
+	WASIErrnoInval   uint32 = 28
+	WASIErrnoNosys   uint32 = 52
+)
+
+const wasiClockThreadCputime uint32 = 3 // the last of the realtime, monotonic, process and thread clocks
+
+const (
+	wasiFdStdin uint32 = iota
+	wasiFdStdout
+	wasiFdStderr
+)
+
+const wasiFiletypeCharacterDevice = 2
+
+// WASIMemory is the view of the linear memory of a module the WASI functions
+// read from and write to. Both return false when out of range.
+type WASIMemory interface {
+	Read(offset, byteCount uint32) ([]byte, bool)
+	Write(offset uint32, v []byte) bool
+}
+
+func wasiWriteUint32(mem WASIMemory, offset, value uint32) bool {
+	return mem.Write(offset, binary.LittleEndian.AppendUint32(nil, value))
+}
+
+func wasiWriteUint64(mem WASIMemory, offset uint32, value uint64) bool {
+	return mem.Write(offset, binary.LittleEndian.AppendUint64(nil, value))
+}
+
+// WASIArgsSizesGet reports no arguments, WASIEnvironSizesGet no environment
+// variables, so `args_get` and `environ_get` have nothing to write and are
+// implemented by WASINoop.
+func WASIArgsSizesGet(mem WASIMemory, countPtr, bufSizePtr uint32) uint32 {
+	if !wasiWriteUint32(mem, countPtr, 0) || !wasiWriteUint32(mem, bufSizePtr, 0) {
+		return WASIErrnoFault
+	}
+	return WASIErrnoSuccess
+}
+
+func WASIEnvironSizesGet(mem WASIMemory, countPtr, bufSizePtr uint32) uint32 {
+	return WASIArgsSizesGet(mem, countPtr, bufSizePtr)
+}
+
+func WASINoop() uint32 { return WASIErrnoSuccess }
+
+func WASINosys() uint32 { return WASIErrnoNosys }
+
+// WASIClockResGet reports a one second resolution for all clocks, as block
+// timestamps have.
+func WASIClockResGet(mem WASIMemory, id, resultPtr uint32) uint32 {
+	if id > wasiClockThreadCputime {
+		return WASIErrnoInval
+	}
+	if !wasiWriteUint64(mem, resultPtr, 1_000_000_000) {
+		return WASIErrnoFault
+	}
+	return WASIErrnoSuccess
+}
+
+// WASIClockTimeGet returns the timestamp of the block in nanoseconds, for all
+// clocks.
+func WASIClockTimeGet(call *Call, mem WASIMemory, id, resultPtr uint32) uint32 {
+	if id > wasiClockThreadCputime {
+		return WASIErrnoInval
+	}
+	var now uint64
+	if call != nil && call.Clock.GetTimestamp() != nil {
+		now = uint64(call.Clock.Timestamp.AsTime().UnixNano())
+	}
+	if !wasiWriteUint64(mem, resultPtr, now) {
+		return WASIErrnoFault
+	}
+	return WASIErrnoSuccess
+}
+
+// WASIRandomGet fills the buffer with bytes from a stream seeded with the
+// block ID and module name, each call continuing the stream of the previous
+// ones within the same block.
+func WASIRandomGet(call *Call, mem WASIMemory, bufPtr, bufLen uint32) uint32 {
+	if call == nil {
+		call = &Call{}
+	}
+	if !mem.Write(bufPtr, call.wasiRandom(int(bufLen))) {
+		return WASIErrnoFault
+	}
+	return WASIErrnoSuccess
+}
+
+func (c *Call) wasiRandom(length int) []byte {
+	if c.wasiRandomSeed == nil {
+		seed := sha256.Sum256([]byte(c.Clock.GetId() + "\x00" + c.ModuleName))
+		c.wasiRandomSeed = seed[:]
+	}
+
+	out := make([]byte, 0, length+sha256.Size)
+	for len(out) < length {
+		h := sha256.New()
+		h.Write(c.wasiRandomSeed)
+		h.Write(binary.LittleEndian.AppendUint64(nil, c.wasiRandomCounter))
+		out = h.Sum(out)
+		c.wasiRandomCounter++
+	}
+	return out[:length]
+}
+
+// WASIFdFdstatGet describes stdin, stdout and stderr as character devices,
+// other file descriptors don't exist.
+func WASIFdFdstatGet(mem WASIMemory, fd, resultPtr uint32) uint32 {
+	if fd > wasiFdStderr {
+		return WASIErrnoBadf
+	}
+	stat := make([]byte, 24)
+	stat[0] = wasiFiletypeCharacterDevice
+	if !mem.Write(resultPtr, stat) {
+		return WASIErrnoFault
+	}
+	return WASIErrnoSuccess
+}
+
+// WASIFdBadf is used by functions taking a file descriptor they have no use
+// for: there are no preopened directories, so no files can be opened.
+func WASIFdBadf() uint32 { return WASIErrnoBadf }
+
+// WASIFdRead reads nothing from stdin.
+func WASIFdRead(mem WASIMemory, fd, iovs, iovsLen, resultPtr uint32) uint32 {
+	if fd != wasiFdStdin {
+		return WASIErrnoBadf
+	}
+	if !wasiWriteUint32(mem, resultPtr, 0) {
+		return WASIErrnoFault
+	}
+	return WASIErrnoSuccess
+}
+
+// WASIFdWrite appends what is written to stdout and stderr to the logs of
+// the call, line by line.
+func WASIFdWrite(call *Call, mem WASIMemory, fd, iovs, iovsLen, resultPtr uint32) uint32 {
+	if fd != wasiFdStdout && fd != wasiFdStderr {
+		return WASIErrnoBadf
+	}
+
+	var written uint32
+	for i := uint32(0); i < iovsLen; i++ {
+		iov, ok := mem.Read(iovs+i*8, 8)
+		if !ok {
+			return WASIErrnoFault
+		}
+		data, ok := mem.Read(binary.LittleEndian.Uint32(iov[0:4]), binary.LittleEndian.Uint32(iov[4:8]))
+		if !ok {
+			return WASIErrnoFault
+		}
+		if call != nil {
+			call.wasiOutput(fd, data)
+		}
+		written += uint32(len(data))
+	}
+
+	if !wasiWriteUint32(mem, resultPtr, written) {
+		return WASIErrnoFault
+	}
+	return WASIErrnoSuccess
+}
+
+func (c *Call) wasiOutput(fd uint32, data []byte) {
+	buf := &c.wasiStdout
+	if fd == wasiFdStderr {
+		buf = &c.wasiStderr
+	}
+	*buf = append(*buf, data...)
+	for {
+		idx := bytes.IndexByte(*buf, '\n')
+		switch {
+		case idx != -1 && idx <= MaxLogByteCount:
+			c.AppendLog(string((*buf)[:idx]))
+			*buf = (*buf)[idx+1:]
+		case len(*buf) >= MaxLogByteCount: // lines longer than a log entry are split
+			c.AppendLog(string((*buf)[:MaxLogByteCount]))
+			*buf = (*buf)[MaxLogByteCount:]
+		default:
+			return
+		}
+	}
+}
+
+// FlushWASIOutput logs what was written to stdout and stderr without a final
+// newline. Runtimes call it once the entrypoint returns.
+func (c *Call) FlushWASIOutput() {
+	for _, buf := range []*[]byte{&c.wasiStdout, &c.wasiStderr} {
+		if len(*buf) != 0 {
+			c.AppendLog(string(*buf))
+			*buf = nil
+		}
+	}
+}
+
+// WASIProcExit aborts the execution, whatever the exit code.
+func WASIProcExit(call *Call, code uint32) {
+	err := fmt.Errorf("wasi proc_exit called with code %d", code)
+	if call == nil {
+		panic(err)
+	}
+	call.ReturnError(err)
+}
@@ -0,0 +1,44 @@
+package wasmtime
+
+import (
+	"fmt"
+
+	"github.com/bytecodealliance/wasmtime-go/v4"
+
+	"github.com/streamingfast/substreams/wasm"
+)
+
+func (i *instance) keccak256(ptr, length, outputPtr int32) {
+	data := i.Heap.ReadBytes(ptr, length)
+	i.Heap.WriteAtPtr(i.CurrentCall.DoKeccak256(data), outputPtr, "keccak256")
+}
+
+func (i *instance) sha256(ptr, length, outputPtr int32) {
+	data := i.Heap.ReadBytes(ptr, length)
+	i.Heap.WriteAtPtr(i.CurrentCall.DoSha256(data), outputPtr, "sha256")
+}
+
+func (i *instance) secp256k1Recover(hashPtr, signaturePtr, outputPtr int32) int32 {
+	hash := i.Heap.ReadBytes(hashPtr, wasm.CryptoDigestSize)
+	signature := i.Heap.ReadBytes(signaturePtr, wasm.Secp256k1SignatureSize)
+	publicKey, ok := i.CurrentCall.DoSecp256k1Recover(hash, signature)
+	if !ok {
+		return 0
+	}
+	i.Heap.WriteAtPtr(publicKey, outputPtr, "secp256k1_recover")
+	return 1
+}
+
+func (i *instance) registerCryptoImports(linker *wasmtime.Linker) error {
+	functions := map[string]interface{}{}
+	functions["keccak256"] = i.keccak256
+	functions["sha256"] = i.sha256
+	functions["secp256k1_recover"] = i.secp256k1Recover
+
+	for n, f := range functions {
+		if err := linker.FuncWrap(wasm.CryptoNamespace, n, f); err != nil {
+			return fmt.Errorf("registering %s import: %w", n, err)
+		}
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/streamingfast/substreams/reqctx"
 	"github.com/streamingfast/substreams/wasm"
+	"github.com/streamingfast/substreams/wasm/instrument"
 )
 
 type instance struct {
@@ -30,6 +31,17 @@ func (i *instance) Close(ctx context.Context) error {
 	return nil
 }
 
+func (i *instance) resetMemoryGrow() {
+	if export := i.wasmInstance.GetExport(i.wasmStore, instrument.MemoryGrowGlobal); export != nil {
+		export.Global().Set(i.wasmStore, wasmtime.ValI32(0))
+	}
+}
+
+func (i *instance) isMemoryGrowFailed() bool {
+	export := i.wasmInstance.GetExport(i.wasmStore, instrument.MemoryGrowGlobal)
+	return export != nil && uint32(export.Global().Get(i.wasmStore).I32()) == instrument.MemoryGrowFailed
+}
+
 func (i *instance) Cleanup(ctx context.Context) error {
 	err := i.Heap.Clear()
 	if err != nil {
@@ -43,7 +55,7 @@ func (i *instance) newExtensionFunction(ctx context.Context, namespace, name str
 	return func(ptr, length, outputPtr int32) {
 		data := i.Heap.ReadBytes(ptr, length)
 
-		out, err := f(ctx, reqctx.Details(ctx).UniqueIDString(), i.CurrentCall.Clock, data)
+		out, err := f(wasm.WithContext(ctx, i.CurrentCall), reqctx.Details(ctx).UniqueIDString(), i.CurrentCall.Clock, data)
 		if err != nil {
 			panic(fmt.Errorf(`running wasm extension "%s::%s": %w`, namespace, name, err))
 		}
@@ -71,6 +83,10 @@ func (i *instance) newImports() error {
 	if err != nil {
 		return fmt.Errorf("registering state imports: %w", err)
 	}
+	err = i.registerCryptoImports(linker)
+	if err != nil {
+		return fmt.Errorf("registering crypto imports: %w", err)
+	}
 
 	if err = linker.FuncWrap("env", "register_panic",
 		func(msgPtr, msgLength int32, filenamePtr, filenameLength int32, lineNumber, columnNumber int32, caller *wasmtime.Caller) {
@@ -117,6 +133,8 @@ func (i *instance) registerStateImports(linker *wasmtime.Linker) error {
 	functions["set_if_not_exists"] = i.setIfNotExists
 	functions["append"] = i.append
 	functions["delete_prefix"] = i.deletePrefix
+	functions["delete_range"] = i.deleteRange
+	functions["delete_range_pointers"] = i.deleteRangePointers
 	functions["add_bigint"] = i.addBigInt
 	functions["add_bigdecimal"] = i.addBigDecimal
 	functions["add_bigfloat"] = i.addBigDecimal
@@ -132,12 +150,17 @@ func (i *instance) registerStateImports(linker *wasmtime.Linker) error {
 	functions["set_max_float64"] = i.setMaxFloat64
 	functions["set_max_bigdecimal"] = i.setMaxBigDecimal
 	functions["set_max_bigfloat"] = i.setMaxBigDecimal
+	functions["set_sum_int64"] = i.setSumInt64
+	functions["set_sum_float64"] = i.setSumFloat64
+	functions["set_sum_bigint"] = i.setSumBigInt
+	functions["set_sum_bigdecimal"] = i.setSumBigDecimal
 	functions["get_at"] = i.getAt
 	functions["get_first"] = i.getFirst
 	functions["get_last"] = i.getLast
 	functions["has_at"] = i.hasAt
 	functions["has_first"] = i.hasFirst
 	functions["has_last"] = i.hasLast
+	functions["scan_prefix"] = i.scanPrefix
 
 	for n, f := range functions {
 		if err := linker.FuncWrap("state", n, f); err != nil {
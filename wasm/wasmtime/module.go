@@ -2,31 +2,50 @@ package wasmtime
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	wasmtime "github.com/bytecodealliance/wasmtime-go/v4"
+	"go.uber.org/zap"
 
 	"github.com/streamingfast/substreams/wasm"
+	"github.com/streamingfast/substreams/wasm/instrument"
 )
 
 type Module struct {
 	module   *wasmtime.Module
 	engine   *wasmtime.Engine
 	registry *wasm.Registry
+	wasi     bool
+
+	stopEpochs chan struct{}
+	closeOnce  sync.Once
 }
 
+// epochTicksPerTimeout is the number of epochs a call can run for before
+// being interrupted, the engine's epoch being incremented every fraction of
+// the execution timeout. Each call thus gets its own deadline, within 10% of
+// the timeout, even when several run concurrently on the same engine.
+const epochTicksPerTimeout = 10
+
 func init() {
-	wasm.RegisterModuleFactory("wasmtime", wasm.ModuleFactoryFunc(newModule))
+	wasm.RegisterModuleFactory("wasmtime", wasm.BinaryTypeModuleFactoryFunc(newModule))
 }
 
-func newModule(ctx context.Context, wasmCode []byte, registry *wasm.Registry) (wasm.Module, error) {
+func newModule(ctx context.Context, wasmCode []byte, binaryType string, registry *wasm.Registry) (wasm.Module, error) {
 	cfg := wasmtime.NewConfig()
 	if registry.MaxFuel() != 0 {
 		cfg.SetConsumeFuel(true)
 	}
+	timeout := registry.ExecutionTimeout()
+	if timeout != 0 {
+		cfg.SetEpochInterruption(true)
+	}
 	engine := wasmtime.NewEngineWithConfig(cfg)
 
-	module, err := wasmtime.NewModule(engine, wasmCode)
+	module, err := compileModule(engine, wasmCode, registry)
 	if err != nil {
 		return nil, fmt.Errorf("creating new module: %w", err)
 	}
@@ -34,14 +53,82 @@ func newModule(ctx context.Context, wasmCode []byte, registry *wasm.Registry) (w
 	// TODO: IF POSSIBLE, hook up all the wasm imports at this point, not at
 	// instantiation time.
 
-	return &Module{
+	m := &Module{
 		module:   module,
 		engine:   engine,
 		registry: registry,
-	}, nil
+		wasi:     binaryType == wasm.BinaryTypeWASIV1,
+	}
+	if timeout != 0 {
+		m.stopEpochs = make(chan struct{})
+		go m.tickEpochs(timeout / epochTicksPerTimeout)
+	}
+	return m, nil
+}
+
+func (m *Module) tickEpochs(interval time.Duration) {
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.engine.IncrementEpoch()
+		case <-m.stopEpochs:
+			return
+		}
+	}
+}
+
+// compileModule compiles `wasmCode`, or deserializes it from the registry's
+// compilation cache when it was compiled before with the same engine settings.
+// Memory limits are enforced by instrumenting the code, fuel and timeouts are
+// handled natively by wasmtime.
+func compileModule(engine *wasmtime.Engine, wasmCode []byte, registry *wasm.Registry) (*wasmtime.Module, error) {
+	cache := registry.CompilationCache()
+	if cache == nil {
+		return instrumentAndCompile(engine, wasmCode, registry)
+	}
+
+	key := cache.Key("wasmtime", wasmCode, fmt.Sprintf("fuel=%t", registry.MaxFuel() != 0), fmt.Sprintf("memory=%d", registry.MaxMemoryPages()), fmt.Sprintf("epochs=%t", registry.ExecutionTimeout() != 0))
+	if artifact, found := cache.Get(key); found {
+		module, err := wasmtime.NewModuleDeserialize(engine, artifact)
+		if err == nil {
+			wasm.CountCompilation(true)
+			return module, nil
+		}
+		zlog.Warn("cannot deserialize cached wasm module, compiling it again", zap.Error(err))
+	}
+
+	wasm.CountCompilation(false)
+	module, err := instrumentAndCompile(engine, wasmCode, registry)
+	if err != nil {
+		return nil, err
+	}
+	artifact, err := module.Serialize()
+	if err != nil {
+		return nil, fmt.Errorf("serializing module: %w", err)
+	}
+	cache.Put(key, artifact)
+	return module, nil
+}
+
+func instrumentAndCompile(engine *wasmtime.Engine, wasmCode []byte, registry *wasm.Registry) (*wasmtime.Module, error) {
+	wasmCode, err := instrument.Instrument(wasmCode, instrument.Config{MaxMemoryPages: registry.MaxMemoryPages()})
+	if err != nil {
+		return nil, fmt.Errorf("instrumenting module: %w", err)
+	}
+	return wasmtime.NewModule(engine, wasmCode)
 }
 
 func (m *Module) Close(ctx context.Context) error {
+	m.closeOnce.Do(func() {
+		if m.stopEpochs != nil {
+			close(m.stopEpochs)
+		}
+	})
 	m.engine.FreeMem()
 	return nil
 }
@@ -85,6 +172,12 @@ func (m *Module) ExecuteNewCall(ctx context.Context, call *wasm.Call, cachedInst
 		}
 		inst.wasmStore.AddFuel(maxFuel)
 	}
+	if m.registry.MaxMemory() != 0 {
+		inst.resetMemoryGrow()
+	}
+	if m.registry.ExecutionTimeout() != 0 {
+		inst.wasmStore.SetEpochDeadline(epochTicksPerTimeout)
+	}
 
 	var args []interface{}
 	var inputStoreCount int
@@ -99,6 +192,9 @@ func (m *Module) ExecuteNewCall(ctx context.Context, call *wasm.Call, cachedInst
 			cnt := v.Value()
 			ptr, err := inst.Heap.Write(cnt, input.Name())
 			if err != nil {
+				if limitErr := m.limitError(call, inst, err); limitErr != nil {
+					return nil, limitErr
+				}
 				return nil, fmt.Errorf("writing %s to heap: %w", input.Name(), err)
 			}
 			length := int32(len(cnt))
@@ -110,16 +206,44 @@ func (m *Module) ExecuteNewCall(ctx context.Context, call *wasm.Call, cachedInst
 
 	inst.CurrentCall = call
 	_, err = entrypoint.Call(inst.wasmStore, args...)
+	call.FlushWASIOutput()
 	if err != nil {
+		if limitErr := m.limitError(call, inst, err); limitErr != nil {
+			return inst, limitErr
+		}
 		return inst, fmt.Errorf("call: %w", err)
 	}
 
 	return inst, nil
 }
 
+// limitError tells if a failed execution was stopped by one of the limits
+// enforced on the module, returning nil otherwise.
+func (m *Module) limitError(call *wasm.Call, inst *instance, err error) error {
+	if maxFuel := m.registry.MaxFuel(); maxFuel != 0 {
+		if remaining, _ := inst.wasmStore.ConsumeFuel(0); remaining == 0 {
+			return wasm.NewFuelExhaustedError(call, maxFuel)
+		}
+	}
+	if maxMemory := m.registry.MaxMemory(); maxMemory != 0 && inst.isMemoryGrowFailed() {
+		return wasm.NewMemoryLimitExceededError(call, maxMemory)
+	}
+	if timeout := m.registry.ExecutionTimeout(); timeout != 0 {
+		var trap *wasmtime.Trap
+		if errors.As(err, &trap) && trap.Code() != nil && *trap.Code() == wasmtime.Interrupt {
+			return wasm.NewExecutionTimeoutError(call, timeout)
+		}
+	}
+	return nil
+}
+
 func (m *Module) newInstance(ctx context.Context) (*instance, error) {
 	linker := wasmtime.NewLinker(m.engine)
 	store := wasmtime.NewStore(m.engine)
+	if m.registry.ExecutionTimeout() != 0 {
+		// with epoch interruption enabled, a store without deadline traps right away
+		store.SetEpochDeadline(epochTicksPerTimeout)
+	}
 
 	i := &instance{
 		wasmEngine: m.engine,
@@ -130,6 +254,11 @@ func (m *Module) newInstance(ctx context.Context) (*instance, error) {
 	if err := i.newImports(); err != nil {
 		return nil, fmt.Errorf("instantiating imports: %w", err)
 	}
+	if m.wasi {
+		if err := i.registerWASIImports(linker); err != nil {
+			return nil, fmt.Errorf("registering wasi imports: %w", err)
+		}
+	}
 	for namespace, imports := range m.registry.Extensions {
 		for importName, f := range imports {
 			f := i.newExtensionFunction(ctx, namespace, importName, f)
@@ -152,5 +281,16 @@ func (m *Module) newInstance(ctx context.Context) (*instance, error) {
 	heap := NewHeap(memory, alloc, dealloc, i.wasmStore)
 	i.Heap = heap
 	i.wasmInstance = instance
+
+	if m.wasi {
+		if initialize := instance.GetExport(i.wasmStore, "_initialize"); initialize != nil {
+			if maxFuel := m.registry.MaxFuel(); maxFuel != 0 {
+				i.wasmStore.AddFuel(maxFuel)
+			}
+			if _, err := initialize.Func().Call(i.wasmStore); err != nil {
+				return nil, fmt.Errorf("initializing wasi module: %w", err)
+			}
+		}
+	}
 	return i, nil
 }
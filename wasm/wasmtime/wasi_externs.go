@@ -0,0 +1,99 @@
+package wasmtime
+
+import (
+	"fmt"
+
+	"github.com/bytecodealliance/wasmtime-go/v4"
+
+	"github.com/streamingfast/substreams/wasm"
+)
+
+// wasiMemory gives the WASI functions access to the memory of the instance,
+// which is only known once instantiated.
+type wasiMemory struct {
+	i *instance
+}
+
+func (m wasiMemory) data() []byte {
+	return m.i.Heap.memory.UnsafeData(m.i.wasmStore)
+}
+
+func (m wasiMemory) Read(offset, byteCount uint32) ([]byte, bool) {
+	data := m.data()
+	if uint64(offset)+uint64(byteCount) > uint64(len(data)) {
+		return nil, false
+	}
+	return data[offset : offset+byteCount], true
+}
+
+func (m wasiMemory) Write(offset uint32, v []byte) bool {
+	data := m.data()
+	if uint64(offset)+uint64(len(v)) > uint64(len(data)) {
+		return false
+	}
+	copy(data[offset:], v)
+	return true
+}
+
+// registerWASIImports registers the wasip1 imports of `wasm/wasi-v1`
+// modules, see wasm.WASINamespace for their behavior.
+func (i *instance) registerWASIImports(linker *wasmtime.Linker) error {
+	mem := wasiMemory{i}
+	badf := func() int32 { return int32(wasm.WASIFdBadf()) }
+
+	functions := map[string]interface{}{}
+	functions["args_get"] = func(argv, argvBuf int32) int32 {
+		return int32(wasm.WASINoop())
+	}
+	functions["args_sizes_get"] = func(countPtr, bufSizePtr int32) int32 {
+		return int32(wasm.WASIArgsSizesGet(mem, uint32(countPtr), uint32(bufSizePtr)))
+	}
+	functions["environ_get"] = func(environ, environBuf int32) int32 {
+		return int32(wasm.WASINoop())
+	}
+	functions["environ_sizes_get"] = func(countPtr, bufSizePtr int32) int32 {
+		return int32(wasm.WASIEnvironSizesGet(mem, uint32(countPtr), uint32(bufSizePtr)))
+	}
+	functions["clock_res_get"] = func(id, resultPtr int32) int32 {
+		return int32(wasm.WASIClockResGet(mem, uint32(id), uint32(resultPtr)))
+	}
+	functions["clock_time_get"] = func(id int32, precision int64, resultPtr int32) int32 {
+		return int32(wasm.WASIClockTimeGet(i.CurrentCall, mem, uint32(id), uint32(resultPtr)))
+	}
+	functions["random_get"] = func(bufPtr, bufLen int32) int32 {
+		return int32(wasm.WASIRandomGet(i.CurrentCall, mem, uint32(bufPtr), uint32(bufLen)))
+	}
+	functions["fd_write"] = func(fd, iovs, iovsLen, resultPtr int32) int32 {
+		return int32(wasm.WASIFdWrite(i.CurrentCall, mem, uint32(fd), uint32(iovs), uint32(iovsLen), uint32(resultPtr)))
+	}
+	functions["fd_read"] = func(fd, iovs, iovsLen, resultPtr int32) int32 {
+		return int32(wasm.WASIFdRead(mem, uint32(fd), uint32(iovs), uint32(iovsLen), uint32(resultPtr)))
+	}
+	functions["fd_fdstat_get"] = func(fd, resultPtr int32) int32 {
+		return int32(wasm.WASIFdFdstatGet(mem, uint32(fd), uint32(resultPtr)))
+	}
+	functions["fd_close"] = func(fd int32) int32 { return badf() }
+	functions["fd_seek"] = func(fd int32, offset int64, whence, resultPtr int32) int32 { return badf() }
+	functions["fd_prestat_get"] = func(fd, resultPtr int32) int32 { return badf() }
+	functions["fd_prestat_dir_name"] = func(fd, pathPtr, pathLen int32) int32 { return badf() }
+	functions["path_open"] = func(fd, dirflags, pathPtr, pathLen, oflags int32, rightsBase, rightsInheriting int64, fdflags, resultPtr int32) int32 {
+		return badf()
+	}
+	functions["poll_oneoff"] = func(in, out, subscriptions, resultPtr int32) int32 {
+		return int32(wasm.WASINosys())
+	}
+	functions["sched_yield"] = func() int32 {
+		return int32(wasm.WASINoop())
+	}
+	functions["proc_exit"] = func(code int32) {
+		wasm.WASIProcExit(i.CurrentCall, uint32(code))
+	}
+
+	for n, f := range functions {
+		if err := linker.FuncWrap(wasm.WASINamespace, n, f); err != nil {
+			return fmt.Errorf("registering %s import: %w", n, err)
+		}
+	}
+
+	return nil
+}
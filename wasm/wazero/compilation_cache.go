@@ -0,0 +1,91 @@
+package wazero
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"go.uber.org/zap"
+)
+
+// compiledSizeFactor estimates the size of a compiled module, from the size
+// of its WASM code, when wazero keeps it in memory only.
+const compiledSizeFactor = 6
+
+// sharedCache is the wazero compilation cache shared by the runtimes, see
+// wasm.CompilationCache.Shared. The modules it compiles are tracked in the
+// wasm.CompilationCache, which bounds them, see wasm.CompilationCache.Hold.
+type sharedCache struct {
+	wazero.CompilationCache
+
+	dir     string // dir is the directory of the wasm.CompilationCache, empty when in memory only.
+	fileDir string // fileDir is the sub-directory of `dir` where wazero writes the compiled modules.
+}
+
+func newSharedCache(dir string) (*sharedCache, error) {
+	if dir == "" {
+		return &sharedCache{CompilationCache: wazero.NewCompilationCache()}, nil
+	}
+
+	cache, err := wazero.NewCompilationCacheWithDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	c := &sharedCache{CompilationCache: cache, dir: dir, fileDir: wazeroFileDir()}
+	if _, err := os.Stat(filepath.Join(dir, c.fileDir)); err != nil {
+		zlog.Warn("cannot find the wazero compilation cache directory, its files will not be evicted", zap.String("dir", dir), zap.Error(err))
+		c.fileDir = ""
+	}
+	return c, nil
+}
+
+// wazeroFileDir is the name of the directory where wazero writes the
+// compiled modules, named after its version like wazero does.
+func wazeroFileDir() string {
+	version := "dev"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if strings.Contains(dep.Path, "github.com/tetratelabs/wazero") && dep.Version != "" && dep.Version != "(devel)" {
+				version = dep.Version
+			}
+		}
+	}
+	return "wazero-" + version + "-" + goruntime.GOARCH + "-" + goruntime.GOOS
+}
+
+// key identifies the module of `code` in the wasm.CompilationCache: the path
+// of the file wazero writes it to, named after the module ID wazero computes.
+func (c *sharedCache) key(code []byte, ensureTermination bool) string {
+	h := sha256.New()
+	h.Write(code)
+	h.Write([]byte{0, boolToByte(ensureTermination)}) // without function listeners, see wasm.Module.AssignModuleID in wazero
+	id := hex.EncodeToString(h.Sum(nil))
+
+	if c.fileDir == "" {
+		return "wazero-compiled-" + id
+	}
+	return filepath.Join(c.fileDir, id)
+}
+
+// size is the size of the compiled module `key`, the size of its file when
+// written to disk.
+func (c *sharedCache) size(key string, code []byte) uint64 {
+	if c.fileDir != "" {
+		if info, err := os.Stat(filepath.Join(c.dir, key)); err == nil {
+			return uint64(info.Size())
+		}
+	}
+	return uint64(len(code)) * compiledSizeFactor
+}
+
+func boolToByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
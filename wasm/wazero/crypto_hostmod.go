@@ -0,0 +1,59 @@
+package wazero
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+
+	"github.com/streamingfast/substreams/wasm"
+)
+
+var cryptoFuncs = []funcs{
+	{
+		"keccak256",
+		[]parm{i32, i32, i32},
+		[]parm{},
+		api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			data := readBytesFromStack(mod, stack[0:])
+			call := wasm.FromContext(ctx)
+
+			writeCryptoOutput(mod, uint32(stack[2]), call.DoKeccak256(data))
+		}),
+	},
+	{
+		"sha256",
+		[]parm{i32, i32, i32},
+		[]parm{},
+		api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			data := readBytesFromStack(mod, stack[0:])
+			call := wasm.FromContext(ctx)
+
+			writeCryptoOutput(mod, uint32(stack[2]), call.DoSha256(data))
+		}),
+	},
+	{
+		"secp256k1_recover",
+		[]parm{i32, i32, i32},
+		[]parm{i32},
+		api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			hash := readBytes(mod, uint32(stack[0]), wasm.CryptoDigestSize)
+			signature := readBytes(mod, uint32(stack[1]), wasm.Secp256k1SignatureSize)
+			call := wasm.FromContext(ctx)
+
+			publicKey, ok := call.DoSecp256k1Recover(hash, signature)
+			if ok {
+				writeCryptoOutput(mod, uint32(stack[2]), publicKey)
+			}
+			setStack0Bool(stack, ok)
+		}),
+	},
+}
+
+// writeCryptoOutput writes to memory allocated by the caller, the outputs of
+// the crypto functions having a fixed size.
+func writeCryptoOutput(mod api.Module, outputPtr uint32, value []byte) {
+	if ok := mod.Memory().Write(outputPtr, value); !ok {
+		panic(fmt.Sprintf("could not write crypto output, ptr=%d, len=%d", outputPtr, len(value)))
+	}
+}
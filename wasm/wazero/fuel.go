@@ -0,0 +1,32 @@
+package wazero
+
+import (
+	"github.com/tetratelabs/wazero/api"
+
+	"github.com/streamingfast/substreams/wasm/instrument"
+)
+
+// fuelGlobalName is the name under which an instrumented module exports the
+// mutable i64 global holding the fuel left for the current call.
+const fuelGlobalName = instrument.FuelGlobal
+
+// fuelExhausted is written to the fuel global by the instrumented code right
+// before it traps, so the host can tell a fuel trap apart from any other
+// `unreachable` reached by the module.
+const fuelExhausted = instrument.FuelExhausted
+
+// instrumentFuel rewrites the WASM binary `code` so that every function
+// decrements a fuel counter before running each of its blocks, see
+// instrument.Instrument for the accounting.
+func instrumentFuel(code []byte) ([]byte, error) {
+	return instrument.Instrument(code, instrument.Config{Fuel: true})
+}
+
+func setFuel(mod api.Module, fuel uint64) {
+	mod.ExportedGlobal(fuelGlobalName).(api.MutableGlobal).Set(fuel)
+}
+
+func isFuelExhausted(mod api.Module) bool {
+	global := mod.ExportedGlobal(fuelGlobalName)
+	return global != nil && global.Get() == fuelExhausted
+}
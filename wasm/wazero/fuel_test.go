@@ -0,0 +1,72 @@
+package wazero
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tetratelabs/wazero"
+)
+
+// (module
+//
+//	(func (export "spin") (loop (br 0)))
+//	(func (export "add") (param i32 i32) (result i32) (i32.add (local.get 0) (local.get 1))))
+var fuelTestModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x0a, 0x02, 0x60, 0x00, 0x00, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f,
+	0x03, 0x03, 0x02, 0x00, 0x01,
+	0x07, 0x0e, 0x02, 0x04, 's', 'p', 'i', 'n', 0x00, 0x00, 0x03, 'a', 'd', 'd', 0x00, 0x01,
+	0x0a, 0x11, 0x02,
+	0x07, 0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b,
+	0x07, 0x00, 0x20, 0x00, 0x20, 0x01, 0x6a, 0x0b,
+}
+
+func TestInstrumentFuel(t *testing.T) {
+	ctx := context.Background()
+
+	code, err := instrumentFuel(fuelTestModule)
+	require.NoError(t, err)
+
+	runtime := wazero.NewRuntime(ctx)
+	defer runtime.Close(ctx)
+
+	mod, err := runtime.Instantiate(ctx, code)
+	require.NoError(t, err)
+
+	setFuel(mod, 1000)
+	res, err := mod.ExportedFunction("add").Call(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3}, res)
+	assert.Equal(t, uint64(996), mod.ExportedGlobal(fuelGlobalName).Get(), "local.get, local.get, i32.add and end should be charged")
+	assert.False(t, isFuelExhausted(mod))
+
+	setFuel(mod, 1000)
+	_, err = mod.ExportedFunction("spin").Call(ctx)
+	require.Error(t, err)
+	assert.True(t, isFuelExhausted(mod))
+
+	setFuel(mod, 1)
+	_, err = mod.ExportedFunction("add").Call(ctx, 1, 2)
+	require.Error(t, err)
+	assert.True(t, isFuelExhausted(mod))
+}
+
+func TestInstrumentFuel_RustModule(t *testing.T) {
+	ctx := context.Background()
+
+	original, err := os.ReadFile("../bench/substreams_wasm/substreams.wasm")
+	require.NoError(t, err)
+
+	code, err := instrumentFuel(original)
+	require.NoError(t, err)
+
+	runtime := wazero.NewRuntime(ctx)
+	defer runtime.Close(ctx)
+
+	mod, err := runtime.CompileModule(ctx, code)
+	require.NoError(t, err)
+	assert.Contains(t, mod.ExportedFunctions(), "map_block")
+}
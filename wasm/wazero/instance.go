@@ -9,6 +9,7 @@ import (
 type instance struct {
 	api.Module
 	allocations []allocation
+	maxFuel     uint64
 }
 
 type allocation struct {
@@ -21,6 +22,14 @@ func (i *instance) Cleanup(ctx context.Context) error {
 	return nil
 }
 
+// refuel gives the instance its full fuel budget back, it is a no-op when
+// fuel metering is disabled.
+func (i *instance) refuel() {
+	if i.maxFuel != 0 {
+		setFuel(i.Module, i.maxFuel)
+	}
+}
+
 func (i *instance) Close(ctx context.Context) error {
 	return i.Module.Close(ctx)
 }
@@ -0,0 +1,18 @@
+package wazero
+
+import (
+	"github.com/tetratelabs/wazero/api"
+
+	"github.com/streamingfast/substreams/wasm/instrument"
+)
+
+func resetMemoryGrow(mod api.Module) {
+	if global := mod.ExportedGlobal(instrument.MemoryGrowGlobal); global != nil {
+		global.(api.MutableGlobal).Set(0)
+	}
+}
+
+func isMemoryGrowFailed(mod api.Module) bool {
+	global := mod.ExportedGlobal(instrument.MemoryGrowGlobal)
+	return global != nil && global.Get() == instrument.MemoryGrowFailed
+}
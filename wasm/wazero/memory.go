@@ -43,6 +43,7 @@ func writeOutputToHeap(ctx context.Context, inst *instance, outputPtr uint32, va
 func deallocate(ctx context.Context, i *instance) {
 	//t0 := time.Now()
 	dealloc := i.ExportedFunction("dealloc")
+	i.refuel()
 	for _, alloc := range i.allocations {
 		//fmt.Println("  dealloc", alloc.ptr, alloc.length)
 		if err := dealloc.CallWithStack(ctx, []uint64{uint64(alloc.ptr), uint64(alloc.length)}); err != nil {
@@ -2,15 +2,18 @@ package wazero
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 
 	"github.com/streamingfast/substreams/reqctx"
 	"github.com/streamingfast/substreams/wasm"
+	"github.com/streamingfast/substreams/wasm/instrument"
 )
 
 // A Module represents a wazero.Runtime that clears and is destroyed upon completion of a request.
@@ -21,19 +24,43 @@ type Module struct {
 	wazModuleConfig wazero.ModuleConfig
 	hostModules     []wazero.CompiledModule
 	userModule      wazero.CompiledModule
+	maxFuel         uint64
+	maxMemory       uint64
+	timeout         time.Duration
+	sharedCompiled  bool   // compiled modules live in a compilation cache shared with other runtimes
+	release         func() // release lets the compilation cache evict the compiled module, see wasm.CompilationCache.Hold
+	wasi            bool
+	profiler        *wasm.Profiler
 }
 
 func init() {
-	wasm.RegisterModuleFactory("wazero", wasm.ModuleFactoryFunc(newModule))
+	wasm.RegisterModuleFactory("wazero", wasm.BinaryTypeModuleFactoryFunc(newModule))
 }
 
-func newModule(ctx context.Context, wasmCode []byte, registry *wasm.Registry) (wasm.Module, error) {
+func newModule(ctx context.Context, wasmCode []byte, binaryType string, registry *wasm.Registry) (wasm.Module, error) {
 	// What's the effect of `ctx` here? Will it kill all the WASM if it cancels?
-	// TODO: try with: wazero.NewRuntimeConfigCompiler()
-	// TODO: try config := wazero.NewRuntimeConfig().WithCompilationCache(cache)
 	runtimeConfig := wazero.NewRuntimeConfigCompiler()
-	// TODO: can we use some caching in the RuntimeConfig so perhaps we reuse
-	// things across runtimes creations?
+	if registry.ExecutionTimeout() != 0 {
+		runtimeConfig = runtimeConfig.WithCloseOnContextDone(true)
+	}
+
+	// Function listeners are bound when compiling, so a profiled module cannot
+	// be shared with other requests through the compilation cache.
+	compilationCache := registry.CompilationCache()
+	profiler := registry.Profiler()
+	sharedCompiled := compilationCache != nil && profiler == nil
+	var wazCache *sharedCache
+	if sharedCompiled {
+		shared, err := compilationCache.Shared("wazero", func() (any, error) {
+			return newSharedCache(compilationCache.Dir())
+		})
+		if err != nil {
+			return nil, fmt.Errorf("creating wazero compilation cache: %w", err)
+		}
+		wazCache = shared.(*sharedCache)
+		runtimeConfig = runtimeConfig.WithCompilationCache(wazCache.CompilationCache)
+	}
+
 	runtime := wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
 	hostModules, err := addExtensionFunctions(ctx, runtime, registry)
 	if err != nil {
@@ -51,15 +78,57 @@ func newModule(ctx context.Context, wasmCode []byte, registry *wasm.Registry) (w
 	if err != nil {
 		return nil, err
 	}
-	hostModules = append(hostModules, envModule, stateModule, loggerModule)
+	cryptoModule, err := addHostFunctions(ctx, runtime, wasm.CryptoNamespace, cryptoFuncs)
+	if err != nil {
+		return nil, err
+	}
+	hostModules = append(hostModules, envModule, stateModule, loggerModule, cryptoModule)
+
+	wasi := binaryType == wasm.BinaryTypeWASIV1
+	if wasi {
+		wasiModule, err := addHostFunctions(ctx, runtime, wasm.WASINamespace, wasiFuncs)
+		if err != nil {
+			return nil, err
+		}
+		hostModules = append(hostModules, wasiModule)
+	}
+
+	maxFuel := registry.MaxFuel()
+	wasmCode, err = prepareCode(wasmCode, instrument.Config{
+		Fuel:           maxFuel != 0,
+		MaxMemoryPages: registry.MaxMemoryPages(),
+	}, compilationCache)
+	if err != nil {
+		return nil, err
+	}
+
+	compileCtx := ctx
+	if profiler != nil {
+		compileCtx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, &profilingListenerFactory{profiler: profiler})
+	}
+
+	var compiledKey string
+	if sharedCompiled {
+		compiledKey = wazCache.key(wasmCode, registry.ExecutionTimeout() != 0)
+		wasm.CountCompilation(compilationCache.Contains(compiledKey))
+	}
 
 	// TODO: where to `Close()` the `runtime` here?
 	// One runtime per request?
-	mod, err := runtime.CompileModule(ctx, wasmCode)
+	mod, err := runtime.CompileModule(compileCtx, wasmCode)
 	if err != nil {
 		return nil, fmt.Errorf("creating new module: %w", err)
 	}
 
+	var release func()
+	if sharedCompiled {
+		release = compilationCache.Hold(compiledKey, wazCache.size(compiledKey, wasmCode), func() {
+			// Only drops the module from the cache, the instances keep
+			// their compiled code.
+			mod.Close(context.Background())
+		})
+	}
+
 	funcs := mod.ExportedFunctions()
 	if funcs["alloc"] == nil {
 		return nil, fmt.Errorf("missing required functions: alloc")
@@ -68,27 +137,72 @@ func newModule(ctx context.Context, wasmCode []byte, registry *wasm.Registry) (w
 		return nil, fmt.Errorf("missing required functions: dealloc")
 	}
 
+	moduleConfig := wazero.NewModuleConfig()
+	if wasi {
+		// `_initialize` is called by instantiateModule, once fuel is set
+		moduleConfig = moduleConfig.WithStartFunctions()
+	}
+
 	return &Module{
-		wazModuleConfig: wazero.NewModuleConfig(),
+		wazModuleConfig: moduleConfig,
 		wazRuntime:      runtime,
 		userModule:      mod,
 		hostModules:     hostModules,
+		maxFuel:         maxFuel,
+		maxMemory:       registry.MaxMemory(),
+		timeout:         registry.ExecutionTimeout(),
+		sharedCompiled:  sharedCompiled,
+		release:         release,
+		wasi:            wasi,
+		profiler:        profiler,
 	}, nil
 }
 
+// prepareCode returns the code to compile, instrumented for fuel metering
+// and memory limits. With a compilation cache, the code is keyed by its
+// original content so instrumentation is skipped for known binaries, and
+// wazero's own cache then skips the compilation.
+func prepareCode(wasmCode []byte, config instrument.Config, cache *wasm.CompilationCache) ([]byte, error) {
+	var key string
+	if cache != nil {
+		key = cache.Key("wazero", wasmCode, fmt.Sprintf("fuel=%t", config.Fuel), fmt.Sprintf("memory=%d", config.MaxMemoryPages))
+		if code, found := cache.Get(key); found {
+			return code, nil
+		}
+	}
+
+	wasmCode, err := instrument.Instrument(wasmCode, config)
+	if err != nil {
+		return nil, fmt.Errorf("instrumenting module: %w", err)
+	}
+
+	if cache != nil {
+		cache.Put(key, wasmCode)
+	}
+	return wasmCode, nil
+}
+
 func (m *Module) Close(ctx context.Context) error {
 	closeFuncs := []func(context.Context) error{
 		m.wazRuntime.Close,
-		m.userModule.Close,
 	}
-	for _, hostMod := range m.hostModules {
-		closeFuncs = append(closeFuncs, hostMod.Close)
+	// Closing a compiled module evicts it from the compilation cache, which
+	// other requests may be using concurrently: the cache evicts it once
+	// released by all of them.
+	if !m.sharedCompiled {
+		closeFuncs = append(closeFuncs, m.userModule.Close)
+		for _, hostMod := range m.hostModules {
+			closeFuncs = append(closeFuncs, hostMod.Close)
+		}
 	}
 	for _, f := range closeFuncs {
 		if err := f(ctx); err != nil {
 			return err
 		}
 	}
+	if m.release != nil {
+		m.release()
+	}
 	return nil
 }
 
@@ -98,7 +212,7 @@ func (m *Module) NewInstance(ctx context.Context) (out wasm.Instance, err error)
 		return nil, fmt.Errorf("could not instantiate wasm module: %w", err)
 	}
 
-	return &instance{Module: mod}, nil
+	return &instance{Module: mod, maxFuel: m.maxFuel}, nil
 }
 
 func (m *Module) ExecuteNewCall(ctx context.Context, call *wasm.Call, cachedInstance wasm.Instance, arguments []wasm.Argument) (out wasm.Instance, err error) {
@@ -111,7 +225,18 @@ func (m *Module) ExecuteNewCall(ctx context.Context, call *wasm.Call, cachedInst
 			return nil, fmt.Errorf("could not instantiate wasm module: %w", err)
 		}
 	}
-	inst := &instance{Module: mod}
+	inst := &instance{Module: mod, maxFuel: m.maxFuel}
+	inst.refuel()
+	if m.maxMemory != 0 {
+		resetMemoryGrow(mod)
+	}
+
+	callCtx := ctx
+	if m.timeout != 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
 
 	f := mod.ExportedFunction(call.Entrypoint)
 	if f == nil {
@@ -128,8 +253,11 @@ func (m *Module) ExecuteNewCall(ctx context.Context, call *wasm.Call, cachedInst
 			args = append(args, uint64(inputStoreCount-1))
 		case wasm.ValueArgument:
 			cnt := v.Value()
-			ptr, err := writeToHeap(ctx, inst, true, cnt)
+			ptr, err := writeToHeap(callCtx, inst, true, cnt)
 			if err != nil {
+				if limitErr := m.limitError(ctx, callCtx, call, mod); limitErr != nil {
+					return nil, limitErr
+				}
 				return nil, fmt.Errorf("writing %s to heap: %w", input.Name(), err)
 			}
 			length := uint64(len(cnt))
@@ -139,14 +267,36 @@ func (m *Module) ExecuteNewCall(ctx context.Context, call *wasm.Call, cachedInst
 		}
 	}
 
-	_, err = f.Call(wasm.WithContext(withInstanceContext(ctx, inst), call), args...)
+	_, err = f.Call(wasm.WithContext(withInstanceContext(callCtx, inst), call), args...)
+	call.FlushWASIOutput()
+	if m.profiler != nil {
+		m.profiler.Flush(call)
+	}
 	if err != nil {
+		if limitErr := m.limitError(ctx, callCtx, call, mod); limitErr != nil {
+			return inst, limitErr
+		}
 		return inst, fmt.Errorf("call: %w", err)
 	}
 
 	return inst, nil
 }
 
+// limitError tells if a failed execution was stopped by one of the limits
+// enforced on the module, returning nil otherwise.
+func (m *Module) limitError(ctx, callCtx context.Context, call *wasm.Call, mod api.Module) error {
+	if m.maxFuel != 0 && isFuelExhausted(mod) {
+		return wasm.NewFuelExhaustedError(call, m.maxFuel)
+	}
+	if m.maxMemory != 0 && isMemoryGrowFailed(mod) {
+		return wasm.NewMemoryLimitExceededError(call, m.maxMemory)
+	}
+	if m.timeout != 0 && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		return wasm.NewExecutionTimeoutError(call, m.timeout)
+	}
+	return nil
+}
+
 func (m *Module) instantiateModule(ctx context.Context) (api.Module, error) {
 	m.Lock()
 	defer m.Unlock()
@@ -161,7 +311,20 @@ func (m *Module) instantiateModule(ctx context.Context) (api.Module, error) {
 		}
 	}
 	mod, err := m.wazRuntime.InstantiateModule(ctx, m.userModule, m.wazModuleConfig.WithName(""))
-	return mod, err
+	if err != nil || !m.wasi {
+		return mod, err
+	}
+
+	if initialize := mod.ExportedFunction("_initialize"); initialize != nil {
+		if m.maxFuel != 0 {
+			setFuel(mod, m.maxFuel)
+		}
+		if _, err := initialize.Call(ctx); err != nil {
+			mod.Close(ctx)
+			return nil, fmt.Errorf("initializing wasi module: %w", err)
+		}
+	}
+	return mod, nil
 }
 
 func addExtensionFunctions(ctx context.Context, runtime wazero.Runtime, registry *wasm.Registry) (out []wazero.CompiledModule, err error) {
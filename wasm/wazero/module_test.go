@@ -0,0 +1,419 @@
+package wazero
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/streamingfast/substreams/metrics"
+	pbsubstreams "github.com/streamingfast/substreams/pb/sf/substreams/v1"
+	"github.com/streamingfast/substreams/wasm"
+)
+
+func TestNewModule_CompilationCache(t *testing.T) {
+	ctx := context.Background()
+
+	code, err := os.ReadFile("../bench/substreams_wasm/substreams.wasm")
+	require.NoError(t, err)
+
+	cache, err := wasm.NewCompilationCache("")
+	require.NoError(t, err)
+	registry := wasm.NewRegistryWithRuntime("wazero", nil, 1000, wasm.WithCompilationCache(cache))
+
+	first, err := registry.NewModule(ctx, code)
+	require.NoError(t, err)
+
+	second, err := registry.NewModule(ctx, code)
+	require.NoError(t, err)
+
+	// Closing a module must not evict the compiled code used by the others.
+	require.NoError(t, first.Close(ctx))
+	inst, err := second.NewInstance(ctx)
+	require.NoError(t, err)
+	require.NoError(t, inst.Close(ctx))
+	require.NoError(t, second.Close(ctx))
+
+	_, found := cache.Get(cache.Key("wazero", code, "fuel=true", "memory=0"))
+	require.True(t, found)
+}
+
+func TestNewModule_CompilationCacheEviction(t *testing.T) {
+	ctx := context.Background()
+
+	code, err := os.ReadFile("../bench/substreams_wasm/substreams.wasm")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	compiledFiles := func() []string {
+		files, err := filepath.Glob(filepath.Join(dir, "wazero-*", "*"))
+		require.NoError(t, err)
+		return files
+	}
+
+	cache, err := wasm.NewCompilationCache(dir)
+	require.NoError(t, err)
+	module, err := wasm.NewRegistryWithRuntime("wazero", nil, 1000, wasm.WithCompilationCache(cache)).NewModule(ctx, code)
+	require.NoError(t, err)
+	require.NoError(t, module.Close(ctx))
+	files := compiledFiles()
+	require.Len(t, files, 1)
+	rel, err := filepath.Rel(dir, files[0])
+	require.NoError(t, err)
+	assert.True(t, cache.Contains(rel), "the compiled module should be tracked under its file")
+
+	cache, err = wasm.NewCompilationCache(dir, wasm.WithCompilationCacheMaxBytes(1024))
+	require.NoError(t, err)
+	require.Empty(t, compiledFiles(), "the compiled modules on disk past the size limit should be evicted on startup")
+	registry := wasm.NewRegistryWithRuntime("wazero", nil, 1000, wasm.WithCompilationCache(cache))
+
+	module, err = registry.NewModule(ctx, code)
+	require.NoError(t, err)
+	require.Empty(t, compiledFiles(), "the compiled module over the size limit should be evicted from disk")
+
+	// The evicted module is still usable until released.
+	inst, err := module.NewInstance(ctx)
+	require.NoError(t, err)
+	require.NoError(t, inst.Close(ctx))
+	require.NoError(t, module.Close(ctx))
+
+	module, err = registry.NewModule(ctx, code)
+	require.NoError(t, err)
+	inst, err = module.NewInstance(ctx)
+	require.NoError(t, err)
+	require.NoError(t, inst.Close(ctx))
+	require.NoError(t, module.Close(ctx))
+}
+
+// (module
+//
+//	(memory (export "memory") 1)
+//	(func (export "alloc") (param i32) (result i32) (i32.const 0))
+//	(func (export "dealloc") (param i32 i32))
+//	(func (export "grow") (if (i32.eq (memory.grow (i32.const 100)) (i32.const -1)) (then unreachable)))
+//	(func (export "spin") (loop (br 0))))
+var limitsTestModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x0e, 0x03, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x00, 0x60, 0x00, 0x00,
+	0x03, 0x05, 0x04, 0x00, 0x01, 0x02, 0x02,
+	0x05, 0x03, 0x01, 0x00, 0x01,
+	0x07, 0x2a, 0x05,
+	0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00,
+	0x05, 'a', 'l', 'l', 'o', 'c', 0x00, 0x00,
+	0x07, 'd', 'e', 'a', 'l', 'l', 'o', 'c', 0x00, 0x01,
+	0x04, 'g', 'r', 'o', 'w', 0x00, 0x02,
+	0x04, 's', 'p', 'i', 'n', 0x00, 0x03,
+	0x0a, 0x20, 0x04,
+	0x04, 0x00, 0x41, 0x00, 0x0b,
+	0x02, 0x00, 0x0b,
+	0x0e, 0x00, 0x41, 0xe4, 0x00, 0x40, 0x00, 0x41, 0x7f, 0x46, 0x04, 0x40, 0x00, 0x0b, 0x0b,
+	0x07, 0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b,
+}
+
+func TestModule_Limits(t *testing.T) {
+	tests := []struct {
+		name        string
+		entrypoint  string
+		opts        []wasm.RegistryOption
+		expectError string
+	}{
+		{
+			name:       "memory within limit",
+			entrypoint: "grow",
+			opts:       []wasm.RegistryOption{wasm.WithMaxMemory(200 * 65536)},
+		},
+		{
+			name:        "memory limit exceeded",
+			entrypoint:  "grow",
+			opts:        []wasm.RegistryOption{wasm.WithMaxMemory(50 * 65536)},
+			expectError: `module "test": memory limit exceeded at block 42, limit is 3276800 bytes`,
+		},
+		{
+			name:        "execution timeout",
+			entrypoint:  "spin",
+			opts:        []wasm.RegistryOption{wasm.WithExecutionTimeout(50 * time.Millisecond)},
+			expectError: `module "test": execution time limit exceeded at block 42, limit is 50ms per block`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			registry := wasm.NewRegistryWithRuntime("wazero", nil, 0, test.opts...)
+
+			module, err := registry.NewModule(ctx, limitsTestModule)
+			require.NoError(t, err)
+			defer module.Close(ctx)
+
+			call := wasm.NewCall(&pbsubstreams.Clock{Number: 42}, "test", test.entrypoint, nil, nil)
+			_, err = module.ExecuteNewCall(ctx, call, nil, nil)
+			if test.expectError == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			var wasmErr *wasm.Error
+			require.ErrorAs(t, err, &wasmErr)
+			assert.Equal(t, test.expectError, wasmErr.Error())
+		})
+	}
+}
+
+// (module
+//
+//	(import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+//	(import "wasi_snapshot_preview1" "clock_time_get" (func $clock_time_get (param i32 i64 i32) (result i32)))
+//	(import "wasi_snapshot_preview1" "random_get" (func $random_get (param i32 i32) (result i32)))
+//	(import "env" "output" (func $output (param i32 i32)))
+//	(memory (export "memory") 1)
+//	(global $initialized (mut i32) (i32.const 0))
+//	(func (export "_initialize") (global.set $initialized (i32.const 1)))
+//	(func (export "alloc") (param i32) (result i32) (i32.const 1024))
+//	(func (export "dealloc") (param i32 i32))
+//	(func (export "run")
+//	  (i64.store (i32.const 100) (i64.const 0x6572656874_0a6968)) ;; "hi\nthere"
+//	  (i32.store (i32.const 16) (i32.const 100))
+//	  (i32.store (i32.const 20) (i32.const 8))
+//	  (drop (call $fd_write (i32.const 1) (i32.const 16) (i32.const 1) (i32.const 32)))
+//	  (drop (call $clock_time_get (i32.const 0) (i64.const 0) (i32.const 40)))
+//	  (drop (call $random_get (i32.const 48) (i32.const 8)))
+//	  (i32.store (i32.const 56) (global.get $initialized))
+//	  (call $output (i32.const 40) (i32.const 20))))
+var wasiTestModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x23, 0x06, 0x60, 0x04, 0x7f, 0x7f, 0x7f,
+	0x7f, 0x01, 0x7f, 0x60, 0x03, 0x7f, 0x7e, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f,
+	0x60, 0x02, 0x7f, 0x7f, 0x00, 0x60, 0x00, 0x00, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x02, 0x7c, 0x04,
+	0x16, 0x77, 0x61, 0x73, 0x69, 0x5f, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x5f, 0x70,
+	0x72, 0x65, 0x76, 0x69, 0x65, 0x77, 0x31, 0x08, 0x66, 0x64, 0x5f, 0x77, 0x72, 0x69, 0x74, 0x65,
+	0x00, 0x00, 0x16, 0x77, 0x61, 0x73, 0x69, 0x5f, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74,
+	0x5f, 0x70, 0x72, 0x65, 0x76, 0x69, 0x65, 0x77, 0x31, 0x0e, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x5f,
+	0x74, 0x69, 0x6d, 0x65, 0x5f, 0x67, 0x65, 0x74, 0x00, 0x01, 0x16, 0x77, 0x61, 0x73, 0x69, 0x5f,
+	0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x5f, 0x70, 0x72, 0x65, 0x76, 0x69, 0x65, 0x77,
+	0x31, 0x0a, 0x72, 0x61, 0x6e, 0x64, 0x6f, 0x6d, 0x5f, 0x67, 0x65, 0x74, 0x00, 0x02, 0x03, 0x65,
+	0x6e, 0x76, 0x06, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x00, 0x03, 0x03, 0x05, 0x04, 0x04, 0x05,
+	0x03, 0x04, 0x05, 0x03, 0x01, 0x00, 0x01, 0x06, 0x06, 0x01, 0x7f, 0x01, 0x41, 0x00, 0x0b, 0x07,
+	0x30, 0x05, 0x06, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x02, 0x00, 0x0b, 0x5f, 0x69, 0x6e, 0x69,
+	0x74, 0x69, 0x61, 0x6c, 0x69, 0x7a, 0x65, 0x00, 0x04, 0x05, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x00,
+	0x05, 0x07, 0x64, 0x65, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x00, 0x06, 0x03, 0x72, 0x75, 0x6e, 0x00,
+	0x07, 0x0a, 0x5c, 0x04, 0x06, 0x00, 0x41, 0x01, 0x24, 0x00, 0x0b, 0x05, 0x00, 0x41, 0x80, 0x08,
+	0x0b, 0x02, 0x00, 0x0b, 0x4a, 0x00, 0x41, 0xe4, 0x00, 0x42, 0xe8, 0xd2, 0xa9, 0xa0, 0x87, 0xad,
+	0x99, 0xb9, 0xe5, 0x00, 0x37, 0x03, 0x00, 0x41, 0x10, 0x41, 0xe4, 0x00, 0x36, 0x02, 0x00, 0x41,
+	0x14, 0x41, 0x08, 0x36, 0x02, 0x00, 0x41, 0x01, 0x41, 0x10, 0x41, 0x01, 0x41, 0x20, 0x10, 0x00,
+	0x1a, 0x41, 0x00, 0x42, 0x00, 0x41, 0x28, 0x10, 0x01, 0x1a, 0x41, 0x30, 0x41, 0x08, 0x10, 0x02,
+	0x1a, 0x41, 0x38, 0x23, 0x00, 0x36, 0x02, 0x00, 0x41, 0x28, 0x41, 0x14, 0x10, 0x03, 0x0b,
+}
+
+func TestModule_WASI(t *testing.T) {
+	ctx := context.Background()
+	registry := wasm.NewRegistryWithRuntime("wazero", nil, 1000)
+
+	module, err := registry.NewModuleWithBinaryType(ctx, wasiTestModule, wasm.BinaryTypeWASIV1)
+	require.NoError(t, err)
+	defer module.Close(ctx)
+
+	run := func(clock *pbsubstreams.Clock) *wasm.Call {
+		call := wasm.NewCall(clock, "test", "run", nil, nil)
+		_, err := module.ExecuteNewCall(ctx, call, nil, nil)
+		require.NoError(t, err)
+		return call
+	}
+
+	timestamp := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	call := run(&pbsubstreams.Clock{Id: "aa", Number: 42, Timestamp: timestamppb.New(timestamp)})
+	assert.Equal(t, []string{"hi", "there"}, call.Logs)
+
+	output := call.Output()
+	require.Len(t, output, 20)
+	assert.Equal(t, uint64(timestamp.UnixNano()), binary.LittleEndian.Uint64(output[0:8]), "clock is the block timestamp")
+	assert.Equal(t, uint32(1), binary.LittleEndian.Uint32(output[16:20]), "_initialize was called")
+
+	sameBlock := run(&pbsubstreams.Clock{Id: "aa", Number: 42, Timestamp: timestamppb.New(timestamp)})
+	assert.Equal(t, output[8:16], sameBlock.Output()[8:16], "random is deterministic")
+
+	otherBlock := run(&pbsubstreams.Clock{Id: "bb", Number: 43, Timestamp: timestamppb.New(timestamp)})
+	assert.NotEqual(t, output[8:16], otherBlock.Output()[8:16], "random is seeded by the block")
+
+	rustModule, err := wasm.NewRegistryWithRuntime("wazero", nil, 0).NewModule(ctx, wasiTestModule)
+	require.NoError(t, err)
+	defer rustModule.Close(ctx)
+	_, err = rustModule.NewInstance(ctx)
+	require.Error(t, err, "wasi imports are only provided to wasi modules")
+}
+
+// (module
+//
+//	(memory (export "memory") 1)
+//	(func $alloc (export "alloc") (param i32) (result i32) (i32.const 0))
+//	(func $dealloc (export "dealloc") (param i32 i32))
+//	(func $inner (loop $l (br_if $l (i32.eqz (i32.const 1)))))
+//	(func $outer (call $inner) (call $inner))
+//	(func $run (export "run") (call $outer) (call $inner)))
+var profilingTestModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x0e, 0x03, 0x60, 0x01, 0x7f, 0x01, 0x7f,
+	0x60, 0x02, 0x7f, 0x7f, 0x00, 0x60, 0x00, 0x00, 0x03, 0x06, 0x05, 0x00, 0x01, 0x02, 0x02, 0x02,
+	0x05, 0x03, 0x01, 0x00, 0x01, 0x07, 0x22, 0x04, 0x06, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x02,
+	0x00, 0x05, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x00, 0x00, 0x07, 0x64, 0x65, 0x61, 0x6c, 0x6c, 0x6f,
+	0x63, 0x00, 0x01, 0x03, 0x72, 0x75, 0x6e, 0x00, 0x04, 0x0a, 0x22, 0x05, 0x04, 0x00, 0x41, 0x00,
+	0x0b, 0x02, 0x00, 0x0b, 0x0a, 0x00, 0x03, 0x40, 0x41, 0x01, 0x45, 0x0d, 0x00, 0x0b, 0x0b, 0x06,
+	0x00, 0x10, 0x02, 0x10, 0x02, 0x0b, 0x06, 0x00, 0x10, 0x03, 0x10, 0x02, 0x0b, 0x00, 0x33, 0x04,
+	0x6e, 0x61, 0x6d, 0x65, 0x01, 0x24, 0x05, 0x00, 0x05, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x01, 0x07,
+	0x64, 0x65, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x02, 0x05, 0x69, 0x6e, 0x6e, 0x65, 0x72, 0x03, 0x05,
+	0x6f, 0x75, 0x74, 0x65, 0x72, 0x04, 0x03, 0x72, 0x75, 0x6e, 0x03, 0x06, 0x01, 0x02, 0x01, 0x00,
+	0x01, 0x6c,
+}
+
+func TestModule_Profiling(t *testing.T) {
+	ctx := context.Background()
+	profiler := wasm.NewProfiler([]string{"test"})
+	registry := wasm.NewRegistryWithRuntime("wazero", nil, 1000, wasm.WithProfiler(profiler))
+
+	module, err := registry.NewModule(ctx, profilingTestModule)
+	require.NoError(t, err)
+	defer module.Close(ctx)
+
+	for _, moduleName := range []string{"test", "test", "other"} {
+		call := wasm.NewCall(&pbsubstreams.Clock{Number: 42}, moduleName, "run", nil, nil)
+		_, err = module.ExecuteNewCall(ctx, call, nil, nil)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"test"}, profiler.Modules())
+	assert.Nil(t, profiler.Profile("other"))
+	assert.Equal(t, map[string]int64{
+		"run":             2,
+		"run;outer":       2,
+		"run;outer;inner": 4,
+		"run;inner":       2,
+	}, decodeProfileCalls(t, profiler.Profile("test")))
+}
+
+// decodeProfileCalls returns the `calls` value of the samples of a gzipped
+// pprof profile, by stack (root first, separated by `;`).
+func decodeProfileCalls(t *testing.T, profile []byte) map[string]int64 {
+	gz, err := gzip.NewReader(bytes.NewReader(profile))
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+
+	var stringTable []string
+	var samples [][]byte
+	functionNames := map[uint64]uint64{} // function ID (also the location ID) to name index
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		require.True(t, n > 0)
+		data = data[n:]
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		require.True(t, n > 0)
+		value := data[:n]
+		data = data[n:]
+
+		switch num {
+		case 2: // sample
+			msg, _ := protowire.ConsumeBytes(value)
+			samples = append(samples, msg)
+		case 5: // function
+			msg, _ := protowire.ConsumeBytes(value)
+			fields := decodeVarintFields(t, msg)
+			functionNames[fields[1]] = fields[2]
+		case 6: // string_table
+			s, _ := protowire.ConsumeString(value)
+			stringTable = append(stringTable, s)
+		}
+	}
+
+	out := map[string]int64{}
+	for _, sample := range samples {
+		var stack []string
+		var values []uint64
+		for len(sample) > 0 {
+			num, _, n := protowire.ConsumeTag(sample)
+			sample = sample[n:]
+			packed, n := protowire.ConsumeBytes(sample)
+			sample = sample[n:]
+			for len(packed) > 0 {
+				v, n := protowire.ConsumeVarint(packed)
+				packed = packed[n:]
+				if num == 1 {
+					stack = append([]string{stringTable[functionNames[v]]}, stack...)
+				} else {
+					values = append(values, v)
+				}
+			}
+		}
+		require.Len(t, values, 2)
+		out[strings.Join(stack, ";")] = int64(values[0])
+	}
+	return out
+}
+
+func decodeVarintFields(t *testing.T, msg []byte) map[protowire.Number]uint64 {
+	out := map[protowire.Number]uint64{}
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		require.Equal(t, protowire.VarintType, typ)
+		msg = msg[n:]
+		v, n := protowire.ConsumeVarint(msg)
+		msg = msg[n:]
+		out[num] = v
+	}
+	return out
+}
+
+// (module
+//
+//	(import "crypto" "keccak256" (func $keccak256 (param i32 i32 i32)))
+//	(import "env" "output" (func $output (param i32 i32)))
+//	(memory (export "memory") 1)
+//	(data (i32.const 0) "abc")
+//	(func (export "alloc") (param i32) (result i32) (i32.const 1024))
+//	(func (export "dealloc") (param i32 i32))
+//	(func (export "run")
+//	  (call $keccak256 (i32.const 0) (i32.const 3) (i32.const 64))
+//	  (call $output (i32.const 64) (i32.const 32))))
+var cryptoTestModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x14, 0x04, 0x60, 0x03, 0x7f, 0x7f, 0x7f,
+	0x00, 0x60, 0x02, 0x7f, 0x7f, 0x00, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x00, 0x00, 0x02, 0x21,
+	0x02, 0x06, 0x63, 0x72, 0x79, 0x70, 0x74, 0x6f, 0x09, 0x6b, 0x65, 0x63, 0x63, 0x61, 0x6b, 0x32,
+	0x35, 0x36, 0x00, 0x00, 0x03, 0x65, 0x6e, 0x76, 0x06, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x00,
+	0x01, 0x03, 0x04, 0x03, 0x02, 0x01, 0x03, 0x05, 0x03, 0x01, 0x00, 0x01, 0x07, 0x22, 0x04, 0x06,
+	0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x02, 0x00, 0x05, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x00, 0x02,
+	0x07, 0x64, 0x65, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x00, 0x03, 0x03, 0x72, 0x75, 0x6e, 0x00, 0x04,
+	0x0a, 0x1d, 0x03, 0x05, 0x00, 0x41, 0x80, 0x08, 0x0b, 0x02, 0x00, 0x0b, 0x12, 0x00, 0x41, 0x00,
+	0x41, 0x03, 0x41, 0xc0, 0x00, 0x10, 0x00, 0x41, 0xc0, 0x00, 0x41, 0x20, 0x10, 0x01, 0x0b, 0x0b,
+	0x09, 0x01, 0x00, 0x41, 0x00, 0x0b, 0x03, 0x61, 0x62, 0x63,
+}
+
+func TestModule_Crypto(t *testing.T) {
+	ctx := context.Background()
+	registry := wasm.NewRegistryWithRuntime("wazero", nil, 1000)
+
+	module, err := registry.NewModule(ctx, cryptoTestModule)
+	require.NoError(t, err)
+	defer module.Close(ctx)
+
+	stats := metrics.NewReqStats(&metrics.Config{}, zap.NewNop())
+	call := wasm.NewCall(&pbsubstreams.Clock{Number: 42}, "test", "run", stats, nil)
+	_, err = module.ExecuteNewCall(ctx, call, nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45", hex.EncodeToString(call.Output()))
+
+	moduleStats := stats.LocalModulesStats()
+	require.Len(t, moduleStats, 1)
+	require.Len(t, moduleStats[0].ExternalCallMetrics, 1)
+	assert.Equal(t, "crypto:keccak256", moduleStats[0].ExternalCallMetrics[0].Name)
+	assert.Equal(t, uint64(1), moduleStats[0].ExternalCallMetrics[0].Count)
+}
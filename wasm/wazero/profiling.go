@@ -0,0 +1,48 @@
+package wazero
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+
+	"github.com/streamingfast/substreams/wasm"
+)
+
+// profilingListenerFactory reports the guest functions entered and exited
+// during calls to the profiler. Listeners are bound to the functions when the
+// module is compiled.
+type profilingListenerFactory struct {
+	profiler *wasm.Profiler
+}
+
+func (f *profilingListenerFactory) NewListener(def api.FunctionDefinition) experimental.FunctionListener {
+	name := def.Name()
+	if name == "" {
+		if exports := def.ExportNames(); len(exports) != 0 {
+			name = exports[0]
+		} else {
+			name = def.DebugName()
+		}
+	}
+	return &profilingListener{profiler: f.profiler, function: name}
+}
+
+type profilingListener struct {
+	profiler *wasm.Profiler
+	function string
+}
+
+func (l *profilingListener) Before(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) context.Context {
+	// Functions called outside of an entrypoint, like `alloc`, have no call
+	if call := wasm.FromContext(ctx); call != nil {
+		l.profiler.Enter(call, l.function)
+	}
+	return ctx
+}
+
+func (l *profilingListener) After(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ error, _ []uint64) {
+	if call := wasm.FromContext(ctx); call != nil {
+		l.profiler.Exit(call)
+	}
+}
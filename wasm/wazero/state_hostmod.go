@@ -75,6 +75,33 @@ var stateFuncs = []funcs{
 			call.DoDeletePrefix(ord, prefix)
 		}),
 	},
+	{
+		"delete_range",
+		[]parm{i64, i32, i32, i32, i32},
+		[]parm{},
+		api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			ord := stack[0]
+			lowKey := readStringFromStack(mod, stack[1:])
+			highKey := readStringFromStack(mod, stack[3:])
+			call := wasm.FromContext(ctx)
+
+			call.DoDeleteRange(ord, lowKey, highKey)
+		}),
+	},
+	{
+		"delete_range_pointers",
+		[]parm{i64, i32, i32, i32, i32, i32, i32},
+		[]parm{},
+		api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			ord := stack[0]
+			lowKey := readStringFromStack(mod, stack[1:])
+			highKey := readStringFromStack(mod, stack[3:])
+			pointerSeparator := readStringFromStack(mod, stack[5:])
+			call := wasm.FromContext(ctx)
+
+			call.DoDeleteRangePointers(ord, lowKey, highKey, pointerSeparator)
+		}),
+	},
 	{
 		"add_bigint",
 		[]parm{i64, i32, i32, i32, i32},
@@ -232,6 +259,58 @@ var stateFuncs = []funcs{
 			call.DoSetMaxBigDecimal(ord, key, value)
 		}),
 	},
+	{
+		"set_sum_int64",
+		[]parm{i64, i32, i32, i32, i32},
+		[]parm{},
+		api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			ord := stack[0]
+			key := readStringFromStack(mod, stack[1:])
+			value := readStringFromStack(mod, stack[3:])
+			call := wasm.FromContext(ctx)
+
+			call.DoSetSumInt64(ord, key, value)
+		}),
+	},
+	{
+		"set_sum_float64",
+		[]parm{i64, i32, i32, i32, i32},
+		[]parm{},
+		api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			ord := stack[0]
+			key := readStringFromStack(mod, stack[1:])
+			value := readStringFromStack(mod, stack[3:])
+			call := wasm.FromContext(ctx)
+
+			call.DoSetSumFloat64(ord, key, value)
+		}),
+	},
+	{
+		"set_sum_bigint",
+		[]parm{i64, i32, i32, i32, i32},
+		[]parm{},
+		api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			ord := stack[0]
+			key := readStringFromStack(mod, stack[1:])
+			value := readStringFromStack(mod, stack[3:])
+			call := wasm.FromContext(ctx)
+
+			call.DoSetSumBigInt(ord, key, value)
+		}),
+	},
+	{
+		"set_sum_bigdecimal",
+		[]parm{i64, i32, i32, i32, i32},
+		[]parm{},
+		api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			ord := stack[0]
+			key := readStringFromStack(mod, stack[1:])
+			value := readStringFromStack(mod, stack[3:])
+			call := wasm.FromContext(ctx)
+
+			call.DoSetSumBigDecimal(ord, key, value)
+		}),
+	},
 
 	// Getter functions
 
@@ -321,6 +400,23 @@ var stateFuncs = []funcs{
 			setStack0Bool(stack, found)
 		}),
 	},
+	{
+		"scan_prefix",
+		[]parm{i32, i32, i32, i32, i32, i32, i32},
+		[]parm{i32},
+		api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			storeIndex := uint32(stack[0])
+			prefix := readStringFromStack(mod, stack[1:])
+			cursor := readStringFromStack(mod, stack[3:])
+			limit := uint32(stack[5])
+			outputPtr := uint32(stack[6])
+			call := wasm.FromContext(ctx)
+			inst := instanceFromContext(ctx)
+
+			value, found := call.DoScanPrefix(int(storeIndex), prefix, cursor, limit)
+			setStackAndOutput(ctx, stack, call, found, inst, outputPtr, value)
+		}),
+	},
 }
 
 func setStackAndOutput(ctx context.Context, stack []uint64, call *wasm.Call, found bool, inst *instance, outputPtr uint32, value []byte) {
@@ -0,0 +1,78 @@
+package wazero
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero/api"
+
+	"github.com/streamingfast/substreams/wasm"
+)
+
+// wasiFuncs are the wasip1 imports of `wasm/wasi-v1` modules, see
+// wasm.WASINamespace for their behavior.
+var wasiFuncs = []funcs{
+	wasiErrnoFunc("args_get", []parm{i32, i32}, func(ctx context.Context, mod api.Module, stack []uint64) uint32 {
+		return wasm.WASINoop()
+	}),
+	wasiErrnoFunc("args_sizes_get", []parm{i32, i32}, func(ctx context.Context, mod api.Module, stack []uint64) uint32 {
+		return wasm.WASIArgsSizesGet(mod.Memory(), uint32(stack[0]), uint32(stack[1]))
+	}),
+	wasiErrnoFunc("environ_get", []parm{i32, i32}, func(ctx context.Context, mod api.Module, stack []uint64) uint32 {
+		return wasm.WASINoop()
+	}),
+	wasiErrnoFunc("environ_sizes_get", []parm{i32, i32}, func(ctx context.Context, mod api.Module, stack []uint64) uint32 {
+		return wasm.WASIEnvironSizesGet(mod.Memory(), uint32(stack[0]), uint32(stack[1]))
+	}),
+	wasiErrnoFunc("clock_res_get", []parm{i32, i32}, func(ctx context.Context, mod api.Module, stack []uint64) uint32 {
+		return wasm.WASIClockResGet(mod.Memory(), uint32(stack[0]), uint32(stack[1]))
+	}),
+	wasiErrnoFunc("clock_time_get", []parm{i32, i64, i32}, func(ctx context.Context, mod api.Module, stack []uint64) uint32 {
+		return wasm.WASIClockTimeGet(wasm.FromContext(ctx), mod.Memory(), uint32(stack[0]), uint32(stack[2]))
+	}),
+	wasiErrnoFunc("random_get", []parm{i32, i32}, func(ctx context.Context, mod api.Module, stack []uint64) uint32 {
+		return wasm.WASIRandomGet(wasm.FromContext(ctx), mod.Memory(), uint32(stack[0]), uint32(stack[1]))
+	}),
+	wasiErrnoFunc("fd_write", []parm{i32, i32, i32, i32}, func(ctx context.Context, mod api.Module, stack []uint64) uint32 {
+		return wasm.WASIFdWrite(wasm.FromContext(ctx), mod.Memory(), uint32(stack[0]), uint32(stack[1]), uint32(stack[2]), uint32(stack[3]))
+	}),
+	wasiErrnoFunc("fd_read", []parm{i32, i32, i32, i32}, func(ctx context.Context, mod api.Module, stack []uint64) uint32 {
+		return wasm.WASIFdRead(mod.Memory(), uint32(stack[0]), uint32(stack[1]), uint32(stack[2]), uint32(stack[3]))
+	}),
+	wasiErrnoFunc("fd_fdstat_get", []parm{i32, i32}, func(ctx context.Context, mod api.Module, stack []uint64) uint32 {
+		return wasm.WASIFdFdstatGet(mod.Memory(), uint32(stack[0]), uint32(stack[1]))
+	}),
+	wasiErrnoFunc("fd_close", []parm{i32}, wasiBadf),
+	wasiErrnoFunc("fd_seek", []parm{i32, i64, i32, i32}, wasiBadf),
+	wasiErrnoFunc("fd_prestat_get", []parm{i32, i32}, wasiBadf),
+	wasiErrnoFunc("fd_prestat_dir_name", []parm{i32, i32, i32}, wasiBadf),
+	wasiErrnoFunc("path_open", []parm{i32, i32, i32, i32, i32, i64, i64, i32, i32}, wasiBadf),
+	wasiErrnoFunc("poll_oneoff", []parm{i32, i32, i32, i32}, func(ctx context.Context, mod api.Module, stack []uint64) uint32 {
+		return wasm.WASINosys()
+	}),
+	wasiErrnoFunc("sched_yield", []parm{}, func(ctx context.Context, mod api.Module, stack []uint64) uint32 {
+		return wasm.WASINoop()
+	}),
+	{
+		"proc_exit",
+		[]parm{i32},
+		[]parm{},
+		api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			wasm.WASIProcExit(wasm.FromContext(ctx), uint32(stack[0]))
+		}),
+	},
+}
+
+func wasiBadf(ctx context.Context, mod api.Module, stack []uint64) uint32 {
+	return wasm.WASIFdBadf()
+}
+
+func wasiErrnoFunc(name string, input []parm, f func(ctx context.Context, mod api.Module, stack []uint64) uint32) funcs {
+	return funcs{
+		name,
+		input,
+		[]parm{i32},
+		api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			stack[0] = uint64(f(ctx, mod, stack))
+		}),
+	}
+}